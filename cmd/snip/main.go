@@ -6,8 +6,13 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/mmrzaf/snip/internal/app"
 	"github.com/mmrzaf/snip/internal/config"
@@ -26,7 +31,7 @@ func run() int {
 	var (
 		cfgPath      string
 		rootOverride string
-		verbose      bool
+		logs         logFlags
 	)
 
 	rootCmd := &cobra.Command{
@@ -54,10 +59,14 @@ snip explain internal/app/snip.go
 snip run api +tests
 snip ls api
 `),
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if logs.format != "text" && logs.format != "json" {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("unknown --log-format %q (want text or json)", logs.format))
+			}
 			if cmd.Name() != "init" {
 				cfgPath = config.FindConfigPath(cfgPath)
 			}
+			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Default behavior: run snapshot when no subcommand is specified.
@@ -65,35 +74,49 @@ snip ls api
 			if err != nil {
 				return app.Wrap(app.ExitUsage, err)
 			}
-			profile := cfg.DefaultProfile
+			profile := config.ResolveProfile(cfg, "")
 			mods := args
 			if len(args) > 0 && !isModifier(args[0]) {
 				profile = args[0]
 				mods = args[1:]
 			}
-			_, err = app.Run(ctx, app.RunOptions{
+			progress := newProgressLine(logs.verbose)
+			res, err := app.Run(ctx, app.RunOptions{
 				ConfigPath:   cfgPath,
 				RootOverride: rootOverride,
 				Profile:      profile,
 				Modifiers:    mods,
 				// Output empty => respects cfg.output.stdout_default and default file output.
-				Logger: loggerFn(verbose),
+				Progress: progress.progressFunc(),
+				Logger:   logs.logger(),
 			})
+			progress.clear()
+			printWarnings(res.Warnings)
 			return err
 		},
 	}
 
+	rootCmd.ValidArgsFunction = completeProfileArgs(&cfgPath, true)
+
 	rootCmd.PersistentFlags().StringVar(&cfgPath, "config", "", "Path to .snip.yaml (or set SNIP_CONFIG)")
 	rootCmd.PersistentFlags().StringVar(&rootOverride, "root", "", "Root directory override")
-	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Enable verbose output")
+	rootCmd.PersistentFlags().BoolVar(&logs.verbose, "verbose", false, "Enable verbose output")
+	rootCmd.PersistentFlags().StringVar(&logs.format, "log-format", "text", "Log format on stderr: text or json")
 
 	rootCmd.AddCommand(newInitCmd(&rootOverride))
-	rootCmd.AddCommand(newRunCmd(ctx, &cfgPath, &rootOverride, &verbose))
-	rootCmd.AddCommand(newLsCmd(ctx, &cfgPath, &rootOverride, &verbose))
-	rootCmd.AddCommand(newDoctorCmd(ctx, &cfgPath, &rootOverride, &verbose))
-	rootCmd.AddCommand(newExplainCmd(ctx, &cfgPath, &rootOverride, &verbose))
+	rootCmd.AddCommand(newRunCmd(ctx, &cfgPath, &rootOverride, &logs))
+	rootCmd.AddCommand(newLsCmd(ctx, &cfgPath, &rootOverride, &logs))
+	rootCmd.AddCommand(newDoctorCmd(ctx, &cfgPath, &rootOverride, &logs))
+	rootCmd.AddCommand(newExplainCmd(ctx, &cfgPath, &rootOverride, &logs))
+	rootCmd.AddCommand(newProfilesCmd(&cfgPath))
+	rootCmd.AddCommand(newConfigCmd(&cfgPath))
+	rootCmd.AddCommand(newSlicesCmd(ctx, &cfgPath, &rootOverride, &logs))
+	rootCmd.AddCommand(newStatCmd(ctx, &cfgPath, &rootOverride, &logs))
+	rootCmd.AddCommand(newDiffCmd())
 	rootCmd.AddCommand(newApplyCmd(&rootOverride))
 	rootCmd.AddCommand(newVersionCmd())
+	rootCmd.AddCommand(newSchemaCmd())
+	rootCmd.AddCommand(newCompletionCmd())
 	rootCmd.SetArgs(preprocessCLIArgs(os.Args[1:]))
 
 	if err := rootCmd.Execute(); err != nil {
@@ -112,12 +135,87 @@ const escapedModifierPrefix = "__snip_modifier__:"
 
 var reDashModifier = regexp.MustCompile(`^-[A-Za-z0-9][A-Za-z0-9_-]*$`)
 
-func loggerFn(verbose bool) *slog.Logger {
-	lvl := slog.LevelInfo
+// logFlags are the persistent --verbose and --log-format flags.
+type logFlags struct {
+	verbose bool
+	format  string // "text" or "json"
+}
+
+// logger returns the stderr logger the flags select: debug level with --verbose, and
+// slog's JSON handler with --log-format json.
+func (f *logFlags) logger() *slog.Logger {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if f.verbose {
+		opts.Level = slog.LevelDebug
+	}
+	if f.format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
+// progressLine shows run progress on stderr as one line rewritten in place ("reading…
+// 842/3,001"), cleared when the run ends. It only draws when stderr is a terminal and
+// --verbose is off, so piped and CI output never sees it, and it waits a moment before
+// drawing so quick runs stay silent. A nil *progressLine does nothing.
+type progressLine struct {
+	start time.Time
+	last  time.Time
+	shown bool
+}
+
+const (
+	progressDelay    = 300 * time.Millisecond
+	progressInterval = 100 * time.Millisecond
+)
+
+func newProgressLine(verbose bool) *progressLine {
 	if verbose {
-		lvl = slog.LevelDebug
+		return nil
+	}
+	if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
 	}
-	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl}))
+	return &progressLine{start: time.Now()}
+}
+
+// progressFunc returns the app.ProgressFunc that draws on p, or nil when p is nil.
+func (p *progressLine) progressFunc() app.ProgressFunc {
+	if p == nil {
+		return nil
+	}
+	return p.update
+}
+
+func (p *progressLine) update(phase string, done, total int) {
+	now := time.Now()
+	if now.Sub(p.start) < progressDelay || now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+	text := fmt.Sprintf("%s… %s files", phase, groupDigits(done))
+	if total > 0 {
+		text = fmt.Sprintf("%s… %s/%s", phase, groupDigits(done), groupDigits(total))
+	}
+	_, _ = fmt.Fprint(os.Stderr, "\r\033[K"+text)
+	p.shown = true
+}
+
+// clear erases the progress line, if one was drawn.
+func (p *progressLine) clear() {
+	if p != nil && p.shown {
+		_, _ = fmt.Fprint(os.Stderr, "\r\033[K")
+		p.shown = false
+	}
+}
+
+// groupDigits formats a count with comma thousands separators.
+func groupDigits(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
 }
 
 func isModifier(s string) bool {
@@ -125,6 +223,22 @@ func isModifier(s string) bool {
 }
 
 func preprocessCLIArgs(args []string) []string {
+	if len(args) >= 2 && (args[0] == "slices" || args[0] == "stat") {
+		// The profile is optional here, so every dash modifier is escaped.
+		out := make([]string, 0, len(args))
+		out = append(out, args[0])
+		for i, a := range args[1:] {
+			if a == "--" {
+				out = append(out, args[1+i:]...)
+				break
+			}
+			if a != "-h" && reDashModifier.MatchString(a) {
+				a = escapedModifierPrefix + a
+			}
+			out = append(out, a)
+		}
+		return out
+	}
 	if len(args) < 3 || args[0] != "run" {
 		return args
 	}
@@ -176,17 +290,32 @@ func escapeRunDashModifiers(args []string) []string {
 
 func isRunFlag(arg string) (needsValue bool, ok bool) {
 	switch arg {
-	case "-o", "--out", "--max-chars", "--format", "--tree-depth", "--config", "--root":
+	case "-o", "--out", "--out-dir", "--max-chars", "--max-tokens", "--per-file-max-lines", "--per-file-max-bytes", "--format", "--tree-depth", "--config", "--root",
+		"--since", "--exclude", "--include", "--only", "--keep-last", "--log-format", "--context-lines", "--append":
 		return true, true
-	case "--stdout", "--no-tree", "--no-manifest", "--include-hidden", "--quiet", "--verbose":
+	case "--stdout", "--no-tree", "--no-manifest", "--line-numbers", "--include-hidden", "--follow-symlinks", "--clipboard", "--gzip", "--quiet", "--watch", "--verbose",
+		"--gitignore", "--no-gitignore", "--all-profiles", "--profile-all", "--redact", "--no-lock", "--no-warn",
+		"--fail-on-partial", "--allow-partial", "--dry-run":
 		return false, true
 	}
 	if strings.HasPrefix(arg, "--out=") ||
+		strings.HasPrefix(arg, "--out-dir=") ||
 		strings.HasPrefix(arg, "--max-chars=") ||
+		strings.HasPrefix(arg, "--max-tokens=") ||
+		strings.HasPrefix(arg, "--per-file-max-lines=") ||
+		strings.HasPrefix(arg, "--per-file-max-bytes=") ||
 		strings.HasPrefix(arg, "--format=") ||
 		strings.HasPrefix(arg, "--tree-depth=") ||
 		strings.HasPrefix(arg, "--config=") ||
-		strings.HasPrefix(arg, "--root=") {
+		strings.HasPrefix(arg, "--root=") ||
+		strings.HasPrefix(arg, "--since=") ||
+		strings.HasPrefix(arg, "--exclude=") ||
+		strings.HasPrefix(arg, "--include=") ||
+		strings.HasPrefix(arg, "--only=") ||
+		strings.HasPrefix(arg, "--keep-last=") ||
+		strings.HasPrefix(arg, "--log-format=") ||
+		strings.HasPrefix(arg, "--context-lines=") ||
+		strings.HasPrefix(arg, "--append=") {
 		return false, true
 	}
 	if strings.HasPrefix(arg, "-o") && len(arg) > 2 {
@@ -242,96 +371,369 @@ func newInitCmd(rootOverride *string) *cobra.Command {
 	return cmd
 }
 
-func newRunCmd(ctx context.Context, cfgPath *string, rootOverride *string, verbose *bool) *cobra.Command {
+func newRunCmd(ctx context.Context, cfgPath *string, rootOverride *string, logs *logFlags) *cobra.Command {
 	var (
-		out           string
-		stdout        bool
-		maxChars      int
-		format        string
-		noTree        bool
-		noManifest    bool
-		treeDepth     int
-		includeHidden bool
-		quiet         bool
+		out            string
+		outDir         string
+		stdout         bool
+		maxChars       int
+		maxTokens      int
+		perFileLines   int
+		perFileBytes   int
+		contextLines   int
+		format         string
+		noTree         bool
+		noManifest     bool
+		treeDepth      int
+		lineNumbers    bool
+		includeHidden  bool
+		followSymlinks bool
+		clipboard      bool
+		gzipOut        bool
+		quiet          bool
+		watch          bool
+		since          string
+		excludes       []string
+		includes       []string
+		only           []string
+		allProfiles    bool
+		redact         bool
+		noLock         bool
+		keepLast       int
+		noWarn         bool
+		allowPartial   bool
+		failPartial    bool
+		dryRun         bool
+		appendTo       string
 	)
+	var gi gitignoreFlags
 	cmd := &cobra.Command{
 		Use:   "run <profile> [modifiers...]",
 		Short: "Generate a bundle for a profile",
-		Args:  cobra.MinimumNArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if allProfiles {
+				if len(args) > 0 {
+					return app.Wrap(app.ExitUsage, fmt.Errorf("--all-profiles cannot be combined with a profile or modifiers"))
+				}
+				return nil
+			}
+			if len(only) > 0 {
+				if len(args) > 0 {
+					return app.Wrap(app.ExitUsage, fmt.Errorf("--only cannot be combined with a profile or modifiers"))
+				}
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		ValidArgsFunction: completeProfileArgs(cfgPath, false),
 		Example: strings.TrimSpace(`
 snip run api
 snip run api +tests
 snip run debug --stdout
 snip run api -docs --max-chars 200000
+snip run api --clipboard
+snip run api --watch
+snip run api --out-dir /tmp/bundles
+snip run full --since main
+snip run api --exclude 'internal/gen/**' --include Makefile
+snip run --only 'internal/app/**' --only README.md
+snip run api --no-gitignore --include 'gen/**'
+snip run --all-profiles --out-dir snapshots
+snip run api --redact
+snip run api --dry-run
+snip run debug +tests --append context.md
 `),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			useGitignore, err := gi.override()
+			if err != nil {
+				return err
+			}
+			if allowPartial && cmd.Flags().Changed("fail-on-partial") && failPartial {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--allow-partial cannot be combined with --fail-on-partial"))
+			}
 			args = unescapeModifiers(args)
-			profile := args[0]
-			mods := args[1:]
+			var profile string
+			var mods []string
+			if len(args) > 0 {
+				profile, mods = args[0], args[1:]
+			}
 			effectiveOut := out
 			if stdout {
 				effectiveOut = "-"
 			}
-			res, err := app.Run(ctx, app.RunOptions{
-				ConfigPath:    *cfgPath,
-				RootOverride:  *rootOverride,
-				Profile:       profile,
-				Modifiers:     mods,
-				Output:        effectiveOut,
-				MaxChars:      maxChars,
-				Format:        format,
-				NoTree:        noTree,
-				NoManifest:    noManifest,
-				TreeDepth:     treeDepth,
-				IncludeHidden: includeHidden,
-				Logger:        loggerFn(*verbose),
-			})
-			if !quiet && res.OutputPath != "" && res.OutputPath != "-" {
-				if _, err := fmt.Fprintln(os.Stdout, res.OutputPath); err != nil {
-					return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+			runOpts := app.RunOptions{
+				ConfigPath:       *cfgPath,
+				RootOverride:     *rootOverride,
+				Profile:          profile,
+				Modifiers:        mods,
+				Output:           effectiveOut,
+				OutputDir:        outDir,
+				MaxChars:         maxChars,
+				MaxTokens:        maxTokens,
+				PerFileMaxLines:  perFileLines,
+				PerFileMaxBytes:  perFileBytes,
+				ContextLines:     contextLines,
+				Format:           format,
+				NoTree:           noTree,
+				NoManifest:       noManifest,
+				TreeDepth:        treeDepth,
+				LineNumbers:      lineNumbers,
+				IncludeHidden:    includeHidden,
+				FollowSymlinks:   followSymlinks,
+				UseGitignore:     useGitignore,
+				Since:            since,
+				Exclude:          excludes,
+				Include:          includes,
+				Only:             only,
+				Clipboard:        clipboard,
+				Gzip:             gzipOut,
+				Redact:           redact,
+				NoLock:           noLock,
+				KeepLast:         keepLast,
+				SuppressWarnings: noWarn,
+				AllowPartial:     allowPartial || !failPartial,
+				DryRun:           dryRun,
+				Append:           appendTo,
+				Logger:           logs.logger(),
+			}
+			if dryRun && (allProfiles || watch) {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--dry-run cannot be combined with --all-profiles or --watch"))
+			}
+			if appendTo != "" && (allProfiles || watch) {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--append cannot be combined with --all-profiles or --watch"))
+			}
+			if allProfiles {
+				if watch {
+					return app.Wrap(app.ExitUsage, fmt.Errorf("--all-profiles cannot be combined with --watch"))
+				}
+				return runAllProfiles(ctx, runOpts, quiet)
+			}
+			if watch {
+				return runWatch(ctx, runOpts, quiet)
+			}
+			progress := newProgressLine(logs.verbose)
+			runOpts.Progress = progress.progressFunc()
+			res, err := app.Run(ctx, runOpts)
+			progress.clear()
+			printWarnings(res.Warnings)
+			if res.DryRun != nil {
+				if _, werr := fmt.Fprint(os.Stdout, formatOutputPlan(*res.DryRun)); werr != nil {
+					return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", werr))
+				}
+				return err
+			}
+			if !quiet {
+				if werr := printOutputPaths(res); werr != nil {
+					return werr
 				}
 			}
 			return err
 		},
 	}
 	cmd.Flags().StringVarP(&out, "out", "o", "", "Output file path override ('-' for stdout)")
+	cmd.Flags().StringVar(&outDir, "out-dir", "", "Output directory override for the output.pattern file (ignored with -o/--stdout)")
 	cmd.Flags().BoolVar(&stdout, "stdout", false, "Write to stdout (equivalent to -o -)")
 	cmd.Flags().IntVar(&maxChars, "max-chars", 0, "Override budgets.max_chars")
-	cmd.Flags().StringVar(&format, "format", "md", "Output format (md)")
+	cmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Override budgets.max_tokens (estimated tokens)")
+	cmd.Flags().IntVar(&perFileLines, "per-file-max-lines", 0, "Override budgets.per_file_max_lines")
+	cmd.Flags().IntVar(&perFileBytes, "per-file-max-bytes", 0, "Override budgets.per_file_max_bytes")
+	cmd.Flags().IntVar(&contextLines, "context-lines", 0, "Keep only contains_regex matches plus N lines around each (overrides slices' contains_context)")
+	cmd.Flags().StringVar(&format, "format", "", "Output format: md, ndjson, plain or html (default render.format)")
 	cmd.Flags().BoolVar(&noTree, "no-tree", false, "Disable tree section")
 	cmd.Flags().BoolVar(&noManifest, "no-manifest", false, "Disable manifest sections")
 	cmd.Flags().IntVar(&treeDepth, "tree-depth", 0, "Override render.tree_depth")
+	cmd.Flags().BoolVar(&lineNumbers, "line-numbers", false, "Prefix fenced content lines with line numbers")
 	cmd.Flags().BoolVar(&includeHidden, "include-hidden", false, "Allow hidden files unless excluded by sensitive/ignore rules")
+	cmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symlinks that stay under root (ignore.follow_symlinks)")
+	cmd.Flags().BoolVar(&clipboard, "clipboard", false, "Copy the bundle to the clipboard (instead of the default file; with -o/--stdout, in addition)")
+	cmd.Flags().BoolVar(&gzipOut, "gzip", false, "Gzip the output and add a .gz suffix (output.compress: gzip)")
 	cmd.Flags().BoolVar(&quiet, "quiet", false, "Do not print output path")
+	gi.register(cmd)
+	cmd.Flags().BoolVar(&watch, "watch", false, "Rebuild the bundle whenever a non-ignored file changes (Ctrl-C to stop)")
+	cmd.Flags().StringVar(&since, "since", "", "Only bundle selected files changed since this git ref (git diff --name-only <ref>...HEAD)")
+	cmd.Flags().StringArrayVar(&excludes, "exclude", nil, "Exclude paths matching this glob from every enabled slice (repeatable)")
+	cmd.Flags().StringArrayVar(&includes, "include", nil, "Include paths matching this glob even if no enabled slice does (repeatable)")
+	cmd.Flags().StringArrayVar(&only, "only", nil, "Bundle only paths matching this glob, ignoring profiles and slices (repeatable; no profile argument)")
+	cmd.Flags().BoolVar(&failPartial, "fail-on-partial", true, "Exit with code 4 when the bundle is partial (default)")
+	cmd.Flags().BoolVar(&allowPartial, "allow-partial", false, "Exit with code 0 even when the bundle is partial; warnings are still printed")
+	cmd.Flags().BoolVar(&noWarn, "no-warn", false, "Do not print warnings about dropped or truncated content (the exit code still reports partial output)")
+	cmd.Flags().IntVar(&keepLast, "keep-last", 0, "Override output.keep_last: keep only the newest N bundles in the output directory")
+	cmd.Flags().BoolVar(&noLock, "no-lock", false, "Do not lock the output directory while updating the counter and output.latest")
+	cmd.Flags().BoolVar(&redact, "redact", false, "Mask sensitive.redact_patterns matches in file content with «REDACTED»")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print where the bundle would be written (file, output.latest, counter) without writing anything")
+	cmd.Flags().StringVar(&appendTo, "append", "", "Append the new files' blocks to this existing markdown bundle, skipping files it already has")
+	cmd.Flags().BoolVar(&allProfiles, "all-profiles", false, "Bundle every profile in the config, one output.pattern file each (no profile argument)")
+	cmd.Flags().BoolVar(&allProfiles, "profile-all", false, "Alias for --all-profiles")
+	_ = cmd.Flags().MarkHidden("profile-all")
 	return cmd
 }
 
-func newLsCmd(ctx context.Context, cfgPath *string, rootOverride *string, verbose *bool) *cobra.Command {
+// formatOutputPlan renders a run --dry-run plan as key: value lines.
+func formatOutputPlan(p app.OutputPlan) string {
+	var sb strings.Builder
+	sb.WriteString("dry run: nothing written\n")
+	state := func(exists bool) string {
+		if exists {
+			return " (exists, would be overwritten)"
+		}
+		return " (new)"
+	}
+	for _, sp := range p.Split {
+		fmt.Fprintf(&sb, "output: %s%s (%d bytes)\n", sp.Path, state(sp.Exists), sp.Bytes)
+		if sp.Latest != "" {
+			fmt.Fprintf(&sb, "latest: %s%s\n", sp.Latest, state(sp.LatestExists))
+		}
+	}
+	switch p.Path {
+	case "":
+	case "-":
+		sb.WriteString("output: stdout\n")
+	default:
+		fmt.Fprintf(&sb, "output: %s%s\n", p.Path, state(p.Exists))
+	}
+	if p.Latest != "" {
+		fmt.Fprintf(&sb, "latest: %s%s\n", p.Latest, state(p.LatestExists))
+	}
+	if p.Counter > 0 {
+		fmt.Fprintf(&sb, "counter: %d (not incremented)\n", p.Counter)
+	}
+	if p.Clipboard {
+		sb.WriteString("clipboard: yes\n")
+	}
+	fmt.Fprintf(&sb, "bytes: %d\n", p.Bytes)
+	return sb.String()
+}
+
+// runAllProfiles runs app.RunAll and prints each profile's warnings and output path.
+func runAllProfiles(ctx context.Context, opts app.RunOptions, quiet bool) error {
+	results, err := app.RunAll(ctx, opts)
+	for _, res := range results {
+		for _, w := range res.Warnings {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: %s: %s\n", res.Plan.Profile, w.Message)
+		}
+		if quiet {
+			continue
+		}
+		if werr := printOutputPaths(res); werr != nil {
+			return werr
+		}
+	}
+	return err
+}
+
+// printOutputPaths prints the files a run wrote, one per line: its bundle, or each slice
+// bundle with output.split.
+func printOutputPaths(res app.RunResult) error {
+	paths := res.OutputPaths
+	if res.OutputPath != "" && res.OutputPath != "-" {
+		paths = []string{res.OutputPath}
+	}
+	for _, p := range paths {
+		if _, err := fmt.Fprintln(os.Stdout, p); err != nil {
+			return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+		}
+	}
+	return nil
+}
+
+// runWatch runs app.Watch until interrupted, printing one line per build.
+func runWatch(ctx context.Context, opts app.RunOptions, quiet bool) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return app.Watch(ctx, app.WatchOptions{
+		Run: opts,
+		OnRun: func(res app.RunResult, err error) {
+			printWarnings(res.Warnings)
+			stamp := time.Now().Format("15:04:05")
+			if err != nil && !res.Partial {
+				_, _ = fmt.Fprintf(os.Stderr, "%s error: %v\n", stamp, err)
+				return
+			}
+			if quiet {
+				return
+			}
+			target := res.OutputPath
+			switch {
+			case len(res.OutputPaths) > 0:
+				target = strings.Join(res.OutputPaths, ", ")
+			case target == "":
+				target = "clipboard"
+			}
+			if target == "-" {
+				return
+			}
+			_, _ = fmt.Fprintf(os.Stdout, "%s built %s (%d files)\n", stamp, target, len(res.Plan.Included))
+		},
+	})
+}
+
+// printWarnings reports run warnings on stderr.
+func printWarnings(ws []app.Warning) {
+	for _, w := range ws {
+		_, _ = fmt.Fprintln(os.Stderr, "warning:", w.Message)
+	}
+}
+
+func newLsCmd(ctx context.Context, cfgPath *string, rootOverride *string, logs *logFlags) *cobra.Command {
 	var (
-		maxChars      int
-		includeHidden bool
+		maxChars       int
+		maxTokens      int
+		perFileLines   int
+		perFileBytes   int
+		contextLines   int
+		includeHidden  bool
+		followSymlinks bool
+		since          string
+		excludes       []string
+		includes       []string
+		format         string
+		printPaths     bool
+		null           bool
 	)
+	var gi gitignoreFlags
 	cmd := &cobra.Command{
-		Use:   "ls <profile> [modifiers...]",
-		Short: "List files that would be included",
-		Args:  cobra.MinimumNArgs(1),
+		Use:               "ls <profile> [modifiers...]",
+		Short:             "List files that would be included",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeProfileArgs(cfgPath, false),
 		Example: strings.TrimSpace(`
 snip ls api
 snip ls api +tests
 snip ls debug -docs
+snip ls full --since main
+snip ls api --per-file-max-lines 200
+snip ls api --no-gitignore
+snip ls api --format json | jq -r '.included[].path'
+snip ls api --print-paths -0 | xargs -0 wc -l
 `),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			useGitignore, err := gi.override()
+			if err != nil {
+				return err
+			}
 			profile := args[0]
 			mods := args[1:]
 			out, _, err := app.List(ctx, app.ListOptions{
-				ConfigPath:    *cfgPath,
-				RootOverride:  *rootOverride,
-				Profile:       profile,
-				Modifiers:     mods,
-				MaxChars:      maxChars,
-				IncludeHidden: includeHidden,
-				Verbose:       *verbose,
-				Logger:        loggerFn(*verbose),
+				ConfigPath:      *cfgPath,
+				RootOverride:    *rootOverride,
+				Profile:         profile,
+				Modifiers:       mods,
+				MaxChars:        maxChars,
+				MaxTokens:       maxTokens,
+				PerFileMaxLines: perFileLines,
+				PerFileMaxBytes: perFileBytes,
+				ContextLines:    contextLines,
+				IncludeHidden:   includeHidden,
+				FollowSymlinks:  followSymlinks,
+				UseGitignore:    useGitignore,
+				Since:           since,
+				Exclude:         excludes,
+				Include:         includes,
+				Verbose:         logs.verbose,
+				Logger:          logs.logger(),
+				Format:          format,
+				PrintPaths:      printPaths,
+				Null:            null,
 			})
 			if out != "" {
 				if _, err := fmt.Fprint(os.Stdout, out); err != nil {
@@ -342,15 +744,29 @@ snip ls debug -docs
 		},
 	}
 	cmd.Flags().IntVar(&maxChars, "max-chars", 0, "Override budgets.max_chars")
+	cmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Override budgets.max_tokens (estimated tokens)")
+	cmd.Flags().IntVar(&perFileLines, "per-file-max-lines", 0, "Override budgets.per_file_max_lines")
+	cmd.Flags().IntVar(&perFileBytes, "per-file-max-bytes", 0, "Override budgets.per_file_max_bytes")
+	cmd.Flags().IntVar(&contextLines, "context-lines", 0, "Keep only contains_regex matches plus N lines around each (overrides slices' contains_context)")
 	cmd.Flags().BoolVar(&includeHidden, "include-hidden", false, "Allow hidden files unless excluded by sensitive/ignore rules")
+	cmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symlinks that stay under root (ignore.follow_symlinks)")
+	cmd.Flags().StringVar(&since, "since", "", "Only list selected files changed since this git ref (git diff --name-only <ref>...HEAD)")
+	cmd.Flags().StringArrayVar(&excludes, "exclude", nil, "Exclude paths matching this glob from every enabled slice (repeatable)")
+	cmd.Flags().StringArrayVar(&includes, "include", nil, "Include paths matching this glob even if no enabled slice does (repeatable)")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&printPaths, "print-paths", false, "Print only the included relative paths, one per line")
+	cmd.Flags().BoolVarP(&null, "null", "0", false, "With --print-paths, terminate each path with NUL instead of a newline")
+	gi.register(cmd)
 	return cmd
 }
 
-func newDoctorCmd(ctx context.Context, cfgPath *string, rootOverride *string, verbose *bool) *cobra.Command {
+func newDoctorCmd(ctx context.Context, cfgPath *string, rootOverride *string, logs *logFlags) *cobra.Command {
 	var (
 		profile       string
 		includeHidden bool
+		asJSON        bool
 	)
+	var gi gitignoreFlags
 	cmd := &cobra.Command{
 		Use:   "doctor [modifiers...]",
 		Short: "Print effective config + environment diagnostics",
@@ -358,15 +774,22 @@ func newDoctorCmd(ctx context.Context, cfgPath *string, rootOverride *string, ve
 snip doctor
 snip doctor +tests
 snip doctor --profile debug -docs
+snip doctor --json
 `),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			useGitignore, err := gi.override()
+			if err != nil {
+				return err
+			}
 			out, err := app.Doctor(ctx, app.DoctorOptions{
 				ConfigPath:    *cfgPath,
 				RootOverride:  *rootOverride,
 				Profile:       profile,
 				Modifiers:     args,
 				IncludeHidden: includeHidden,
-				Logger:        loggerFn(*verbose),
+				UseGitignore:  useGitignore,
+				Logger:        logs.logger(),
+				JSON:          asJSON,
 			})
 			if err != nil {
 				return err
@@ -378,35 +801,65 @@ snip doctor --profile debug -docs
 		},
 	}
 	cmd.Flags().StringVar(&profile, "profile", "", "Profile (defaults to config default_profile)")
+	_ = cmd.RegisterFlagCompletionFunc("profile", func(c *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeProfileArgs(cfgPath, false)(c, nil, toComplete)
+	})
 	cmd.Flags().BoolVar(&includeHidden, "include-hidden", false, "Allow hidden files unless excluded by sensitive/ignore rules")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Emit JSON")
+	gi.register(cmd)
 	return cmd
 }
 
-func newExplainCmd(ctx context.Context, cfgPath *string, rootOverride *string, verbose *bool) *cobra.Command {
+func newExplainCmd(ctx context.Context, cfgPath *string, rootOverride *string, logs *logFlags) *cobra.Command {
 	var (
 		profile       string
 		includeHidden bool
+		excludes      []string
+		includes      []string
+		asJSON        bool
 	)
+	var gi gitignoreFlags
 	cmd := &cobra.Command{
-		Use:   "explain <path> [modifiers...]",
-		Short: "Explain why a path is included/excluded and what matched",
+		Use:   "explain <path|glob>... [modifiers...]",
+		Short: "Explain why paths are included/excluded and what matched",
 		Args:  cobra.MinimumNArgs(1),
 		Example: strings.TrimSpace(`
 snip explain internal/app/snip.go
 snip explain .github/workflows/ci.yml
 snip explain internal/app/snip.go +tests
+snip explain 'internal/**' README.md
+snip explain --json .env
+snip explain --no-gitignore dist/app.js
 `),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			target := args[0]
-			mods := args[1:]
+			useGitignore, err := gi.override()
+			if err != nil {
+				return err
+			}
+			var targets, mods []string
+			for _, a := range args {
+				if isModifier(a) {
+					mods = append(mods, a)
+				} else {
+					targets = append(targets, a)
+				}
+			}
+			if len(targets) == 0 {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("explain needs at least one path or glob"))
+			}
 			out, err := app.Explain(ctx, app.ExplainOptions{
 				ConfigPath:    *cfgPath,
 				RootOverride:  *rootOverride,
 				Profile:       profile,
 				Modifiers:     mods,
 				IncludeHidden: includeHidden,
-				Path:          target,
-				Logger:        loggerFn(*verbose),
+				Exclude:       excludes,
+				Include:       includes,
+				Path:          targets[0],
+				Paths:         targets[1:],
+				UseGitignore:  useGitignore,
+				Logger:        logs.logger(),
+				JSON:          asJSON,
 			})
 			if err != nil {
 				return err
@@ -418,37 +871,296 @@ snip explain internal/app/snip.go +tests
 		},
 	}
 	cmd.Flags().StringVar(&profile, "profile", "", "Profile (defaults to config default_profile)")
+	_ = cmd.RegisterFlagCompletionFunc("profile", func(c *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeProfileArgs(cfgPath, false)(c, nil, toComplete)
+	})
 	cmd.Flags().BoolVar(&includeHidden, "include-hidden", false, "Allow hidden files unless excluded by sensitive/ignore rules")
+	cmd.Flags().StringArrayVar(&excludes, "exclude", nil, "Explain as if run with this --exclude glob (repeatable)")
+	cmd.Flags().StringArrayVar(&includes, "include", nil, "Explain as if run with this --include glob (repeatable)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Emit JSON")
+	gi.register(cmd)
+	return cmd
+}
+
+// gitignoreFlags holds --gitignore/--no-gitignore, which override ignore.use_gitignore for
+// one invocation.
+type gitignoreFlags struct{ on, off bool }
+
+func (f *gitignoreFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.on, "gitignore", false, "Apply .gitignore rules even if ignore.use_gitignore is false")
+	cmd.Flags().BoolVar(&f.off, "no-gitignore", false, "Skip .gitignore rules even if ignore.use_gitignore is true")
+}
+
+// override returns the flag's value, or nil when neither is set so the config decides.
+func (f *gitignoreFlags) override() (*bool, error) {
+	switch {
+	case f.on && f.off:
+		return nil, app.Wrap(app.ExitUsage, fmt.Errorf("--gitignore and --no-gitignore are mutually exclusive"))
+	case f.on || f.off:
+		v := f.on
+		return &v, nil
+	}
+	return nil, nil
+}
+
+func newProfilesCmd(cfgPath *string) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "List profiles with their slices and effective budgets",
+		Args:  cobra.NoArgs,
+		Example: strings.TrimSpace(`
+snip profiles
+snip profiles --json
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := app.Profiles(app.ProfilesOptions{
+				ConfigPath: *cfgPath,
+				JSON:       asJSON,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprint(os.Stdout, out); err != nil {
+				return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Emit JSON")
+	return cmd
+}
+
+func newConfigCmd(cfgPath *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Maintain the .snip.yaml file",
+		Args:  cobra.NoArgs,
+	}
+	var dryRun bool
+	migrate := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade the config file to the current schema version",
+		Args:  cobra.NoArgs,
+		Example: strings.TrimSpace(`
+snip config migrate --dry-run
+snip config migrate
+snip --config base.snip.yaml config migrate
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := app.MigrateConfig(app.MigrateOptions{
+				ConfigPath: *cfgPath,
+				DryRun:     dryRun,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprint(os.Stdout, out); err != nil {
+				return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+			}
+			return nil
+		},
+	}
+	migrate.Flags().BoolVar(&dryRun, "dry-run", false, "Print the migration as a unified diff without writing the file")
+	cmd.AddCommand(migrate)
+	return cmd
+}
+
+func newSlicesCmd(ctx context.Context, cfgPath *string, rootOverride *string, logs *logFlags) *cobra.Command {
+	var (
+		includeHidden  bool
+		followSymlinks bool
+		asJSON         bool
+	)
+	cmd := &cobra.Command{
+		Use:               "slices [profile] [modifiers...]",
+		Short:             "Show matched file counts and sizes per enabled slice",
+		ValidArgsFunction: completeProfileArgs(cfgPath, true),
+		Example: strings.TrimSpace(`
+snip slices
+snip slices api +tests
+snip slices debug -docs --json
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			args = unescapeModifiers(args)
+			profile := ""
+			mods := args
+			if len(args) > 0 && !isModifier(args[0]) {
+				profile = args[0]
+				mods = args[1:]
+			}
+			out, err := app.Slices(ctx, app.SlicesOptions{
+				ConfigPath:     *cfgPath,
+				RootOverride:   *rootOverride,
+				Profile:        profile,
+				Modifiers:      mods,
+				IncludeHidden:  includeHidden,
+				FollowSymlinks: followSymlinks,
+				JSON:           asJSON,
+				Logger:         logs.logger(),
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprint(os.Stdout, out); err != nil {
+				return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&includeHidden, "include-hidden", false, "Allow hidden files unless excluded by sensitive/ignore rules")
+	cmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symlinks that stay under root (ignore.follow_symlinks)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Emit JSON")
+	return cmd
+}
+
+func newStatCmd(ctx context.Context, cfgPath *string, rootOverride *string, logs *logFlags) *cobra.Command {
+	var (
+		maxChars       int
+		maxTokens      int
+		includeHidden  bool
+		followSymlinks bool
+	)
+	cmd := &cobra.Command{
+		Use:               "stat [profile] [modifiers...]",
+		Short:             "Report bundle size against budgets without writing",
+		ValidArgsFunction: completeProfileArgs(cfgPath, true),
+		Example: strings.TrimSpace(`
+snip stat
+snip stat api +tests
+snip stat debug -docs --max-tokens 50000
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			args = unescapeModifiers(args)
+			profile := ""
+			mods := args
+			if len(args) > 0 && !isModifier(args[0]) {
+				profile = args[0]
+				mods = args[1:]
+			}
+			out, _, err := app.Stat(ctx, app.StatOptions{
+				ConfigPath:     *cfgPath,
+				RootOverride:   *rootOverride,
+				Profile:        profile,
+				Modifiers:      mods,
+				MaxChars:       maxChars,
+				MaxTokens:      maxTokens,
+				IncludeHidden:  includeHidden,
+				FollowSymlinks: followSymlinks,
+				Logger:         logs.logger(),
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprint(os.Stdout, out); err != nil {
+				return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&maxChars, "max-chars", 0, "Override budgets.max_chars")
+	cmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Override budgets.max_tokens (estimated tokens)")
+	cmd.Flags().BoolVar(&includeHidden, "include-hidden", false, "Allow hidden files unless excluded by sensitive/ignore rules")
+	cmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symlinks that stay under root (ignore.follow_symlinks)")
+	return cmd
+}
+
+func newDiffCmd() *cobra.Command {
+	var fileHeader string
+	cmd := &cobra.Command{
+		Use:   "diff <old.md> <new.md>",
+		Short: "Compare two bundles: added, removed and modified files",
+		Args:  cobra.ExactArgs(2),
+		Example: strings.TrimSpace(`
+snip diff .snip/api-1.md .snip/api-2.md
+snip diff old.md new.md --file-header '===== FILE: {path} ====='
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := app.Diff(app.DiffOptions{
+				OldPath:    args[0],
+				NewPath:    args[1],
+				FileHeader: fileHeader,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprint(os.Stdout, out); err != nil {
+				return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&fileHeader, "file-header", "", "File header template (default: the bundle's delimiter_header)")
 	return cmd
 }
 
 func newApplyCmd(rootOverride *string) *cobra.Command {
 	var (
-		fileHeader string
-		write      bool
-		force      bool
+		fileHeader      string
+		fileHeaderRegex string
+		write           bool
+		force           bool
+		showDiff        bool
+		backup          bool
+		auto            bool
+		patch           bool
+		stdin           bool
 	)
 	cmd := &cobra.Command{
-		Use:   "apply <input-file>",
+		Use:   "apply <input-file|->",
 		Short: "Apply AI-generated markdown code blocks to the filesystem",
 		Long: strings.TrimSpace(`
 Apply AI-generated markdown code blocks to the filesystem.
-Does not require snip format.
+Does not require snip format. A block whose content is exactly __DELETE__
+deletes its target (requires --force). With --patch, the input is a unified
+diff (as produced by git diff) applied to existing files instead.
+Pass - (or --stdin) to read the input from stdin.
 `),
-		Args: cobra.ExactArgs(1),
+		Args: cobra.MaximumNArgs(1),
 		Example: strings.TrimSpace(`
 snip apply ai.txt --file-header '===== FILE: {path} ====='
 snip apply ai.txt --file-header '<<<FILE:{path}>>>' --write --force
+snip apply ai.txt --file-header '<<<FILE:{path}>>>' --force --diff
+snip apply .snip/last.md --auto
+git diff | snip apply - --patch --write
+pbpaste | snip apply --stdin --auto
+snip apply ai.txt --file-header '<<<FILE:{path}>>>' --write --force --backup
+snip apply ai.txt --file-header-regex '^// FILE \(\d+ of \d+\): (?P<path>.+)$'
 `),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if strings.TrimSpace(fileHeader) == "" {
-				return app.Wrap(app.ExitUsage, fmt.Errorf("--file-header is required (must contain {path})"))
+			if fileHeader != "" && fileHeaderRegex != "" {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--file-header and --file-header-regex are mutually exclusive"))
 			}
-			res, err := applytool.Run(args[0], applytool.Options{
-				Root:       *rootOverride,
-				FileHeader: fileHeader,
-				Write:      write,
-				Force:      force,
+			if auto && (fileHeader != "" || fileHeaderRegex != "") {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--auto cannot be combined with --file-header or --file-header-regex"))
+			}
+			if patch && (auto || fileHeader != "" || fileHeaderRegex != "") {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--patch cannot be combined with --file-header, --file-header-regex or --auto"))
+			}
+			if !patch && !auto && strings.TrimSpace(fileHeader) == "" && strings.TrimSpace(fileHeaderRegex) == "" {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--file-header (must contain {path}), --file-header-regex (must contain (?P<path>...)), --auto or --patch is required"))
+			}
+			if showDiff && write {
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--diff previews a dry run and cannot be combined with --write"))
+			}
+			input := "-"
+			switch {
+			case stdin && len(args) > 0 && args[0] != "-":
+				return app.Wrap(app.ExitUsage, fmt.Errorf("--stdin cannot be combined with an input file"))
+			case len(args) > 0:
+				input = args[0]
+			case !stdin:
+				return app.Wrap(app.ExitUsage, fmt.Errorf("an input file (or - / --stdin for stdin) is required"))
+			}
+			res, err := applytool.Run(input, applytool.Options{
+				Root:            *rootOverride,
+				FileHeader:      fileHeader,
+				FileHeaderRegex: fileHeaderRegex,
+				Auto:            auto,
+				Patch:           patch,
+				Write:           write,
+				Force:           force,
+				Backup:          backup,
 			})
 			if err != nil {
 				if applytool.IsKind(err, applytool.KindInvalidInput) {
@@ -465,26 +1177,63 @@ snip apply ai.txt --file-header '<<<FILE:{path}>>>' --write --force
 					return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
 				}
 				for _, f := range res.Files {
-					action := "CREATE"
-					if f.Overwrite {
-						action = "OVERWRITE"
+					size := fmt.Sprintf("%d bytes", len(f.Content))
+					if f.Mode != 0 && f.Mode != applytool.DefaultFileMode {
+						size += fmt.Sprintf(", mode %04o", uint32(f.Mode))
+					}
+					line := fmt.Sprintf("CREATE %s (%s)", f.RelPath, size)
+					switch {
+					case f.Delete && !f.Exists:
+						line = fmt.Sprintf("DELETE %s (not found, skipped)", f.RelPath)
+					case f.Delete:
+						line = "DELETE " + f.RelPath
+					case f.Overwrite:
+						line = fmt.Sprintf("OVERWRITE %s (%s)", f.RelPath, size)
 					}
-					if _, err := fmt.Fprintf(os.Stdout, "%s %s (%d bytes)\n", action, f.RelPath, len(f.Content)); err != nil {
+					if _, err := fmt.Fprintln(os.Stdout, line); err != nil {
+						return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+					}
+					if !showDiff {
+						continue
+					}
+					diff, err := applytool.Diff(f)
+					if err != nil {
+						return app.Wrap(app.ExitIO, err)
+					}
+					if _, err := fmt.Fprint(os.Stdout, diff); err != nil {
+						return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+					}
+				}
+				if res.SkippedHeaders > 0 {
+					if _, err := fmt.Fprintf(os.Stdout, "SKIPPED: %d header(s) inside other code fences (not applied)\n", res.SkippedHeaders); err != nil {
 						return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
 					}
 				}
 				return nil
 			}
 
-			if _, err := fmt.Fprintf(os.Stdout, "WROTE: %d file(s)\n", res.Wrote); err != nil {
+			summary := fmt.Sprintf("WROTE: %d file(s)", res.Wrote)
+			if res.Deleted > 0 {
+				summary += fmt.Sprintf(", DELETED: %d file(s)", res.Deleted)
+			}
+			if _, err := fmt.Fprintln(os.Stdout, summary); err != nil {
 				return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
 			}
 			for _, f := range res.Files {
 				action := "CREATED"
-				if f.Overwrite {
+				switch {
+				case f.Delete && !f.Exists:
+					action = "SKIPPED (not found)"
+				case f.Delete:
+					action = "DELETED"
+				case f.Overwrite:
 					action = "OVERWROTE"
 				}
-				if _, err := fmt.Fprintf(os.Stdout, "%s %s\n", action, f.RelPath); err != nil {
+				line := fmt.Sprintf("%s %s", action, f.RelPath)
+				if f.BackupPath != "" {
+					line += " (backup: " + f.BackupPath + ")"
+				}
+				if _, err := fmt.Fprintln(os.Stdout, line); err != nil {
 					return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
 				}
 			}
@@ -492,11 +1241,135 @@ snip apply ai.txt --file-header '<<<FILE:{path}>>>' --write --force
 		},
 	}
 	cmd.Flags().StringVar(&fileHeader, "file-header", "", "Header line template containing {path} (e.g. '===== FILE: {path} =====')")
+	cmd.Flags().StringVar(&fileHeaderRegex, "file-header-regex", "", "Header line regex with a named group 'path' (e.g. '^// FILE \\(\\d+ of \\d+\\): (?P<path>.+)$')")
+	cmd.Flags().StringVar(&fileHeaderRegex, "header-regex", "", "Alias for --file-header-regex")
+	_ = cmd.Flags().MarkHidden("header-regex")
+	cmd.Flags().BoolVar(&auto, "auto", false, "Detect the header from a snip bundle's delimiter_header (else '## N) path')")
+	cmd.Flags().BoolVar(&patch, "patch", false, "Treat the input as a unified diff and patch files in place")
+	cmd.Flags().BoolVar(&stdin, "stdin", false, "Read the input from stdin (same as passing -)")
 	cmd.Flags().BoolVar(&write, "write", false, "Write files to disk (default is dry-run)")
-	cmd.Flags().BoolVar(&force, "force", false, "Allow overwriting existing files")
+	cmd.Flags().BoolVar(&force, "force", false, "Allow overwriting (or deleting) existing files")
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "In dry-run, print a unified diff of each change")
+	cmd.Flags().BoolVar(&backup, "backup", false, "Copy each overwritten file to <path>.snip-bak first")
 	return cmd
 }
 
+func newSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "schema",
+		Short:   "Print the JSON Schema for .snip.yaml",
+		Hidden:  true,
+		Args:    cobra.NoArgs,
+		Example: "snip schema > snip.schema.json\n",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schema, err := config.Schema()
+			if err != nil {
+				return app.Wrap(app.ExitIO, err)
+			}
+			if _, err := fmt.Fprintln(os.Stdout, string(schema)); err != nil {
+				return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+			}
+			return nil
+		},
+	}
+}
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:       "completion bash|zsh|fish|powershell",
+		Short:     "Generate a shell completion script",
+		ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)(cmd, args); err != nil {
+				return app.Wrap(app.ExitUsage, err)
+			}
+			return nil
+		},
+		Example: strings.TrimSpace(`
+source <(snip completion bash)
+snip completion zsh > "${fpath[1]}/_snip"
+snip completion fish > ~/.config/fish/completions/snip.fish
+snip completion powershell | Out-String | Invoke-Expression
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			var err error
+			switch args[0] {
+			case "bash":
+				err = root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				err = root.GenZshCompletion(os.Stdout)
+			case "fish":
+				err = root.GenFishCompletion(os.Stdout, true)
+			case "powershell":
+				err = root.GenPowerShellCompletionWithDesc(os.Stdout)
+			}
+			if err != nil {
+				return app.Wrap(app.ExitIO, fmt.Errorf("write stdout: %w", err))
+			}
+			return nil
+		},
+	}
+}
+
+// completeProfileArgs completes "[profile] [modifiers...]" arguments from the config: profile
+// names for the first argument, then +slice for slices the profile leaves off and -slice for
+// the ones it enables, skipping slices already toggled. With profileOptional, modifiers are
+// also offered for the first argument (against default_profile). Without a loadable config it
+// offers nothing rather than failing.
+func completeProfileArgs(cfgPath *string, profileOptional bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		cfg, err := config.Load(config.FindConfigPath(*cfgPath))
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		args = unescapeModifiers(args)
+
+		var out []string
+		if len(args) == 0 && !isModifier(toComplete) {
+			for name := range cfg.Profiles {
+				if strings.HasPrefix(name, toComplete) {
+					out = append(out, name)
+				}
+			}
+			sort.Strings(out)
+			if !profileOptional {
+				return out, cobra.ShellCompDirectiveNoFileComp
+			}
+		}
+
+		profile := config.ResolveProfile(cfg, "")
+		if len(args) > 0 && !isModifier(args[0]) {
+			profile = args[0]
+		}
+		enabled := map[string]bool{}
+		for _, s := range cfg.Profiles[profile].Enable {
+			enabled[s] = true
+		}
+		toggled := map[string]bool{}
+		for _, a := range args {
+			if isModifier(a) {
+				toggled[a[1:]] = true
+			}
+		}
+		names := make([]string, 0, len(cfg.Slices))
+		for name := range cfg.Slices {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			mod := "+" + name
+			if enabled[name] {
+				mod = "-" + name
+			}
+			if !toggled[name] && strings.HasPrefix(mod, toComplete) {
+				out = append(out, mod)
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
 func newVersionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:     "version",
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -115,3 +117,77 @@ func TestApplyCommandWritesWithHeaderTemplate(t *testing.T) {
 		t.Fatalf("written content=%q", string(b))
 	}
 }
+
+func TestCompleteProfileArgsFromConfig(t *testing.T) {
+	root := t.TempDir()
+	cfg := config.Default()
+	cfg.Root = root
+	cfg.DefaultProfile = "full"
+	cfg.Slices = map[string]config.SliceConfig{
+		"docs":  {Include: []string{"README.md"}, Priority: 10},
+		"tests": {Include: []string{"**/*_test.go"}, Priority: 20},
+		"api":   {Include: []string{"**/*.go"}, Priority: 30},
+	}
+	cfg.Profiles = map[string]config.Profile{
+		"full": {Enable: []string{"docs", "api"}},
+		"fast": {Enable: []string{"api"}},
+	}
+	cfgPath := filepath.Join(root, ".snip.yaml")
+	if err := config.Write(cfgPath, cfg); err != nil {
+		t.Fatalf("config.Write: %v", err)
+	}
+
+	for _, tc := range []struct {
+		optional   bool
+		args       []string
+		toComplete string
+		want       string
+	}{
+		{false, nil, "", "fast full"},
+		{false, nil, "fu", "full"},
+		{false, []string{"full"}, "", "-api -docs +tests"},
+		{false, []string{"fast", "+docs"}, "", "-api +tests"},
+		{false, []string{"fast"}, "+", "+docs +tests"},
+		{true, nil, "", "fast full -api -docs +tests"},
+		{true, nil, "-", "-api -docs"},
+	} {
+		got, _ := completeProfileArgs(&cfgPath, tc.optional)(nil, tc.args, tc.toComplete)
+		if strings.Join(got, " ") != tc.want {
+			t.Fatalf("complete(%v, %q)=%v want %q", tc.args, tc.toComplete, got, tc.want)
+		}
+	}
+
+	missing := filepath.Join(root, "missing.yaml")
+	if got, _ := completeProfileArgs(&missing, false)(nil, nil, ""); len(got) != 0 {
+		t.Fatalf("completion without config=%v", got)
+	}
+}
+
+func TestLogFormatSelectsHandler(t *testing.T) {
+	if _, ok := (&logFlags{format: "text"}).logger().Handler().(*slog.TextHandler); !ok {
+		t.Fatalf("text format did not use slog.TextHandler")
+	}
+	logs := &logFlags{verbose: true, format: "json"}
+	h, ok := logs.logger().Handler().(*slog.JSONHandler)
+	if !ok {
+		t.Fatalf("json format did not use slog.JSONHandler")
+	}
+	if !h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatalf("--verbose should enable debug logs with --log-format json")
+	}
+
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+	os.Args = []string{"snip", "version", "--log-format", "xml"}
+	if code := run(); code != app.ExitUsage {
+		t.Fatalf("run() code=%d want=%d", code, app.ExitUsage)
+	}
+}
+
+func TestGroupDigits(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 842: "842", 3001: "3,001", 12304: "12,304", 1234567: "1,234,567"} {
+		if got := groupDigits(n); got != want {
+			t.Fatalf("groupDigits(%d)=%q want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/bundleparse"
+	"github.com/mmrzaf/snip/internal/render"
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// appendTarget is the existing markdown bundle run --append adds file blocks to.
+type appendTarget struct {
+	path   string
+	body   string // the bundle without its bundle_sha256 line
+	sealed bool
+	files  int // files the bundle already numbers; appended blocks continue after them
+	paths  map[string]bool
+	perm   os.FileMode
+}
+
+// readAppendTarget reads the bundle at path and the files it already holds. fileHeader is the
+// configured file_block.header; when empty the bundle's own delimiter_header (or the default
+// "## N) path" headings) is used, as for snip diff.
+func readAppendTarget(path, fileHeader string) (appendTarget, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return appendTarget{}, Wrap(ExitUsage, fmt.Errorf("resolve --append path: %w", err))
+	}
+	st, err := os.Stat(abs)
+	if err != nil {
+		return appendTarget{}, Wrap(ExitUsage, fmt.Errorf("--append bundle: %w", err))
+	}
+	b, err := os.ReadFile(abs)
+	if err != nil {
+		return appendTarget{}, Wrap(ExitIO, fmt.Errorf("read --append bundle: %w", err))
+	}
+	parsed, err := bundleparse.Parse(string(b), fileHeader)
+	if err != nil {
+		return appendTarget{}, Wrapf(ExitUsage, err, "%s is not a snip bundle", path)
+	}
+	t := appendTarget{path: abs, files: len(parsed.Files), paths: make(map[string]bool, len(parsed.Files)), perm: st.Mode().Perm()}
+	for _, f := range parsed.Files {
+		t.paths[f.Path] = true
+	}
+	t.body, t.sealed = render.UnsealMarkdown(string(b))
+	return t, nil
+}
+
+// write appends continuation to the bundle, sealing it again if it was sealed.
+func (t appendTarget) write(continuation, nl string) error {
+	if nl == "" {
+		nl = "\n"
+	}
+	data := t.body
+	if data != "" && !strings.HasSuffix(data, "\n") {
+		data += nl
+	}
+	data += continuation
+	if t.sealed {
+		data = render.SealMarkdown(data, nl)
+	}
+	if err := util.AtomicWriteFile(t.path, []byte(data), t.perm); err != nil {
+		return fmt.Errorf("write %s: %w", t.path, err)
+	}
+	return nil
+}
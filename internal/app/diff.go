@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mmrzaf/snip/internal/bundleparse"
+)
+
+// DiffOptions configures snip diff.
+type DiffOptions struct {
+	OldPath    string
+	NewPath    string
+	FileHeader string // overrides the bundles' declared delimiter_header
+}
+
+// Diff compares two markdown bundles and lists added, removed and modified files.
+func Diff(opts DiffOptions) (string, error) {
+	oldB, err := readBundle(opts.OldPath, opts.FileHeader)
+	if err != nil {
+		return "", err
+	}
+	newB, err := readBundle(opts.NewPath, opts.FileHeader)
+	if err != nil {
+		return "", err
+	}
+	changes := bundleparse.Diff(oldB, newB)
+
+	counts := map[bundleparse.ChangeKind]int{}
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	for _, c := range changes {
+		counts[c.Kind]++
+		switch c.Kind {
+		case bundleparse.Added:
+			fmt.Fprintf(tw, "added\t%s\tlines=+%d\n", c.Path, c.NewLines)
+		case bundleparse.Removed:
+			fmt.Fprintf(tw, "removed\t%s\tlines=-%d\n", c.Path, c.OldLines)
+		case bundleparse.Modified:
+			fmt.Fprintf(tw, "modified\t%s\tlines=%d->%d (%+d) bytes=%d->%d\n", c.Path, c.OldLines, c.NewLines, c.LineDelta(), c.OldBytes, c.NewBytes)
+		}
+	}
+	_ = tw.Flush()
+
+	unchanged := len(newB.Files) - counts[bundleparse.Added] - counts[bundleparse.Modified]
+	fmt.Fprintf(&sb, "summary: added=%d removed=%d modified=%d unchanged=%d\n",
+		counts[bundleparse.Added], counts[bundleparse.Removed], counts[bundleparse.Modified], unchanged)
+	return sb.String(), nil
+}
+
+func readBundle(path, fileHeader string) (bundleparse.Bundle, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return bundleparse.Bundle{}, Wrap(ExitIO, fmt.Errorf("read bundle: %w", err))
+	}
+	parsed, err := bundleparse.Parse(string(b), fileHeader)
+	if err != nil {
+		return bundleparse.Bundle{}, Wrapf(ExitUsage, err, "%s", path)
+	}
+	return parsed, nil
+}
@@ -2,17 +2,25 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
+	"unicode/utf8"
+
+	"github.com/bmatcuk/doublestar/v4"
 
 	"github.com/mmrzaf/snip/internal/budget"
 	"github.com/mmrzaf/snip/internal/config"
 	"github.com/mmrzaf/snip/internal/discovery"
 	"github.com/mmrzaf/snip/internal/gitinfo"
+	"github.com/mmrzaf/snip/internal/render"
 	"github.com/mmrzaf/snip/internal/selector"
 )
 
@@ -25,110 +33,372 @@ type DoctorOptions struct {
 	IncludeHidden bool
 	Logger        *slog.Logger
 	Now           func() time.Time
+	// JSON renders the DoctorReport as indented JSON instead of text.
+	JSON bool
+	// UseGitignore, when non-nil, overrides ignore.use_gitignore.
+	UseGitignore *bool
+}
+
+// DoctorReport is what snip doctor found, before rendering.
+type DoctorReport struct {
+	ConfigPath    string   `json:"config_path"`
+	Root          string   `json:"root"`
+	Profile       string   `json:"profile"`
+	EnabledSlices []string `json:"enabled_slices"`
+	// Presets are the built-in presets the config expanded, with the slices each supplied.
+	Presets []DoctorPreset `json:"presets,omitempty"`
+	Budgets DoctorBudgets  `json:"budgets"`
+	Size    DoctorSize     `json:"size"`
+	// Tree is set when render.tree_max_nodes folds the bundle tree.
+	Tree      *DoctorTree     `json:"tree,omitempty"`
+	Git       DoctorGit       `json:"git"`
+	Discovery DoctorDiscovery `json:"discovery"`
+	Warnings  []string        `json:"warnings"`
+	// ExclusionReasons counts dropped files per reason, most frequent first. The text output
+	// shows the top eight.
+	ExclusionReasons []ReasonCount `json:"exclusion_reasons"`
+	// PotentialSecrets are lines of selected files that look like secrets, in path and line
+	// order; only scanned with sensitive.scan_content.
+	PotentialSecrets []SecretFinding `json:"potential_secrets,omitempty"`
+}
+
+// DoctorPreset is a preset the config uses and the slices that came from it (possibly
+// overridden key by key in the config).
+type DoctorPreset struct {
+	ID     string   `json:"id"`
+	Slices []string `json:"slices"`
+}
+
+// SecretFinding is one line flagged by the content scan (see discovery.ScanSecrets).
+type SecretFinding struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Kind string `json:"kind"`
+}
+
+// DoctorBudgets are the effective limits for the profile.
+type DoctorBudgets struct {
+	MaxChars        int    `json:"max_chars"`
+	MaxTokens       int    `json:"max_tokens"`
+	PerFileMaxLines int    `json:"per_file_max_lines"`
+	PerFileMaxBytes int    `json:"per_file_max_bytes"`
+	TruncateMode    string `json:"truncate_mode"`
+	DropPolicy      string `json:"drop_policy"`
+}
+
+// DoctorSize is the size of the selection rendered once, before budget enforcement.
+type DoctorSize struct {
+	EstimatedChars  int `json:"estimated_chars"`
+	EstimatedTokens int `json:"estimated_tokens"`
+	// BudgetUtilization is the size as a percentage of the tighter of max_chars and
+	// max_tokens; above 100 means run will drop or cut content.
+	BudgetUtilization int `json:"budget_utilization"`
+}
+
+// DoctorTree describes how the tree section was fitted to render.tree_max_nodes.
+type DoctorTree struct {
+	Entries   int  `json:"entries"`
+	MaxNodes  int  `json:"max_nodes"`
+	Collapsed int  `json:"collapsed_dirs"`
+	Cut       bool `json:"cut"`
+}
+
+// DoctorGit describes the repository state. Dirty is nil when it could not be determined.
+type DoctorGit struct {
+	Available bool   `json:"available"`
+	SHA       string `json:"sha,omitempty"`
+	Dirty     *bool  `json:"dirty"`
+}
+
+// DoctorDiscovery is the effective discovery configuration.
+type DoctorDiscovery struct {
+	UseGitignore   bool `json:"use_gitignore"`
+	UseSnipignore  bool `json:"use_snipignore"`
+	FollowSymlinks bool `json:"follow_symlinks"`
+	Cache          bool `json:"cache"`
+	TrackedOnly    bool `json:"tracked_only"`
+	IncludeHidden  bool `json:"include_hidden"`
+}
+
+// ReasonCount is the number of files excluded for one reason.
+type ReasonCount struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
 }
 
 // Doctor returns effective configuration and environment diagnostics.
 func Doctor(ctx context.Context, opts DoctorOptions) (string, error) {
+	rep, err := doctorReport(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	if opts.JSON {
+		return marshalReport(rep)
+	}
+	return renderDoctor(rep), nil
+}
+
+func doctorReport(ctx context.Context, opts DoctorOptions) (DoctorReport, error) {
 	cfg, err := config.Load(opts.ConfigPath)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return DoctorReport{}, Wrap(ExitUsage, err)
 	}
 	root, err := config.EffectiveRoot(cfg, opts.RootOverride)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return DoctorReport{}, Wrap(ExitUsage, err)
 	}
 
-	profile := opts.Profile
-	if profile == "" {
-		profile = cfg.DefaultProfile
-	}
+	profile := config.ResolveProfile(cfg, opts.Profile)
 
 	cfg, err = config.ApplyProfileOverrides(cfg, profile)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return DoctorReport{}, Wrap(ExitUsage, err)
+	}
+	if opts.UseGitignore != nil {
+		cfg.Ignore.UseGitignore = *opts.UseGitignore
 	}
 
 	mods, err := selector.ParseModifiers(opts.Modifiers)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return DoctorReport{}, Wrap(ExitUsage, err)
 	}
 	enabled, err := selector.EnabledSlices(cfg, profile, mods)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return DoctorReport{}, Wrap(ExitUsage, err)
 	}
+	cfg.Selector.EnableOrder = selector.EnableOrder(cfg, profile, mods)
 	enabledOrdered := selector.EnabledSliceList(enabled, cfg)
 
-	limits := budget.Limits{
-		MaxChars:        cfg.Budgets.MaxChars,
-		PerFileMaxLines: cfg.Budgets.PerFileMaxLines,
-		PerFileMaxBytes: cfg.Budgets.PerFileMaxBytes,
-	}
+	limits := limitsFromConfig(cfg, 0, 0, 0, 0)
 
 	sha, shaErr := gitinfo.ShortSHA(ctx, root)
 	gitAvail := shaErr == nil && sha != ""
 	if !gitAvail {
-		sha = "(unavailable)"
+		sha = ""
+	}
+	var dirty *bool
+	if gitAvail {
+		if d, err := gitinfo.IsDirty(ctx, root); err == nil {
+			dirty = &d
+		}
 	}
 
-	eng, err := discovery.NewEngine(root, cfg.Ignore.UseGitignore, cfg.Ignore.Always, cfg.Sensitive.ExcludeGlobs, cfg.Ignore.BinaryExtensions)
+	eng, err := newDiscoveryEngine(ctx, root, cfg, nil)
 	if err != nil {
-		return "", Wrap(ExitIO, err)
+		return DoctorReport{}, Wrap(ExitIO, err)
 	}
 	discovered, err := eng.Discover()
 	if err != nil {
-		return "", Wrap(ExitIO, err)
+		return DoctorReport{}, Wrap(ExitIO, err)
 	}
 	sel, err := selector.Select(cfg, enabled, discovered, opts.IncludeHidden)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return DoctorReport{}, Wrap(ExitUsage, err)
+	}
+
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	bundleSHA := sha
+	if !gitAvail {
+		bundleSHA = "000000" // as gitState reports it to run
+	}
+	info := bundleInfo(cfg, root, opts.RootOverride, profile, enabledOrdered, bundleSHA, dirty, now())
+	size, err := doctorSize(ctx, cfg, root, info, discovered, sel, limits)
+	if err != nil {
+		return DoctorReport{}, err
+	}
+
+	var tree *DoctorTree
+	if cfg.Render.IncludeTree && cfg.Render.TreeMaxNodes > 0 {
+		depth := max(cfg.Render.TreeDepth, 1)
+		if fit := render.FitTree(treePathsFromDiscovery(discovered), depth, cfg.Render.TreeMaxNodes); fit.Fitted() {
+			tree = &DoctorTree{Entries: fit.Entries, MaxNodes: cfg.Render.TreeMaxNodes, Collapsed: fit.Collapsed, Cut: fit.Cut}
+		}
 	}
 
 	reasonCounts := map[string]int{}
 	for _, d := range sel.Dropped {
 		reasonCounts[string(d.ExclusionReason)]++
 	}
-	type kv struct {
-		k string
-		v int
-	}
-	var rows []kv
+	rows := []ReasonCount{}
 	for k, v := range reasonCounts {
-		rows = append(rows, kv{k: k, v: v})
+		rows = append(rows, ReasonCount{Reason: k, Count: v})
 	}
 	sort.Slice(rows, func(i, j int) bool {
-		if rows[i].v != rows[j].v {
-			return rows[i].v > rows[j].v
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
 		}
-		return rows[i].k < rows[j].k
+		return rows[i].Reason < rows[j].Reason
 	})
-	if len(rows) > 8 {
-		rows = rows[:8]
+
+	var secrets []SecretFinding
+	if cfg.Sensitive.ScanContent {
+		// Selection keeps path order; unreadable files are reported by run, not here.
+		for _, f := range sel.Included {
+			hits, err := discovery.ScanSecrets(f.AbsPath)
+			if err != nil {
+				continue
+			}
+			for _, h := range hits {
+				secrets = append(secrets, SecretFinding{Path: f.RelPath, Line: h.Line, Kind: h.Kind})
+			}
+		}
+	}
+
+	warnings := []string{}
+	for _, s := range enabledOrdered {
+		if mc := cfg.Slices[s].Budget.MaxChars; mc > limits.MaxChars {
+			warnings = append(warnings, fmt.Sprintf("slice %q budget.max_chars=%d exceeds budgets.max_chars=%d", s, mc, limits.MaxChars))
+		}
+	}
+
+	return DoctorReport{
+		ConfigPath:    opts.ConfigPath,
+		Root:          filepath.Clean(root),
+		Profile:       profile,
+		EnabledSlices: enabledOrdered,
+		Presets:       doctorPresets(cfg.PresetSlices),
+		Budgets: DoctorBudgets{
+			MaxChars:        limits.MaxChars,
+			MaxTokens:       limits.MaxTokens,
+			PerFileMaxLines: limits.PerFileMaxLines,
+			PerFileMaxBytes: limits.PerFileMaxBytes,
+			TruncateMode:    limits.TruncateMode,
+			DropPolicy:      limits.DropPolicy,
+		},
+		Size: size,
+		Tree: tree,
+		Git:  DoctorGit{Available: gitAvail, SHA: sha, Dirty: dirty},
+		Discovery: DoctorDiscovery{
+			UseGitignore:   cfg.Ignore.UseGitignore,
+			UseSnipignore:  cfg.Ignore.SnipignoreEnabled(),
+			FollowSymlinks: cfg.Ignore.FollowSymlinks,
+			Cache:          cfg.Ignore.Cache,
+			TrackedOnly:    cfg.Ignore.TrackedOnly,
+			IncludeHidden:  opts.IncludeHidden,
+		},
+		Warnings:         warnings,
+		ExclusionReasons: rows,
+		PotentialSecrets: secrets,
+	}, nil
+}
+
+// doctorPresets groups slice→preset ownership by preset, both sorted.
+func doctorPresets(owners map[string]string) []DoctorPreset {
+	byPreset := map[string][]string{}
+	for s, id := range owners {
+		byPreset[id] = append(byPreset[id], s)
 	}
+	var out []DoctorPreset
+	for id, slices := range byPreset {
+		sort.Strings(slices)
+		out = append(out, DoctorPreset{ID: id, Slices: slices})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// doctorSize builds the plan for the selection and renders it once in the configured format,
+// without EnforceGlobalBudget, to show how close the profile is to its limits.
+func doctorSize(ctx context.Context, cfg config.Config, root string, info render.BundleInfo, discovered []discovery.PathInfo, sel selector.Selected, limits budget.Limits) (DoctorSize, error) {
+	b := &budget.Builder{Limits: limits, SliceLimits: sliceLimitsFromConfig(cfg), HashContent: cfg.Render.IncludeHashes, StripPatterns: stripPatternsFromConfig(cfg)}
+	plan, err := b.BuildPlan(ctx, info.Profile, info.Enabled, sel)
+	if err != nil {
+		return DoctorSize{}, Wrap(ExitIO, err)
+	}
+	rndr := newRenderer(cfg.Render, cfg, discovered)
+	rndr.Manifest.Commits = fileCommits(ctx, root, cfg.Render, plan)
+	rendered, err := rendererFor(rndr, cfg.Render.Format, info)(plan)
+	if err != nil {
+		return DoctorSize{}, Wrap(ExitIO, err)
+	}
+
+	size := DoctorSize{EstimatedChars: utf8.RuneCountInString(rendered), EstimatedTokens: limits.Tokens(rendered)}
+	if limits.MaxChars > 0 {
+		size.BudgetUtilization = percent(size.EstimatedChars, limits.MaxChars)
+	}
+	if limits.MaxTokens > 0 {
+		size.BudgetUtilization = max(size.BudgetUtilization, percent(size.EstimatedTokens, limits.MaxTokens))
+	}
+	return size, nil
+}
+
+// percent is n as a rounded percentage of total.
+func percent(n, total int) int {
+	return (n*100 + total/2) / total
+}
 
+func renderDoctor(rep DoctorReport) string {
 	var b strings.Builder
 	w := func(s string, a ...any) { fmt.Fprintf(&b, s+"\n", a...) }
 
+	sha := rep.Git.SHA
+	if !rep.Git.Available {
+		sha = "(unavailable)"
+	}
+	lim, disc := rep.Budgets, rep.Discovery
+
 	w("snip doctor")
 	w("")
-	w("config_path: %s", opts.ConfigPath)
-	w("root: %s", filepath.Clean(root))
-	w("profile: %s", profile)
-	w("enabled_slices: [%s]", strings.Join(enabledOrdered, ", "))
-	w("budgets: max_chars=%d per_file_max_lines=%d per_file_max_bytes=%d", limits.MaxChars, limits.PerFileMaxLines, limits.PerFileMaxBytes)
-	w("git: available=%t sha=%s", gitAvail, sha)
-	w("discovery: use_gitignore=%t include_hidden=%t", cfg.Ignore.UseGitignore, opts.IncludeHidden)
+	w("config_path: %s", rep.ConfigPath)
+	w("root: %s", rep.Root)
+	w("profile: %s", rep.Profile)
+	w("enabled_slices: [%s]", strings.Join(rep.EnabledSlices, ", "))
+	for _, p := range rep.Presets {
+		w("preset: %s slices=[%s]", p.ID, strings.Join(p.Slices, ", "))
+	}
+	w("budgets: max_chars=%d max_tokens=%d per_file_max_lines=%d per_file_max_bytes=%d truncate_mode=%s drop_policy=%s", lim.MaxChars, lim.MaxTokens, lim.PerFileMaxLines, lim.PerFileMaxBytes, lim.TruncateMode, lim.DropPolicy)
+	w("size: estimated_chars=%d estimated_tokens=%d budget_utilization=%d%%", rep.Size.EstimatedChars, rep.Size.EstimatedTokens, rep.Size.BudgetUtilization)
+	if t := rep.Tree; t != nil {
+		w("tree: entries=%d max_nodes=%d collapsed_dirs=%d cut=%t", t.Entries, t.MaxNodes, t.Collapsed, t.Cut)
+	}
+	w("git: available=%t sha=%s git_dirty=%s", rep.Git.Available, sha, render.DirtyLabel(rep.Git.Dirty))
+	if rep.Git.Dirty != nil && *rep.Git.Dirty {
+		w("hint: working tree has uncommitted changes; bundles may not match any commit")
+	}
+	w("discovery: use_gitignore=%t use_snipignore=%t follow_symlinks=%t cache=%t tracked_only=%t include_hidden=%t", disc.UseGitignore, disc.UseSnipignore, disc.FollowSymlinks, disc.Cache, disc.TrackedOnly, disc.IncludeHidden)
+
+	if len(rep.Warnings) > 0 {
+		w("")
+		w("warnings:")
+		for _, msg := range rep.Warnings {
+			w("  - %s", msg)
+		}
+	}
+
+	if len(rep.PotentialSecrets) > 0 {
+		w("")
+		w("potential_secrets:")
+		for _, f := range rep.PotentialSecrets {
+			w("  - %s:%d (%s)", f.Path, f.Line, f.Kind)
+		}
+	}
 
 	w("")
 	w("top_exclusion_reasons:")
+	rows := rep.ExclusionReasons
+	if len(rows) > 8 {
+		rows = rows[:8]
+	}
 	if len(rows) == 0 {
 		w("  (none)")
 	} else {
 		for _, r := range rows {
-			w("  - %s: %d", r.k, r.v)
+			w("  - %s: %d", r.Reason, r.Count)
 		}
 	}
+	return b.String()
+}
 
-	return b.String(), nil
+// marshalReport renders a doctor, explain or ls report as indented JSON.
+func marshalReport(v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", Wrap(ExitIO, fmt.Errorf("marshal json: %w", err))
+	}
+	return string(b) + "\n", nil
 }
 
 // ExplainOptions configures snip explain.
@@ -138,61 +408,192 @@ type ExplainOptions struct {
 	Profile       string
 	Modifiers     []string
 	IncludeHidden bool
+	Exclude       []string // see RunOptions.Exclude
+	Include       []string // see RunOptions.Include
 	Path          string
-	Logger        *slog.Logger
-	Now           func() time.Time
+	// Paths are more paths to explain after Path. With more than one path, or a path with glob
+	// metacharacters (matched against discovered files), Explain prints one row per file
+	// instead of the detailed report.
+	Paths  []string
+	Logger *slog.Logger
+	Now    func() time.Time
+	// JSON renders the ExplainReport as indented JSON instead of text.
+	JSON bool
+	// UseGitignore, when non-nil, overrides ignore.use_gitignore.
+	UseGitignore *bool
+}
+
+// ExplainReport is what snip explain found, before rendering. A single plain path fills Path,
+// Discovery, SliceMatches and Selection; several paths or a glob fill Files instead.
+type ExplainReport struct {
+	Root          string   `json:"root"`
+	Profile       string   `json:"profile"`
+	EnabledSlices []string `json:"enabled_slices"`
+	IncludeHidden bool     `json:"include_hidden"`
+
+	Path         string            `json:"path,omitempty"`
+	Discovery    *ExplainDiscovery `json:"discovery,omitempty"`
+	SliceMatches []ExplainSlice    `json:"slice_matches,omitempty"`
+	Selection    *ExplainSelection `json:"selection,omitempty"`
+	// DiscoveryChecks are the path-rule stages evaluated for Path, in order. For a path
+	// that was not found they are only set when an excluded ancestor directory explains why.
+	DiscoveryChecks []ExplainCheck `json:"discovery_checks,omitempty"`
+
+	Files []ExplainFile `json:"files,omitempty"`
+}
+
+// ExplainDiscovery is the discovery verdict for a path. Reason and Detail are set when the
+// path was excluded before selection.
+type ExplainDiscovery struct {
+	Found    bool   `json:"found"`
+	Excluded bool   `json:"excluded"`
+	Reason   string `json:"reason,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// ExplainCheck is one discovery path-rule stage and its verdict (see discovery.RuleCheck).
+type ExplainCheck struct {
+	Rule     string   `json:"rule"`
+	Path     string   `json:"path"`
+	Patterns []string `json:"patterns"`
+	Matched  string   `json:"matched,omitempty"`
+	Excluded bool     `json:"excluded"`
+}
+
+// ExplainSlice is a slice whose include or exclude patterns match the path.
+type ExplainSlice struct {
+	Slice    string        `json:"slice"`
+	Priority int           `json:"priority"`
+	Enabled  bool          `json:"enabled"`
+	Include  *ExplainMatch `json:"include,omitempty"`
+	Exclude  *ExplainMatch `json:"exclude,omitempty"`
+	// Contains is the slice's contains_regex verdict; it is only checked for enabled
+	// slices whose path patterns keep the file.
+	Contains *ExplainContains `json:"contains,omitempty"`
+}
+
+// ExplainContains is whether the file's content matched a slice's contains_regex.
+type ExplainContains struct {
+	Pattern string `json:"pattern"`
+	Matched bool   `json:"matched"`
+}
+
+// ExplainMatch is the pattern that matched; Regex marks include_regex/exclude_regex entries.
+type ExplainMatch struct {
+	Pattern string `json:"pattern"`
+	Regex   bool   `json:"regex,omitempty"`
+	// ProfileOverride marks a glob added by the profile's include/exclude map rather than
+	// the slice itself.
+	ProfileOverride bool `json:"profile_override,omitempty"`
+}
+
+// String formats the match like selector.PatternMatch, noting profile overrides.
+func (m ExplainMatch) String() string {
+	out := selector.PatternMatch{Matched: true, Pattern: m.Pattern, Regex: m.Regex}.String()
+	if m.ProfileOverride {
+		out += " (profile override)"
+	}
+	return out
+}
+
+// ExplainSelection is how selection treats the path under the enabled slices.
+type ExplainSelection struct {
+	InEnabledSlices bool     `json:"in_enabled_slices"`
+	MatchedSlices   []string `json:"matched_enabled_slices"`
+	// GlobalExcludeSlice is the slice whose exclude removed the file under
+	// selector.global_exclude_wins.
+	GlobalExcludeSlice string        `json:"global_exclude_slice,omitempty"`
+	GlobalExclude      *ExplainMatch `json:"global_exclude,omitempty"`
+	CLIInclude         *ExplainMatch `json:"cli_include,omitempty"`
+	CLIExclude         *ExplainMatch `json:"cli_exclude,omitempty"`
+	Included           bool          `json:"included"`
+}
+
+// ExplainFile is one row of the multi-path table. Reason is empty for files included by
+// their slices.
+type ExplainFile struct {
+	Path     string   `json:"path"`
+	Included bool     `json:"included"`
+	Reason   string   `json:"reason,omitempty"`
+	Slices   []string `json:"slices"`
 }
 
-// Explain returns inclusion/exclusion details for a single path.
+// Explain returns inclusion/exclusion details for a single path, or a verdict table for
+// several paths and globs.
 func Explain(ctx context.Context, opts ExplainOptions) (string, error) {
+	rep, err := explainReport(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	if opts.JSON {
+		return marshalReport(rep)
+	}
+	return renderExplain(rep), nil
+}
+
+func explainReport(ctx context.Context, opts ExplainOptions) (ExplainReport, error) {
 	cfg, err := config.Load(opts.ConfigPath)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return ExplainReport{}, Wrap(ExitUsage, err)
 	}
 	root, err := config.EffectiveRoot(cfg, opts.RootOverride)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return ExplainReport{}, Wrap(ExitUsage, err)
 	}
 
-	profile := opts.Profile
-	if profile == "" {
-		profile = cfg.DefaultProfile
-	}
+	profile := config.ResolveProfile(cfg, opts.Profile)
 
 	cfg, err = config.ApplyProfileOverrides(cfg, profile)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return ExplainReport{}, Wrap(ExitUsage, err)
+	}
+	if opts.UseGitignore != nil {
+		cfg.Ignore.UseGitignore = *opts.UseGitignore
 	}
+	cfg.Selector.Exclude, cfg.Selector.Include = opts.Exclude, opts.Include
 
 	mods, err := selector.ParseModifiers(opts.Modifiers)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return ExplainReport{}, Wrap(ExitUsage, err)
 	}
 	enabled, err := selector.EnabledSlices(cfg, profile, mods)
 	if err != nil {
-		return "", Wrap(ExitUsage, err)
+		return ExplainReport{}, Wrap(ExitUsage, err)
 	}
 	enabledOrdered := selector.EnabledSliceList(enabled, cfg)
 
-	// Normalize path to relpath under root (best effort).
-	rel := opts.Path
-	if filepath.IsAbs(rel) {
-		if r, rerr := filepath.Rel(root, rel); rerr == nil {
-			rel = r
+	var targets []string
+	for _, p := range append([]string{opts.Path}, opts.Paths...) {
+		if p != "" {
+			targets = append(targets, explainRel(root, p))
 		}
 	}
-	rel = filepath.ToSlash(filepath.Clean(rel))
-	rel = strings.TrimPrefix(rel, "./")
+	if len(targets) == 0 {
+		return ExplainReport{}, Wrap(ExitUsage, fmt.Errorf("explain needs a path"))
+	}
 
-	eng, err := discovery.NewEngine(root, cfg.Ignore.UseGitignore, cfg.Ignore.Always, cfg.Sensitive.ExcludeGlobs, cfg.Ignore.BinaryExtensions)
+	eng, err := newDiscoveryEngine(ctx, root, cfg, nil)
 	if err != nil {
-		return "", Wrap(ExitIO, err)
+		return ExplainReport{}, Wrap(ExitIO, err)
 	}
 	discovered, err := eng.Discover()
 	if err != nil {
-		return "", Wrap(ExitIO, err)
+		return ExplainReport{}, Wrap(ExitIO, err)
+	}
+
+	rep := ExplainReport{
+		Root:          filepath.Clean(root),
+		Profile:       profile,
+		EnabledSlices: enabledOrdered,
+		IncludeHidden: opts.IncludeHidden,
+	}
+	if len(targets) > 1 || isGlob(targets[0]) {
+		rep.Files = explainFiles(cfg, enabled, enabledOrdered, discovered, targets, opts.IncludeHidden)
+		return rep, nil
 	}
 
+	rel := targets[0]
+	rep.Path = rel
 	var pi *discovery.PathInfo
 	for i := range discovered {
 		if discovered[i].RelPath == rel {
@@ -200,65 +601,252 @@ func Explain(ctx context.Context, opts ExplainOptions) (string, error) {
 			break
 		}
 	}
+	checks, err := eng.ExplainPath(rel)
+	if err != nil {
+		return ExplainReport{}, Wrap(ExitIO, err)
+	}
+	for _, c := range checks {
+		rep.DiscoveryChecks = append(rep.DiscoveryChecks, ExplainCheck{
+			Rule:     c.Rule,
+			Path:     c.Path,
+			Patterns: append([]string{}, c.Patterns...),
+			Matched:  c.Matched,
+			Excluded: c.Excluded,
+		})
+	}
+	if pi == nil {
+		rep.Discovery = &ExplainDiscovery{}
+		if n := len(checks); n == 0 || !checks[n-1].Excluded {
+			rep.DiscoveryChecks = nil
+		}
+		return rep, nil
+	}
+	rep.Discovery = &ExplainDiscovery{Found: true, Excluded: pi.Excluded}
+	if pi.Excluded {
+		rep.Discovery.Reason = string(pi.ExclusionReason)
+		rep.Discovery.Detail = pi.ExclusionDetail
+	}
 
+	sel := explainSelect(cfg, enabled, enabledOrdered, rel, pi.AbsPath, pi.IsHidden, opts.IncludeHidden)
+	enabledSet := map[string]bool{}
+	for _, s := range enabled {
+		enabledSet[s] = true
+	}
+	for _, m := range sel.matches {
+		if !m.include.Matched && !m.exclude.Matched {
+			continue
+		}
+		rep.SliceMatches = append(rep.SliceMatches, ExplainSlice{
+			Slice:    m.name,
+			Priority: m.priority,
+			Enabled:  enabledSet[m.name],
+			Include:  profileMatch(m.include, cfg.Slices[m.name].ProfileInclude),
+			Exclude:  profileMatch(m.exclude, cfg.Slices[m.name].ProfileExclude),
+			Contains: m.contains,
+		})
+	}
+
+	rep.Selection = &ExplainSelection{
+		InEnabledSlices: len(sel.effective) > 0,
+		MatchedSlices:   append([]string{}, sel.effective...),
+		Included:        !pi.Excluded && len(sel.effective) > 0,
+	}
+	if sel.globalSlice != "" {
+		rep.Selection.GlobalExcludeSlice = sel.globalSlice
+		rep.Selection.GlobalExclude = explainMatch(sel.globalMatch)
+	}
+	if sel.forced {
+		rep.Selection.CLIInclude = explainMatch(sel.adHocInc)
+	}
+	if sel.excludedAdHoc {
+		rep.Selection.CLIExclude = explainMatch(sel.adHocExc)
+	}
+	return rep, nil
+}
+
+func explainMatch(m selector.PatternMatch) *ExplainMatch {
+	if !m.Matched {
+		return nil
+	}
+	return &ExplainMatch{Pattern: m.Pattern, Regex: m.Regex}
+}
+
+// profileMatch is explainMatch for a slice pattern, marking globs from the profile's overrides.
+func profileMatch(m selector.PatternMatch, profileGlobs []string) *ExplainMatch {
+	em := explainMatch(m)
+	if em != nil && !m.Regex {
+		em.ProfileOverride = slices.Contains(profileGlobs, m.Pattern)
+	}
+	return em
+}
+
+func renderExplain(rep ExplainReport) string {
 	var b strings.Builder
 	w := func(s string, a ...any) { fmt.Fprintf(&b, s+"\n", a...) }
 
 	w("snip explain")
 	w("")
-	w("path: %s", rel)
-	w("root: %s", filepath.Clean(root))
-	w("profile: %s", profile)
-	w("enabled_slices: [%s]", strings.Join(enabledOrdered, ", "))
-	w("include_hidden: %t", opts.IncludeHidden)
+	if rep.Discovery == nil {
+		w("root: %s", rep.Root)
+		w("profile: %s", rep.Profile)
+		w("enabled_slices: [%s]", strings.Join(rep.EnabledSlices, ", "))
+		w("include_hidden: %t", rep.IncludeHidden)
+		w("")
+		b.WriteString(renderExplainTable(rep.Files))
+		return b.String()
+	}
 
-	if pi == nil {
+	w("path: %s", rep.Path)
+	w("root: %s", rep.Root)
+	w("profile: %s", rep.Profile)
+	w("enabled_slices: [%s]", strings.Join(rep.EnabledSlices, ", "))
+	w("include_hidden: %t", rep.IncludeHidden)
+
+	d := rep.Discovery
+	if !d.Found {
 		w("")
 		w("discovery: not_found_under_root=true")
-		return b.String(), nil
+		renderExplainChecks(&b, rep.Path, rep.DiscoveryChecks)
+		return b.String()
 	}
 
 	w("")
 	w("discovery:")
-	w("  excluded: %t", pi.Excluded)
-	if pi.Excluded {
-		w("  reason: %s", string(pi.ExclusionReason))
-		w("  detail: %s", pi.ExclusionDetail)
+	w("  excluded: %t", d.Excluded)
+	if d.Excluded {
+		w("  reason: %s", d.Reason)
+		w("  detail: %s", d.Detail)
 	} else {
 		w("  reason: (none)")
 	}
+	renderExplainChecks(&b, rep.Path, rep.DiscoveryChecks)
+
+	w("")
+	w("slice_matches:")
+	for _, m := range rep.SliceMatches {
+		tag := " "
+		if m.Enabled {
+			tag = "x"
+		}
+		w("  [%s] %s (priority=%d)", tag, m.Slice, m.Priority)
+		if m.Include != nil {
+			w("      include: matched %s", m.Include)
+		}
+		if m.Exclude != nil {
+			w("      exclude: matched %s", m.Exclude)
+		}
+		if c := m.Contains; c != nil {
+			verdict := "matched"
+			if !c.Matched {
+				verdict = "no match"
+			}
+			w("      contains: %s regex=%q", verdict, c.Pattern)
+		}
+	}
+
+	sel := rep.Selection
+	w("")
+	w("effective_selection:")
+	w("  in_enabled_slices: %t", sel.InEnabledSlices)
+	w("  matched_enabled_slices: [%s]", strings.Join(sel.MatchedSlices, ", "))
+	if sel.GlobalExclude != nil {
+		w("  global_exclude: slice=%s %s", sel.GlobalExcludeSlice, sel.GlobalExclude)
+	}
+	if sel.CLIInclude != nil {
+		w("  cli_include: %s", sel.CLIInclude)
+	}
+	if sel.CLIExclude != nil {
+		w("  cli_exclude: %s", sel.CLIExclude)
+	}
+	w("  included: %t", sel.Included)
+	return b.String()
+}
 
-	// Slice match detail (for all slices, but highlight enabled ones).
-	type sm struct {
-		name             string
-		priority         int
-		includeMatched   bool
-		includePattern   string
-		includeExplicitH bool
-		excludeMatched   bool
-		excludePattern   string
-		member           bool
+// renderExplainChecks writes the discovery_checks section: one line per stage with its
+// verdict, the deciding pattern and every pattern it was checked against.
+func renderExplainChecks(b *strings.Builder, path string, checks []ExplainCheck) {
+	if len(checks) == 0 {
+		return
+	}
+	b.WriteString("\ndiscovery_checks:\n")
+	for _, c := range checks {
+		verdict := "passed"
+		switch {
+		case c.Excluded:
+			verdict = fmt.Sprintf("excluded by %q", c.Matched)
+		case c.Matched != "":
+			verdict = fmt.Sprintf("passed (re-included by %q)", c.Matched)
+		}
+		if c.Path != path {
+			verdict += " dir=" + c.Path
+		}
+		quoted := make([]string, len(c.Patterns))
+		for i, p := range c.Patterns {
+			quoted[i] = strconv.Quote(p)
+		}
+		fmt.Fprintf(b, "  %s: %s patterns=[%s]\n", c.Rule, verdict, strings.Join(quoted, ", "))
 	}
-	var matches []sm
+}
+
+// explainRel normalizes a path argument to a slash-separated path relative to root (best
+// effort; globs pass through unchanged apart from a leading "./").
+func explainRel(root, p string) string {
+	if filepath.IsAbs(p) {
+		if r, err := filepath.Rel(root, p); err == nil {
+			p = r
+		}
+	}
+	p = filepath.ToSlash(filepath.Clean(p))
+	return strings.TrimPrefix(p, "./")
+}
+
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[{")
+}
+
+// explainSliceMatch is one slice's include/exclude verdict for a path.
+type explainSliceMatch struct {
+	name             string
+	priority         int
+	include          selector.PatternMatch
+	includeExplicitH bool
+	exclude          selector.PatternMatch
+	member           bool
+	contains         *ExplainContains // set when contains_regex was checked
+}
+
+// explainSelection is how selection treats a discovered path under the enabled slices.
+type explainSelection struct {
+	matches       []explainSliceMatch // every slice, highest priority first
+	effective     []string            // enabled slices that keep the file
+	containsMiss  bool                // path rules kept the file but no contains_regex matched
+	forced        bool                // added by an ad-hoc --include glob
+	adHocInc      selector.PatternMatch
+	adHocExc      selector.PatternMatch
+	excludedAdHoc bool // removed by an ad-hoc --exclude glob
+	globalSlice   string
+	globalMatch   selector.PatternMatch
+}
+
+// explainSelect mirrors selector.Select for a single path.
+func explainSelect(cfg config.Config, enabled, enabledOrdered []string, rel, abs string, isHidden, includeHidden bool) explainSelection {
+	var sel explainSelection
 	for name, sl := range cfg.Slices {
-		inc, incPat, incExplicitHidden, exc, excPat := selector.ExplainSliceMatch(rel, sl)
-		member := inc && !exc
-		matches = append(matches, sm{
+		inc, incExplicitHidden, exc := selector.ExplainSliceMatch(rel, sl)
+		sel.matches = append(sel.matches, explainSliceMatch{
 			name:             name,
 			priority:         sl.Priority,
-			includeMatched:   inc,
-			includePattern:   incPat,
+			include:          inc,
 			includeExplicitH: incExplicitHidden,
-			excludeMatched:   exc,
-			excludePattern:   excPat,
-			member:           member,
+			exclude:          exc,
+			member:           inc.Matched && !exc.Matched,
 		})
 	}
-	sort.Slice(matches, func(i, j int) bool {
-		if matches[i].priority != matches[j].priority {
-			return matches[i].priority > matches[j].priority
+	sort.Slice(sel.matches, func(i, j int) bool {
+		if sel.matches[i].priority != sel.matches[j].priority {
+			return sel.matches[i].priority > sel.matches[j].priority
 		}
-		return matches[i].name < matches[j].name
+		return sel.matches[i].name < sel.matches[j].name
 	})
 
 	enabledSet := map[string]bool{}
@@ -267,46 +855,131 @@ func Explain(ctx context.Context, opts ExplainOptions) (string, error) {
 	}
 
 	// Effective membership under enabled slices + hidden policy (mirrors selector.membership logic).
-	var effective []string
-	isHidden := pi.IsHidden
-	for _, m := range matches {
+	pathMember := false
+	for i := range sel.matches {
+		m := &sel.matches[i]
 		if !enabledSet[m.name] {
 			continue
 		}
 		if !m.member {
 			continue
 		}
-		if isHidden && !opts.IncludeHidden && !m.includeExplicitH {
+		if isHidden && !includeHidden && !m.includeExplicitH {
 			continue
 		}
-		effective = append(effective, m.name)
+		pathMember = true
+		if sl := cfg.Slices[m.name]; sl.ContainsRegex != "" {
+			if matched, ok := selector.ContainsMatch(sl, abs); ok {
+				m.contains = &ExplainContains{Pattern: sl.ContainsRegex, Matched: matched}
+				if !matched {
+					continue
+				}
+			}
+		}
+		sel.effective = append(sel.effective, m.name)
 	}
-	sort.Strings(effective)
+	sort.Strings(sel.effective)
+	sel.containsMiss = pathMember && len(sel.effective) == 0
 
-	w("")
-	w("slice_matches:")
-	for _, m := range matches {
-		if !m.includeMatched && !m.excludeMatched {
-			continue
+	// An ad-hoc --include glob adds a file outside every enabled slice to the top one.
+	adHocInc, adHocIncExplicitH, adHocExc := selector.AdHocMatch(cfg, rel)
+	sel.adHocInc, sel.adHocExc = adHocInc, adHocExc
+	if len(sel.effective) == 0 && adHocInc.Matched && (!isHidden || includeHidden || adHocIncExplicitH) {
+		sel.effective = []string{enabledOrdered[0]}
+		sel.forced = true
+	}
+
+	// Cross-slice exclude (selector.global_exclude_wins) strips the file from all slices.
+	if cfg.Selector.GlobalExcludeWins && len(sel.effective) > 0 {
+		if s, m, ok := selector.GlobalExcludeMatch(cfg, enabled, rel); ok {
+			sel.globalSlice, sel.globalMatch = s, m
+			sel.effective = nil
 		}
-		tag := " "
-		if enabledSet[m.name] {
-			tag = "x"
+	}
+	// Ad-hoc --exclude globs apply on top of every slice's excludes.
+	sel.excludedAdHoc = len(sel.effective) > 0 && adHocExc.Matched
+	if sel.excludedAdHoc {
+		sel.effective = nil
+	}
+	return sel
+}
+
+// explainFiles returns one row per discovered file matching targets (exact paths or
+// doublestar globs), in path order. Targets that match nothing get a not_found_under_root row
+// after the rest.
+func explainFiles(cfg config.Config, enabled, enabledOrdered []string, discovered []discovery.PathInfo, targets []string, includeHidden bool) []ExplainFile {
+	seen := map[string]bool{}
+	var rows []discovery.PathInfo
+	var missing []string
+	for _, t := range targets {
+		found := false
+		for _, pi := range discovered {
+			ok := pi.RelPath == t
+			if !ok && isGlob(t) {
+				ok, _ = doublestar.Match(t, pi.RelPath)
+			}
+			if !ok {
+				continue
+			}
+			found = true
+			if !seen[pi.RelPath] {
+				seen[pi.RelPath] = true
+				rows = append(rows, pi)
+			}
 		}
-		w("  [%s] %s (priority=%d)", tag, m.name, m.priority)
-		if m.includeMatched {
-			w("      include: matched pattern=%q", m.includePattern)
+		if !found {
+			missing = append(missing, t)
 		}
-		if m.excludeMatched {
-			w("      exclude: matched pattern=%q", m.excludePattern)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].RelPath < rows[j].RelPath })
+
+	files := []ExplainFile{}
+	for _, pi := range rows {
+		f := ExplainFile{Path: pi.RelPath, Reason: string(pi.ExclusionReason), Slices: []string{}}
+		if !pi.Excluded {
+			sel := explainSelect(cfg, enabled, enabledOrdered, pi.RelPath, pi.AbsPath, pi.IsHidden, includeHidden)
+			switch {
+			case len(sel.effective) > 0:
+				f.Included, f.Reason, f.Slices = true, "", sel.effective
+				if sel.forced {
+					f.Reason = "cli_include"
+				}
+			case sel.globalSlice != "":
+				f.Reason = "global_exclude:" + sel.globalSlice
+			case sel.excludedAdHoc:
+				f.Reason = "cli_exclude"
+			case sel.containsMiss:
+				f.Reason = "contains_regex"
+			default:
+				f.Reason = "no_enabled_slice"
+			}
 		}
+		files = append(files, f)
 	}
+	for _, t := range missing {
+		files = append(files, ExplainFile{Path: t, Reason: "not_found_under_root", Slices: []string{}})
+	}
+	return files
+}
 
-	w("")
-	w("effective_selection:")
-	w("  in_enabled_slices: %t", len(effective) > 0)
-	w("  matched_enabled_slices: [%s]", strings.Join(effective, ", "))
-	w("  included: %t", !pi.Excluded && len(effective) > 0)
-
-	return b.String(), nil
+// renderExplainTable renders files as "PATH VERDICT REASON SLICES" columns.
+func renderExplainTable(files []ExplainFile) string {
+	var buf strings.Builder
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PATH\tVERDICT\tREASON\tSLICES")
+	for _, f := range files {
+		verdict, reason, slices := "excluded", f.Reason, "-"
+		if f.Included {
+			verdict = "included"
+		}
+		if reason == "" {
+			reason = "-"
+		}
+		if len(f.Slices) > 0 {
+			slices = strings.Join(f.Slices, ",")
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Path, verdict, reason, slices)
+	}
+	_ = tw.Flush()
+	return buf.String()
 }
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/config"
+	"github.com/mmrzaf/snip/internal/tools/apply"
+)
+
+// MigrateOptions configures snip config migrate.
+type MigrateOptions struct {
+	ConfigPath string
+	// DryRun prints a unified diff of the migration instead of writing it.
+	DryRun bool
+}
+
+// MigrateConfig upgrades the config file at ConfigPath to config.CurrentVersion and writes it
+// back, keeping its comments and key order. Only that file is rewritten; files it extends are
+// migrated in memory whenever they load, and on disk by migrating each of them.
+func MigrateConfig(opts MigrateOptions) (string, error) {
+	path := opts.ConfigPath
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return "", Wrap(ExitUsage, fmt.Errorf("read config: %w", err))
+	}
+	migrated, steps, err := config.MigrateYAML(old)
+	if err != nil {
+		return "", Wrapf(ExitUsage, err, "%s", path)
+	}
+
+	var sb strings.Builder
+	if len(steps) == 0 {
+		fmt.Fprintf(&sb, "%s: already at version %d\n", path, config.CurrentVersion)
+		return sb.String(), nil
+	}
+	if opts.DryRun {
+		diff, err := apply.Diff(apply.PlannedFile{RelPath: filepath.Base(path), AbsPath: path, Exists: true, Content: migrated})
+		if err != nil {
+			return "", Wrap(ExitIO, err)
+		}
+		sb.WriteString(diff)
+	}
+	verb := "migrated"
+	if opts.DryRun {
+		verb = "would migrate"
+	}
+	fmt.Fprintf(&sb, "%s: %s to version %d\n", path, verb, config.CurrentVersion)
+	for _, s := range steps {
+		fmt.Fprintf(&sb, "  %s\n", s)
+	}
+	if opts.DryRun {
+		sb.WriteString("dry run: nothing written\n")
+		return sb.String(), nil
+	}
+	if err := config.WriteYAML(path, migrated); err != nil {
+		return "", Wrap(ExitIO, fmt.Errorf("write config: %w", err))
+	}
+	return sb.String(), nil
+}
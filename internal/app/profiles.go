@@ -0,0 +1,95 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/config"
+	"github.com/mmrzaf/snip/internal/selector"
+)
+
+// ProfilesOptions configures snip profiles.
+type ProfilesOptions struct {
+	ConfigPath string
+	JSON       bool
+}
+
+// ProfileSummary describes a profile after overrides are applied.
+type ProfileSummary struct {
+	Name      string   `json:"name"`
+	Default   bool     `json:"default"`
+	Extends   string   `json:"extends,omitempty"`
+	Slices    []string `json:"slices"`
+	MaxChars  int      `json:"max_chars"`
+	TreeDepth int      `json:"tree_depth"`
+}
+
+// Profiles lists configured profiles with their enabled slices and effective limits.
+func Profiles(opts ProfilesOptions) (string, error) {
+	cfg, err := config.Load(opts.ConfigPath)
+	if err != nil {
+		return "", Wrap(ExitUsage, err)
+	}
+	summaries, err := profileSummaries(cfg)
+	if err != nil {
+		return "", Wrap(ExitUsage, err)
+	}
+
+	if opts.JSON {
+		b, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			return "", Wrap(ExitIO, fmt.Errorf("marshal json: %w", err))
+		}
+		return string(b) + "\n", nil
+	}
+
+	width := 0
+	for _, s := range summaries {
+		width = max(width, len(s.Name))
+	}
+	var sb strings.Builder
+	for _, s := range summaries {
+		mark := " "
+		if s.Default {
+			mark = "*"
+		}
+		fmt.Fprintf(&sb, "%s %-*s  slices=[%s] max_chars=%d tree_depth=%d",
+			mark, width, s.Name, strings.Join(s.Slices, ","), s.MaxChars, s.TreeDepth)
+		if s.Extends != "" {
+			fmt.Fprintf(&sb, " extends=%s", s.Extends)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+func profileSummaries(cfg config.Config) ([]ProfileSummary, error) {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]ProfileSummary, 0, len(names))
+	for _, name := range names {
+		eff, err := config.ApplyProfileOverrides(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		enabled, err := selector.EnabledSlices(eff, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ProfileSummary{
+			Name:      name,
+			Default:   name == cfg.DefaultProfile,
+			Extends:   cfg.Profiles[name].Extends,
+			Slices:    selector.EnabledSliceList(enabled, eff),
+			MaxChars:  eff.Budgets.MaxChars,
+			TreeDepth: eff.Render.TreeDepth,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/config"
+)
+
+// tokenShapes are the regexps for the output.pattern tokens whose value changes from run to
+// run. Every other token is matched as the value this run would write.
+var tokenShapes = map[string]string{
+	"ts":      `\d{8}-\d{6}`,
+	"date":    `\d{8}`,
+	"counter": `\d{3,}`,
+	"gitsha":  `[0-9a-f]+`,
+}
+
+// bundleNameRegexp matches the file names writeDefaultOutputFunc can produce from
+// output.pattern with extension ext for profile on branch: {ts}, {date}, {counter} and
+// {gitsha} match their own shape, and the remaining tokens and literals match exactly.
+func bundleNameRegexp(root string, cfg config.Config, profile, branch, ext string) *regexp.Regexp {
+	tokens := outputTokens(root, cfg, profile, "", branch)
+	for k := range tokenShapes {
+		tokens[k] = "\x00" + k + "\x00"
+	}
+	name := expandOutputName(cfg.Output.Pattern, tokens, ext)
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i, part := range strings.Split(name, "\x00") {
+		if i%2 == 1 {
+			sb.WriteString(tokenShapes[part])
+			continue
+		}
+		sb.WriteString(regexp.QuoteMeta(part))
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// pruneBundles removes the oldest bundles in dir beyond the newest keep, counting only files
+// bundleNameRegexp says a run of profile could have written; anything else in dir is left
+// alone. output.latest, the counter and the lock file are never removed.
+// Failures are returned as warnings: the new bundle is already written.
+func pruneBundles(dir, root string, cfg config.Config, profile, branch, ext string, keep int) []Warning {
+	warn := func(path string, err error) Warning {
+		return Warning{Kind: WarnPruneFailed, Path: path, Message: fmt.Sprintf("prune old bundle %s: %v", path, err)}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []Warning{warn(dir, err)}
+	}
+
+	skip := map[string]bool{"counter": true, "lock": true}
+	if cfg.Output.Latest != "" {
+		latest := filepath.Base(cfg.Output.Latest)
+		if ext != ".md" {
+			latest = strings.TrimSuffix(latest, ".md") + ext
+		}
+		skip[latest] = true
+	}
+
+	re := bundleNameRegexp(root, cfg, profile, branch, ext)
+	type bundle struct {
+		name string
+		mod  int64
+	}
+	var bundles []bundle
+	for _, e := range entries {
+		if !e.Type().IsRegular() || skip[e.Name()] || !re.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed by a concurrent prune
+		}
+		bundles = append(bundles, bundle{name: e.Name(), mod: info.ModTime().UnixNano()})
+	}
+	if len(bundles) <= keep {
+		return nil
+	}
+
+	// Newest first; names break ties so the same files go on every platform.
+	sort.Slice(bundles, func(i, j int) bool {
+		if bundles[i].mod != bundles[j].mod {
+			return bundles[i].mod > bundles[j].mod
+		}
+		return bundles[i].name > bundles[j].name
+	})
+	var warnings []Warning
+	for _, b := range bundles[keep:] {
+		path := filepath.Join(dir, b.name)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			warnings = append(warnings, warn(path, err))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/snip/internal/config"
+)
+
+// RunAll runs every profile in the config, in name order, writing each bundle to its own
+// output.pattern file (output.stdout_default is ignored). opts.Profile, Modifiers, Output,
+// Only and Clipboard must be empty, and output.pattern must contain {profile} so the files
+// do not overwrite each other. All runs share one timestamp.
+//
+// A partial profile does not stop the others; the results of every profile that ran are
+// returned (each Plan.Profile names its profile, even for a failed run), and the error is ExitPartial if any of them was partial. Any other error stops
+// the loop and is returned with the results so far.
+func RunAll(ctx context.Context, opts RunOptions) ([]RunResult, error) {
+	switch {
+	case opts.Profile != "" || len(opts.Modifiers) > 0:
+		return nil, Wrap(ExitUsage, fmt.Errorf("all profiles cannot be combined with a profile or modifiers"))
+	case opts.Output != "":
+		return nil, Wrap(ExitUsage, fmt.Errorf("all profiles write one file each and cannot be combined with an explicit output"))
+	case len(opts.Only) > 0:
+		return nil, Wrap(ExitUsage, fmt.Errorf("all profiles cannot be combined with --only"))
+	case opts.Clipboard:
+		return nil, Wrap(ExitUsage, fmt.Errorf("all profiles cannot be combined with --clipboard"))
+	}
+	cfg, err := config.Load(opts.ConfigPath)
+	if err != nil {
+		return nil, Wrap(ExitUsage, err)
+	}
+	if !strings.Contains(cfg.Output.Pattern, "{profile}") {
+		return nil, Wrap(ExitUsage, fmt.Errorf("output.pattern %q must contain {profile} to bundle all profiles", cfg.Output.Pattern))
+	}
+	if opts.Now, err = clock(opts.Now); err != nil {
+		return nil, err
+	}
+	now := opts.Now()
+	opts.Now = func() time.Time { return now }
+	opts.FileOutput = true
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		results []RunResult
+		partial []string
+	)
+	for _, name := range names {
+		o := opts
+		o.Profile = name
+		res, err := Run(ctx, o)
+		res.Plan.Profile = name
+		results = append(results, res)
+		if err != nil {
+			var ae *Error
+			if !errors.As(err, &ae) || ae.ExitCode() != ExitPartial {
+				return results, fmt.Errorf("profile %s: %w", name, err)
+			}
+			partial = append(partial, name)
+		}
+	}
+	if len(partial) > 0 {
+		return results, Wrap(ExitPartial, fmt.Errorf("partial output for profile(s): %s", strings.Join(partial, ", ")))
+	}
+	return results, nil
+}
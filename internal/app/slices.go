@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/snip/internal/config"
+	"github.com/mmrzaf/snip/internal/selector"
+)
+
+// SlicesOptions configures snip slices.
+type SlicesOptions struct {
+	ConfigPath     string
+	RootOverride   string
+	Profile        string
+	Modifiers      []string
+	IncludeHidden  bool
+	FollowSymlinks bool
+	JSON           bool
+	Logger         *slog.Logger
+	Now            func() time.Time
+}
+
+// SliceSummary describes the files an enabled slice matches before budgeting.
+type SliceSummary struct {
+	Name     string   `json:"name"`
+	Priority int      `json:"priority"`
+	Include  []string `json:"include"`
+	Files    int      `json:"files"`
+	Bytes    int64    `json:"bytes"`
+}
+
+// Slices reports, for each slice enabled by the profile and modifiers, how many
+// discovered files it matches and their total size, ordered by priority.
+func Slices(ctx context.Context, opts SlicesOptions) (string, error) {
+	cfg, err := config.Load(opts.ConfigPath)
+	if err != nil {
+		return "", Wrap(ExitUsage, err)
+	}
+	root, err := config.EffectiveRoot(cfg, opts.RootOverride)
+	if err != nil {
+		return "", Wrap(ExitUsage, err)
+	}
+	profile := config.ResolveProfile(cfg, opts.Profile)
+	cfg, err = config.ApplyProfileOverrides(cfg, profile)
+	if err != nil {
+		return "", Wrap(ExitUsage, err)
+	}
+	if opts.FollowSymlinks {
+		cfg.Ignore.FollowSymlinks = true
+	}
+	mods, err := selector.ParseModifiers(opts.Modifiers)
+	if err != nil {
+		return "", Wrap(ExitUsage, err)
+	}
+	enabled, err := selector.EnabledSlices(cfg, profile, mods)
+	if err != nil {
+		return "", Wrap(ExitUsage, err)
+	}
+	cfg.Selector.EnableOrder = selector.EnableOrder(cfg, profile, mods)
+	enabledOrdered := selector.EnabledSliceList(enabled, cfg)
+
+	eng, err := newDiscoveryEngine(ctx, root, cfg, nil)
+	if err != nil {
+		return "", Wrap(ExitIO, err)
+	}
+	discovered, err := eng.Discover()
+	if err != nil {
+		return "", Wrap(ExitIO, err)
+	}
+	selected, err := selector.Select(cfg, enabled, discovered, opts.IncludeHidden)
+	if err != nil {
+		return "", Wrap(ExitUsage, err)
+	}
+
+	byName := map[string]*SliceSummary{}
+	summaries := make([]SliceSummary, len(enabledOrdered))
+	for i, name := range enabledOrdered {
+		sc := cfg.Slices[name]
+		summaries[i] = SliceSummary{Name: name, Priority: sc.Priority, Include: append([]string{}, sc.Include...)}
+		byName[name] = &summaries[i]
+	}
+	// A file counts toward every slice it belongs to, not only its primary slice.
+	for _, f := range selected.Included {
+		for _, s := range f.Slices {
+			if sum := byName[s]; sum != nil {
+				sum.Files++
+				sum.Bytes += f.SizeBytes
+			}
+		}
+	}
+
+	if opts.JSON {
+		b, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			return "", Wrap(ExitIO, fmt.Errorf("marshal json: %w", err))
+		}
+		return string(b) + "\n", nil
+	}
+
+	width := 0
+	for _, s := range summaries {
+		width = max(width, len(s.Name))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "profile: %s\n", profile)
+	for _, s := range summaries {
+		fmt.Fprintf(&sb, "  %-*s  priority=%d files=%d bytes=%d include=[%s]\n",
+			width, s.Name, s.Priority, s.Files, s.Bytes, strings.Join(s.Include, ", "))
+	}
+	return sb.String(), nil
+}
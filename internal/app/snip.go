@@ -1,15 +1,22 @@
 package app
 
 import (
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"os/user"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mmrzaf/snip/internal/budget"
+	"github.com/mmrzaf/snip/internal/clipboard"
 	"github.com/mmrzaf/snip/internal/config"
 	"github.com/mmrzaf/snip/internal/discovery"
 	"github.com/mmrzaf/snip/internal/gitinfo"
@@ -20,26 +27,150 @@ import (
 
 // RunOptions configures snip run.
 type RunOptions struct {
-	ConfigPath    string
-	RootOverride  string
-	Profile       string
-	Modifiers     []string
-	Output        string // "-" for stdout
-	MaxChars      int
-	Format        string
-	NoTree        bool
-	NoManifest    bool
-	TreeDepth     int
-	IncludeHidden bool
-	Logger        *slog.Logger
-	Now           func() time.Time
+	ConfigPath     string
+	RootOverride   string
+	Profile        string
+	Modifiers      []string
+	Output         string // "-" for stdout
+	MaxChars       int
+	MaxTokens      int
+	Format         string
+	NoTree         bool
+	NoManifest     bool
+	TreeDepth      int
+	LineNumbers    bool
+	IncludeHidden  bool
+	FollowSymlinks bool
+	Since          string   // git ref; only bundle files changed between its merge base and HEAD
+	Exclude        []string // ad-hoc globs excluded from every enabled slice
+	Include        []string // ad-hoc globs included even when no enabled slice matches
+	Only           []string // replaces slices and profiles with one slice of these globs; needs no Profile
+	Gzip           bool     // gzip the written bundle (output.compress: gzip)
+	// OutputDir replaces output.dir for a default-output run, keeping output.pattern and
+	// output.latest; a relative path is relative to the working directory. It is ignored
+	// when the bundle goes to Output or stdout.
+	OutputDir string
+	// PerFileMaxLines and PerFileMaxBytes override budgets.per_file_max_lines and
+	// budgets.per_file_max_bytes when > 0, like MaxChars does for budgets.max_chars.
+	PerFileMaxLines int
+	PerFileMaxBytes int
+	// ContextLines overrides contains_context for every slice with a contains_regex, so
+	// their files are cut down to the matches and this many lines around each.
+	ContextLines int
+	// UseGitignore, when non-nil, overrides ignore.use_gitignore for this run.
+	UseGitignore *bool
+	// Clipboard copies the bundle to the OS clipboard. Without an explicit Output it
+	// replaces the file write; with one (including "-") the bundle goes to both.
+	Clipboard bool
+	// NoWrite builds the bundle without writing it anywhere; the caller reads
+	// RunResult.Content and RunResult.Plan instead.
+	NoWrite bool
+	// FileOutput writes the output.pattern file even when output.stdout_default is set
+	// (RunAll uses it so every profile gets its own file).
+	FileOutput bool
+	// Redact masks sensitive.redact_patterns matches in file content (see
+	// budget.Builder.RedactPatterns); it needs at least one pattern.
+	Redact bool
+	// NoLock skips the advisory lock that serializes counter and output.latest updates
+	// between concurrent runs writing to the same output directory.
+	NoLock bool
+	// KeepLast overrides output.keep_last when > 0.
+	KeepLast int
+	// SuppressWarnings leaves RunResult.Warnings empty so callers print nothing; the plan
+	// still records every drop, and a partial run still returns ExitPartial.
+	SuppressWarnings bool
+	// AllowPartial makes a partial run succeed: Run returns a nil error instead of
+	// ExitPartial, and RunResult.Partial and the warnings still report it.
+	AllowPartial bool
+	// ReuseOutput replaces the output.pattern name of a default-output run with this path
+	// (an earlier RunResult.OutputPath); output.latest is still refreshed. Watch uses it so
+	// every rebuild rewrites the same bundle.
+	ReuseOutput string
+	// DryRun builds the bundle and resolves where it would go (RunResult.DryRun) without
+	// writing, copying, pruning or advancing the counter.
+	DryRun bool
+	// Append adds the bundle's file blocks to the existing markdown bundle at this path
+	// instead of writing a new bundle: no second header, tree or manifest, numbering continues
+	// after the bundle's files, and files it already holds (by path) are skipped
+	// (RunResult.Skipped). Budgets apply to the appended blocks.
+	Append string
+	// Progress, when set, is told how discovery and file reads advance (see ProgressFunc).
+	Progress ProgressFunc
+	Logger   *slog.Logger
+	Now      func() time.Time
 }
 
+// Progress phases reported to a ProgressFunc.
+const (
+	PhaseDiscover = "discovering"
+	PhaseRead     = "reading"
+)
+
+// ProgressFunc receives progress for the slow phases of a run: PhaseDiscover with the files
+// walked so far (total is 0, as it is not known up front), then PhaseRead with the selected
+// files read so far out of total. It is called often and should return quickly.
+type ProgressFunc func(phase string, done, total int)
+
 // RunResult is the result of snip run.
 type RunResult struct {
 	OutputPath string
 	Partial    bool
 	HardCut    bool
+	// Content is the rendered bundle. NDJSON is streamed when written, so for that
+	// format Content is only populated with NoWrite.
+	Content string
+	// Plan is the final plan after budget enforcement, including dropped files.
+	Plan budget.Plan
+	// Warnings are the notable drops in Plan, for the caller to report. They are set
+	// even when writing the bundle fails.
+	Warnings []Warning
+	// DryRun is where the bundle would have been written; only set with RunOptions.DryRun.
+	DryRun *OutputPlan
+	// Skipped lists the selected files RunOptions.Append left out because the bundle
+	// already had them.
+	Skipped []string
+	// OutputPaths are the per-slice bundles written with output.split: per_slice, in enabled
+	// slice order; OutputPath is then empty.
+	OutputPaths []string
+}
+
+// OutputPlan is the destination a run resolved without writing (run --dry-run).
+type OutputPlan struct {
+	// Path is the bundle file, "-" for stdout, or "" when the bundle only goes to the clipboard.
+	Path string
+	// Exists reports that Path is an existing file the run would replace.
+	Exists bool
+	// Latest is the output.latest copy that would be refreshed ("" when unset or when the
+	// bundle does not go to a default-output file), and LatestExists whether it exists.
+	Latest       string
+	LatestExists bool
+	// Counter is the {counter} value the run would take (0 without the token). It is read,
+	// not incremented.
+	Counter   int
+	Clipboard bool
+	// Bytes is the size of the rendered bundle before compression.
+	Bytes int
+	// Split holds one plan per slice bundle with output.split: per_slice (Path is then
+	// empty); each sets Path, Exists, Latest, LatestExists and Bytes.
+	Split []OutputPlan
+}
+
+// Warning kinds, one per drop that is worth reporting.
+const (
+	WarnSliceDropped        = "slice_dropped"
+	WarnUnreadable          = "unreadable"
+	WarnInvalidUTF8         = "invalid_utf8"
+	WarnSliceBudgetExceeded = "slice_budget_exceeded"
+	WarnWholeFileTooLarge   = "whole_file_too_large"
+	WarnBudgetExceeded      = "budget_exceeded"
+	WarnPruneFailed         = "prune_failed"
+)
+
+// Warning is a non-fatal problem found while building a bundle.
+type Warning struct {
+	Kind    string // one of the Warn* constants
+	Path    string // the file, or the slice for WarnSliceDropped
+	Message string // human-readable, as printed after "warning: "
 }
 
 // Run executes a snapshot run and writes output.
@@ -48,24 +179,62 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 	if log == nil {
 		log = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
 	}
-	if opts.Now == nil {
-		opts.Now = time.Now
-	}
-	if opts.Format != "" && opts.Format != "md" {
-		return RunResult{}, Wrap(ExitUsage, fmt.Errorf("unsupported format %q", opts.Format))
+	var err error
+	if opts.Now, err = clock(opts.Now); err != nil {
+		return RunResult{}, err
 	}
 	cfg, err := config.Load(opts.ConfigPath)
 	if err != nil {
 		return RunResult{}, Wrap(ExitUsage, err)
 	}
+	format := cfg.Render.Format
+	if opts.Format != "" {
+		format = opts.Format
+	}
+	if format != "md" && format != "ndjson" && format != "plain" && format != "html" {
+		return RunResult{}, Wrap(ExitUsage, fmt.Errorf("unsupported format %q", format))
+	}
+	if opts.Append != "" {
+		switch {
+		case format != "md":
+			return RunResult{}, Wrap(ExitUsage, fmt.Errorf("--append needs the md format, not %q", format))
+		case opts.Output != "" || opts.Clipboard || opts.DryRun || opts.ReuseOutput != "":
+			return RunResult{}, Wrap(ExitUsage, fmt.Errorf("--append cannot be combined with --out, --stdout, --clipboard or --dry-run"))
+		case opts.Gzip || cfg.Output.Compress == "gzip":
+			return RunResult{}, Wrap(ExitUsage, fmt.Errorf("--append cannot write a gzip bundle"))
+		}
+	}
 	root, err := config.EffectiveRoot(cfg, opts.RootOverride)
 	if err != nil {
 		return RunResult{}, Wrap(ExitUsage, err)
 	}
+	if cfg, err = applyOnly(cfg, &opts); err != nil {
+		return RunResult{}, err
+	}
 	cfg, err = config.ApplyProfileOverrides(cfg, opts.Profile)
 	if err != nil {
 		return RunResult{}, Wrap(ExitUsage, err)
 	}
+	if opts.FollowSymlinks {
+		cfg.Ignore.FollowSymlinks = true
+	}
+	if opts.UseGitignore != nil {
+		cfg.Ignore.UseGitignore = *opts.UseGitignore
+	}
+	cfg.Output.NoLock = opts.NoLock
+	if opts.KeepLast < 0 {
+		return RunResult{}, Wrap(ExitUsage, fmt.Errorf("--keep-last must be >= 0"))
+	}
+	if opts.KeepLast > 0 {
+		cfg.Output.KeepLast = opts.KeepLast
+	}
+	if opts.Redact && len(cfg.Sensitive.RedactPatterns) == 0 {
+		return RunResult{}, Wrap(ExitUsage, fmt.Errorf("--redact needs at least one sensitive.redact_patterns entry"))
+	}
+	if cfg, err = applyContextLines(cfg, opts.ContextLines); err != nil {
+		return RunResult{}, err
+	}
+	cfg.Selector.Exclude, cfg.Selector.Include = opts.Exclude, opts.Include
 
 	mods, err := selector.ParseModifiers(opts.Modifiers)
 	if err != nil {
@@ -75,16 +244,10 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 	if err != nil {
 		return RunResult{}, Wrap(ExitUsage, err)
 	}
+	cfg.Selector.EnableOrder = selector.EnableOrder(cfg, opts.Profile, mods)
 	enabledOrdered := selector.EnabledSliceList(enabled, cfg)
 
-	limits := budget.Limits{
-		MaxChars:        cfg.Budgets.MaxChars,
-		PerFileMaxLines: cfg.Budgets.PerFileMaxLines,
-		PerFileMaxBytes: cfg.Budgets.PerFileMaxBytes,
-	}
-	if opts.MaxChars > 0 {
-		limits.MaxChars = opts.MaxChars
-	}
+	limits := limitsFromConfig(cfg, opts.MaxChars, opts.MaxTokens, opts.PerFileMaxLines, opts.PerFileMaxBytes)
 
 	renderCfg := cfg.Render
 	if opts.NoTree {
@@ -96,13 +259,20 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 	if opts.TreeDepth > 0 {
 		renderCfg.TreeDepth = opts.TreeDepth
 	}
+	if opts.LineNumbers {
+		renderCfg.LineNumbers = true
+	}
 
 	slicePriorities := map[string]int{}
 	for _, s := range enabled {
 		slicePriorities[s] = cfg.Slices[s].Priority
 	}
 
-	eng, err := discovery.NewEngine(root, cfg.Ignore.UseGitignore, cfg.Ignore.Always, cfg.Sensitive.ExcludeGlobs, cfg.Ignore.BinaryExtensions)
+	var progress func(int)
+	if opts.Progress != nil {
+		progress = func(n int) { opts.Progress(PhaseDiscover, n, 0) }
+	}
+	eng, err := newDiscoveryEngine(ctx, root, cfg, progress)
 	if err != nil {
 		return RunResult{}, Wrap(ExitIO, err)
 	}
@@ -116,138 +286,514 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 	if err != nil {
 		return RunResult{}, Wrap(ExitUsage, err)
 	}
+	if opts.Since != "" {
+		if selected, err = changedSince(ctx, root, opts.Since, selected); err != nil {
+			return RunResult{}, err
+		}
+	}
+	var (
+		target  appendTarget
+		skipped []string
+	)
+	if opts.Append != "" {
+		if target, err = readAppendTarget(opts.Append, renderCfg.FileBlock.Header); err != nil {
+			return RunResult{}, err
+		}
+		kept := selected.Included[:0]
+		for _, f := range selected.Included {
+			if target.paths[f.RelPath] {
+				skipped = append(skipped, f.RelPath)
+				continue
+			}
+			kept = append(kept, f)
+		}
+		selected.Included = kept
+		log.Debug("skipped files already in the bundle", "bundle", target.path, "count", len(skipped))
+	}
 	log.Debug("selected", "included", len(selected.Included), "dropped", len(selected.Dropped))
 
-	b := &budget.Builder{Limits: limits}
+	b := &budget.Builder{Limits: limits, SliceLimits: sliceLimitsFromConfig(cfg), HashContent: renderCfg.IncludeHashes, StripPatterns: stripPatternsFromConfig(cfg)}
+	if opts.Redact {
+		b.RedactPatterns = compilePatterns(cfg.Sensitive.RedactPatterns)
+	}
+	if format == "md" && renderCfg.IncludeHashes && opts.Append == "" {
+		// A hard-cut bundle is sealed after the cut; keep the seal line within the budget.
+		b.HardCutReserve = render.SealReserve(renderCfg.Newline)
+	}
+	if opts.Progress != nil {
+		b.Progress = func(done, total int) { opts.Progress(PhaseRead, done, total) }
+	}
 	plan, err := b.BuildPlan(ctx, opts.Profile, enabledOrdered, selected)
 	if err != nil {
 		return RunResult{}, Wrap(ExitIO, err)
 	}
 
-	sha, err := gitinfo.ShortSHA(ctx, root)
-	if err != nil || sha == "" {
-		sha = "000000"
+	// Plain bundles and appended blocks have no header to show git_dirty in.
+	sha, dirty := gitState(ctx, root, format != "plain" && opts.Append == "")
+
+	rndr := newRenderer(renderCfg, cfg, discovered)
+	rndr.Manifest.Commits = fileCommits(ctx, root, renderCfg, plan)
+
+	now := opts.Now()
+	info := bundleInfo(cfg, root, opts.RootOverride, opts.Profile, enabledOrdered, sha, dirty, now)
+
+	renderFn := rendererFor(rndr, format, info)
+	if opts.Append != "" {
+		renderFn = func(p budget.Plan) (string, error) { return rndr.RenderContinuation(p, target.files+1) }
+	}
+	planFinal, rendered, err := b.EnforceGlobalBudget(ctx, plan, slicePriorities, renderFn)
+	if err != nil {
+		return RunResult{}, Wrap(ExitIO, err)
+	}
+	if planFinal.HardCut && format == "md" && renderCfg.IncludeHashes && opts.Append == "" {
+		// The cut removed the checksum line; seal what is left.
+		rendered = render.SealMarkdown(rendered, renderCfg.Newline)
 	}
 
-	rndr := render.Renderer{
-		Newline:         renderCfg.Newline,
-		CodeFences:      renderCfg.CodeFences,
-		IncludeTree:     renderCfg.IncludeTree,
-		TreeDepth:       renderCfg.TreeDepth,
-		TreePaths:       treePathsFromDiscovery(discovered),
-		SlicePatterns:   slicePatternsFromConfig(cfg),
-		IncludeManifest: renderCfg.IncludeManifest,
-		Manifest: render.ManifestOptions{
-			GroupBySlice:           renderCfg.Manifest.GroupBySlice,
-			IncludeLineCounts:      renderCfg.Manifest.IncludeLineCounts,
-			IncludeByteCounts:      renderCfg.Manifest.IncludeByteCounts,
-			IncludeTruncationNotes: renderCfg.Manifest.IncludeTruncationNotes,
-			IncludeUnreadableNotes: renderCfg.Manifest.IncludeUnreadableNotes,
-		},
-		FileBlock: render.FileBlockOptions{
-			Header: renderCfg.FileBlock.Header,
-			Footer: renderCfg.FileBlock.Footer,
-		},
+	// emit writes the final bundle. NDJSON is streamed from the finalized plan rather than
+	// the measured string; a hard cut would split a JSON line (or leave HTML tags unclosed),
+	// so trailing files are dropped instead.
+	emit := func(w io.Writer) error {
+		_, err := io.WriteString(w, rendered)
+		return err
+	}
+	ext := ".md"
+	switch format {
+	case "plain":
+		ext = ".txt"
+	case "html":
+		ext = ".html"
+		if planFinal.HardCut {
+			planFinal, err = fitWholeFiles(planFinal, limits, renderedSize(renderFn, limits), rndr.Manifest.GroupBySlice, rndr.FileOrder)
+			if err != nil {
+				return RunResult{}, Wrap(ExitIO, err)
+			}
+			if rendered, err = renderFn(planFinal); err != nil {
+				return RunResult{}, Wrap(ExitIO, err)
+			}
+		}
+	}
+	if format == "ndjson" {
+		if planFinal.HardCut {
+			size, err := ndjsonSize(rndr, info, planFinal, limits)
+			if err != nil {
+				return RunResult{}, Wrap(ExitIO, err)
+			}
+			if planFinal, err = fitWholeFiles(planFinal, limits, size, rndr.Manifest.GroupBySlice, rndr.FileOrder); err != nil {
+				return RunResult{}, Wrap(ExitIO, err)
+			}
+		}
+		rendered = ""
+		emit = func(w io.Writer) error { return rndr.RenderNDJSON(w, info, planFinal) }
+		ext = ".ndjson"
 	}
 
-	rootLabel := cfg.Root
-	if opts.RootOverride != "" {
-		rootLabel = opts.RootOverride
+	// With output.split the budget holds for the combined bundle; the slice bundles are cut
+	// from it, so a hard cut drops whole files instead.
+	splitting := splitApplies(cfg, opts)
+	var split []sliceBundle
+	if splitting {
+		if planFinal.HardCut && (format == "md" || format == "plain") {
+			if planFinal, err = fitWholeFiles(planFinal, limits, renderedSize(renderFn, limits), rndr.Manifest.GroupBySlice, rndr.FileOrder); err != nil {
+				return RunResult{}, Wrap(ExitIO, err)
+			}
+			if rendered, err = renderFn(planFinal); err != nil {
+				return RunResult{}, Wrap(ExitIO, err)
+			}
+		}
+		if split, err = splitBySlice(planFinal, enabledOrdered, rndr, format, info); err != nil {
+			return RunResult{}, Wrap(ExitIO, err)
+		}
 	}
-	if rootLabel == "" {
-		rootLabel = "."
+
+	res := RunResult{Partial: planFinal.Partial, HardCut: planFinal.HardCut, Content: rendered, Plan: planFinal, Skipped: skipped}
+	if !opts.SuppressWarnings {
+		res.Warnings = planWarnings(planFinal)
+	}
+	// fail reports a write error without dropping the warnings.
+	fail := func(code int, err error) (RunResult, error) {
+		return RunResult{Warnings: res.Warnings}, Wrap(code, err)
+	}
+	finish := func() (RunResult, error) {
+		if res.Partial && !opts.AllowPartial {
+			return res, Wrap(ExitPartial, fmt.Errorf("partial output"))
+		}
+		return res, nil
 	}
 
-	now := opts.Now().In(time.Local)
-	info := render.BundleInfo{
-		Repo:        filepath.Base(root),
-		Root:        rootLabel,
-		Profile:     opts.Profile,
-		Enabled:     enabledOrdered,
-		GitSHA:      sha,
-		Timestamp:   now,
-		SnipVersion: Version,
+	if opts.NoWrite {
+		if format == "ndjson" {
+			var sb strings.Builder
+			if err := emit(&sb); err != nil {
+				return fail(ExitIO, err)
+			}
+			res.Content = sb.String()
+		}
+		return finish()
 	}
 
-	renderFn := func(p budget.Plan) (string, error) { return rndr.RenderMarkdown(info, p) }
-	planFinal, rendered, err := b.EnforceGlobalBudget(ctx, plan, slicePriorities, renderFn)
-	if err != nil {
-		return RunResult{}, Wrap(ExitIO, err)
+	if opts.DryRun {
+		out, err := planOutput(ctx, root, cfg, opts, sha, now, ext, format, rendered, emit, splitting, split)
+		if err != nil {
+			return fail(ExitIO, err)
+		}
+		res.DryRun = &out
+		return finish()
+	}
+
+	if opts.Append != "" {
+		if len(planFinal.Included) > 0 {
+			if err := target.write(rendered, renderCfg.Newline); err != nil {
+				return fail(ExitIO, err)
+			}
+		}
+		res.OutputPath = target.path
+		return finish()
+	}
+
+	if opts.Clipboard {
+		text := rendered
+		if format == "ndjson" {
+			var sb strings.Builder
+			if err := emit(&sb); err != nil {
+				return fail(ExitIO, err)
+			}
+			text = sb.String()
+		}
+		if err := clipboard.Write(ctx, text); err != nil {
+			return fail(ExitIO, fmt.Errorf("copy to clipboard: %w", err))
+		}
+		if opts.Output == "" {
+			return finish()
+		}
 	}
 
-	warnPartial(os.Stderr, planFinal)
+	outputPath := opts.Output
+	if opts.Gzip || cfg.Output.Compress == "gzip" {
+		emit = gzipWriter(emit)
+		for i := range split {
+			split[i].emit = gzipWriter(split[i].emit)
+		}
+		ext += ".gz"
+		if outputPath != "" && outputPath != "-" && !strings.HasSuffix(outputPath, ".gz") {
+			outputPath += ".gz"
+		}
+	}
 
-	stdout := opts.Output == "-" || (opts.Output == "" && cfg.Output.StdoutDefault)
+	stdout := outputPath == "-" || (outputPath == "" && cfg.Output.StdoutDefault && !opts.FileOutput)
 	if stdout {
-		if _, err := fmt.Fprint(os.Stdout, rendered); err != nil {
-			return RunResult{}, Wrap(ExitIO, fmt.Errorf("write stdout: %w", err))
+		if err := emit(os.Stdout); err != nil {
+			return fail(ExitIO, fmt.Errorf("write stdout: %w", err))
 		}
-		res := RunResult{OutputPath: "-", Partial: planFinal.Partial, HardCut: planFinal.HardCut}
-		if res.Partial {
-			return res, Wrap(ExitPartial, fmt.Errorf("partial output"))
+		res.OutputPath = "-"
+		return finish()
+	}
+
+	if outputPath != "" {
+		outPath, err := writeExplicitOutputFunc(outputPath, emit)
+		if err != nil {
+			return fail(ExitIO, err)
 		}
-		return res, nil
+		res.OutputPath = outPath
+		return finish()
 	}
 
-	if opts.Output != "" {
-		outPath, err := writeExplicitOutput(opts.Output, rendered)
+	if opts.ReuseOutput != "" {
+		unlock, err := lockOutputDir(filepath.Dir(opts.ReuseOutput), cfg)
 		if err != nil {
-			return RunResult{}, Wrap(ExitIO, err)
+			return fail(ExitIO, err)
 		}
-		res := RunResult{OutputPath: outPath, Partial: planFinal.Partial, HardCut: planFinal.HardCut}
-		if res.Partial {
-			return res, Wrap(ExitPartial, fmt.Errorf("partial output"))
+		err = writeWithLatest(opts.ReuseOutput, cfg, ext, emit)
+		unlock()
+		if err != nil {
+			return fail(ExitIO, err)
 		}
-		return res, nil
+		res.OutputPath = opts.ReuseOutput
+		return finish()
 	}
 
-	outPath, err := writeDefaultOutput(root, cfg, opts.Profile, sha, now, rendered)
+	var branch string
+	if strings.Contains(cfg.Output.Pattern, "{branch}") {
+		// Without git (or on a detached HEAD) the token is empty and collapses away.
+		branch, _ = gitinfo.Branch(ctx, root)
+	}
+	if opts.OutputDir != "" {
+		dir, err := filepath.Abs(opts.OutputDir)
+		if err != nil {
+			return fail(ExitUsage, fmt.Errorf("resolve --out-dir: %w", err))
+		}
+		cfg.Output.Dir = dir
+	}
+	if splitting {
+		paths, err := writeSplitOutput(root, cfg, opts.Profile, sha, branch, now, ext, split)
+		res.OutputPaths = paths
+		if err != nil {
+			return RunResult{Warnings: res.Warnings, OutputPaths: paths}, Wrap(ExitIO, err)
+		}
+		if cfg.Output.KeepLast > 0 && len(paths) > 0 {
+			for _, b := range split {
+				if ws := pruneBundles(filepath.Dir(paths[0]), root, sliceOutputConfig(cfg, b.slice), opts.Profile, branch, ext, cfg.Output.KeepLast); !opts.SuppressWarnings {
+					res.Warnings = append(res.Warnings, ws...)
+				}
+			}
+		}
+		return finish()
+	}
+	outPath, err := writeDefaultOutputFunc(root, cfg, opts.Profile, sha, branch, now, ext, emit)
 	if err != nil {
-		return RunResult{}, Wrap(ExitIO, err)
+		return fail(ExitIO, err)
 	}
-	res := RunResult{OutputPath: outPath, Partial: planFinal.Partial, HardCut: planFinal.HardCut}
-	if res.Partial {
-		return res, Wrap(ExitPartial, fmt.Errorf("partial output"))
+	res.OutputPath = outPath
+	if cfg.Output.KeepLast > 0 {
+		if ws := pruneBundles(filepath.Dir(outPath), root, cfg, opts.Profile, branch, ext, cfg.Output.KeepLast); !opts.SuppressWarnings {
+			res.Warnings = append(res.Warnings, ws...)
+		}
 	}
-	return res, nil
+	return finish()
 }
 
-func warnPartial(w *os.File, plan budget.Plan) {
-	warn := func(msg string) {
-		_, _ = fmt.Fprintln(w, "warning:", msg)
+// planOutput resolves the destination Run would write to, following the same precedence, and
+// touches nothing: the counter is peeked and the output directory need not exist.
+func planOutput(ctx context.Context, root string, cfg config.Config, opts RunOptions, sha string, now time.Time, ext, format, rendered string, emit func(io.Writer) error, splitting bool, split []sliceBundle) (OutputPlan, error) {
+	plan := OutputPlan{Clipboard: opts.Clipboard, Bytes: len(rendered)}
+	if format == "ndjson" {
+		var sb strings.Builder
+		if err := emit(&sb); err != nil {
+			return OutputPlan{}, err
+		}
+		plan.Bytes = sb.Len()
+	}
+	if opts.Clipboard && opts.Output == "" {
+		return plan, nil
+	}
+
+	outputPath := opts.Output
+	if (opts.Gzip || cfg.Output.Compress == "gzip") && outputPath != "" && outputPath != "-" && !strings.HasSuffix(outputPath, ".gz") {
+		outputPath += ".gz"
+	}
+	if opts.Gzip || cfg.Output.Compress == "gzip" {
+		ext += ".gz"
+	}
+	switch {
+	case outputPath == "-" || (outputPath == "" && cfg.Output.StdoutDefault && !opts.FileOutput):
+		plan.Path = "-"
+		return plan, nil
+	case outputPath != "":
+		abs, err := filepath.Abs(outputPath)
+		if err != nil {
+			return OutputPlan{}, fmt.Errorf("resolve output path: %w", err)
+		}
+		plan.Path = abs
+	case opts.ReuseOutput != "":
+		plan.Path = opts.ReuseOutput
+	default:
+		var branch string
+		if strings.Contains(cfg.Output.Pattern, "{branch}") {
+			branch, _ = gitinfo.Branch(ctx, root)
+		}
+		if opts.OutputDir != "" {
+			dir, err := filepath.Abs(opts.OutputDir)
+			if err != nil {
+				return OutputPlan{}, fmt.Errorf("resolve --out-dir: %w", err)
+			}
+			cfg.Output.Dir = dir
+		}
+		dir := outputDir(root, cfg)
+		if strings.Contains(cfg.Output.Pattern, "{counter}") {
+			c, err := util.PeekCounter(dir)
+			if err != nil {
+				return OutputPlan{}, fmt.Errorf("counter: %w", err)
+			}
+			plan.Counter = c
+		}
+		if splitting {
+			for _, b := range split {
+				sc := sliceOutputConfig(cfg, b.slice)
+				sp, err := planSliceOutput(b, filepath.Join(dir, outputFileName(root, sc, opts.Profile, sha, branch, now, ext, plan.Counter)), sc, ext)
+				if err != nil {
+					return OutputPlan{}, err
+				}
+				plan.Split = append(plan.Split, sp)
+			}
+			return plan, nil
+		}
+		plan.Path = filepath.Join(dir, outputFileName(root, cfg, opts.Profile, sha, branch, now, ext, plan.Counter))
+	}
+	plan.Exists = fileExists(plan.Path)
+	if outputPath == "" {
+		if plan.Latest = latestPath(plan.Path, cfg, ext); plan.Latest != "" {
+			plan.LatestExists = fileExists(plan.Latest)
+		}
+	}
+	return plan, nil
+}
+
+// planSliceOutput is the OutputPlan of one output.split bundle written to path.
+func planSliceOutput(b sliceBundle, path string, cfg config.Config, ext string) (OutputPlan, error) {
+	plan := OutputPlan{Path: path, Exists: fileExists(path), Bytes: len(b.rendered)}
+	if b.rendered == "" {
+		var sb strings.Builder
+		if err := b.emit(&sb); err != nil {
+			return OutputPlan{}, err
+		}
+		plan.Bytes = sb.Len()
+	}
+	if plan.Latest = latestPath(path, cfg, ext); plan.Latest != "" {
+		plan.LatestExists = fileExists(plan.Latest)
+	}
+	return plan, nil
+}
+
+func fileExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.Mode().IsRegular()
+}
+
+// clock returns now, or when it is nil a clock for the bundle timestamp and {ts} tokens: the
+// local time, or SOURCE_DATE_EPOCH (in UTC) when that is set so reproducible builds get
+// identical bundles.
+func clock(now func() time.Time) (func() time.Time, error) {
+	if now != nil {
+		return now, nil
+	}
+	if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, Wrap(ExitUsage, fmt.Errorf("SOURCE_DATE_EPOCH must be a Unix timestamp in seconds, got %q", v))
+		}
+		fixed := time.Unix(sec, 0).UTC()
+		return func() time.Time { return fixed }, nil
+	}
+	return func() time.Time { return time.Now().In(time.Local) }, nil
+}
+
+// rendererFor returns the function that renders a plan in format (md, ndjson, plain or html).
+func rendererFor(rndr render.Renderer, format string, info render.BundleInfo) func(budget.Plan) (string, error) {
+	switch format {
+	case "ndjson":
+		return func(p budget.Plan) (string, error) { return rndr.RenderNDJSONString(info, p) }
+	case "plain":
+		return func(p budget.Plan) (string, error) { return rndr.RenderPlain(p) }
+	case "html":
+		return func(p budget.Plan) (string, error) { return rndr.RenderHTML(info, p) }
+	default:
+		return func(p budget.Plan) (string, error) { return rndr.RenderMarkdown(info, p) }
+	}
+}
+
+// gzipWriter wraps write so its output is gzip-compressed.
+func gzipWriter(write func(io.Writer) error) func(io.Writer) error {
+	return func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		if err := write(gz); err != nil {
+			_ = gz.Close()
+			return err
+		}
+		return gz.Close()
+	}
+}
+
+// planWarnings lists the drops in plan worth reporting, slices first.
+func planWarnings(plan budget.Plan) []Warning {
+	var out []Warning
+	warn := func(kind, path, msg string) {
+		out = append(out, Warning{Kind: kind, Path: path, Message: msg})
 	}
 	for _, s := range plan.DroppedSlices {
-		warn(fmt.Sprintf("slice dropped due to budget: %s", s))
+		warn(WarnSliceDropped, s, fmt.Sprintf("slice dropped due to budget: %s", s))
 	}
 	for _, d := range plan.Dropped {
 		switch d.Reason {
 		case "unreadable":
-			warn(fmt.Sprintf("unreadable file excluded: %s", d.RelPath))
+			warn(WarnUnreadable, d.RelPath, fmt.Sprintf("unreadable file excluded: %s", d.RelPath))
 		case "invalid_utf8":
-			warn(fmt.Sprintf("invalid UTF-8 file excluded: %s", d.RelPath))
+			warn(WarnInvalidUTF8, d.RelPath, fmt.Sprintf("invalid UTF-8 file excluded: %s", d.RelPath))
+		case "slice_budget_exceeded":
+			warn(WarnSliceBudgetExceeded, d.RelPath, fmt.Sprintf("file dropped due to slice budget: %s", d.RelPath))
+		case "whole_file_too_large":
+			warn(WarnWholeFileTooLarge, d.RelPath, fmt.Sprintf("file dropped instead of truncated (whole_files_only): %s", d.RelPath))
 		case "budget_exceeded":
-			// Files are already implied by slice warnings; keep noise low.
+			// Files of dropped slices are already implied by slice warnings; keep noise low.
+			if d.Detail != budget.DetailSliceDropped {
+				warn(WarnBudgetExceeded, d.RelPath, fmt.Sprintf("file dropped due to budget (%s): %s", d.Detail, d.RelPath))
+			}
 		}
 	}
+	return out
 }
 
 // ListOptions configures snip ls.
 type ListOptions struct {
-	ConfigPath    string
-	RootOverride  string
-	Profile       string
-	Modifiers     []string
-	MaxChars      int
-	IncludeHidden bool
-	Verbose       bool
-	Logger        *slog.Logger
-	Now           func() time.Time
+	ConfigPath     string
+	RootOverride   string
+	Profile        string
+	Modifiers      []string
+	MaxChars       int
+	MaxTokens      int
+	IncludeHidden  bool
+	FollowSymlinks bool
+	Since          string   // see RunOptions.Since
+	Exclude        []string // see RunOptions.Exclude
+	Include        []string // see RunOptions.Include
+	Verbose        bool
+	Logger         *slog.Logger
+	Now            func() time.Time
+	// PerFileMaxLines, PerFileMaxBytes and UseGitignore: see RunOptions.
+	PerFileMaxLines int
+	PerFileMaxBytes int
+	UseGitignore    *bool
+	ContextLines    int // see RunOptions.ContextLines
+	// Format is "text" (the default listing) or "json" (a ListReport).
+	Format string
+	// PrintPaths prints only the included relative paths, one per line, or NUL-terminated
+	// with Null (for xargs -0). It cannot be combined with Format "json".
+	PrintPaths bool
+	Null       bool
+}
+
+// ListReport is the finalized plan as printed by `snip ls --format json`.
+type ListReport struct {
+	Profile       string        `json:"profile"`
+	EnabledSlices []string      `json:"enabled_slices"`
+	Included      []ListFile    `json:"included"`
+	DroppedSlices []string      `json:"dropped_slices"`
+	Dropped       []ListDropped `json:"dropped"`
+	Partial       bool          `json:"partial"`
+}
+
+// ListFile is an included file in a ListReport, in bundle order.
+type ListFile struct {
+	Path      string   `json:"path"`
+	Slices    []string `json:"slices"`
+	Primary   string   `json:"primary"`
+	Truncated bool     `json:"truncated"`
+}
+
+// ListDropped is a selected file left out of the bundle, with the reason.
+type ListDropped struct {
+	Path    string   `json:"path"`
+	Slices  []string `json:"slices"`
+	Primary string   `json:"primary,omitempty"`
+	Reason  string   `json:"reason"`
+	Detail  string   `json:"detail,omitempty"`
 }
 
 // List executes the selection and budget enforcement and prints a dry-run listing.
 func List(ctx context.Context, opts ListOptions) (string, bool, error) {
-	if opts.Now == nil {
-		opts.Now = time.Now
+	var err error
+	if opts.Now, err = clock(opts.Now); err != nil {
+		return "", false, err
+	}
+	switch opts.Format {
+	case "", "text", "json":
+	default:
+		return "", false, Wrap(ExitUsage, fmt.Errorf("unknown ls format %q (want text or json)", opts.Format))
+	}
+	if opts.PrintPaths && opts.Format == "json" {
+		return "", false, Wrap(ExitUsage, fmt.Errorf("--print-paths cannot be combined with --format json"))
+	}
+	if opts.Null && !opts.PrintPaths {
+		return "", false, Wrap(ExitUsage, fmt.Errorf("--null requires --print-paths"))
 	}
 	log := opts.Logger
 	if log == nil {
@@ -266,6 +812,16 @@ func List(ctx context.Context, opts ListOptions) (string, bool, error) {
 	if err != nil {
 		return "", false, Wrap(ExitUsage, err)
 	}
+	if opts.FollowSymlinks {
+		cfg.Ignore.FollowSymlinks = true
+	}
+	if opts.UseGitignore != nil {
+		cfg.Ignore.UseGitignore = *opts.UseGitignore
+	}
+	if cfg, err = applyContextLines(cfg, opts.ContextLines); err != nil {
+		return "", false, err
+	}
+	cfg.Selector.Exclude, cfg.Selector.Include = opts.Exclude, opts.Include
 	mods, err := selector.ParseModifiers(opts.Modifiers)
 	if err != nil {
 		return "", false, Wrap(ExitUsage, err)
@@ -274,24 +830,18 @@ func List(ctx context.Context, opts ListOptions) (string, bool, error) {
 	if err != nil {
 		return "", false, Wrap(ExitUsage, err)
 	}
+	cfg.Selector.EnableOrder = selector.EnableOrder(cfg, opts.Profile, mods)
 	enabledOrdered := selector.EnabledSliceList(enabled, cfg)
 
-	limits := budget.Limits{
-		MaxChars:        cfg.Budgets.MaxChars,
-		PerFileMaxLines: cfg.Budgets.PerFileMaxLines,
-		PerFileMaxBytes: cfg.Budgets.PerFileMaxBytes,
-	}
-	if opts.MaxChars > 0 {
-		limits.MaxChars = opts.MaxChars
-	}
-	b := &budget.Builder{Limits: limits}
+	limits := limitsFromConfig(cfg, opts.MaxChars, opts.MaxTokens, opts.PerFileMaxLines, opts.PerFileMaxBytes)
+	b := &budget.Builder{Limits: limits, SliceLimits: sliceLimitsFromConfig(cfg), HashContent: cfg.Render.IncludeHashes, StripPatterns: stripPatternsFromConfig(cfg)}
 
 	slicePriorities := map[string]int{}
 	for _, s := range enabled {
 		slicePriorities[s] = cfg.Slices[s].Priority
 	}
 
-	eng, err := discovery.NewEngine(root, cfg.Ignore.UseGitignore, cfg.Ignore.Always, cfg.Sensitive.ExcludeGlobs, cfg.Ignore.BinaryExtensions)
+	eng, err := newDiscoveryEngine(ctx, root, cfg, nil)
 	if err != nil {
 		return "", false, Wrap(ExitIO, err)
 	}
@@ -303,55 +853,23 @@ func List(ctx context.Context, opts ListOptions) (string, bool, error) {
 	if err != nil {
 		return "", false, Wrap(ExitUsage, err)
 	}
+	if opts.Since != "" {
+		if selected, err = changedSince(ctx, root, opts.Since, selected); err != nil {
+			return "", false, err
+		}
+	}
 
 	plan, err := b.BuildPlan(ctx, opts.Profile, enabledOrdered, selected)
 	if err != nil {
 		return "", false, Wrap(ExitIO, err)
 	}
 
-	sha, err := gitinfo.ShortSHA(ctx, root)
-	if err != nil || sha == "" {
-		sha = "000000"
-	}
-	rndr := render.Renderer{
-		Newline:         cfg.Render.Newline,
-		CodeFences:      cfg.Render.CodeFences,
-		IncludeTree:     cfg.Render.IncludeTree,
-		TreeDepth:       cfg.Render.TreeDepth,
-		TreePaths:       treePathsFromDiscovery(discovered),
-		SlicePatterns:   slicePatternsFromConfig(cfg),
-		IncludeManifest: cfg.Render.IncludeManifest,
-		Manifest: render.ManifestOptions{
-			GroupBySlice:           cfg.Render.Manifest.GroupBySlice,
-			IncludeLineCounts:      cfg.Render.Manifest.IncludeLineCounts,
-			IncludeByteCounts:      cfg.Render.Manifest.IncludeByteCounts,
-			IncludeTruncationNotes: cfg.Render.Manifest.IncludeTruncationNotes,
-			IncludeUnreadableNotes: cfg.Render.Manifest.IncludeUnreadableNotes,
-		},
-		FileBlock: render.FileBlockOptions{
-			Header: cfg.Render.FileBlock.Header,
-			Footer: cfg.Render.FileBlock.Footer,
-		},
-	}
-
-	rootLabel := cfg.Root
-	if opts.RootOverride != "" {
-		rootLabel = opts.RootOverride
-	}
-	if rootLabel == "" {
-		rootLabel = "."
-	}
+	sha, dirty := gitState(ctx, root, false) // ls never prints the header
+	rndr := newRenderer(cfg.Render, cfg, discovered)
+	rndr.Manifest.Commits = fileCommits(ctx, root, cfg.Render, plan)
 
-	now := opts.Now().In(time.Local)
-	info := render.BundleInfo{
-		Repo:        filepath.Base(root),
-		Root:        rootLabel,
-		Profile:     opts.Profile,
-		Enabled:     enabledOrdered,
-		GitSHA:      sha,
-		Timestamp:   now,
-		SnipVersion: Version,
-	}
+	now := opts.Now()
+	info := bundleInfo(cfg, root, opts.RootOverride, opts.Profile, enabledOrdered, sha, dirty, now)
 	renderFn := func(p budget.Plan) (string, error) { return rndr.RenderMarkdown(info, p) }
 	planFinal, _, err := b.EnforceGlobalBudget(ctx, plan, slicePriorities, renderFn)
 	if err != nil {
@@ -359,7 +877,54 @@ func List(ctx context.Context, opts ListOptions) (string, bool, error) {
 	}
 
 	log.Debug("ls finalized", "included", len(planFinal.Included), "dropped", len(planFinal.Dropped), "partial", planFinal.Partial)
+	// List in bundle order, not the budget's priority order.
+	planFinal.Included = render.OrderIncluded(planFinal.Included, rndr.Manifest.GroupBySlice, rndr.FileOrder)
+
+	var out string
+	switch {
+	case opts.PrintPaths:
+		term := "\n"
+		if opts.Null {
+			term = "\x00"
+		}
+		var sb strings.Builder
+		for _, f := range planFinal.Included {
+			sb.WriteString(f.RelPath + term)
+		}
+		out = sb.String()
+	case opts.Format == "json":
+		out, err = marshalReport(listReport(opts.Profile, enabledOrdered, planFinal))
+		if err != nil {
+			return "", false, err
+		}
+	default:
+		out = listText(enabledOrdered, planFinal, opts.Verbose)
+	}
+	if planFinal.Partial {
+		return out, true, Wrap(ExitPartial, fmt.Errorf("partial output"))
+	}
+	return out, false, nil
+}
 
+func listReport(profile string, enabled []string, plan budget.Plan) ListReport {
+	rep := ListReport{
+		Profile:       profile,
+		EnabledSlices: enabled,
+		Included:      make([]ListFile, 0, len(plan.Included)),
+		DroppedSlices: append([]string{}, plan.DroppedSlices...),
+		Dropped:       make([]ListDropped, 0, len(plan.Dropped)),
+		Partial:       plan.Partial,
+	}
+	for _, f := range plan.Included {
+		rep.Included = append(rep.Included, ListFile{Path: f.RelPath, Slices: f.Slices, Primary: f.PrimarySlice, Truncated: f.Truncated})
+	}
+	for _, d := range plan.Dropped {
+		rep.Dropped = append(rep.Dropped, ListDropped{Path: d.RelPath, Slices: d.Slices, Primary: d.PrimarySlice, Reason: d.Reason, Detail: d.Detail})
+	}
+	return rep
+}
+
+func listText(enabledOrdered []string, planFinal budget.Plan, verbose bool) string {
 	var sb strings.Builder
 	sb.WriteString("Enabled slices: [" + strings.Join(enabledOrdered, ", ") + "]\n")
 	sb.WriteString("Included files:\n")
@@ -373,7 +938,7 @@ func List(ctx context.Context, opts ListOptions) (string, bool, error) {
 		}
 	}
 
-	if opts.Verbose {
+	if verbose {
 		sb.WriteString("Dropped:\n")
 		for _, d := range planFinal.Dropped {
 			fmt.Fprintf(&sb, "  - %s reason=%s", d.RelPath, d.Reason)
@@ -396,15 +961,116 @@ func List(ctx context.Context, opts ListOptions) (string, bool, error) {
 			fmt.Fprintf(&sb, "Dropped files due to budget: %d (use --verbose for details)\n", droppedCount)
 		}
 	}
+	return sb.String()
+}
+
+// bundleSize is the measured size of a rendering: runes and estimated tokens (tokens are
+// only counted when limits set max_tokens).
+type bundleSize struct {
+	chars, tokens int
+}
 
-	partial := planFinal.Partial
-	if partial {
-		return sb.String(), true, Wrap(ExitPartial, fmt.Errorf("partial output"))
+// fits reports whether sz is within limits.
+func (sz bundleSize) fits(limits budget.Limits) bool {
+	return (limits.MaxChars <= 0 || sz.chars <= limits.MaxChars) && (limits.MaxTokens <= 0 || sz.tokens <= limits.MaxTokens)
+}
+
+// measure returns the bundleSize of s under limits.
+func measure(s string, limits budget.Limits) bundleSize {
+	sz := bundleSize{chars: utf8.RuneCountInString(s)}
+	if limits.MaxTokens > 0 {
+		sz.tokens = limits.Tokens(s)
 	}
-	return sb.String(), false, nil
+	return sz
+}
+
+// renderedSize measures plans by rendering them with renderFn.
+func renderedSize(renderFn func(budget.Plan) (string, error), limits budget.Limits) func(budget.Plan) (bundleSize, error) {
+	return func(p budget.Plan) (bundleSize, error) {
+		r, err := renderFn(p)
+		if err != nil {
+			return bundleSize{}, err
+		}
+		return measure(r, limits), nil
+	}
+}
+
+// ndjsonSize measures NDJSON renderings of prefixes of plan's files (in bundle order) without
+// buffering the bundle: each file line is encoded and measured once, and only the header is
+// re-encoded per plan. Token estimates are summed per line, which never undercounts the
+// default estimator.
+func ndjsonSize(rndr render.Renderer, info render.BundleInfo, plan budget.Plan, limits budget.Limits) (func(budget.Plan) (bundleSize, error), error) {
+	prefix := []bundleSize{{}}
+	err := rndr.EachNDJSONFile(plan, func(line string) error {
+		sz, last := measure(line, limits), prefix[len(prefix)-1]
+		prefix = append(prefix, bundleSize{chars: last.chars + sz.chars, tokens: last.tokens + sz.tokens})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(p budget.Plan) (bundleSize, error) {
+		h, err := rndr.NDJSONHeader(info, p)
+		if err != nil {
+			return bundleSize{}, err
+		}
+		sz, files := measure(h, limits), prefix[len(p.Included)]
+		return bundleSize{chars: sz.chars + files.chars, tokens: sz.tokens + files.tokens}, nil
+	}, nil
+}
+
+// fitWholeFiles keeps the longest prefix of plan's files in bundle order whose rendering fits
+// limits, dropping the rest; formats that cannot be cut mid-file (NDJSON, HTML, split
+// bundles) use it after a hard cut. size measures a candidate plan, whose Included is always
+// such a prefix.
+func fitWholeFiles(plan budget.Plan, limits budget.Limits, size func(budget.Plan) (bundleSize, error), groupBySlice bool, fileOrder string) (budget.Plan, error) {
+	ordered := render.OrderIncluded(plan.Included, groupBySlice, fileOrder)
+	// keep returns plan with the first k files of ordered, the rest dropped last first.
+	keep := func(k int) budget.Plan {
+		p := plan
+		p.Included = ordered[:k]
+		p.Dropped = append([]budget.DroppedEntry(nil), plan.Dropped...)
+		for i := len(ordered) - 1; i >= k; i-- {
+			f := ordered[i]
+			p.Dropped = append(p.Dropped, budget.DroppedEntry{
+				RelPath:      f.RelPath,
+				Slices:       append([]string(nil), f.Slices...),
+				PrimarySlice: f.PrimarySlice,
+				Reason:       "budget_exceeded",
+				Detail:       "hard cut",
+			})
+		}
+		if k == 0 {
+			p.Included = nil
+		}
+		return p
+	}
+
+	// Binary search the most files that fit; the bundle grows with every file kept.
+	lo, hi := 0, len(ordered)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		sz, err := size(keep(mid))
+		if err != nil {
+			return budget.Plan{}, err
+		}
+		if sz.fits(limits) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return keep(lo), nil
 }
 
 func writeExplicitOutput(path string, rendered string) (string, error) {
+	return writeExplicitOutputFunc(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, rendered)
+		return err
+	})
+}
+
+func writeExplicitOutputFunc(path string, write func(io.Writer) error) (string, error) {
 	if path == "" {
 		return "", fmt.Errorf("out path is empty")
 	}
@@ -419,12 +1085,194 @@ func writeExplicitOutput(path string, rendered string) (string, error) {
 		path = filepath.Join(cwd, path)
 	}
 	path = filepath.Clean(path)
-	if err := util.AtomicWriteFile(path, []byte(rendered), 0o644); err != nil {
+	if err := util.AtomicWriteFunc(path, 0o644, write); err != nil {
 		return "", fmt.Errorf("write bundle: %w", err)
 	}
 	return path, nil
 }
 
+// onlyName names the synthetic slice and profile built for RunOptions.Only.
+const onlyName = "only"
+
+// applyOnly replaces cfg's slices and profiles with a single slice of opts.Only and selects its
+// profile. Without Only it returns cfg unchanged.
+func applyOnly(cfg config.Config, opts *RunOptions) (config.Config, error) {
+	if len(opts.Only) == 0 {
+		return cfg, nil
+	}
+	if opts.Profile != "" || len(opts.Modifiers) > 0 {
+		return config.Config{}, Wrap(ExitUsage, fmt.Errorf("--only cannot be combined with a profile or modifiers"))
+	}
+	cfg.Slices = map[string]config.SliceConfig{onlyName: {Include: append([]string(nil), opts.Only...)}}
+	cfg.Profiles = map[string]config.Profile{onlyName: {Enable: []string{onlyName}}}
+	cfg.DefaultProfile = onlyName
+	opts.Profile = onlyName
+	return cfg, nil
+}
+
+// applyContextLines sets contains_context to n on every slice with a contains_regex
+// (--context-lines). n == 0 leaves cfg unchanged.
+func applyContextLines(cfg config.Config, n int) (config.Config, error) {
+	switch {
+	case n < 0:
+		return config.Config{}, Wrap(ExitUsage, fmt.Errorf("--context-lines must be >= 0"))
+	case n == 0:
+		return cfg, nil
+	}
+	slices := make(map[string]config.SliceConfig, len(cfg.Slices))
+	found := false
+	for name, sl := range cfg.Slices {
+		if sl.ContainsRegex != "" && !sl.WholeFilesOnly {
+			sl.ContainsContext = n
+			found = true
+		}
+		slices[name] = sl
+	}
+	if !found {
+		return config.Config{}, Wrap(ExitUsage, fmt.Errorf("--context-lines needs a slice with contains_regex"))
+	}
+	cfg.Slices = slices
+	return cfg, nil
+}
+
+// fileCommits looks up the last commit of every planned file when render.manifest.include_git_info
+// is set. It returns nil when the option is off or git history is unavailable.
+func fileCommits(ctx context.Context, root string, rc config.RenderConfig, plan budget.Plan) map[string]render.FileCommit {
+	if !rc.Manifest.IncludeGitInfo || len(plan.Included) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(plan.Included))
+	for _, f := range plan.Included {
+		paths = append(paths, f.RelPath)
+	}
+	commits, err := gitinfo.FileCommits(ctx, root, paths)
+	if err != nil {
+		return nil
+	}
+	out := make(map[string]render.FileCommit, len(commits))
+	for rel, c := range commits {
+		out[rel] = render.FileCommit{SHA: c.SHA, AuthorDate: c.AuthorDate}
+	}
+	return out
+}
+
+// changedSince narrows sel to the files changed since ref (see gitinfo.ChangedFiles). Unchanged
+// files are removed outright rather than recorded as dropped, so they stay out of the manifest.
+func changedSince(ctx context.Context, root, ref string, sel selector.Selected) (selector.Selected, error) {
+	changed, err := gitinfo.ChangedFiles(ctx, root, ref)
+	if err != nil {
+		return selector.Selected{}, Wrap(ExitUsage, fmt.Errorf("--since %s: %w", ref, err))
+	}
+	keep := func(files []selector.File) []selector.File {
+		out := files[:0]
+		for _, f := range files {
+			if changed[f.RelPath] {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+	return selector.Selected{Included: keep(sel.Included), Dropped: keep(sel.Dropped)}, nil
+}
+
+// gitState returns the short SHA ("000000" outside git) and the dirty state (nil outside git,
+// or when withDirty is false because the output never shows it).
+func gitState(ctx context.Context, root string, withDirty bool) (string, *bool) {
+	sha, err := gitinfo.ShortSHA(ctx, root)
+	if err != nil || sha == "" {
+		return "000000", nil
+	}
+	if !withDirty {
+		return sha, nil
+	}
+	dirty, err := gitinfo.IsDirty(ctx, root)
+	if err != nil {
+		return sha, nil
+	}
+	return sha, &dirty
+}
+
+// bundleInfo builds the bundle header fields.
+func bundleInfo(cfg config.Config, root, rootOverride, profile string, enabled []string, sha string, dirty *bool, now time.Time) render.BundleInfo {
+	rootLabel := cfg.Root
+	if rootOverride != "" {
+		rootLabel = rootOverride
+	}
+	if rootLabel == "" {
+		rootLabel = "."
+	}
+	return render.BundleInfo{
+		Repo:        filepath.Base(root),
+		Root:        rootLabel,
+		Profile:     profile,
+		Enabled:     enabled,
+		GitSHA:      sha,
+		GitDirty:    dirty,
+		Timestamp:   now,
+		SnipVersion: Version,
+	}
+}
+
+// newRenderer builds the renderer for rc (the effective render settings after CLI overrides).
+func newRenderer(rc config.RenderConfig, cfg config.Config, discovered []discovery.PathInfo) render.Renderer {
+	return render.Renderer{
+		Newline:         rc.Newline,
+		CodeFences:      rc.CodeFences,
+		IncludeTree:     rc.IncludeTree,
+		TreeDepth:       rc.TreeDepth,
+		TreeMaxNodes:    rc.TreeMaxNodes,
+		TreePaths:       treePathsFromDiscovery(discovered),
+		TreeSizes:       rc.TreeSizes,
+		TreePathBytes:   treeBytesFromDiscovery(rc, discovered),
+		SlicePatterns:   slicePatternsFromConfig(cfg),
+		IncludeManifest: rc.IncludeManifest,
+		Manifest: render.ManifestOptions{
+			GroupBySlice:           rc.Manifest.GroupBySlice,
+			IncludeLineCounts:      rc.Manifest.IncludeLineCounts,
+			IncludeByteCounts:      rc.Manifest.IncludeByteCounts,
+			IncludeTruncationNotes: rc.Manifest.IncludeTruncationNotes,
+			IncludeUnreadableNotes: rc.Manifest.IncludeUnreadableNotes,
+			IncludeHashes:          rc.IncludeHashes,
+		},
+		FileBlock: render.FileBlockOptions{
+			Header: rc.FileBlock.Header,
+			Footer: rc.FileBlock.Footer,
+		},
+		LineNumbers:      rc.LineNumbers,
+		EmbedWarnings:    rc.EmbedWarnings,
+		IncludeTOC:       rc.IncludeTOC,
+		Languages:        rc.Languages,
+		WarningsPosition: rc.WarningsPosition,
+		SkipEmptyFiles:   rc.SkipEmptyFiles,
+		HTMLPrism:        rc.HTMLPrism,
+		FileOrder:        rc.FileOrder,
+	}
+}
+
+// newDiscoveryEngine builds the engine for cfg; progress may be nil.
+func newDiscoveryEngine(ctx context.Context, root string, cfg config.Config, progress func(files int)) (*discovery.Engine, error) {
+	var tracked map[string]bool
+	if cfg.Ignore.TrackedOnly {
+		// Outside a git repo (or without git) every file is considered, as if the option were off.
+		tracked, _ = gitinfo.TrackedFiles(ctx, root)
+	}
+	return discovery.New(root, discovery.Options{
+		UseGitignore:     cfg.Ignore.UseGitignore,
+		UseSnipignore:    cfg.Ignore.SnipignoreEnabled(),
+		FollowSymlinks:   cfg.Ignore.FollowSymlinks,
+		UseCache:         cfg.Ignore.Cache,
+		MaxFileBytes:     cfg.Ignore.MaxFileBytes,
+		MaxFiles:         cfg.Ignore.MaxFiles,
+		IgnoreAlways:     cfg.Ignore.Always,
+		SensitiveGlobs:   cfg.Sensitive.ExcludeGlobs,
+		BinaryExts:       cfg.Ignore.BinaryExtensions,
+		Tracked:          tracked,
+		TextExts:         cfg.Ignore.TextExtensions,
+		BinarySniffRatio: cfg.Ignore.BinarySniffRatio,
+		Progress:         progress,
+	})
+}
+
 func treePathsFromDiscovery(discovered []discovery.PathInfo) []string {
 	out := make([]string, 0, len(discovered))
 	for _, pi := range discovered {
@@ -436,6 +1284,80 @@ func treePathsFromDiscovery(discovered []discovery.PathInfo) []string {
 	return out
 }
 
+// treeBytesFromDiscovery returns the size of every tree path when render.tree_sizes is set.
+func treeBytesFromDiscovery(rc config.RenderConfig, discovered []discovery.PathInfo) map[string]int64 {
+	if !rc.TreeSizes {
+		return nil
+	}
+	out := make(map[string]int64, len(discovered))
+	for _, pi := range discovered {
+		if !pi.Excluded {
+			out[pi.RelPath] = pi.SizeBytes
+		}
+	}
+	return out
+}
+
+// limitsFromConfig returns the configured budgets with positive CLI overrides applied.
+func limitsFromConfig(cfg config.Config, maxChars, maxTokens, perFileMaxLines, perFileMaxBytes int) budget.Limits {
+	limits := budget.Limits{
+		MaxChars:        cfg.Budgets.MaxChars,
+		MaxTokens:       cfg.Budgets.MaxTokens,
+		PerFileMaxLines: cfg.Budgets.PerFileMaxLines,
+		PerFileMaxBytes: cfg.Budgets.PerFileMaxBytes,
+		TruncateMode:    cfg.Budgets.TruncateMode,
+		DropPolicy:      cfg.Budgets.DropPolicy,
+
+		CollapseBlankLines:     cfg.Budgets.CollapseBlankLines,
+		TrimTrailingWhitespace: cfg.Budgets.TrimTrailingWhitespace,
+	}
+	if maxChars > 0 {
+		limits.MaxChars = maxChars
+	}
+	if maxTokens > 0 {
+		limits.MaxTokens = maxTokens
+	}
+	if perFileMaxLines > 0 {
+		limits.PerFileMaxLines = perFileMaxLines
+	}
+	if perFileMaxBytes > 0 {
+		limits.PerFileMaxBytes = perFileMaxBytes
+	}
+	return limits
+}
+
+func sliceLimitsFromConfig(cfg config.Config) map[string]budget.SliceLimits {
+	out := map[string]budget.SliceLimits{}
+	for s, sl := range cfg.Slices {
+		lim := budget.SliceLimits{MaxChars: sl.Budget.MaxChars, MaxFiles: sl.Budget.MaxFiles, WholeFilesOnly: sl.WholeFilesOnly}
+		if sl.ContainsContext > 0 {
+			lim.Contains, _ = regexp.Compile(sl.ContainsRegex) // checked by config.Validate
+			lim.ContextLines = sl.ContainsContext
+		}
+		if lim != (budget.SliceLimits{}) {
+			out[s] = lim
+		}
+	}
+	return out
+}
+
+// stripPatternsFromConfig compiles render.strip_patterns; config.Validate has already
+// rejected invalid ones.
+func stripPatternsFromConfig(cfg config.Config) []*regexp.Regexp {
+	return compilePatterns(cfg.Render.StripPatterns)
+}
+
+// compilePatterns compiles regexes that config.Validate has already checked.
+func compilePatterns(pats []string) []*regexp.Regexp {
+	var out []*regexp.Regexp
+	for _, pat := range pats {
+		if re, err := regexp.Compile(pat); err == nil {
+			out = append(out, re)
+		}
+	}
+	return out
+}
+
 func slicePatternsFromConfig(cfg config.Config) map[string]render.SlicePatterns {
 	out := make(map[string]render.SlicePatterns, len(cfg.Slices))
 	for s, sl := range cfg.Slices {
@@ -447,59 +1369,143 @@ func slicePatternsFromConfig(cfg config.Config) map[string]render.SlicePatterns
 	return out
 }
 
-func writeDefaultOutput(root string, cfg config.Config, profile string, gitsha string, ts time.Time, rendered string) (string, error) {
-	outDir := cfg.Output.Dir
-	if outDir == "" {
-		outDir = ".snip"
-	}
+func writeDefaultOutput(root string, cfg config.Config, profile string, gitsha string, branch string, ts time.Time, rendered string) (string, error) {
+	return writeDefaultOutputFunc(root, cfg, profile, gitsha, branch, ts, ".md", func(w io.Writer) error {
+		_, err := io.WriteString(w, rendered)
+		return err
+	})
+}
 
-	var absDir string
-	if filepath.IsAbs(outDir) {
-		absDir = filepath.Clean(outDir)
-	} else {
-		absDir = filepath.Clean(filepath.Join(root, outDir))
+// currentUser returns the OS user name for the {user} token, or "" if unknown.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		// Windows reports DOMAIN\user; keep the user part.
+		name := u.Username
+		if i := strings.LastIndexAny(name, `\/`); i >= 0 {
+			name = name[i+1:]
+		}
+		return name
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
 	}
+	return os.Getenv("USERNAME")
+}
 
+func writeDefaultOutputFunc(root string, cfg config.Config, profile string, gitsha string, branch string, ts time.Time, ext string, write func(io.Writer) error) (string, error) {
+	absDir := outputDir(root, cfg)
 	if err := os.MkdirAll(absDir, 0o755); err != nil {
 		return "", fmt.Errorf("mkdir output dir: %w", err)
 	}
+	// Concurrent runs into the same directory would otherwise race on the counter and latest.
+	unlock, err := lockOutputDir(absDir, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	counter := 0
+	if strings.Contains(cfg.Output.Pattern, "{counter}") {
+		if counter, err = util.NextCounter(absDir); err != nil {
+			return "", fmt.Errorf("counter: %w", err)
+		}
+	}
+	outPath := filepath.Join(absDir, outputFileName(root, cfg, profile, gitsha, branch, ts, ext, counter))
+	if err := writeWithLatest(outPath, cfg, ext, write); err != nil {
+		return "", err
+	}
+	return outPath, nil
+}
+
+// outputDir is the absolute output.dir (default .snip) for root.
+func outputDir(root string, cfg config.Config) string {
+	dir := cfg.Output.Dir
+	if dir == "" {
+		dir = ".snip"
+	}
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return filepath.Clean(filepath.Join(root, dir))
+}
+
+// outputFileName expands output.pattern into a bundle file name with extension ext. counter
+// fills {counter}; the caller takes it from util.NextCounter (or util.PeekCounter for a dry
+// run). It has no side effects.
+func outputFileName(root string, cfg config.Config, profile, gitsha, branch string, ts time.Time, ext string, counter int) string {
+	tokens := outputTokens(root, cfg, profile, gitsha, branch)
+	tokens["ts"] = ts.Format("20060102-150405")
+	tokens["date"] = ts.Format("20060102")
+	if strings.Contains(cfg.Output.Pattern, "{counter}") {
+		tokens["counter"] = fmt.Sprintf("%03d", counter)
+	}
+	return expandOutputName(cfg.Output.Pattern, tokens, ext)
+}
 
+// outputTokens returns the output.pattern token values that do not depend on the run's time
+// or counter.
+func outputTokens(root string, cfg config.Config, profile, gitsha, branch string) map[string]string {
 	tokens := map[string]string{
-		"ts":      ts.Format("20060102-150405"),
 		"profile": profile,
 		"gitsha":  gitsha,
+		"branch":  branch,
 		"repo":    filepath.Base(root),
 		"name":    filepath.Base(root),
+		"slice":   "", // named by sliceOutputConfig with output.split; collapses otherwise
 	}
-
-	if strings.Contains(cfg.Output.Pattern, "{counter}") {
-		c, err := util.NextCounter(absDir)
-		if err != nil {
-			return "", fmt.Errorf("counter: %w", err)
-		}
-		tokens["counter"] = fmt.Sprintf("%03d", c)
+	if strings.Contains(cfg.Output.Pattern, "{user}") {
+		tokens["user"] = currentUser()
 	}
+	return tokens
+}
 
-	fileName := util.ApplyPatternTokens(cfg.Output.Pattern, tokens)
+// expandOutputName applies tokens to pattern and turns the result into a file name with
+// extension ext.
+func expandOutputName(pattern string, tokens map[string]string, ext string) string {
+	fileName := util.ApplyPatternTokens(pattern, tokens)
 	fileName = strings.ReplaceAll(fileName, "/", "_")
 	fileName = strings.ReplaceAll(fileName, "\\", "_")
 	fileName = filepath.Base(fileName)
-	if !strings.HasSuffix(strings.ToLower(fileName), ".md") {
-		fileName += ".md"
+	if !strings.HasSuffix(strings.ToLower(fileName), ext) {
+		fileName = strings.TrimSuffix(fileName, ".md") + ext
 	}
+	return fileName
+}
 
-	outPath := filepath.Join(absDir, fileName)
-	if err := util.AtomicWriteFile(outPath, []byte(rendered), 0o644); err != nil {
-		return "", fmt.Errorf("write bundle: %w", err)
+// latestPath is the output.latest file refreshed next to outPath, or "" when it is unset.
+func latestPath(outPath string, cfg config.Config, ext string) string {
+	if cfg.Output.Latest == "" {
+		return ""
 	}
+	p := filepath.Join(filepath.Dir(outPath), filepath.Base(cfg.Output.Latest))
+	if ext != ".md" {
+		p = strings.TrimSuffix(p, ".md") + ext
+	}
+	return p
+}
 
-	if cfg.Output.Latest != "" {
-		latestName := filepath.Base(cfg.Output.Latest)
-		latestPath := filepath.Join(absDir, latestName)
-		if err := util.AtomicWriteFile(latestPath, []byte(rendered), 0o644); err != nil {
-			return "", fmt.Errorf("write latest: %w", err)
-		}
+// lockOutputDir takes the output directory's advisory lock unless output.NoLock is set.
+func lockOutputDir(dir string, cfg config.Config) (func(), error) {
+	if cfg.Output.NoLock {
+		return func() {}, nil
 	}
+	unlock, err := util.LockDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w (use --no-lock on file systems without locking)", err)
+	}
+	return unlock, nil
+}
 
-	return outPath, nil
+// writeWithLatest writes the bundle to outPath and refreshes output.latest in the same directory.
+func writeWithLatest(outPath string, cfg config.Config, ext string, write func(io.Writer) error) error {
+	if err := util.AtomicWriteFunc(outPath, 0o644, write); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+
+	if latest := latestPath(outPath, cfg, ext); latest != "" {
+		if err := util.AtomicWriteFunc(latest, 0o644, write); err != nil {
+			return fmt.Errorf("write latest: %w", err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/snip/internal/budget"
+	"github.com/mmrzaf/snip/internal/config"
+	"github.com/mmrzaf/snip/internal/render"
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// sliceBundle is one bundle of an output.split: per_slice run.
+type sliceBundle struct {
+	slice    string
+	plan     budget.Plan
+	rendered string // empty for NDJSON, which is streamed by emit
+	emit     func(io.Writer) error
+}
+
+// splitApplies reports whether the bundle goes to the default output, the only destination
+// output.split applies to: -o, stdout, the clipboard, --append and ReuseOutput get the
+// combined bundle.
+func splitApplies(cfg config.Config, opts RunOptions) bool {
+	return cfg.Output.Split == "per_slice" && !opts.NoWrite && opts.Output == "" && !opts.Clipboard &&
+		opts.Append == "" && opts.ReuseOutput == "" && (!cfg.Output.StdoutDefault || opts.FileOutput)
+}
+
+// splitBySlice renders plan (already within the global budget) as one bundle per slice in
+// enabled order, each holding the included and dropped files whose primary slice it is.
+// Slices without included files get no bundle. Each bundle's header and manifest name only
+// its slice, and its tree shows only its files.
+func splitBySlice(plan budget.Plan, enabled []string, rndr render.Renderer, format string, info render.BundleInfo) ([]sliceBundle, error) {
+	rndr.TreePaths, rndr.TreePathBytes = nil, nil
+	patterns := rndr.SlicePatterns
+	var out []sliceBundle
+	for _, s := range enabled {
+		rndr := rndr
+		rndr.SlicePatterns = map[string]render.SlicePatterns{s: patterns[s]}
+		p := budget.Plan{Profile: plan.Profile, EnabledSlices: []string{s}, Partial: plan.Partial}
+		for _, f := range plan.Included {
+			if f.PrimarySlice == s {
+				p.Included = append(p.Included, f)
+			}
+		}
+		if len(p.Included) == 0 {
+			continue
+		}
+		for _, d := range plan.Dropped {
+			if d.PrimarySlice == s {
+				p.Dropped = append(p.Dropped, d)
+			}
+		}
+		sliceInfo := info
+		sliceInfo.Enabled = []string{s}
+
+		b := sliceBundle{slice: s, plan: p}
+		if format == "ndjson" {
+			b.emit = func(w io.Writer) error { return rndr.RenderNDJSON(w, sliceInfo, p) }
+		} else {
+			rendered, err := rendererFor(rndr, format, sliceInfo)(p)
+			if err != nil {
+				return nil, err
+			}
+			b.rendered = rendered
+			b.emit = func(w io.Writer) error {
+				_, err := io.WriteString(w, rendered)
+				return err
+			}
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// sliceOutputConfig returns cfg with output.pattern and output.latest naming slice's bundle.
+// A pattern without {slice} gets it after {profile} (or before the extension), so the
+// default snip_{profile}_{ts}_{gitsha}.md becomes snip_{profile}_{slice}_{ts}_{gitsha}.md;
+// latest gets _<slice> before its extension.
+func sliceOutputConfig(cfg config.Config, slice string) config.Config {
+	pattern := cfg.Output.Pattern
+	switch {
+	case strings.Contains(pattern, "{slice}"):
+	case strings.Contains(pattern, "{profile}"):
+		pattern = strings.Replace(pattern, "{profile}", "{profile}_{slice}", 1)
+	case strings.HasSuffix(pattern, ".md"):
+		pattern = strings.TrimSuffix(pattern, ".md") + "_{slice}.md"
+	default:
+		pattern += "_{slice}"
+	}
+	cfg.Output.Pattern = strings.ReplaceAll(pattern, "{slice}", slice)
+	if latest := cfg.Output.Latest; latest != "" {
+		ext := filepath.Ext(latest)
+		cfg.Output.Latest = strings.TrimSuffix(latest, ext) + "_" + slice + ext
+	}
+	return cfg
+}
+
+// writeSplitOutput writes each slice bundle to the output directory under one lock and one
+// {counter} value, refreshing the slices' output.latest files, and returns the paths written.
+func writeSplitOutput(root string, cfg config.Config, profile, gitsha, branch string, ts time.Time, ext string, bundles []sliceBundle) ([]string, error) {
+	absDir := outputDir(root, cfg)
+	if err := os.MkdirAll(absDir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir output dir: %w", err)
+	}
+	unlock, err := lockOutputDir(absDir, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	counter := 0
+	if strings.Contains(cfg.Output.Pattern, "{counter}") {
+		if counter, err = util.NextCounter(absDir); err != nil {
+			return nil, fmt.Errorf("counter: %w", err)
+		}
+	}
+	paths := make([]string, 0, len(bundles))
+	for _, b := range bundles {
+		sc := sliceOutputConfig(cfg, b.slice)
+		outPath := filepath.Join(absDir, outputFileName(root, sc, profile, gitsha, branch, ts, ext, counter))
+		if err := writeWithLatest(outPath, sc, ext, b.emit); err != nil {
+			return paths, err
+		}
+		paths = append(paths, outPath)
+	}
+	return paths, nil
+}
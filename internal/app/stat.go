@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mmrzaf/snip/internal/budget"
+	"github.com/mmrzaf/snip/internal/config"
+	"github.com/mmrzaf/snip/internal/selector"
+)
+
+// StatOptions configures snip stat.
+type StatOptions struct {
+	ConfigPath     string
+	RootOverride   string
+	Profile        string
+	Modifiers      []string
+	MaxChars       int
+	MaxTokens      int
+	IncludeHidden  bool
+	FollowSymlinks bool
+	Logger         *slog.Logger
+	Now            func() time.Time
+}
+
+// Stat builds and renders the bundle in memory and reports its size against the
+// budgets, with a per-slice breakdown. Nothing is written. The bool result reports
+// whether the run would be partial.
+func Stat(ctx context.Context, opts StatOptions) (string, bool, error) {
+	var err error
+	if opts.Now, err = clock(opts.Now); err != nil {
+		return "", false, err
+	}
+	cfg, err := config.Load(opts.ConfigPath)
+	if err != nil {
+		return "", false, Wrap(ExitUsage, err)
+	}
+	root, err := config.EffectiveRoot(cfg, opts.RootOverride)
+	if err != nil {
+		return "", false, Wrap(ExitUsage, err)
+	}
+	profile := config.ResolveProfile(cfg, opts.Profile)
+	cfg, err = config.ApplyProfileOverrides(cfg, profile)
+	if err != nil {
+		return "", false, Wrap(ExitUsage, err)
+	}
+	if opts.FollowSymlinks {
+		cfg.Ignore.FollowSymlinks = true
+	}
+	mods, err := selector.ParseModifiers(opts.Modifiers)
+	if err != nil {
+		return "", false, Wrap(ExitUsage, err)
+	}
+	enabled, err := selector.EnabledSlices(cfg, profile, mods)
+	if err != nil {
+		return "", false, Wrap(ExitUsage, err)
+	}
+	cfg.Selector.EnableOrder = selector.EnableOrder(cfg, profile, mods)
+	enabledOrdered := selector.EnabledSliceList(enabled, cfg)
+
+	limits := limitsFromConfig(cfg, opts.MaxChars, opts.MaxTokens, 0, 0)
+	b := &budget.Builder{Limits: limits, SliceLimits: sliceLimitsFromConfig(cfg), HashContent: cfg.Render.IncludeHashes, StripPatterns: stripPatternsFromConfig(cfg)}
+	slicePriorities := map[string]int{}
+	for _, s := range enabled {
+		slicePriorities[s] = cfg.Slices[s].Priority
+	}
+
+	eng, err := newDiscoveryEngine(ctx, root, cfg, nil)
+	if err != nil {
+		return "", false, Wrap(ExitIO, err)
+	}
+	discovered, err := eng.Discover()
+	if err != nil {
+		return "", false, Wrap(ExitIO, err)
+	}
+	selected, err := selector.Select(cfg, enabled, discovered, opts.IncludeHidden)
+	if err != nil {
+		return "", false, Wrap(ExitUsage, err)
+	}
+	plan, err := b.BuildPlan(ctx, profile, enabledOrdered, selected)
+	if err != nil {
+		return "", false, Wrap(ExitIO, err)
+	}
+
+	sha, dirty := gitState(ctx, root, false) // stat never prints the header
+	rndr := newRenderer(cfg.Render, cfg, discovered)
+	rndr.Manifest.Commits = fileCommits(ctx, root, cfg.Render, plan)
+	info := bundleInfo(cfg, root, opts.RootOverride, profile, enabledOrdered, sha, dirty, opts.Now())
+	renderFn := rendererFor(rndr, cfg.Render.Format, info)
+	planFinal, rendered, err := b.EnforceGlobalBudget(ctx, plan, slicePriorities, renderFn)
+	if err != nil {
+		return "", false, Wrap(ExitIO, err)
+	}
+
+	type sliceStat struct{ files, chars, truncated int }
+	perSlice := map[string]*sliceStat{}
+	for _, s := range enabledOrdered {
+		perSlice[s] = &sliceStat{}
+	}
+	truncated := 0
+	for _, f := range planFinal.Included {
+		st := perSlice[f.PrimarySlice]
+		if st == nil {
+			continue
+		}
+		st.files++
+		st.chars += utf8.RuneCountInString(f.Content)
+		if f.Truncated {
+			st.truncated++
+			truncated++
+		}
+	}
+	dropped := map[string]bool{}
+	for _, s := range planFinal.DroppedSlices {
+		dropped[s] = true
+	}
+
+	chars := utf8.RuneCountInString(rendered)
+	tokens := limits.Tokens(rendered)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "profile: %s\n", profile)
+	fmt.Fprintf(&sb, "enabled_slices: [%s]\n", strings.Join(enabledOrdered, ", "))
+	fmt.Fprintf(&sb, "files: %d (truncated=%d dropped=%d)\n", len(planFinal.Included), truncated, len(planFinal.Dropped))
+	fmt.Fprintf(&sb, "chars: %s\n", usage(chars, limits.MaxChars))
+	fmt.Fprintf(&sb, "tokens: ~%s\n", usage(tokens, limits.MaxTokens))
+	fmt.Fprintf(&sb, "partial: %t\n", planFinal.Partial)
+	fmt.Fprintf(&sb, "hard_cut: %t\n", planFinal.HardCut)
+	sb.WriteString("\nslices:\n")
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	for _, s := range enabledOrdered {
+		if dropped[s] {
+			fmt.Fprintf(tw, "  %s\tdropped (budget_exceeded)\n", s)
+			continue
+		}
+		st := perSlice[s]
+		fmt.Fprintf(tw, "  %s\tfiles=%d\tchars=%d\ttruncated=%d\n", s, st.files, st.chars, st.truncated)
+	}
+	_ = tw.Flush()
+	return sb.String(), planFinal.Partial, nil
+}
+
+// usage formats n against limit, e.g. "9500 / 10000 (95%)"; a zero limit is unbounded.
+func usage(n, limit int) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d (no limit)", n)
+	}
+	return fmt.Sprintf("%d / %d (%d%%)", n, limit, n*100/limit)
+}
@@ -1,4 +1,4 @@
 package app
 
 // Version is the snip version. It can be overridden at build time via ldflags.
-var Version = "1.2.1"
+var Version = "1.3.0"
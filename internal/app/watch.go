@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/mmrzaf/snip/internal/config"
+	"github.com/mmrzaf/snip/internal/discovery"
+)
+
+// DefaultWatchDebounce is how long Watch waits after the last relevant change before rebuilding.
+const DefaultWatchDebounce = 300 * time.Millisecond
+
+// WatchOptions configures snip run --watch.
+type WatchOptions struct {
+	Run      RunOptions
+	Debounce time.Duration // 0 means DefaultWatchDebounce
+	// OnRun is called after every build, the initial one included, with Run's result.
+	OnRun func(RunResult, error)
+}
+
+// Watch builds the bundle once, then rebuilds it whenever a file under root changes that
+// discovery would not ignore (so edits in .git, node_modules or the output directory never
+// trigger a rebuild). Rebuilds write to the first build's output path and refresh
+// output.latest; with output.split each rebuild writes new slice bundles. Watch returns nil when ctx is canceled; a failing rebuild is reported to
+// OnRun and watching continues, but a failing initial build (other than partial output) is
+// returned.
+func Watch(ctx context.Context, opts WatchOptions) error {
+	log := opts.Run.Logger
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	}
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+	report := opts.OnRun
+	if report == nil {
+		report = func(RunResult, error) {}
+	}
+
+	first, err := Run(ctx, opts.Run)
+	report(first, err)
+	if err != nil && !first.Partial {
+		return err
+	}
+
+	runOpts := opts.Run
+	if runOpts.Output == "" && first.OutputPath != "" && first.OutputPath != "-" {
+		runOpts.ReuseOutput = first.OutputPath
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return Wrap(ExitIO, fmt.Errorf("start watcher: %w", err))
+	}
+	defer func() { _ = watcher.Close() }()
+
+	watched := map[string]bool{}
+	var list *discovery.Watchlist
+	// sync rebuilds the watchlist (config and .gitignore files may have changed) and
+	// watches any directories that appeared since the last call.
+	sync := func() error {
+		wl, err := newWatchlist(ctx, opts.Run)
+		if err != nil {
+			return err
+		}
+		for dir := range wl.Dirs {
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				log.Warn("watch directory", "dir", dir, "err", err)
+				continue
+			}
+			watched[dir] = true
+		}
+		list = wl
+		return nil
+	}
+	if err := sync(); err != nil {
+		return err
+	}
+	skipOutput := outputFilter(runOpts, first.OutputPath, list.Root)
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op == fsnotify.Chmod || skipOutput(ev.Name) || list.Ignored(ev.Name) {
+				continue
+			}
+			if ev.Op.Has(fsnotify.Remove) || ev.Op.Has(fsnotify.Rename) {
+				delete(watched, ev.Name)
+			}
+			log.Debug("change", "path", ev.Name, "op", ev.Op.String())
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warn("watch", "err", err)
+		case <-timer.C:
+			res, err := Run(ctx, runOpts)
+			report(res, err)
+			if err := sync(); err != nil {
+				log.Warn("refresh watch list", "err", err)
+			}
+		}
+	}
+}
+
+// newWatchlist resolves the config and discovery rules the way Run does.
+func newWatchlist(ctx context.Context, opts RunOptions) (*discovery.Watchlist, error) {
+	cfg, err := config.Load(opts.ConfigPath)
+	if err != nil {
+		return nil, Wrap(ExitUsage, err)
+	}
+	root, err := config.EffectiveRoot(cfg, opts.RootOverride)
+	if err != nil {
+		return nil, Wrap(ExitUsage, err)
+	}
+	if cfg, err = applyOnly(cfg, &opts); err != nil {
+		return nil, err
+	}
+	cfg, err = config.ApplyProfileOverrides(cfg, opts.Profile)
+	if err != nil {
+		return nil, Wrap(ExitUsage, err)
+	}
+	if opts.FollowSymlinks {
+		cfg.Ignore.FollowSymlinks = true
+	}
+	if opts.UseGitignore != nil {
+		cfg.Ignore.UseGitignore = *opts.UseGitignore
+	}
+	eng, err := newDiscoveryEngine(ctx, root, cfg, nil)
+	if err != nil {
+		return nil, Wrap(ExitIO, err)
+	}
+	wl, err := eng.Watchlist()
+	if err != nil {
+		return nil, Wrap(ExitIO, err)
+	}
+	return wl, nil
+}
+
+// outputFilter reports paths written by the build itself: the bundle, its temp files, and (for
+// default outputs outside the root directory itself) everything in the output directory, such
+// as output.latest.
+func outputFilter(opts RunOptions, outputPath, root string) func(string) bool {
+	if outputPath == "" || outputPath == "-" {
+		return func(string) bool { return false }
+	}
+	out, err := filepath.Abs(outputPath)
+	if err != nil {
+		out = outputPath
+	}
+	dir, base := filepath.Dir(out), filepath.Base(out)
+	ownDir := opts.Output == "" && dir != root
+	return func(name string) bool {
+		if ownDir && filepath.Dir(name) == dir {
+			return true
+		}
+		if name == out {
+			return true
+		}
+		return filepath.Dir(name) == dir && strings.HasPrefix(filepath.Base(name), "."+base+".tmp.")
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"sort"
+	"time"
 	"unicode/utf8"
 
 	"github.com/mmrzaf/snip/internal/selector"
@@ -20,15 +24,110 @@ var errInvalidUTF8 = errors.New("invalid utf-8")
 // Limits control bundle budgets.
 type Limits struct {
 	MaxChars        int
+	MaxTokens       int // 0 disables the token budget.
 	PerFileMaxLines int
 	PerFileMaxBytes int
+	// TruncateMode is TruncateHead (default) or TruncateHeadTail.
+	TruncateMode string
+	// TokenEstimator estimates tokens for MaxTokens. Defaults to EstimateTokens.
+	TokenEstimator func(string) int
+	// DropPolicy is DropLowPriority (default), DropLargest or DropNewest.
+	DropPolicy string
+	// CollapseBlankLines reduces each run of blank lines in a file to one, and
+	// TrimTrailingWhitespace removes spaces and tabs at line ends. Both apply before
+	// truncation, so line and byte counts describe the rewritten content.
+	CollapseBlankLines     bool
+	TrimTrailingWhitespace bool
+}
+
+// Drop policies for EnforceGlobalBudget.
+const (
+	// DropLowPriority drops slices, lowest priority first: a few of a slice's files when
+	// that is enough, else the whole slice.
+	DropLowPriority = "drop_low_priority"
+	// DropLargest drops files regardless of slice, largest (original bytes) first.
+	DropLargest = "drop_largest"
+	// DropNewest drops files regardless of slice, most recently modified first.
+	DropNewest = "drop_newest"
+)
+
+// DetailSliceDropped is the Detail of files dropped with their slice by DropLowPriority.
+// Other budget_exceeded files name why they went alone: the drop policy, "within slice" or
+// "include_priorities".
+const DetailSliceDropped = "slice dropped"
+
+// Per-file truncation modes.
+const (
+	// TruncateHead keeps the first lines of a file.
+	TruncateHead = "head"
+	// TruncateHeadTail keeps the first 2/3 and last 1/3 of the line budget.
+	TruncateHeadTail = "head_tail"
+)
+
+// Limit names reported in the hard-cut marker.
+const (
+	LimitMaxChars  = "max_chars"
+	LimitMaxTokens = "max_tokens"
+)
+
+// Tokens estimates the tokens in s using TokenEstimator, or EstimateTokens by default.
+func (l Limits) Tokens(s string) int {
+	if l.TokenEstimator != nil {
+		return l.TokenEstimator(s)
+	}
+	return EstimateTokens(s)
+}
+
+// Exceeded reports the first limit rendered exceeds (LimitMaxChars or LimitMaxTokens),
+// or "" if it fits.
+func (l Limits) Exceeded(rendered string) string {
+	if l.MaxChars > 0 && runeCount(rendered) > l.MaxChars {
+		return LimitMaxChars
+	}
+	if l.MaxTokens > 0 && l.Tokens(rendered) > l.MaxTokens {
+		return LimitMaxTokens
+	}
+	return ""
+}
+
+// SliceLimits caps a single slice's share of the bundle. Zero values disable a limit.
+type SliceLimits struct {
+	MaxChars int // total content chars of the slice's files
+	MaxFiles int
+	// WholeFilesOnly drops a file (reason "whole_file_too_large") wherever per-file
+	// truncation, including budget tightening, would otherwise shorten it.
+	WholeFilesOnly bool
+	// Contains and ContextLines, when both set, make the slice's files excerpts: only the
+	// lines matching Contains (the slice's contains_regex) and ContextLines lines around each
+	// are kept (see FileEntry.Excerpt).
+	Contains     *regexp.Regexp
+	ContextLines int
 }
 
 // Builder constructs plans and enforces budgets.
 type Builder struct {
 	Limits Limits
+	// SliceLimits are keyed by slice name and applied to files by primary slice.
+	SliceLimits map[string]SliceLimits
+	// HashContent sets FileEntry.SHA256 for every included file.
+	HashContent bool
+	// StripPatterns are removed from each file's content before truncation, so line and
+	// byte counts describe the stripped text (see FileEntry.Stripped).
+	StripPatterns []*regexp.Regexp
+	// RedactPatterns are masked with RedactedMarker after StripPatterns are removed
+	// (see FileEntry.Redacted).
+	RedactPatterns []*regexp.Regexp
+	// Progress, when set, is called as BuildPlan reads each selected file, with the file's
+	// 1-based position and the number of files to read.
+	Progress func(done, total int)
+	// HardCutReserve is text the caller appends after a hard cut (the markdown seal line);
+	// the cut leaves room for it within the limits.
+	HardCutReserve string
 }
 
+// RedactedMarker replaces each RedactPatterns match.
+const RedactedMarker = "«REDACTED»"
+
 // FileEntry is an included file with metadata and (possibly truncated) content.
 type FileEntry struct {
 	RelPath       string
@@ -38,10 +137,43 @@ type FileEntry struct {
 	Priority      int
 	OriginalLines int
 	OriginalBytes int64
+	ModTime       time.Time
 	KeptLines     int
 	KeptBytes     int
 	Truncated     bool
-	Content       string
+	// Segments are the original line ranges kept when Truncated: one for head
+	// truncation, two for head_tail.
+	Segments []LineRange
+	Content  string
+	// SHA256 is the hex SHA-256 of the original file bytes (not the truncated Content); it is
+	// only set when Builder.HashContent is.
+	SHA256 string
+	// Stripped reports that a Builder.StripPatterns match was removed from the content;
+	// OriginalLines, OriginalBytes and Segments then refer to the stripped text.
+	Stripped bool
+	// Redacted counts Builder.RedactPatterns matches replaced by RedactedMarker.
+	Redacted int
+	// Empty marks a file with no content (zero bytes, or nothing left after transforms).
+	Empty bool
+	// Excerpt marks a file cut down to its contains_regex match windows (see
+	// SliceLimits.ContextLines); it is also Truncated, and Segments lists the windows.
+	Excerpt bool
+	// Encoding is the encoding announced by a byte order mark (util.EncodingUTF8BOM,
+	// EncodingUTF16LE or EncodingUTF16BE); empty for plain UTF-8. The BOM is not part of
+	// Content, and UTF-16 files are transcoded, so OriginalBytes counts UTF-8 bytes.
+	Encoding string
+	// IncludeIndex is the position of the primary slice's include pattern that matched the
+	// file (see selector.File.IncludeIndex).
+	IncludeIndex int
+	// PriorityDelta ranks the file within its primary slice (include_priorities, see
+	// selector.File.PriorityDelta); Priority+PriorityDelta decides which files the tightening
+	// phase of EnforceGlobalBudget drops first.
+	PriorityDelta int
+}
+
+// LineRange is an inclusive, 1-based range of original line numbers.
+type LineRange struct {
+	Start, End int
 }
 
 // DroppedEntry records a dropped/excluded file.
@@ -82,11 +214,17 @@ func (b *Builder) BuildPlan(ctx context.Context, profile string, enabledOrdered
 		}
 	}
 
-	for _, f := range selected.Included {
+	for i, f := range selected.Included {
 		if err := ctx.Err(); err != nil {
 			return Plan{}, err
 		}
-		entry, err := readAndTruncateFile(f.RelPath, f.AbsPath, f.Slices, f.PrimarySlice, f.PrimaryPriority, b.Limits.PerFileMaxLines, b.Limits.PerFileMaxBytes)
+		if b.Progress != nil {
+			b.Progress(i+1, len(selected.Included))
+		}
+		entry, err := b.read(f.RelPath, f.AbsPath, f.Slices, f.PrimarySlice, f.PrimaryPriority, b.Limits.PerFileMaxLines)
+		if err == nil && b.HashContent {
+			entry.SHA256, err = fileSHA256(f.AbsPath)
+		}
 		if err != nil {
 			if errors.Is(err, errInvalidUTF8) {
 				p.Dropped = append(p.Dropped, DroppedEntry{
@@ -110,15 +248,71 @@ func (b *Builder) BuildPlan(ctx context.Context, profile string, enabledOrdered
 			p.Partial = true
 			continue
 		}
+		entry.IncludeIndex = f.IncludeIndex
+		entry.PriorityDelta = f.PriorityDelta
+		if entry.Truncated && b.SliceLimits[entry.PrimarySlice].WholeFilesOnly {
+			p.Dropped = append(p.Dropped, wholeFileDropped(entry, b.Limits.PerFileMaxLines, b.Limits.PerFileMaxBytes))
+			p.Partial = true
+			continue
+		}
 		p.Included = append(p.Included, entry)
 	}
 
+	b.enforceSliceLimits(&p)
 	orderPlan(&p)
 	return p, nil
 }
 
-// EnforceGlobalBudget ensures the rendered plan stays under MaxChars.
-// It applies the drop_low_priority policy and deterministic truncation tightening.
+// wholeFileDropped records a whole-files-only file that the given per-file limits would truncate.
+func wholeFileDropped(f FileEntry, maxLines, maxBytes int) DroppedEntry {
+	return DroppedEntry{
+		RelPath:      f.RelPath,
+		Slices:       append([]string(nil), f.Slices...),
+		PrimarySlice: f.PrimarySlice,
+		Reason:       "whole_file_too_large",
+		Detail:       fmt.Sprintf("lines=%d bytes=%d per_file_max_lines=%d per_file_max_bytes=%d", f.OriginalLines, f.OriginalBytes, maxLines, maxBytes),
+	}
+}
+
+// enforceSliceLimits drops files (in path order) from slices over their per-slice budget.
+// It runs before global enforcement so one slice cannot starve the others.
+func (b *Builder) enforceSliceLimits(p *Plan) {
+	if len(b.SliceLimits) == 0 {
+		return
+	}
+	sort.Slice(p.Included, func(i, j int) bool { return p.Included[i].RelPath < p.Included[j].RelPath })
+	files := map[string]int{}
+	chars := map[string]int{}
+	kept := p.Included[:0]
+	for _, f := range p.Included {
+		lim := b.SliceLimits[f.PrimarySlice]
+		n := runeCount(f.Content)
+		overFiles := lim.MaxFiles > 0 && files[f.PrimarySlice]+1 > lim.MaxFiles
+		overChars := lim.MaxChars > 0 && chars[f.PrimarySlice]+n > lim.MaxChars
+		if overFiles || overChars {
+			detail := "slice max_chars"
+			if overFiles {
+				detail = "slice max_files"
+			}
+			p.Dropped = append(p.Dropped, DroppedEntry{
+				RelPath:      f.RelPath,
+				Slices:       append([]string(nil), f.Slices...),
+				PrimarySlice: f.PrimarySlice,
+				Reason:       "slice_budget_exceeded",
+				Detail:       detail,
+			})
+			p.Partial = true
+			continue
+		}
+		files[f.PrimarySlice]++
+		chars[f.PrimarySlice] += n
+		kept = append(kept, f)
+	}
+	p.Included = kept
+}
+
+// EnforceGlobalBudget ensures the rendered plan stays under MaxChars and MaxTokens
+// (whichever is hit first). It applies Limits.DropPolicy and deterministic truncation tightening.
 func (b *Builder) EnforceGlobalBudget(
 	ctx context.Context,
 	plan Plan,
@@ -132,11 +326,104 @@ func (b *Builder) EnforceGlobalBudget(
 	if err != nil {
 		return Plan{}, "", err
 	}
-	if runeCount(rendered) <= b.Limits.MaxChars {
+	if b.Limits.Exceeded(rendered) == "" {
 		return plan, rendered, nil
 	}
 
-	// Drop slices from lowest priority to highest.
+	var (
+		plan2 Plan
+		r2    string
+		fits  bool
+	)
+	switch b.Limits.DropPolicy {
+	case DropLargest, DropNewest:
+		plan2, r2, fits, err = b.dropFiles(ctx, plan, renderFn)
+	default:
+		plan2, r2, fits, err = b.dropSlices(ctx, plan, slicePriorities, renderFn)
+	}
+	if err != nil {
+		return Plan{}, "", err
+	}
+	if fits {
+		return plan2, r2, nil
+	}
+
+	// Drop files ranked below the rest by include_priorities.
+	plan2, r2, fits, err = b.dropRanked(ctx, plan2, renderFn)
+	if err != nil {
+		return Plan{}, "", err
+	}
+	if fits {
+		return plan2, r2, nil
+	}
+
+	// Tighten per-file truncation (halve max lines) once and retry.
+	tight := plan2
+	tight.Included = nil
+	newMaxLines := max(1, b.Limits.PerFileMaxLines/2)
+	for _, f := range plan2.Included {
+		if err := ctx.Err(); err != nil {
+			return Plan{}, "", err
+		}
+		entry, err := b.read(f.RelPath, f.AbsPath, f.Slices, f.PrimarySlice, f.Priority, newMaxLines)
+		if err != nil {
+			// Treat any issue as unreadable/invalid and drop (partial).
+			tight.Dropped = append(tight.Dropped, DroppedEntry{
+				RelPath:      f.RelPath,
+				Slices:       append([]string(nil), f.Slices...),
+				PrimarySlice: f.PrimarySlice,
+				Reason:       "unreadable",
+				Detail:       err.Error(),
+			})
+			tight.Partial = true
+			continue
+		}
+		entry.SHA256 = f.SHA256
+		entry.IncludeIndex = f.IncludeIndex
+		entry.PriorityDelta = f.PriorityDelta
+		if entry.Truncated && b.SliceLimits[entry.PrimarySlice].WholeFilesOnly {
+			tight.Dropped = append(tight.Dropped, wholeFileDropped(entry, newMaxLines, b.Limits.PerFileMaxBytes))
+			continue
+		}
+		tight.Included = append(tight.Included, entry)
+	}
+	orderPlan(&tight)
+	r3, err := renderFn(tight)
+	if err != nil {
+		return Plan{}, "", err
+	}
+	if b.Limits.Exceeded(r3) == "" {
+		return tight, r3, nil
+	}
+
+	// Hard cut the rendered output.
+	hard := tight
+	hard.HardCut = true
+	hard.Partial = true
+	// Re-render with HardCut set so renderers that surface plan status can report it.
+	r4, err := renderFn(hard)
+	if err != nil {
+		return Plan{}, "", err
+	}
+	limit := b.Limits.Exceeded(r4)
+	if limit == "" {
+		limit = LimitMaxChars
+	}
+	marker := fmt.Sprintf("\n… [BUNDLE TRUNCATED: budget_exceeded limit=%s]\n", limit)
+	return hard, b.hardCut(r4, marker), nil
+}
+
+// dropSlices applies DropLowPriority: it drops slices from lowest priority to highest, keeping
+// the last one, until the bundle fits. Before a slice goes whole, the fewest of its files (in
+// rankOrder) that make the bundle fit are tried, keeping at least one. It reports whether it
+// fit; if not, the returned plan (with only the last slice) is the starting point for
+// truncation tightening.
+func (b *Builder) dropSlices(
+	ctx context.Context,
+	plan Plan,
+	slicePriorities map[string]int,
+	renderFn func(Plan) (string, error),
+) (Plan, string, bool, error) {
 	plan2 := plan
 	plan2.Partial = true
 	plan2.DroppedSlices = nil
@@ -158,12 +445,22 @@ func (b *Builder) EnforceGlobalBudget(
 
 	for _, dropSlice := range orderedSlices {
 		if err := ctx.Err(); err != nil {
-			return Plan{}, "", err
+			return Plan{}, "", false, err
 		}
 		// Never drop the highest remaining slice if it's the last one; break to tightening.
 		if countKeptSlices(keep) <= 1 {
 			break
 		}
+		// Dropping a few of the slice's files may be enough; the last one goes with the slice.
+		if order := rankOrder(plan2.Included, dropSlice); len(order) > 1 {
+			p, r, fits, err := b.dropFewest(ctx, plan2, order[:len(order)-1], "within slice", renderFn)
+			if err != nil {
+				return Plan{}, "", false, err
+			}
+			if fits {
+				return p, r, true, nil
+			}
+		}
 		keep[dropSlice] = false
 		plan2.DroppedSlices = append(plan2.DroppedSlices, dropSlice)
 
@@ -173,57 +470,161 @@ func (b *Builder) EnforceGlobalBudget(
 
 		r2, err := renderFn(plan2)
 		if err != nil {
-			return Plan{}, "", err
+			return Plan{}, "", false, err
 		}
-		if runeCount(r2) <= b.Limits.MaxChars {
-			return plan2, r2, nil
+		if b.Limits.Exceeded(r2) == "" {
+			return plan2, r2, true, nil
 		}
 	}
+	return plan2, "", false, nil
+}
 
-	// Tighten per-file truncation (halve max lines) once and retry.
-	tight := plan2
-	tight.Included = nil
-	newMaxLines := max(1, b.Limits.PerFileMaxLines/2)
-	for _, f := range plan2.Included {
+// dropFiles applies DropLargest or DropNewest: it drops the fewest files, in policy order and
+// regardless of slice, that make the bundle fit, keeping at least one file. If even that does
+// not fit, the plan with every droppable file dropped is returned for truncation tightening.
+func (b *Builder) dropFiles(ctx context.Context, plan Plan, renderFn func(Plan) (string, error)) (Plan, string, bool, error) {
+	order := append([]FileEntry(nil), plan.Included...)
+	sort.Slice(order, func(i, j int) bool {
+		fi, fj := order[i], order[j]
+		if b.Limits.DropPolicy == DropNewest {
+			if !fi.ModTime.Equal(fj.ModTime) {
+				return fi.ModTime.After(fj.ModTime)
+			}
+		} else if fi.OriginalBytes != fj.OriginalBytes {
+			return fi.OriginalBytes > fj.OriginalBytes
+		}
+		return fi.RelPath < fj.RelPath
+	})
+	return b.dropFewest(ctx, plan, order[:max(len(order)-1, 0)], b.Limits.DropPolicy, renderFn)
+}
+
+// dropRanked drops the files ranked below the best-ranked ones (Priority+PriorityDelta), lowest
+// first, then largest, then by path: the fewest that make the bundle fit. The best-ranked files
+// are never dropped, so nothing is without include_priorities. If dropping every lower-ranked
+// file does not fit, that plan is returned for truncation tightening.
+func (b *Builder) dropRanked(ctx context.Context, plan Plan, renderFn func(Plan) (string, error)) (Plan, string, bool, error) {
+	order := rankOrder(plan.Included, "")
+	n := 0
+	for n < len(order) && order[n].rank() < order[len(order)-1].rank() {
+		n++
+	}
+	if n == 0 {
+		return plan, "", false, nil
+	}
+	return b.dropFewest(ctx, plan, order[:n], "include_priorities", renderFn)
+}
+
+// rankOrder returns the files of slice (by primary slice; every file when slice is "") in the
+// order they are dropped: lowest rank first, then largest (original bytes), then by path.
+func rankOrder(files []FileEntry, slice string) []FileEntry {
+	var order []FileEntry
+	for _, f := range files {
+		if slice == "" || f.PrimarySlice == slice {
+			order = append(order, f)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool {
+		fi, fj := order[i], order[j]
+		if fi.rank() != fj.rank() {
+			return fi.rank() < fj.rank()
+		}
+		if fi.OriginalBytes != fj.OriginalBytes {
+			return fi.OriginalBytes > fj.OriginalBytes
+		}
+		return fi.RelPath < fj.RelPath
+	})
+	return order
+}
+
+// rank is the file's priority with its include_priorities delta applied.
+func (f FileEntry) rank() int { return f.Priority + f.PriorityDelta }
+
+// dropFewest drops the shortest prefix of order (files of plan) that makes the bundle fit,
+// recording each file as budget_exceeded with detail. If dropping all of order does not fit,
+// that plan is returned with fits false.
+func (b *Builder) dropFewest(ctx context.Context, plan Plan, order []FileEntry, detail string, renderFn func(Plan) (string, error)) (Plan, string, bool, error) {
+	// without returns plan with the first k files of order dropped, and its rendering.
+	without := func(k int) (Plan, string, error) {
 		if err := ctx.Err(); err != nil {
 			return Plan{}, "", err
 		}
-		entry, err := readAndTruncateFile(f.RelPath, f.AbsPath, f.Slices, f.PrimarySlice, f.Priority, newMaxLines, b.Limits.PerFileMaxBytes)
-		if err != nil {
-			// Treat any issue as unreadable/invalid and drop (partial).
-			tight.Dropped = append(tight.Dropped, DroppedEntry{
+		drop := map[string]bool{}
+		for _, f := range order[:k] {
+			drop[f.RelPath] = true
+		}
+		p := plan
+		p.Partial = true
+		p.Included = nil
+		p.Dropped = append([]DroppedEntry(nil), plan.Dropped...)
+		for _, f := range plan.Included {
+			if !drop[f.RelPath] {
+				p.Included = append(p.Included, f)
+				continue
+			}
+			p.Dropped = append(p.Dropped, DroppedEntry{
 				RelPath:      f.RelPath,
 				Slices:       append([]string(nil), f.Slices...),
 				PrimarySlice: f.PrimarySlice,
-				Reason:       "unreadable",
-				Detail:       err.Error(),
+				Reason:       "budget_exceeded",
+				Detail:       detail,
 			})
-			tight.Partial = true
-			continue
 		}
-		tight.Included = append(tight.Included, entry)
+		orderPlan(&p)
+		r, err := renderFn(p)
+		return p, r, err
 	}
-	orderPlan(&tight)
-	r3, err := renderFn(tight)
+
+	hi := len(order)
+	best, bestR, err := without(hi)
 	if err != nil {
-		return Plan{}, "", err
+		return Plan{}, "", false, err
 	}
-	if runeCount(r3) <= b.Limits.MaxChars {
-		return tight, r3, nil
+	if hi < 1 || b.Limits.Exceeded(bestR) != "" {
+		return best, "", false, nil
 	}
+	// Binary search the fewest drops that fit; the bundle shrinks as files are dropped.
+	lo := 1
+	for lo < hi {
+		mid := (lo + hi) / 2
+		p, r, err := without(mid)
+		if err != nil {
+			return Plan{}, "", false, err
+		}
+		if b.Limits.Exceeded(r) == "" {
+			hi, best, bestR = mid, p, r
+		} else {
+			lo = mid + 1
+		}
+	}
+	return best, bestR, true, nil
+}
 
-	// Hard cut the rendered output.
-	hard := tight
-	hard.HardCut = true
-	hard.Partial = true
-	marker := "\n… [BUNDLE TRUNCATED: budget_exceeded]\n"
-	hardCut := hardCutRunes(r3, b.Limits.MaxChars-len([]rune(marker)))
-	if hardCut == "" {
-		hardCut = marker
-	} else {
-		hardCut += marker
-	}
-	return hard, hardCut, nil
+// hardCut returns the longest rune prefix of s that, with marker and HardCutReserve
+// appended, fits all limits. The reserve itself is not part of the result.
+func (b *Builder) hardCut(s, marker string) string {
+	tail := marker + b.HardCutReserve
+	r := []rune(s)
+	n := len(r)
+	if b.Limits.MaxChars > 0 {
+		n = min(n, b.Limits.MaxChars-len([]rune(tail)))
+	}
+	if n <= 0 {
+		return marker
+	}
+	if b.Limits.MaxTokens > 0 && b.Limits.Tokens(string(r[:n])+tail) > b.Limits.MaxTokens {
+		// Binary search the longest prefix within the token budget (estimates grow with length).
+		lo, hi := 0, n
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if b.Limits.Tokens(string(r[:mid])+tail) <= b.Limits.MaxTokens {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		n = lo
+	}
+	return string(r[:n]) + marker
 }
 
 func filterIncludedByKept(in []FileEntry, keep map[string]bool) []FileEntry {
@@ -247,7 +648,7 @@ func droppedFromRemovedSlices(in []FileEntry, keep map[string]bool) []DroppedEnt
 			Slices:       append([]string(nil), f.Slices...),
 			PrimarySlice: f.PrimarySlice,
 			Reason:       "budget_exceeded",
-			Detail:       "slice dropped",
+			Detail:       DetailSliceDropped,
 		})
 	}
 	return out
@@ -265,17 +666,6 @@ func countKeptSlices(keep map[string]bool) int {
 
 func runeCount(s string) int { return utf8.RuneCountInString(s) }
 
-func hardCutRunes(s string, max int) string {
-	if max <= 0 {
-		return ""
-	}
-	r := []rune(s)
-	if len(r) <= max {
-		return s
-	}
-	return string(r[:max])
-}
-
 func orderPlan(p *Plan) {
 	sort.Slice(p.Included, func(i, j int) bool {
 		if p.Included[i].Priority != p.Included[j].Priority {
@@ -286,7 +676,24 @@ func orderPlan(p *Plan) {
 	sort.Slice(p.Dropped, func(i, j int) bool { return p.Dropped[i].RelPath < p.Dropped[j].RelPath })
 }
 
-func readAndTruncateFile(rel, abs string, slices []string, primary string, priority int, maxLines, maxBytes int) (FileEntry, error) {
+// fileSHA256 returns the hex SHA-256 of the file at abs.
+func fileSHA256(abs string) (string, error) {
+	f, err := os.Open(abs)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readAndTruncateFile(rel, abs string, slices []string, primary string, priority int, maxLines, maxBytes int, mode string, tf contentTransform) (FileEntry, error) {
+	if mode == TruncateHeadTail {
+		return readHeadTail(rel, abs, slices, primary, priority, maxLines, maxBytes, tf)
+	}
 	st, err := os.Stat(abs)
 	if err != nil {
 		return FileEntry{}, err
@@ -299,8 +706,20 @@ func readAndTruncateFile(rel, abs string, slices []string, primary string, prior
 	}
 	defer func() { _ = f.Close() }()
 
+	src, origBytes, encoding, err := decodeText(f, origBytes)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	var tr transformed
+	if tf.active() {
+		if tr, err = tf.apply(src); err != nil {
+			return FileEntry{}, err
+		}
+		src, origBytes = tr.src, tr.size
+	}
+
 	var kept bytes.Buffer
-	reader := bufio.NewReaderSize(f, 64*1024)
+	reader := bufio.NewReaderSize(src, 64*1024)
 
 	origLines := 0
 	keptLines := 0
@@ -401,9 +820,13 @@ func readAndTruncateFile(rel, abs string, slices []string, primary string, prior
 	content := kept.String()
 	content = util.NormalizeNewlines(content)
 
+	var segments []LineRange
 	if truncated {
 		marker := fmt.Sprintf("… [TRUNCATED: original_lines=%d kept_lines=%d]\n", origLines, keptLines)
 		content += marker
+		if keptLines > 0 {
+			segments = []LineRange{{Start: 1, End: keptLines}}
+		}
 	}
 
 	return FileEntry{
@@ -414,10 +837,16 @@ func readAndTruncateFile(rel, abs string, slices []string, primary string, prior
 		Priority:      priority,
 		OriginalLines: origLines,
 		OriginalBytes: origBytes,
+		ModTime:       st.ModTime(),
 		KeptLines:     keptLines,
 		KeptBytes:     kept.Len(),
 		Truncated:     truncated,
+		Segments:      segments,
 		Content:       content,
+		Stripped:      tr.stripped,
+		Redacted:      tr.redacted,
+		Empty:         !seenAny,
+		Encoding:      encoding,
 	}, nil
 }
 
@@ -2,10 +2,13 @@ package budget
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mmrzaf/snip/internal/selector"
 )
@@ -129,3 +132,662 @@ func TestGlobalBudgetDropsLowPrioritySlice(t *testing.T) {
 		t.Fatalf("expected partial")
 	}
 }
+
+func TestGlobalBudgetDropsFilesWithinSliceFirst(t *testing.T) {
+	t.Parallel()
+
+	plan := Plan{
+		Profile:       "p",
+		EnabledSlices: []string{"api", "docs", "tests"},
+		Included: []FileEntry{
+			{RelPath: "api.go", Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, OriginalBytes: 10, Content: "a"},
+			{RelPath: "big_test.go", Slices: []string{"tests"}, PrimarySlice: "tests", Priority: 1, OriginalBytes: 900, Content: "t"},
+			{RelPath: "docs/a.md", Slices: []string{"docs"}, PrimarySlice: "docs", Priority: 50, OriginalBytes: 500, Content: "d"},
+			{RelPath: "docs/b.md", Slices: []string{"docs"}, PrimarySlice: "docs", Priority: 50, OriginalBytes: 100, Content: "d"},
+			{RelPath: "docs/c.md", Slices: []string{"docs"}, PrimarySlice: "docs", Priority: 50, PriorityDelta: -1, OriginalBytes: 50, Content: "d"},
+			{RelPath: "small_test.go", Slices: []string{"tests"}, PrimarySlice: "tests", Priority: 1, OriginalBytes: 5, Content: "t"},
+		},
+	}
+	slicePriorities := map[string]int{"api": 100, "docs": 50, "tests": 1}
+	// One char per file.
+	renderFn := func(p Plan) (string, error) { return strings.Repeat("x", len(p.Included)), nil }
+
+	for _, tc := range []struct {
+		maxChars      int
+		dropped       string
+		droppedSlices string
+	}{
+		// The largest file of the lowest slice is enough.
+		{5, "big_test.go=within slice", ""},
+		// The lowest slice goes whole, then the next slice loses its lowest-ranked file,
+		// then its largest.
+		{3, "big_test.go=slice dropped docs/c.md=within slice small_test.go=slice dropped", "tests"},
+		{2, "big_test.go=slice dropped docs/a.md=within slice docs/c.md=within slice small_test.go=slice dropped", "tests"},
+	} {
+		b := &Builder{Limits: Limits{MaxChars: tc.maxChars, PerFileMaxLines: 10, PerFileMaxBytes: 1 << 20}}
+		final, _, err := b.EnforceGlobalBudget(context.Background(), plan, slicePriorities, renderFn)
+		if err != nil {
+			t.Fatalf("max_chars=%d: EnforceGlobalBudget: %v", tc.maxChars, err)
+		}
+		var got []string
+		for _, d := range final.Dropped {
+			got = append(got, d.RelPath+"="+d.Detail)
+		}
+		if strings.Join(got, " ") != tc.dropped || strings.Join(final.DroppedSlices, " ") != tc.droppedSlices {
+			t.Fatalf("max_chars=%d: dropped=%v slices=%v\nwant %s slices=%s", tc.maxChars, got, final.DroppedSlices, tc.dropped, tc.droppedSlices)
+		}
+		if !final.Partial || final.HardCut {
+			t.Fatalf("max_chars=%d: partial=%v hard_cut=%v", tc.maxChars, final.Partial, final.HardCut)
+		}
+	}
+}
+
+func TestGlobalBudgetDropFilePolicies(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	plan := Plan{
+		Profile:       "p",
+		EnabledSlices: []string{"api", "docs"},
+		Included: []FileEntry{
+			{RelPath: "a", Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, OriginalBytes: 50, ModTime: now, Content: "a"},
+			{RelPath: "b", Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, OriginalBytes: 10, ModTime: now.Add(-time.Hour), Content: "b"},
+			{RelPath: "c", Slices: []string{"docs"}, PrimarySlice: "docs", Priority: 1, OriginalBytes: 80, ModTime: now.Add(-2 * time.Hour), Content: "c"},
+		},
+	}
+	slicePriorities := map[string]int{"api": 100, "docs": 1}
+	// One char per file: fits once a single file has been dropped.
+	renderFn := func(p Plan) (string, error) { return strings.Repeat("x", len(p.Included)), nil }
+
+	for policy, want := range map[string]string{DropLargest: "c", DropNewest: "a"} {
+		b := &Builder{Limits: Limits{MaxChars: 2, PerFileMaxLines: 10, PerFileMaxBytes: 1 << 20, DropPolicy: policy}}
+		final, _, err := b.EnforceGlobalBudget(context.Background(), plan, slicePriorities, renderFn)
+		if err != nil {
+			t.Fatalf("%s: EnforceGlobalBudget: %v", policy, err)
+		}
+		if len(final.DroppedSlices) != 0 {
+			t.Fatalf("%s: droppedSlices=%v", policy, final.DroppedSlices)
+		}
+		if len(final.Dropped) != 1 || final.Dropped[0].RelPath != want || final.Dropped[0].Detail != policy {
+			t.Fatalf("%s: dropped=%+v", policy, final.Dropped)
+		}
+		if len(final.Included) != 2 || !final.Partial {
+			t.Fatalf("%s: included=%d partial=%v", policy, len(final.Included), final.Partial)
+		}
+	}
+
+	// With a tighter budget, drop_newest keeps the oldest file, from the lowest slice.
+	b := &Builder{Limits: Limits{MaxChars: 1, PerFileMaxLines: 10, PerFileMaxBytes: 1 << 20, DropPolicy: DropNewest}}
+	final, _, err := b.EnforceGlobalBudget(context.Background(), plan, slicePriorities, renderFn)
+	if err != nil {
+		t.Fatalf("EnforceGlobalBudget: %v", err)
+	}
+	if len(final.Included) != 1 || final.Included[0].RelPath != "c" {
+		t.Fatalf("included=%+v", final.Included)
+	}
+}
+
+func TestGlobalBudgetDropsLowRankedFilesFirst(t *testing.T) {
+	t.Parallel()
+
+	plan := Plan{
+		Profile:       "p",
+		EnabledSlices: []string{"api"},
+		Included: []FileEntry{
+			{RelPath: "core/a.go", Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, PriorityDelta: 10, OriginalBytes: 90, Content: "a"},
+			{RelPath: "main.go", Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, OriginalBytes: 10, Content: "m"},
+			{RelPath: "util/b.go", Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, PriorityDelta: -5, OriginalBytes: 20, Content: "b"},
+			{RelPath: "util/c.go", Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, PriorityDelta: -5, OriginalBytes: 30, Content: "c"},
+		},
+	}
+	// One char per file.
+	renderFn := func(p Plan) (string, error) { return strings.Repeat("x", len(p.Included)), nil }
+
+	for maxChars, want := range map[int][]string{3: {"util/c.go"}, 2: {"util/b.go", "util/c.go"}, 1: {"main.go", "util/b.go", "util/c.go"}} {
+		b := &Builder{Limits: Limits{MaxChars: maxChars, PerFileMaxLines: 10, PerFileMaxBytes: 1 << 20}}
+		final, _, err := b.EnforceGlobalBudget(context.Background(), plan, map[string]int{"api": 100}, renderFn)
+		if err != nil {
+			t.Fatalf("max_chars=%d: EnforceGlobalBudget: %v", maxChars, err)
+		}
+		var got []string
+		for _, d := range final.Dropped {
+			if d.Reason != "budget_exceeded" || d.Detail != "include_priorities" {
+				t.Fatalf("max_chars=%d: dropped=%+v", maxChars, d)
+			}
+			got = append(got, d.RelPath)
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") || !final.Partial || final.HardCut {
+			t.Fatalf("max_chars=%d: dropped=%v want %v (partial=%v hard_cut=%v)", maxChars, got, want, final.Partial, final.HardCut)
+		}
+	}
+}
+
+func TestGlobalBudgetTighteningKeepsIncludeIndex(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content := strings.Repeat("xxxxxxxxx\n", 10)
+	plan := Plan{Profile: "p", EnabledSlices: []string{"api"}}
+	// include: ["b.go", "a.go"]
+	for i, name := range []string{"b.go", "a.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		plan.Included = append(plan.Included, FileEntry{RelPath: name, AbsPath: path, Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, IncludeIndex: i, KeptLines: 10, Content: content})
+	}
+	// Ten chars per kept line; dropping a file costs as much as it saves, so only
+	// tightening both files fits.
+	renderFn := func(p Plan) (string, error) {
+		n := 100 * len(p.Dropped)
+		for _, f := range p.Included {
+			n += 10 * f.KeptLines
+		}
+		return strings.Repeat("x", n), nil
+	}
+	b := &Builder{Limits: Limits{MaxChars: 150, PerFileMaxLines: 10, PerFileMaxBytes: 1 << 20}}
+	final, _, err := b.EnforceGlobalBudget(context.Background(), plan, map[string]int{"api": 100}, renderFn)
+	if err != nil {
+		t.Fatalf("EnforceGlobalBudget: %v", err)
+	}
+	if final.HardCut || len(final.Included) != 2 || final.Included[0].KeptLines != 5 {
+		t.Fatalf("expected tightening to fit: %+v", final)
+	}
+	for _, f := range final.Included {
+		if want := map[string]int{"b.go": 0, "a.go": 1}[f.RelPath]; f.IncludeIndex != want {
+			t.Fatalf("%s IncludeIndex=%d want %d", f.RelPath, f.IncludeIndex, want)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	t.Parallel()
+
+	if got := EstimateTokens(""); got != 0 {
+		t.Fatalf("empty=%d", got)
+	}
+	// Plain words approximate chars/4.
+	if got := EstimateTokens("abcdefghabcdefgh"); got != 4 {
+		t.Fatalf("words=%d want 4", got)
+	}
+	// Punctuation-heavy code costs more than the same number of word chars.
+	if EstimateTokens("{}();[]{}();[]") <= EstimateTokens("abcdefghijklmn") {
+		t.Fatalf("punctuation should estimate higher than words")
+	}
+	// Indentation is cheap relative to words.
+	if EstimateTokens("                ") >= EstimateTokens("abcdefghabcdefgh") {
+		t.Fatalf("whitespace should estimate lower than words")
+	}
+}
+
+func TestGlobalBudgetStopsAtFirstLimitHit(t *testing.T) {
+	t.Parallel()
+
+	// One "token" per rune makes the token limit the tighter one.
+	perRune := func(s string) int { return len([]rune(s)) }
+	b := &Builder{Limits: Limits{MaxChars: 1000, MaxTokens: 60, PerFileMaxLines: 10, PerFileMaxBytes: 1 << 20, TokenEstimator: perRune}}
+	plan := Plan{
+		Profile:       "p",
+		EnabledSlices: []string{"api"},
+		Included: []FileEntry{
+			{RelPath: "a", AbsPath: "/x/a", Slices: []string{"api"}, PrimarySlice: "api", Priority: 100, Content: "aaaa"},
+		},
+	}
+	renderFn := func(p Plan) (string, error) { return strings.Repeat("x", 200), nil }
+	final, rendered, err := b.EnforceGlobalBudget(context.Background(), plan, map[string]int{"api": 100}, renderFn)
+	if err != nil {
+		t.Fatalf("EnforceGlobalBudget: %v", err)
+	}
+	if !final.HardCut {
+		t.Fatalf("expected hard cut")
+	}
+	if !strings.Contains(rendered, "[BUNDLE TRUNCATED: budget_exceeded limit=max_tokens]") {
+		t.Fatalf("marker should name max_tokens: %q", rendered)
+	}
+	if got := perRune(rendered); got > 60 {
+		t.Fatalf("rendered tokens=%d want <= 60", got)
+	}
+
+	// With a tighter char budget, max_chars is reported instead.
+	b.Limits.MaxChars = 80
+	b.Limits.MaxTokens = 150
+	_, rendered, err = b.EnforceGlobalBudget(context.Background(), plan, map[string]int{"api": 100}, renderFn)
+	if err != nil {
+		t.Fatalf("EnforceGlobalBudget: %v", err)
+	}
+	if !strings.Contains(rendered, "limit=max_chars]") {
+		t.Fatalf("marker should name max_chars: %q", rendered)
+	}
+	if got := len([]rune(rendered)); got > 80 {
+		t.Fatalf("rendered chars=%d want <= 80", got)
+	}
+}
+
+func TestSliceLimitsDropWithinSlice(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return p
+	}
+	file := func(name, slice string, pri int) selector.File {
+		return selector.File{RelPath: name, AbsPath: filepath.Join(dir, name), Slices: []string{slice}, PrimarySlice: slice, PrimaryPriority: pri}
+	}
+	write("a.go", "package a\n")
+	write("d1.md", "one\n")
+	write("d2.md", "two\n")
+	write("d3.md", "three\n")
+
+	b := &Builder{
+		Limits:      Limits{MaxChars: 100000, PerFileMaxLines: 100, PerFileMaxBytes: 1 << 20},
+		SliceLimits: map[string]SliceLimits{"docs": {MaxFiles: 2}},
+	}
+	selected := selector.Selected{Included: []selector.File{
+		file("a.go", "api", 100),
+		file("d1.md", "docs", 1),
+		file("d2.md", "docs", 1),
+		file("d3.md", "docs", 1),
+	}}
+	plan, err := b.BuildPlan(context.Background(), "p", []string{"api", "docs"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.Included) != 3 {
+		t.Fatalf("included=%d want 3", len(plan.Included))
+	}
+	if len(plan.Dropped) != 1 || plan.Dropped[0].RelPath != "d3.md" || plan.Dropped[0].Reason != "slice_budget_exceeded" {
+		t.Fatalf("dropped=%+v", plan.Dropped)
+	}
+	if !plan.Partial {
+		t.Fatalf("expected partial")
+	}
+
+	// Char limit: "one\n" fits (4 chars); "two\n" would exceed 6.
+	b.SliceLimits = map[string]SliceLimits{"docs": {MaxChars: 6}}
+	plan, err = b.BuildPlan(context.Background(), "p", []string{"api", "docs"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.Included) != 2 || len(plan.Dropped) != 2 {
+		t.Fatalf("included=%d dropped=%d want 2/2", len(plan.Included), len(plan.Dropped))
+	}
+}
+
+func TestWholeFilesOnlyDropsInsteadOfTruncating(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"big.yaml":   "a: 1\nb: 2\nc: 3\nd: 4\n",
+		"small.yaml": "a: 1\nb: 2\n",
+		"big.go":     "l1\nl2\nl3\nl4\n",
+	}
+	var selected selector.Selected
+	for _, name := range []string{"big.go", "big.yaml", "small.yaml"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(files[name]), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		slice := "config"
+		if strings.HasSuffix(name, ".go") {
+			slice = "api"
+		}
+		selected.Included = append(selected.Included, selector.File{RelPath: name, AbsPath: p, Slices: []string{slice}, PrimarySlice: slice})
+	}
+
+	b := &Builder{
+		Limits:      Limits{MaxChars: 100000, PerFileMaxLines: 3, PerFileMaxBytes: 1 << 20},
+		SliceLimits: map[string]SliceLimits{"config": {WholeFilesOnly: true}},
+	}
+	plan, err := b.BuildPlan(context.Background(), "p", []string{"api", "config"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.Dropped) != 1 || plan.Dropped[0].RelPath != "big.yaml" || plan.Dropped[0].Reason != "whole_file_too_large" {
+		t.Fatalf("dropped=%+v", plan.Dropped)
+	}
+	if len(plan.Included) != 2 || !plan.Partial {
+		t.Fatalf("included=%d partial=%v", len(plan.Included), plan.Partial)
+	}
+	for _, f := range plan.Included {
+		if f.RelPath == "big.go" && !f.Truncated {
+			t.Fatalf("big.go should still be truncated: %+v", f)
+		}
+	}
+
+	// Tightening halves per_file_max_lines to 1: small.yaml is dropped rather than cut.
+	b.Limits.MaxChars = 1
+	renderFn := func(p Plan) (string, error) {
+		for _, f := range p.Included {
+			if f.Truncated && f.PrimarySlice == "config" {
+				t.Fatalf("config file truncated: %+v", f)
+			}
+		}
+		return "xx", nil
+	}
+	final, _, err := b.EnforceGlobalBudget(context.Background(), plan, map[string]int{}, renderFn)
+	if err != nil {
+		t.Fatalf("EnforceGlobalBudget: %v", err)
+	}
+	for _, f := range final.Included {
+		if f.RelPath == "small.yaml" {
+			t.Fatalf("small.yaml should be dropped after tightening")
+		}
+	}
+}
+
+func TestHeadTailTruncationKeepsBothEnds(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.txt")
+	var sb strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&sb, "l%d\n", i)
+	}
+	if err := os.WriteFile(p, []byte(sb.String()), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	b := &Builder{Limits: Limits{MaxChars: 100000, PerFileMaxLines: 6, PerFileMaxBytes: 1 << 20, TruncateMode: TruncateHeadTail}}
+	selected := selector.Selected{Included: []selector.File{{
+		RelPath:         "a.txt",
+		AbsPath:         p,
+		Slices:          []string{"api"},
+		PrimarySlice:    "api",
+		PrimaryPriority: 10,
+	}}}
+	plan, err := b.BuildPlan(context.Background(), "p", []string{"api"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	fe := plan.Included[0]
+	want := "l1\nl2\nl3\nl4\n… [MIDDLE TRUNCATED: original_lines=10 kept_lines=6 omitted=5-8]\nl9\nl10\n"
+	if fe.Content != want {
+		t.Fatalf("content=%q\nwant   %q", fe.Content, want)
+	}
+	if !fe.Truncated || fe.KeptLines != 6 || fe.OriginalLines != 10 {
+		t.Fatalf("entry=%+v", fe)
+	}
+	if len(fe.Segments) != 2 || fe.Segments[0] != (LineRange{1, 4}) || fe.Segments[1] != (LineRange{9, 10}) {
+		t.Fatalf("segments=%v", fe.Segments)
+	}
+
+	// Files within budget are untouched.
+	b.Limits.PerFileMaxLines = 10
+	plan, err = b.BuildPlan(context.Background(), "p", []string{"api"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if fe := plan.Included[0]; fe.Truncated || fe.Content != sb.String() {
+		t.Fatalf("untruncated entry=%+v", fe)
+	}
+
+	// A long minified line over the byte budget is skipped, even with a rune split across
+	// read chunks.
+	long := strings.Repeat("x", 32765) + "é" + strings.Repeat("x", 70000)
+	if err := os.WriteFile(p, []byte("a\n"+long+"\nb\nc\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	b.Limits.PerFileMaxLines = 6
+	b.Limits.PerFileMaxBytes = 100
+	plan, err = b.BuildPlan(context.Background(), "p", []string{"api"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	want = "a\n… [MIDDLE TRUNCATED: original_lines=4 kept_lines=3 omitted=2-2]\nb\nc\n"
+	if fe := plan.Included[0]; fe.Content != want || fe.KeptBytes != 6 {
+		t.Fatalf("content=%q kept_bytes=%d\nwant   %q", fe.Content, fe.KeptBytes, want)
+	}
+}
+
+func TestContextLinesExcerptMatches(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.go")
+	var sb strings.Builder
+	for i := 1; i <= 20; i++ {
+		if i == 5 || i == 7 || i == 15 {
+			fmt.Fprintf(&sb, "hit%d\n", i)
+			continue
+		}
+		fmt.Fprintf(&sb, "l%d\n", i)
+	}
+	if err := os.WriteFile(p, []byte(sb.String()), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	b := &Builder{
+		Limits:      Limits{MaxChars: 100000, PerFileMaxLines: 100, PerFileMaxBytes: 1 << 20},
+		SliceLimits: map[string]SliceLimits{"api": {Contains: regexp.MustCompile(`hit`), ContextLines: 1}},
+	}
+	selected := selector.Selected{Included: []selector.File{{
+		RelPath:         "a.go",
+		AbsPath:         p,
+		Slices:          []string{"api"},
+		PrimarySlice:    "api",
+		PrimaryPriority: 10,
+	}}}
+	plan, err := b.BuildPlan(context.Background(), "p", []string{"api"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	fe := plan.Included[0]
+	want := "… [GAP]\nl4\nhit5\nl6\nhit7\nl8\n… [GAP]\nl14\nhit15\nl16\n… [GAP]\n"
+	if fe.Content != want {
+		t.Fatalf("content=%q\nwant   %q", fe.Content, want)
+	}
+	if !fe.Excerpt || !fe.Truncated || fe.KeptLines != 8 || fe.OriginalLines != 20 {
+		t.Fatalf("entry=%+v", fe)
+	}
+	if len(fe.Segments) != 3 || fe.Segments[0] != (LineRange{1, 0}) || fe.Segments[1] != (LineRange{4, 8}) || fe.Segments[2] != (LineRange{14, 16}) {
+		t.Fatalf("segments=%v", fe.Segments)
+	}
+
+	// The per-file limits still cut the excerpt short.
+	b.Limits.PerFileMaxLines = 6
+	plan, err = b.BuildPlan(context.Background(), "p", []string{"api"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	want = "… [GAP]\nl4\nhit5\nl6\nhit7\nl8\n… [GAP]\nl14\n… [TRUNCATED: original_lines=20 kept_lines=6]\n"
+	if fe := plan.Included[0]; fe.Content != want || fe.KeptLines != 6 {
+		t.Fatalf("content=%q\nwant   %q", fe.Content, want)
+	}
+
+	// Without a match the file is read as usual.
+	b.Limits.PerFileMaxLines = 100
+	b.SliceLimits["api"] = SliceLimits{Contains: regexp.MustCompile(`absent`), ContextLines: 1}
+	plan, err = b.BuildPlan(context.Background(), "p", []string{"api"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if fe := plan.Included[0]; fe.Excerpt || fe.Truncated || fe.Content != sb.String() {
+		t.Fatalf("unmatched entry=%+v", fe)
+	}
+}
+
+func TestHashContentUsesOriginalBytes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(p, []byte("l1\nl2\nl3\nl4\nl5\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	selected := selector.Selected{Included: []selector.File{{
+		RelPath: "a.txt", AbsPath: p, Slices: []string{"api"}, PrimarySlice: "api", PrimaryPriority: 10,
+	}}}
+
+	b := &Builder{Limits: Limits{MaxChars: 100000, PerFileMaxLines: 2, PerFileMaxBytes: 1 << 20}, HashContent: true}
+	plan, err := b.BuildPlan(context.Background(), "p", []string{"api"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	// sha256 of "l1\nl2\nl3\nl4\nl5\n", not of the two kept lines.
+	const want = "7b4d7795f2964691768ffa4bf908374a8c4d01a04196703ce99669740a96c019"
+	if got := plan.Included[0].SHA256; got != want {
+		t.Fatalf("SHA256=%s want %s", got, want)
+	}
+
+	b.HashContent = false
+	plan, err = b.BuildPlan(context.Background(), "p", []string{"api"}, selected)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if plan.Included[0].SHA256 != "" {
+		t.Fatalf("SHA256 set without HashContent: %s", plan.Included[0].SHA256)
+	}
+}
+
+func TestStripPatternsRemoveLicenseHeader(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	licensed := filepath.Join(dir, "a.go")
+	body := "package a\n\nfunc A() {}\n"
+	original := "// Copyright 2024 Example\r\n// Licensed under MIT\r\n\r\n" + body
+	if err := os.WriteFile(licensed, []byte(original), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	plain := filepath.Join(dir, "b.go")
+	if err := os.WriteFile(plain, []byte(body), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	strip := []*regexp.Regexp{regexp.MustCompile(`\A(?://[^\n]*\n)+\n*`)}
+	for _, mode := range []string{TruncateHead, TruncateHeadTail} {
+		b := &Builder{
+			Limits:        Limits{MaxChars: 100000, PerFileMaxLines: 100, PerFileMaxBytes: 1 << 20, TruncateMode: mode},
+			StripPatterns: strip,
+		}
+		selected := selector.Selected{Included: []selector.File{
+			{RelPath: "a.go", AbsPath: licensed, Slices: []string{"code"}, PrimarySlice: "code"},
+			{RelPath: "b.go", AbsPath: plain, Slices: []string{"code"}, PrimarySlice: "code"},
+		}}
+		plan, err := b.BuildPlan(context.Background(), "p", []string{"code"}, selected)
+		if err != nil {
+			t.Fatalf("%s: BuildPlan: %v", mode, err)
+		}
+		a, other := plan.Included[0], plan.Included[1]
+		if !a.Stripped || a.Content != body || a.OriginalLines != 3 || a.OriginalBytes != int64(len(body)) {
+			t.Fatalf("%s: stripped entry=%+v", mode, a)
+		}
+		if other.Stripped || other.Content != body {
+			t.Fatalf("%s: untouched entry=%+v", mode, other)
+		}
+	}
+	if got, err := os.ReadFile(licensed); err != nil || string(got) != original {
+		t.Fatalf("file on disk changed: %q err=%v", got, err)
+	}
+}
+
+func TestWhitespaceRewritesShrinkContent(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(p, []byte("package a  \n\n\n \t\nfunc A() {}\t\n\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	selected := selector.Selected{Included: []selector.File{{RelPath: "a.go", AbsPath: p, Slices: []string{"code"}, PrimarySlice: "code"}}}
+
+	cases := []struct {
+		collapse, trim bool
+		want           string
+	}{
+		{false, false, "package a  \n\n\n \t\nfunc A() {}\t\n\n"},
+		{true, false, "package a  \n\nfunc A() {}\t\n\n"},
+		{false, true, "package a\n\n\n\nfunc A() {}\n\n"},
+		{true, true, "package a\n\nfunc A() {}\n\n"},
+	}
+	for _, tc := range cases {
+		b := &Builder{Limits: Limits{
+			MaxChars: 100000, PerFileMaxLines: 100, PerFileMaxBytes: 1 << 20,
+			CollapseBlankLines: tc.collapse, TrimTrailingWhitespace: tc.trim,
+		}}
+		plan, err := b.BuildPlan(context.Background(), "p", []string{"code"}, selected)
+		if err != nil {
+			t.Fatalf("BuildPlan: %v", err)
+		}
+		fe := plan.Included[0]
+		if fe.Content != tc.want || fe.KeptBytes != len(tc.want) || fe.KeptLines != strings.Count(tc.want, "\n") || fe.Stripped {
+			t.Fatalf("collapse=%t trim=%t: content=%q kept_lines=%d kept_bytes=%d", tc.collapse, tc.trim, fe.Content, fe.KeptLines, fe.KeptBytes)
+		}
+	}
+}
+
+func TestEmptyFilesAreFlagged(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.go")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	blank := filepath.Join(dir, "blank.go")
+	if err := os.WriteFile(blank, []byte("\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	selected := selector.Selected{Included: []selector.File{
+		{RelPath: "blank.go", AbsPath: blank, Slices: []string{"code"}, PrimarySlice: "code"},
+		{RelPath: "empty.go", AbsPath: empty, Slices: []string{"code"}, PrimarySlice: "code"},
+	}}
+	for _, mode := range []string{TruncateHead, TruncateHeadTail} {
+		b := &Builder{Limits: Limits{MaxChars: 100000, PerFileMaxLines: 10, PerFileMaxBytes: 1 << 20, TruncateMode: mode}}
+		plan, err := b.BuildPlan(context.Background(), "p", []string{"code"}, selected)
+		if err != nil {
+			t.Fatalf("%s: BuildPlan: %v", mode, err)
+		}
+		if plan.Included[0].Empty || !plan.Included[1].Empty {
+			t.Fatalf("%s: empty flags=%t,%t", mode, plan.Included[0].Empty, plan.Included[1].Empty)
+		}
+	}
+}
+
+func TestBOMFilesAreDecoded(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"plain.txt": []byte("héllo\nworld\n"),
+		"bom8.txt":  []byte("\xef\xbb\xbfhéllo\nworld\n"),
+		"le.txt":    []byte("\xff\xfeh\x00\xe9\x00l\x00l\x00o\x00\n\x00w\x00o\x00r\x00l\x00d\x00\n\x00"),
+		"be.txt":    []byte("\xfe\xff\x00h\x00\xe9\x00l\x00l\x00o\x00\n\x00w\x00o\x00r\x00l\x00d\x00\n"),
+		"odd.txt":   []byte("\xff\xfeh\x00i"),
+	}
+	var selected selector.Selected
+	for _, name := range []string{"be.txt", "bom8.txt", "le.txt", "odd.txt", "plain.txt"} {
+		abs := filepath.Join(dir, name)
+		if err := os.WriteFile(abs, files[name], 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		selected.Included = append(selected.Included, selector.File{RelPath: name, AbsPath: abs, Slices: []string{"code"}, PrimarySlice: "code"})
+	}
+
+	want := map[string]string{"plain.txt": "", "bom8.txt": "utf-8-bom", "le.txt": "utf-16le", "be.txt": "utf-16be"}
+	for _, mode := range []string{TruncateHead, TruncateHeadTail} {
+		b := &Builder{Limits: Limits{MaxChars: 100000, PerFileMaxLines: 1, PerFileMaxBytes: 1 << 20, TruncateMode: mode}}
+		plan, err := b.BuildPlan(context.Background(), "p", []string{"code"}, selected)
+		if err != nil {
+			t.Fatalf("%s: BuildPlan: %v", mode, err)
+		}
+		if len(plan.Dropped) != 1 || plan.Dropped[0].RelPath != "odd.txt" || plan.Dropped[0].Reason != "invalid_utf8" {
+			t.Fatalf("%s: dropped=%+v", mode, plan.Dropped)
+		}
+		for _, f := range plan.Included {
+			if f.Encoding != want[f.RelPath] {
+				t.Fatalf("%s: %s encoding=%q", mode, f.RelPath, f.Encoding)
+			}
+			if !strings.HasPrefix(f.Content, "héllo\n") || f.OriginalLines != 2 || f.OriginalBytes != 13 {
+				t.Fatalf("%s: %s content=%q lines=%d bytes=%d", mode, f.RelPath, f.Content, f.OriginalLines, f.OriginalBytes)
+			}
+		}
+	}
+}
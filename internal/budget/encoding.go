@@ -0,0 +1,41 @@
+package budget
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// decodeText checks r for a byte order mark. Without one, r's bytes are returned as they
+// are and enc is empty. A UTF-8 BOM is skipped; UTF-16 content is transcoded to UTF-8 in
+// memory (an odd byte count is reported as errInvalidUTF8). size is the byte size of the
+// returned content, given the original size.
+func decodeText(r io.Reader, size int64) (src io.Reader, n int64, enc string, err error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, err := br.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, 0, "", err
+	}
+	enc, bomLen := util.DetectBOM(head)
+	if enc == "" {
+		return br, size, "", nil
+	}
+	if _, err := br.Discard(bomLen); err != nil {
+		return nil, 0, "", err
+	}
+	if enc == util.EncodingUTF8BOM {
+		return br, size - int64(bomLen), enc, nil
+	}
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	text, ok := util.DecodeUTF16(data, enc)
+	if !ok {
+		return nil, 0, "", errInvalidUTF8
+	}
+	return strings.NewReader(text), int64(len(text)), enc, nil
+}
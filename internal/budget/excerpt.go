@@ -0,0 +1,174 @@
+package budget
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// GapMarker separates the windows of an excerpt (see SliceLimits.ContextLines).
+const GapMarker = "… [GAP]"
+
+// read reads one selected file under the per-file limits with maxLines: as an excerpt of its
+// contains_regex matches when its primary slice sets ContextLines, else whole or truncated
+// per Limits.TruncateMode.
+func (b *Builder) read(rel, abs string, slices []string, primary string, priority, maxLines int) (FileEntry, error) {
+	if lim := b.SliceLimits[primary]; lim.ContextLines > 0 && lim.Contains != nil {
+		return readExcerpt(rel, abs, slices, primary, priority, maxLines, b.Limits.PerFileMaxBytes, lim.Contains, lim.ContextLines, b.transform(), b.Limits.TruncateMode)
+	}
+	return readAndTruncateFile(rel, abs, slices, primary, priority, maxLines, b.Limits.PerFileMaxBytes, b.Limits.TruncateMode, b.transform())
+}
+
+// readExcerpt keeps only the lines of the file that match re plus context lines around each
+// match, merging windows that touch. Omitted stretches become a GapMarker line, and the
+// windows are recorded as Segments. The per-file limits still apply: when the windows exceed
+// them, the excerpt ends at the last line that fits with the usual truncation marker. A file
+// without matches (after strip/redact transforms), or whose windows cover every line, is read
+// as usual.
+func readExcerpt(rel, abs string, slices []string, primary string, priority int, maxLines, maxBytes int, re *regexp.Regexp, context int, tf contentTransform, mode string) (FileEntry, error) {
+	whole := func() (FileEntry, error) {
+		return readAndTruncateFile(rel, abs, slices, primary, priority, maxLines, maxBytes, mode, tf)
+	}
+	st, err := os.Stat(abs)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	src, size, encoding, err := decodeText(f, st.Size())
+	if err != nil {
+		return FileEntry{}, err
+	}
+	var tr transformed
+	if tf.active() {
+		if tr, err = tf.apply(src); err != nil {
+			return FileEntry{}, err
+		}
+		src, size = tr.src, tr.size
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	if !utf8.Valid(data) {
+		return FileEntry{}, errInvalidUTF8
+	}
+	text := util.NormalizeNewlines(string(data))
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	windows := matchWindows(text, lines, re, context)
+	if len(windows) == 0 || (len(windows) == 1 && windows[0] == LineRange{Start: 1, End: len(lines)}) {
+		return whole()
+	}
+
+	var (
+		sb        strings.Builder
+		segments  []LineRange
+		kept      int
+		keptBytes int
+		cut       bool
+		next      = 1 // first original line not yet written or skipped
+	)
+	for _, w := range windows {
+		if kept >= maxLines || keptBytes+len(lines[w.Start-1]) > maxBytes {
+			cut = true
+			break
+		}
+		if w.Start > next {
+			if len(segments) == 0 {
+				// An empty first segment keeps the leading marker unnumbered.
+				segments = append(segments, LineRange{Start: 1, End: 0})
+			}
+			sb.WriteString(GapMarker + "\n")
+		}
+		seg := LineRange{Start: w.Start, End: w.Start - 1}
+		for n := w.Start; n <= w.End; n++ {
+			line := lines[n-1]
+			if kept >= maxLines || keptBytes+len(line) > maxBytes {
+				cut = true
+				break
+			}
+			sb.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				sb.WriteString("\n")
+			}
+			kept++
+			keptBytes += len(line)
+			seg.End = n
+		}
+		segments = append(segments, seg)
+		next = seg.End + 1
+		if cut {
+			break
+		}
+	}
+	switch {
+	case cut:
+		fmt.Fprintf(&sb, "… [TRUNCATED: original_lines=%d kept_lines=%d]\n", len(lines), kept)
+	case next <= len(lines):
+		sb.WriteString(GapMarker + "\n")
+	}
+
+	return FileEntry{
+		RelPath:       rel,
+		AbsPath:       abs,
+		Slices:        append([]string(nil), slices...),
+		PrimarySlice:  primary,
+		Priority:      priority,
+		OriginalLines: len(lines),
+		OriginalBytes: size,
+		ModTime:       st.ModTime(),
+		KeptLines:     kept,
+		KeptBytes:     keptBytes,
+		Truncated:     true,
+		Segments:      segments,
+		Content:       sb.String(),
+		Stripped:      tr.stripped,
+		Redacted:      tr.redacted,
+		Excerpt:       true,
+		Encoding:      encoding,
+	}, nil
+}
+
+// matchWindows returns the line ranges of re's matches in text, each widened by context lines
+// on both sides and merged with its neighbours when they overlap or touch.
+func matchWindows(text string, lines []string, re *regexp.Regexp, context int) []LineRange {
+	starts := make([]int, len(lines)) // byte offset of each line
+	off := 0
+	for i, l := range lines {
+		starts[i] = off
+		off += len(l)
+	}
+	lineOf := func(pos int) int { // 1-based line holding byte pos
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > pos })
+	}
+
+	var out []LineRange
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		if len(lines) == 0 {
+			break
+		}
+		first := lineOf(m[0])
+		last := lineOf(max(m[1]-1, m[0]))
+		w := LineRange{Start: max(1, first-context), End: min(len(lines), last+context)}
+		if n := len(out); n > 0 && w.Start <= out[n-1].End+1 {
+			out[n-1].End = max(out[n-1].End, w.End)
+			continue
+		}
+		out = append(out, w)
+	}
+	return out
+}
@@ -0,0 +1,180 @@
+package budget
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// readHeadTail is the TruncateHeadTail variant of readAndTruncateFile: it keeps the
+// first 2/3 and the last 1/3 of the line budget, joined by a middle marker.
+// The byte budget is shared; tail lines are given up first when it is exceeded.
+func readHeadTail(rel, abs string, slices []string, primary string, priority int, maxLines, maxBytes int, tf contentTransform) (FileEntry, error) {
+	st, err := os.Stat(abs)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	src, size, encoding, err := decodeText(f, st.Size())
+	if err != nil {
+		return FileEntry{}, err
+	}
+	var tr transformed
+	if tf.active() {
+		if tr, err = tf.apply(src); err != nil {
+			return FileEntry{}, err
+		}
+		src, size = tr.src, tr.size
+	}
+
+	headN := (2*maxLines + 2) / 3
+	tailN := maxLines - headN
+
+	var (
+		head      []string
+		headBytes int
+		headOpen  = true
+		tail      []string // the most recent lines after the head that fit the budget
+		tailBytes int
+		origLines int
+		line      []byte // the current line, up to the bytes the budget could keep
+		lineLen   int    // the current line's full length
+		pending   bool   // the current line has bytes but no newline yet
+		carry     []byte // incomplete rune at the end of the last chunk
+	)
+	// keep records b as part of the current line. A line longer than the budget left after
+	// the head can never be kept, so only that many bytes are buffered; once the head is
+	// closed and there is no tail, lines are only counted.
+	keep := func(b []byte) {
+		lineLen += len(b)
+		if !headOpen && tailN == 0 {
+			return
+		}
+		if room := maxBytes - headBytes - len(line); room > 0 {
+			line = append(line, b[:min(len(b), room)]...)
+		}
+	}
+	endLine := func() {
+		origLines++
+		switch {
+		case headOpen && len(head) < headN && headBytes+lineLen <= maxBytes:
+			head = append(head, string(line))
+			headBytes += lineLen
+		case tailN > 0 && headBytes+lineLen > maxBytes:
+			// Every tail ending at this line is over budget.
+			headOpen = false
+			tail, tailBytes = nil, 0
+		case tailN > 0:
+			headOpen = false
+			tail = append(tail, string(line))
+			tailBytes += lineLen
+			for len(tail) > tailN || headBytes+tailBytes > maxBytes {
+				tailBytes -= len(tail[0])
+				tail = tail[1:]
+			}
+		default:
+			headOpen = false
+		}
+		line, lineLen, pending = line[:0], 0, false
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			in := chunk
+			if len(carry) > 0 {
+				in = append(carry, chunk...)
+			}
+			ok, rest := util.FeedUTF8(in)
+			if !ok {
+				return FileEntry{}, errInvalidUTF8
+			}
+			carry = append(carry[:0], rest...)
+			for len(chunk) > 0 {
+				i := bytes.IndexByte(chunk, '\n')
+				if i < 0 {
+					keep(chunk)
+					pending = true
+					break
+				}
+				keep(chunk[:i+1])
+				endLine()
+				chunk = chunk[i+1:]
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return FileEntry{}, err
+		}
+	}
+	if len(carry) != 0 {
+		return FileEntry{}, errInvalidUTF8
+	}
+	if pending {
+		endLine()
+	}
+
+	kept := len(head) + len(tail)
+	truncated := kept < origLines
+	var (
+		sb       strings.Builder
+		segments []LineRange
+	)
+	for _, l := range head {
+		sb.WriteString(l)
+	}
+	switch {
+	case !truncated:
+		for _, l := range tail {
+			sb.WriteString(l)
+		}
+	case len(tail) == 0:
+		fmt.Fprintf(&sb, "… [TRUNCATED: original_lines=%d kept_lines=%d]\n", origLines, kept)
+		if len(head) > 0 {
+			segments = []LineRange{{Start: 1, End: len(head)}}
+		}
+	default:
+		tailStart := origLines - len(tail) + 1
+		fmt.Fprintf(&sb, "… [MIDDLE TRUNCATED: original_lines=%d kept_lines=%d omitted=%d-%d]\n",
+			origLines, kept, len(head)+1, tailStart-1)
+		for _, l := range tail {
+			sb.WriteString(l)
+		}
+		// The head range is kept even when empty so the marker line stays unnumbered.
+		segments = []LineRange{{Start: 1, End: len(head)}, {Start: tailStart, End: origLines}}
+	}
+
+	return FileEntry{
+		RelPath:       rel,
+		AbsPath:       abs,
+		Slices:        append([]string(nil), slices...),
+		PrimarySlice:  primary,
+		Priority:      priority,
+		OriginalLines: origLines,
+		OriginalBytes: size,
+		ModTime:       st.ModTime(),
+		KeptLines:     kept,
+		KeptBytes:     headBytes + tailBytes,
+		Truncated:     truncated,
+		Segments:      segments,
+		Content:       util.NormalizeNewlines(sb.String()),
+		Stripped:      tr.stripped,
+		Redacted:      tr.redacted,
+		Empty:         origLines == 0,
+		Encoding:      encoding,
+	}, nil
+}
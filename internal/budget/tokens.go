@@ -0,0 +1,32 @@
+package budget
+
+import (
+	"math"
+	"unicode"
+)
+
+// EstimateTokens returns a heuristic LLM token estimate for s.
+//
+// It starts from the common "about 4 characters per token" rule for word characters and
+// adjusts for content that tokenizes differently: code punctuation is denser (roughly 2
+// characters per token), runs of spaces/indentation are cheap, and non-ASCII runes are
+// close to one token each. The estimate is deterministic and intentionally conservative.
+func EstimateTokens(s string) int {
+	var word, punct, space, newline, other int
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			newline++
+		case r > unicode.MaxASCII:
+			other++
+		case unicode.IsSpace(r):
+			space++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word++
+		default:
+			punct++
+		}
+	}
+	est := float64(word)/4 + float64(punct)/2 + float64(space)/8 + float64(newline)/2 + float64(other)
+	return int(math.Ceil(est))
+}
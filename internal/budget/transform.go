@@ -0,0 +1,102 @@
+package budget
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// contentTransform rewrites a file's content before truncation. The zero value leaves
+// content untouched and keeps files streamed instead of read whole.
+type contentTransform struct {
+	strip                  []*regexp.Regexp
+	redact                 []*regexp.Regexp
+	trimTrailingWhitespace bool
+	collapseBlankLines     bool
+}
+
+func (b *Builder) transform() contentTransform {
+	return contentTransform{
+		strip:                  b.StripPatterns,
+		redact:                 b.RedactPatterns,
+		trimTrailingWhitespace: b.Limits.TrimTrailingWhitespace,
+		collapseBlankLines:     b.Limits.CollapseBlankLines,
+	}
+}
+
+func (t contentTransform) active() bool {
+	return len(t.strip) > 0 || len(t.redact) > 0 || t.trimTrailingWhitespace || t.collapseBlankLines
+}
+
+// transformed is the outcome of contentTransform.apply.
+type transformed struct {
+	src      io.Reader
+	size     int64
+	stripped bool // a strip pattern matched
+	redacted int  // redact pattern matches replaced by RedactedMarker
+}
+
+// apply reads r whole and applies t to its content (with newlines normalized, so patterns
+// only need to handle "\n"): strip patterns first, then redaction, then whitespace trimming
+// and blank-line collapsing. It returns a reader over the result and its size; when nothing
+// changed, the original bytes are returned as they were.
+func (t contentTransform) apply(r io.Reader) (transformed, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return transformed{}, err
+	}
+	if !utf8.Valid(data) {
+		return transformed{}, errInvalidUTF8
+	}
+	text := util.NormalizeNewlines(string(data))
+	out := text
+	for _, re := range t.strip {
+		out = re.ReplaceAllString(out, "")
+	}
+	res := transformed{stripped: out != text}
+	for _, re := range t.redact {
+		out = re.ReplaceAllStringFunc(out, func(string) string {
+			res.redacted++
+			return RedactedMarker
+		})
+	}
+	if t.trimTrailingWhitespace || t.collapseBlankLines {
+		out = t.rewriteLines(out)
+	}
+	if out == text {
+		return transformed{src: strings.NewReader(string(data)), size: int64(len(data))}, nil
+	}
+	res.src, res.size = strings.NewReader(out), int64(len(out))
+	return res, nil
+}
+
+// rewriteLines trims trailing spaces and tabs from each line and/or collapses runs of
+// blank (whitespace-only) lines to their first line.
+func (t contentTransform) rewriteLines(text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	var sb strings.Builder
+	sb.Grow(len(text))
+	prevBlank := false
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		body, nl := strings.CutSuffix(line, "\n")
+		if t.trimTrailingWhitespace {
+			body = strings.TrimRight(body, " \t")
+		}
+		blank := strings.TrimSpace(body) == ""
+		if t.collapseBlankLines && blank && prevBlank {
+			continue
+		}
+		prevBlank = blank
+		sb.WriteString(body)
+		if nl {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
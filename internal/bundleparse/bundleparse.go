@@ -0,0 +1,186 @@
+// Package bundleparse reads snip markdown bundles back into structured form:
+// header metadata, the included-files manifest, and per-file content.
+package bundleparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/tools/apply"
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// File is one included file as recorded in a bundle.
+type File struct {
+	Path      string
+	Lines     int // original line count; from the manifest, else counted from content
+	Bytes     int // original byte count; from the manifest, else len(Content)
+	Truncated bool
+	Content   string
+}
+
+// Bundle is a parsed snip bundle.
+type Bundle struct {
+	Meta            map[string]string // header fields such as profile, git_sha, timestamp
+	DelimiterHeader string            // file header template, if the bundle declares one
+	Files           []File            // manifest order; files found only as blocks come last
+}
+
+var reManifestLine = regexp.MustCompile(`^\s*\d+\s+(.+?)\s+((?:lines=\d+\s+)?(?:bytes=\d+\s+)?slices=\[[^\]]*\].*)$`)
+
+// Parse reads a markdown bundle. fileHeader overrides the delimiter declared in the
+// manifest; when both are empty the default "## N) path" headers are assumed.
+func Parse(input string, fileHeader string) (Bundle, error) {
+	src := util.NormalizeNewlines(input)
+	b := Bundle{Meta: map[string]string{}}
+	byPath := map[string]int{}
+
+	// Header fields run until the first "## " section; the manifest section lists
+	// included files. Scanning stops once the manifest has been read.
+	section := "header"
+	for _, line := range strings.Split(src, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			if section == "manifest" {
+				break
+			}
+			section = "other"
+			if strings.TrimSpace(line) == "## Manifest (included)" {
+				section = "manifest"
+			}
+			continue
+		}
+		switch section {
+		case "header":
+			if k, v, ok := strings.Cut(line, ": "); ok && !strings.HasPrefix(k, "#") {
+				b.Meta[k] = v
+			}
+		case "manifest":
+			if v, ok := strings.CutPrefix(line, "delimiter_header: "); ok {
+				h, err := strconv.Unquote(v)
+				if err != nil {
+					return Bundle{}, fmt.Errorf("invalid delimiter_header %s: %w", v, err)
+				}
+				b.DelimiterHeader = h
+				continue
+			}
+			m := reManifestLine.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			f := File{Path: m[1], Lines: -1, Bytes: -1}
+			for _, attr := range strings.Fields(m[2]) {
+				k, v, _ := strings.Cut(attr, "=")
+				switch k {
+				case "lines":
+					f.Lines, _ = strconv.Atoi(v)
+				case "bytes":
+					f.Bytes, _ = strconv.Atoi(v)
+				case "truncated":
+					f.Truncated = v == "true"
+				}
+			}
+			byPath[f.Path] = len(b.Files)
+			b.Files = append(b.Files, f)
+		}
+	}
+
+	header := fileHeader
+	if header == "" {
+		header = b.DelimiterHeader
+	}
+	var (
+		blocks []apply.Block
+		err    error
+	)
+	if header != "" {
+		blocks, err = apply.Parse(src, header)
+	} else {
+		blocks, err = apply.ParseRegex(src, apply.DefaultHeaderRegex)
+	}
+	// An empty bundle has no blocks; that is only an error if the manifest lists files.
+	if err != nil && !(len(b.Files) == 0 && errors.Is(err, apply.ErrNoBlocks)) {
+		return Bundle{}, fmt.Errorf("parse file blocks: %w", err)
+	}
+
+	for _, blk := range blocks {
+		content := string(blk.Content)
+		i, ok := byPath[blk.Path]
+		if !ok {
+			i = len(b.Files)
+			byPath[blk.Path] = i
+			b.Files = append(b.Files, File{Path: blk.Path, Lines: -1, Bytes: -1})
+		}
+		f := &b.Files[i]
+		f.Content = content
+		if f.Lines < 0 {
+			f.Lines = strings.Count(content, "\n")
+		}
+		if f.Bytes < 0 {
+			f.Bytes = len(content)
+		}
+	}
+	for i := range b.Files {
+		b.Files[i].Lines = max(b.Files[i].Lines, 0)
+		b.Files[i].Bytes = max(b.Files[i].Bytes, 0)
+	}
+	return b, nil
+}
+
+// ChangeKind classifies a file difference between two bundles.
+type ChangeKind string
+
+// Change kinds reported by Diff.
+const (
+	Added    ChangeKind = "added"
+	Removed  ChangeKind = "removed"
+	Modified ChangeKind = "modified"
+)
+
+// Change is one file that differs between two bundles.
+type Change struct {
+	Kind     ChangeKind
+	Path     string
+	OldLines int
+	NewLines int
+	OldBytes int
+	NewBytes int
+}
+
+// LineDelta is NewLines - OldLines.
+func (c Change) LineDelta() int { return c.NewLines - c.OldLines }
+
+// Diff compares two bundles by path. A file is modified when its line or byte count
+// or its content differs. Changes are sorted by path.
+func Diff(oldB, newB Bundle) []Change {
+	oldFiles := map[string]File{}
+	for _, f := range oldB.Files {
+		oldFiles[f.Path] = f
+	}
+	newFiles := map[string]File{}
+	for _, f := range newB.Files {
+		newFiles[f.Path] = f
+	}
+
+	var out []Change
+	for p, o := range oldFiles {
+		n, ok := newFiles[p]
+		if !ok {
+			out = append(out, Change{Kind: Removed, Path: p, OldLines: o.Lines, OldBytes: o.Bytes})
+			continue
+		}
+		if o.Lines != n.Lines || o.Bytes != n.Bytes || o.Content != n.Content {
+			out = append(out, Change{Kind: Modified, Path: p, OldLines: o.Lines, NewLines: n.Lines, OldBytes: o.Bytes, NewBytes: n.Bytes})
+		}
+	}
+	for p, n := range newFiles {
+		if _, ok := oldFiles[p]; !ok {
+			out = append(out, Change{Kind: Added, Path: p, NewLines: n.Lines, NewBytes: n.Bytes})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
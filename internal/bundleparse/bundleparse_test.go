@@ -0,0 +1,71 @@
+package bundleparse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmrzaf/snip/internal/budget"
+	"github.com/mmrzaf/snip/internal/render"
+)
+
+func renderBundle(t *testing.T, header string, files ...budget.FileEntry) string {
+	t.Helper()
+	r := render.Renderer{
+		CodeFences:      true,
+		IncludeManifest: true,
+		Manifest:        render.ManifestOptions{IncludeLineCounts: true, IncludeByteCounts: true},
+		FileBlock:       render.FileBlockOptions{Header: header},
+	}
+	info := render.BundleInfo{Repo: "r", Root: ".", Profile: "p", GitSHA: "abc123", Timestamp: time.Unix(0, 0)}
+	out, err := r.RenderMarkdown(info, budget.Plan{Included: files})
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	return out
+}
+
+func entry(path, content string, lines int) budget.FileEntry {
+	return budget.FileEntry{RelPath: path, Slices: []string{"code"}, PrimarySlice: "code", Content: content, OriginalLines: lines, OriginalBytes: int64(len(content))}
+}
+
+func TestParseAndDiffBundles(t *testing.T) {
+	t.Parallel()
+
+	for _, header := range []string{"", "===== FILE: {path} ====="} {
+		oldB, err := Parse(renderBundle(t, header,
+			entry("a.go", "package a\n", 1),
+			entry("b.go", "package b\n", 1),
+		), "")
+		if err != nil {
+			t.Fatalf("Parse old (header=%q): %v", header, err)
+		}
+		if oldB.Meta["git_sha"] != "abc123" || len(oldB.Files) != 2 {
+			t.Fatalf("header=%q meta=%v files=%+v", header, oldB.Meta, oldB.Files)
+		}
+		if oldB.Files[0].Content != "package a\n" {
+			t.Fatalf("header=%q content=%q", header, oldB.Files[0].Content)
+		}
+
+		newB, err := Parse(renderBundle(t, header,
+			entry("a.go", "package a\n\nfunc A() {}\n", 3),
+			entry("c.go", "package c\n", 1),
+		), "")
+		if err != nil {
+			t.Fatalf("Parse new (header=%q): %v", header, err)
+		}
+
+		changes := Diff(oldB, newB)
+		if len(changes) != 3 {
+			t.Fatalf("header=%q changes=%+v", header, changes)
+		}
+		if c := changes[0]; c.Kind != Modified || c.Path != "a.go" || c.LineDelta() != 2 {
+			t.Fatalf("header=%q a.go change=%+v", header, c)
+		}
+		if c := changes[1]; c.Kind != Removed || c.Path != "b.go" {
+			t.Fatalf("header=%q b.go change=%+v", header, c)
+		}
+		if c := changes[2]; c.Kind != Added || c.Path != "c.go" || c.NewLines != 1 {
+			t.Fatalf("header=%q c.go change=%+v", header, c)
+		}
+	}
+}
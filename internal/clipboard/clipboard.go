@@ -0,0 +1,61 @@
+// Package clipboard copies text to the OS clipboard using the platform's CLI tool.
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when no supported clipboard tool is installed.
+var ErrUnavailable = errors.New("no clipboard tool found (tried pbcopy, wl-copy, xclip, xsel, clip.exe)")
+
+// Write copies text to the system clipboard.
+func Write(ctx context.Context, text string) error {
+	argv, err := command(runtime.GOOS, os.Getenv, exec.LookPath)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", argv[0], err, msg)
+		}
+		return fmt.Errorf("%s: %w", argv[0], err)
+	}
+	return nil
+}
+
+// command picks the clipboard tool for goos. On Linux, Wayland is preferred when
+// WAYLAND_DISPLAY is set; clip.exe covers WSL.
+func command(goos string, getenv func(string) string, lookPath func(string) (string, error)) ([]string, error) {
+	var candidates [][]string
+	switch goos {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip.exe"}}
+	default:
+		if getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-copy"})
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"},
+			[]string{"clip.exe"},
+		)
+	}
+	for _, c := range candidates {
+		if p, err := lookPath(c[0]); err == nil {
+			return append([]string{p}, c[1:]...), nil
+		}
+	}
+	return nil, ErrUnavailable
+}
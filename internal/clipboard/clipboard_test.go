@@ -0,0 +1,40 @@
+package clipboard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCommandPicksFirstAvailableTool(t *testing.T) {
+	t.Parallel()
+
+	have := func(names ...string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			for _, n := range names {
+				if n == name {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+	}
+	env := func(vals map[string]string) func(string) string {
+		return func(k string) string { return vals[k] }
+	}
+
+	argv, err := command("linux", env(nil), have("xsel", "xclip"))
+	if err != nil || argv[0] != "/usr/bin/xclip" || argv[2] != "clipboard" {
+		t.Fatalf("argv=%v err=%v", argv, err)
+	}
+	argv, err = command("linux", env(map[string]string{"WAYLAND_DISPLAY": "wayland-0"}), have("xclip", "wl-copy"))
+	if err != nil || argv[0] != "/usr/bin/wl-copy" {
+		t.Fatalf("wayland argv=%v err=%v", argv, err)
+	}
+	argv, err = command("darwin", env(nil), have("pbcopy"))
+	if err != nil || argv[0] != "/usr/bin/pbcopy" {
+		t.Fatalf("darwin argv=%v err=%v", argv, err)
+	}
+	if _, err := command("linux", env(nil), have()); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err=%v want ErrUnavailable", err)
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,6 +20,7 @@ import (
 // Additions are backward-compatible (optional fields).
 type Config struct {
 	Version        int                    `yaml:"version"`
+	Extends        string                 `yaml:"extends,omitempty"` // base config file layered under this one (see Load)
 	Root           string                 `yaml:"root"`
 	Name           string                 `yaml:"name"`
 	DefaultProfile string                 `yaml:"default_profile"`
@@ -25,8 +29,15 @@ type Config struct {
 	Budgets        BudgetConfig           `yaml:"budgets"`
 	Ignore         IgnoreConfig           `yaml:"ignore"`
 	Sensitive      SensitiveConfig        `yaml:"sensitive"`
+	Selector       SelectorConfig         `yaml:"selector"`
 	Slices         map[string]SliceConfig `yaml:"slices"`
 	Profiles       map[string]Profile     `yaml:"profiles"`
+	// Presets name built-in slice templates ("go-service", or pinned "go-service@v1") whose
+	// slices are layered under this config's; see internal/presets.
+	Presets []string `yaml:"presets,omitempty"`
+	// PresetSlices maps each slice that came from a preset to that preset's ID, e.g.
+	// "api": "go-service@v1", even when the config overrides some of its keys. Set by Load.
+	PresetSlices map[string]string `yaml:"-"`
 }
 
 // OutputConfig controls where bundles are written.
@@ -35,6 +46,15 @@ type OutputConfig struct {
 	Pattern       string `yaml:"pattern"`
 	Latest        string `yaml:"latest"`
 	StdoutDefault bool   `yaml:"stdout_default"`
+	// Compress is "none" (default) or "gzip"; gzip output gets a ".gz" suffix.
+	Compress string `yaml:"compress,omitempty"`
+	// KeepLast, when > 0, keeps only the newest KeepLast bundles matching Pattern in Dir.
+	KeepLast int `yaml:"keep_last,omitempty"`
+	// Split is "none" (default) or "per_slice": a default-output run then writes one bundle per
+	// slice, named by Pattern with a {slice} token.
+	Split string `yaml:"split,omitempty"`
+	// NoLock skips the advisory lock on the output directory (run --no-lock); runtime only.
+	NoLock bool `yaml:"-"`
 }
 
 // RenderConfig controls markdown rendering.
@@ -47,6 +67,38 @@ type RenderConfig struct {
 	IncludeManifest bool            `yaml:"include_manifest"`
 	Manifest        ManifestConfig  `yaml:"manifest"`
 	FileBlock       FileBlockConfig `yaml:"file_block"`
+	// LineNumbers prefixes each fenced content line with its original line number.
+	LineNumbers bool `yaml:"line_numbers"`
+	// EmbedWarnings adds a "## Warnings" section to the bundle when the run is partial.
+	EmbedWarnings bool `yaml:"embed_warnings"`
+	// WarningsPosition places the warnings section: "top" (after the header, default) or "bottom".
+	WarningsPosition string `yaml:"warnings_position"`
+	// IncludeTOC adds a "## Contents" section linking to each file heading. It needs the default
+	// "## N) path" headings, so it is ignored when file_block.header or footer is set.
+	IncludeTOC bool `yaml:"include_toc,omitempty"`
+	// TreeSizes appends file sizes (and directory totals) to tree entries.
+	TreeSizes bool `yaml:"tree_sizes,omitempty"`
+	// IncludeHashes adds a SHA-256 of each file's original bytes to the manifest and ends
+	// markdown bundles with a bundle_sha256 line over the rest of the bundle.
+	IncludeHashes bool `yaml:"include_hashes,omitempty"`
+	// Languages maps file extensions (".tsx" or "tsx") to code fence languages, over the
+	// built-in mapping; an empty language disables the hint for that extension.
+	Languages map[string]string `yaml:"languages,omitempty"`
+	// StripPatterns are regexes removed from each file's content before budgeting and
+	// rendering (e.g. a leading license block). Files on disk are never changed.
+	StripPatterns []string `yaml:"strip_patterns,omitempty"`
+	// SkipEmptyFiles omits empty files from the content section; the manifest still lists
+	// them as empty.
+	SkipEmptyFiles bool `yaml:"skip_empty_files,omitempty"`
+	// HTMLPrism links Prism.js from a CDN in html bundles for syntax highlighting; without it
+	// the page has no external references.
+	HTMLPrism bool `yaml:"html_prism,omitempty"`
+	// TreeMaxNodes caps the tree section at this many entries by folding directories into
+	// "dir (N files)" lines, deepest and largest first (0 disables).
+	TreeMaxNodes int `yaml:"tree_max_nodes,omitempty"`
+	// FileOrder orders files within each slice group (or overall without group_by_slice):
+	// "path" (default), "slice_priority", or "include_order" (the slice's include pattern order).
+	FileOrder string `yaml:"file_order,omitempty"`
 }
 
 // FileBlockConfig customizes per-file delimiter markers.
@@ -62,45 +114,137 @@ type ManifestConfig struct {
 	IncludeByteCounts      bool `yaml:"include_byte_counts"`
 	IncludeTruncationNotes bool `yaml:"include_truncation_notes"`
 	IncludeUnreadableNotes bool `yaml:"include_unreadable_notes"`
+	// IncludeGitInfo adds each file's last commit SHA and author date (skipped outside git).
+	IncludeGitInfo bool `yaml:"include_git_info,omitempty"`
 }
 
 // BudgetConfig controls output budgets.
 type BudgetConfig struct {
 	MaxChars        int    `yaml:"max_chars"`
+	MaxTokens       int    `yaml:"max_tokens,omitempty"` // 0 disables; estimated tokens
 	PerFileMaxLines int    `yaml:"per_file_max_lines"`
 	PerFileMaxBytes int    `yaml:"per_file_max_bytes"`
 	DropPolicy      string `yaml:"drop_policy"`
+	TruncateMode    string `yaml:"truncate_mode"` // head or head_tail
+	// CollapseBlankLines reduces runs of blank lines in each file to a single one, and
+	// TrimTrailingWhitespace drops spaces and tabs at line ends, before truncation.
+	CollapseBlankLines     bool `yaml:"collapse_blank_lines,omitempty"`
+	TrimTrailingWhitespace bool `yaml:"trim_trailing_whitespace,omitempty"`
 }
 
 // IgnoreConfig controls ignore rules.
 type IgnoreConfig struct {
-	UseGitignore     bool     `yaml:"use_gitignore"`
+	UseGitignore bool `yaml:"use_gitignore"`
+	// UseSnipignore reads <root>/.snipignore. Nil means the default (true).
+	UseSnipignore *bool `yaml:"use_snipignore,omitempty"`
+	// FollowSymlinks follows symlinks that resolve inside root; others are skipped.
+	FollowSymlinks bool `yaml:"follow_symlinks,omitempty"`
+	// Cache reuses discovery results from <root>/.snip/cache while directory mtimes,
+	// ignore files and ignore settings are unchanged.
+	Cache bool `yaml:"cache,omitempty"`
+	// MaxFileBytes excludes files larger than this many bytes (0 disables).
+	MaxFileBytes int64 `yaml:"max_file_bytes,omitempty"`
+	// MaxFiles excludes files past this many candidates, in path order (0 disables).
+	MaxFiles int `yaml:"max_files,omitempty"`
+	// TrackedOnly excludes files not tracked by git. Outside a git repo it has no effect.
+	TrackedOnly      bool     `yaml:"tracked_only,omitempty"`
 	Always           []string `yaml:"always"`
 	BinaryExtensions []string `yaml:"binary_extensions"`
+	// BinarySniffRatio is the share of non-text bytes above which a sniffed file is binary
+	// (0 means the default, 0.30).
+	BinarySniffRatio float64 `yaml:"binary_sniff_ratio,omitempty"`
+	// TextExtensions are always treated as text: they skip binary_extensions and the sniff.
+	TextExtensions []string `yaml:"text_extensions,omitempty"`
+}
+
+// SnipignoreEnabled reports whether .snipignore should be read (default true).
+func (c IgnoreConfig) SnipignoreEnabled() bool {
+	return c.UseSnipignore == nil || *c.UseSnipignore
 }
 
 // SensitiveConfig controls sensitive exclusions.
 type SensitiveConfig struct {
 	ExcludeGlobs []string `yaml:"exclude_globs"`
+	// RedactPatterns are regexes whose matches are masked in file content when a run
+	// uses --redact; matching files stay in the bundle.
+	RedactPatterns []string `yaml:"redact_patterns,omitempty"`
+	// ScanContent makes doctor scan selected files for likely secrets (key headers,
+	// access key IDs, JWTs, high-entropy tokens). Findings are reported, never excluded.
+	ScanContent bool `yaml:"scan_content,omitempty"`
+}
+
+// SelectorConfig controls slice membership resolution.
+type SelectorConfig struct {
+	// GlobalExcludeWins makes any enabled slice's exclude match remove the file from all slices.
+	GlobalExcludeWins bool `yaml:"global_exclude_wins"`
+	// PrimaryTiebreak picks the primary slice of a file among equal-priority memberships:
+	// "name" (default, alphabetical) or "first_enabled" (earliest in EnableOrder).
+	PrimaryTiebreak string `yaml:"primary_tiebreak,omitempty"`
+	// EnableOrder is the order the current command's profile and modifiers enable slices in
+	// (see selector.EnableOrder); it is never read from the config file.
+	EnableOrder []string `yaml:"-"`
+	// Exclude and Include hold the ad-hoc --exclude/--include globs of a single command; they
+	// are never read from the config file.
+	Exclude []string `yaml:"-"`
+	Include []string `yaml:"-"`
 }
 
 // SliceConfig defines a slice.
 type SliceConfig struct {
-	Include  []string `yaml:"include"`
-	Exclude  []string `yaml:"exclude"`
-	Priority int      `yaml:"priority"`
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// IncludeRegex and ExcludeRegex are Go regular expressions matched against the
+	// slash-separated relative path, alongside the Include/Exclude globs.
+	IncludeRegex []string `yaml:"include_regex,omitempty"`
+	ExcludeRegex []string `yaml:"exclude_regex,omitempty"`
+	Priority     int      `yaml:"priority"`
+	// IncludePriorities ranks files within the slice: glob -> delta added to the slice's
+	// priority when the budget drops files. The longest matching glob wins, so a deeper
+	// directory overrides its parent; unmatched files get 0.
+	IncludePriorities map[string]int `yaml:"include_priorities,omitempty"`
+	Budget            SliceBudget    `yaml:"budget,omitempty"`
+	// ContainsRegex, when set, keeps a file matched by the patterns above in the slice
+	// only if its content matches this Go regular expression.
+	ContainsRegex string `yaml:"contains_regex,omitempty"`
+	// ContainsContext, when > 0, cuts the slice's files (by primary slice) down to the lines
+	// ContainsRegex matches plus this many lines around each match. Requires ContainsRegex.
+	ContainsContext int `yaml:"contains_context,omitempty"`
+	// WholeFilesOnly drops files that per-file truncation would shorten instead of
+	// including them partially.
+	WholeFilesOnly bool `yaml:"whole_files_only,omitempty"`
+	// ProfileInclude and ProfileExclude are the globs ApplyProfileOverrides appended to
+	// Include and Exclude from the profile's include/exclude maps, so explain can attribute
+	// them; runtime only.
+	ProfileInclude []string `yaml:"-"`
+	ProfileExclude []string `yaml:"-"`
+}
+
+// SliceBudget caps how much of the bundle a single slice may use. Zero values disable a limit.
+type SliceBudget struct {
+	MaxChars int `yaml:"max_chars,omitempty"`
+	MaxFiles int `yaml:"max_files,omitempty"`
 }
 
 // Profile defines a profile.
 type Profile struct {
+	// Extends names a parent profile whose slices and overrides are inherited;
+	// Enable then adds slices and Disable removes them (see ResolveProfiles).
+	Extends string         `yaml:"extends,omitempty"`
 	Enable  []string       `yaml:"enable"`
+	Disable []string       `yaml:"disable,omitempty"`
 	Budgets BudgetOverride `yaml:"budgets"`
 	Render  RenderOverride `yaml:"render"`
+	// Include and Exclude add globs to the named slices' include/exclude lists for this
+	// profile only (slice name -> globs), e.g. exclude: {tests: ["**/integration_test.go"]}.
+	// A child profile adds its globs to its parent's.
+	Include map[string][]string `yaml:"include,omitempty"`
+	Exclude map[string][]string `yaml:"exclude,omitempty"`
 }
 
 // BudgetOverride allows per-profile overrides.
 type BudgetOverride struct {
-	MaxChars int `yaml:"max_chars"`
+	MaxChars  int `yaml:"max_chars"`
+	MaxTokens int `yaml:"max_tokens,omitempty"`
 }
 
 // RenderOverride allows per-profile overrides.
@@ -144,6 +288,7 @@ func Default() Config {
 			PerFileMaxLines: 600,
 			PerFileMaxBytes: 262144,
 			DropPolicy:      "drop_low_priority",
+			TruncateMode:    "head",
 		},
 		Ignore: IgnoreConfig{
 			UseGitignore: true,
@@ -179,29 +324,151 @@ func Default() Config {
 	}
 }
 
-// Load reads and validates a config file.
+// Load reads and validates a config file. If the file sets `extends`, it is first merged over
+// its base file (and that file's own base, and so on); see readLayers for the precedence rules.
+// Files written for an older schema version are migrated in memory (see Migrate).
 func Load(path string) (Config, error) {
-	b, err := os.ReadFile(path)
+	layers, err := readLayers(path, nil)
+	if err != nil {
+		return Config{}, err
+	}
+	layers, presetSlices, err := applyPresets(layers)
 	if err != nil {
-		return Config{}, fmt.Errorf("read config: %w", err)
+		return Config{}, err
+	}
+	b, err := yaml.Marshal(layers)
+	if err != nil {
+		return Config{}, fmt.Errorf("merge config: %w", err)
 	}
 	var cfg Config
 	if err := yaml.Unmarshal(b, &cfg); err != nil {
 		return Config{}, fmt.Errorf("parse yaml: %w", err)
 	}
-	if cfg.Version == 0 {
-		cfg.Version = 1
-	}
-	if cfg.Version != 1 {
+	if cfg.Version != CurrentVersion {
 		return Config{}, fmt.Errorf("unsupported config version %d", cfg.Version)
 	}
+	for s, id := range presetSlices {
+		if _, ok := cfg.Slices[s]; ok {
+			if cfg.PresetSlices == nil {
+				cfg.PresetSlices = map[string]string{}
+			}
+			cfg.PresetSlices[s] = id // slices set to null were removed
+		}
+	}
+	cfg, err = interpolateEnv(cfg, os.LookupEnv)
+	if err != nil {
+		return Config{}, err
+	}
 	cfg = mergeDefaults(cfg)
+	cfg, err = ResolveProfiles(cfg)
+	if err != nil {
+		return Config{}, err
+	}
 	if err := Validate(cfg); err != nil {
 		return Config{}, err
 	}
 	return cfg, nil
 }
 
+// ResolveProfiles flattens `extends` chains: each profile starts from its resolved
+// parent's enabled slices and overrides, adds its own Enable, removes its Disable,
+// and replaces any override it sets. Resolving an already-resolved config is a no-op.
+func ResolveProfiles(cfg Config) (Config, error) {
+	resolved := make(map[string]Profile, len(cfg.Profiles))
+	var resolve func(name string, chain []string) (Profile, error)
+	resolve = func(name string, chain []string) (Profile, error) {
+		if p, ok := resolved[name]; ok {
+			return p, nil
+		}
+		for i, c := range chain {
+			if c == name {
+				return Profile{}, fmt.Errorf("profile %q has circular extends: %s", name, strings.Join(append(chain[i:], name), " -> "))
+			}
+		}
+		p := cfg.Profiles[name]
+		if p.Extends == "" {
+			resolved[name] = p
+			return p, nil
+		}
+		if _, ok := cfg.Profiles[p.Extends]; !ok {
+			return Profile{}, fmt.Errorf("profile %q extends unknown profile %q", name, p.Extends)
+		}
+		parent, err := resolve(p.Extends, append(chain, name))
+		if err != nil {
+			return Profile{}, err
+		}
+		resolved[name] = inheritProfile(parent, p)
+		return resolved[name], nil
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := resolve(name, nil); err != nil {
+			return Config{}, err
+		}
+	}
+
+	out := cfg
+	out.Profiles = resolved
+	return out, nil
+}
+
+// inheritProfile applies child as a delta on top of parent.
+func inheritProfile(parent, child Profile) Profile {
+	disabled := map[string]bool{}
+	for _, s := range child.Disable {
+		disabled[s] = true
+	}
+	seen := map[string]bool{}
+	var enable []string
+	for _, s := range append(append([]string(nil), parent.Enable...), child.Enable...) {
+		if seen[s] || disabled[s] {
+			continue
+		}
+		seen[s] = true
+		enable = append(enable, s)
+	}
+
+	out := child
+	out.Enable = enable
+	if out.Budgets.MaxChars == 0 {
+		out.Budgets.MaxChars = parent.Budgets.MaxChars
+	}
+	if out.Budgets.MaxTokens == 0 {
+		out.Budgets.MaxTokens = parent.Budgets.MaxTokens
+	}
+	if out.Render.TreeDepth == 0 {
+		out.Render.TreeDepth = parent.Render.TreeDepth
+	}
+	out.Include = mergeSliceGlobs(parent.Include, child.Include)
+	out.Exclude = mergeSliceGlobs(parent.Exclude, child.Exclude)
+	return out
+}
+
+// mergeSliceGlobs returns parent's globs per slice followed by child's, each glob once. A child
+// resolved before already carries its parent's globs, so merging again changes nothing.
+func mergeSliceGlobs(parent, child map[string][]string) map[string][]string {
+	if len(parent) == 0 {
+		return child
+	}
+	out := make(map[string][]string, len(parent)+len(child))
+	for s, globs := range parent {
+		out[s] = append([]string(nil), globs...)
+	}
+	for s, globs := range child {
+		for _, g := range globs {
+			if !slices.Contains(out[s], g) {
+				out[s] = append(out[s], g)
+			}
+		}
+	}
+	return out
+}
+
 func mergeDefaults(cfg Config) Config {
 	def := Default()
 
@@ -232,6 +499,9 @@ func mergeDefaults(cfg Config) Config {
 	if cfg.Budgets.DropPolicy == "" {
 		cfg.Budgets.DropPolicy = def.Budgets.DropPolicy
 	}
+	if cfg.Budgets.TruncateMode == "" {
+		cfg.Budgets.TruncateMode = def.Budgets.TruncateMode
+	}
 	if cfg.Ignore.Always == nil {
 		cfg.Ignore.Always = def.Ignore.Always
 	}
@@ -273,24 +543,69 @@ func firstProfileName(m map[string]Profile) string {
 
 // Validate enforces basic schema constraints.
 func Validate(cfg Config) error {
-	if cfg.Version != 1 {
-		return fmt.Errorf("version must be 1")
+	if cfg.Version != CurrentVersion {
+		return fmt.Errorf("version must be %d", CurrentVersion)
 	}
 	if cfg.Budgets.MaxChars <= 0 {
 		return fmt.Errorf("budgets.max_chars must be > 0")
 	}
+	if cfg.Budgets.MaxTokens < 0 {
+		return fmt.Errorf("budgets.max_tokens must be >= 0")
+	}
 	if cfg.Budgets.PerFileMaxLines <= 0 {
 		return fmt.Errorf("budgets.per_file_max_lines must be > 0")
 	}
 	if cfg.Budgets.PerFileMaxBytes <= 0 {
 		return fmt.Errorf("budgets.per_file_max_bytes must be > 0")
 	}
-	if cfg.Render.Format != "md" {
-		return fmt.Errorf("render.format must be 'md'")
+	if cfg.Ignore.MaxFileBytes < 0 {
+		return fmt.Errorf("ignore.max_file_bytes must be >= 0")
+	}
+	if cfg.Ignore.MaxFiles < 0 {
+		return fmt.Errorf("ignore.max_files must be >= 0")
+	}
+	if r := cfg.Ignore.BinarySniffRatio; r < 0 || r > 1 {
+		return fmt.Errorf("ignore.binary_sniff_ratio must be between 0 and 1")
+	}
+	switch cfg.Render.Format {
+	case "md", "ndjson", "plain", "html":
+	default:
+		return fmt.Errorf("render.format must be 'md', 'ndjson', 'plain' or 'html'")
 	}
 	if cfg.Output.Pattern == "" {
 		return fmt.Errorf("output.pattern is required")
 	}
+	switch cfg.Output.Compress {
+	case "", "none", "gzip":
+	default:
+		return fmt.Errorf("output.compress must be 'none' or 'gzip'")
+	}
+	switch cfg.Output.Split {
+	case "", "none", "per_slice":
+	default:
+		return fmt.Errorf("output.split must be 'none' or 'per_slice'")
+	}
+	if cfg.Output.KeepLast < 0 {
+		return fmt.Errorf("output.keep_last must be >= 0")
+	}
+	if cfg.Render.TreeMaxNodes < 0 {
+		return fmt.Errorf("render.tree_max_nodes must be >= 0")
+	}
+	switch cfg.Selector.PrimaryTiebreak {
+	case "", "name", "first_enabled":
+	default:
+		return fmt.Errorf("selector.primary_tiebreak must be 'name' or 'first_enabled'")
+	}
+	switch cfg.Render.WarningsPosition {
+	case "", "top", "bottom":
+	default:
+		return fmt.Errorf("render.warnings_position must be 'top' or 'bottom'")
+	}
+	switch cfg.Render.FileOrder {
+	case "", "path", "slice_priority", "include_order":
+	default:
+		return fmt.Errorf("render.file_order must be 'path', 'slice_priority' or 'include_order'")
+	}
 
 	// Validate delimiter strings: must be single-line to keep output parseable.
 	if strings.ContainsAny(cfg.Render.FileBlock.Header, "\r\n") {
@@ -299,6 +614,20 @@ func Validate(cfg Config) error {
 	if strings.ContainsAny(cfg.Render.FileBlock.Footer, "\r\n") {
 		return fmt.Errorf("render.file_block.footer must not contain newlines")
 	}
+	for ext, lang := range cfg.Render.Languages {
+		if e := strings.TrimPrefix(ext, "."); e == "" || e != strings.ToLower(e) || strings.ContainsAny(e, "./\\ ") {
+			return fmt.Errorf("render.languages key %q must be a lowercase file extension such as \".tsx\"", ext)
+		}
+		if strings.ContainsAny(lang, " \t\r\n`") {
+			return fmt.Errorf("render.languages[%q] must be a single word", ext)
+		}
+	}
+	if err := validateContentPatterns("render.strip_patterns", cfg.Render.StripPatterns); err != nil {
+		return err
+	}
+	if err := validateContentPatterns("sensitive.redact_patterns", cfg.Sensitive.RedactPatterns); err != nil {
+		return err
+	}
 
 	if len(cfg.Slices) == 0 {
 		return fmt.Errorf("at least one slice is required")
@@ -307,17 +636,71 @@ func Validate(cfg Config) error {
 		return fmt.Errorf("at least one profile is required")
 	}
 
-	for name := range cfg.Slices {
+	for name, sl := range cfg.Slices {
 		if name == "" {
 			return fmt.Errorf("slice name cannot be empty")
 		}
+		if sl.Budget.MaxChars < 0 {
+			return fmt.Errorf("slice %q budget.max_chars must be >= 0", name)
+		}
+		if sl.Budget.MaxFiles < 0 {
+			return fmt.Errorf("slice %q budget.max_files must be >= 0", name)
+		}
+		for _, field := range []struct {
+			key      string
+			patterns []string
+		}{{"include", sl.Include}, {"exclude", sl.Exclude}} {
+			for _, pat := range field.patterns {
+				if err := validateGlob(pat); err != nil {
+					return fmt.Errorf("slice %q %s pattern %q is invalid: %v", name, field.key, pat, err)
+				}
+			}
+		}
+		for pat := range sl.IncludePriorities {
+			if err := validateGlob(pat); err != nil {
+				return fmt.Errorf("slice %q include_priorities pattern %q is invalid: %v", name, pat, err)
+			}
+		}
+		for _, field := range []struct {
+			key      string
+			patterns []string
+		}{{"include_regex", sl.IncludeRegex}, {"exclude_regex", sl.ExcludeRegex}} {
+			for _, pat := range field.patterns {
+				if _, err := regexp.Compile(pat); err != nil {
+					return fmt.Errorf("slice %q %s %q is invalid: %v", name, field.key, pat, err)
+				}
+			}
+		}
+		if sl.ContainsRegex != "" {
+			if _, err := regexp.Compile(sl.ContainsRegex); err != nil {
+				return fmt.Errorf("slice %q contains_regex %q is invalid: %v", name, sl.ContainsRegex, err)
+			}
+		}
+		switch {
+		case sl.ContainsContext < 0:
+			return fmt.Errorf("slice %q contains_context must be >= 0", name)
+		case sl.ContainsContext > 0 && sl.ContainsRegex == "":
+			return fmt.Errorf("slice %q contains_context requires contains_regex", name)
+		case sl.ContainsContext > 0 && sl.WholeFilesOnly:
+			return fmt.Errorf("slice %q cannot combine contains_context with whole_files_only", name)
+		}
 		// NOTE: allow empty include list (init creates standard slices but leaves absent ones empty).
 	}
 
-	for name, p := range cfg.Profiles {
+	// Unknown or circular parents are reported here; the resolved view is validated below.
+	resolvedCfg, err := ResolveProfiles(cfg)
+	if err != nil {
+		return err
+	}
+	for name, p := range resolvedCfg.Profiles {
 		if name == "" {
 			return fmt.Errorf("profile name cannot be empty")
 		}
+		for _, s := range p.Disable {
+			if _, ok := cfg.Slices[s]; !ok {
+				return fmt.Errorf("profile %q disables unknown slice %q", name, s)
+			}
+		}
 		if len(p.Enable) == 0 {
 			return fmt.Errorf("profile %q must enable at least one slice", name)
 		}
@@ -326,6 +709,21 @@ func Validate(cfg Config) error {
 				return fmt.Errorf("profile %q enables unknown slice %q", name, s)
 			}
 		}
+		for _, field := range []struct {
+			key   string
+			globs map[string][]string
+		}{{"include", p.Include}, {"exclude", p.Exclude}} {
+			for s, globs := range field.globs {
+				if _, ok := cfg.Slices[s]; !ok {
+					return fmt.Errorf("profile %q %s names unknown slice %q", name, field.key, s)
+				}
+				for _, pat := range globs {
+					if err := validateGlob(pat); err != nil {
+						return fmt.Errorf("profile %q %s pattern %q for slice %q is invalid: %v", name, field.key, pat, s, err)
+					}
+				}
+			}
+		}
 	}
 
 	if cfg.DefaultProfile != "" {
@@ -334,9 +732,13 @@ func Validate(cfg Config) error {
 		}
 	}
 
-	// Policy name check (v1 only supports drop_low_priority).
-	if cfg.Budgets.DropPolicy != "drop_low_priority" {
-		return fmt.Errorf("budgets.drop_policy must be 'drop_low_priority'")
+	switch cfg.Budgets.DropPolicy {
+	case "drop_low_priority", "drop_largest", "drop_newest":
+	default:
+		return fmt.Errorf("budgets.drop_policy must be 'drop_low_priority', 'drop_largest' or 'drop_newest'")
+	}
+	if cfg.Budgets.TruncateMode != "head" && cfg.Budgets.TruncateMode != "head_tail" {
+		return fmt.Errorf("budgets.truncate_mode must be 'head' or 'head_tail'")
 	}
 
 	return nil
@@ -375,9 +777,26 @@ func ApplyProfileOverrides(cfg Config, profile string) (Config, error) {
 	if p.Budgets.MaxChars > 0 {
 		out.Budgets.MaxChars = p.Budgets.MaxChars
 	}
+	if p.Budgets.MaxTokens > 0 {
+		out.Budgets.MaxTokens = p.Budgets.MaxTokens
+	}
 	if p.Render.TreeDepth > 0 {
 		out.Render.TreeDepth = p.Render.TreeDepth
 	}
+	if len(p.Include) > 0 || len(p.Exclude) > 0 {
+		out.Slices = make(map[string]SliceConfig, len(cfg.Slices))
+		for name, sl := range cfg.Slices {
+			if inc := p.Include[name]; len(inc) > 0 {
+				sl.Include = append(append([]string(nil), sl.Include...), inc...)
+				sl.ProfileInclude = inc
+			}
+			if exc := p.Exclude[name]; len(exc) > 0 {
+				sl.Exclude = append(append([]string(nil), sl.Exclude...), exc...)
+				sl.ProfileExclude = exc
+			}
+			out.Slices[name] = sl
+		}
+	}
 	return out, nil
 }
 
@@ -387,6 +806,12 @@ func Write(path string, cfg Config) error {
 	if err != nil {
 		return fmt.Errorf("marshal yaml: %w", err)
 	}
+	return WriteYAML(path, b)
+}
+
+// WriteYAML writes already-encoded config contents to path the way Write does: through a
+// temporary file renamed over the old one.
+func WriteYAML(path string, b []byte) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("mkdir: %w", err)
@@ -413,3 +838,35 @@ func EnsureNoSymlinkRoot(path string) error {
 	}
 	return nil
 }
+
+// validateGlob rejects slice patterns that could never match a file: malformed doublestar syntax
+// (matching would fail on every path, which selection treats as no match) and a trailing slash,
+// since patterns are matched against file paths, not directories.
+func validateGlob(pat string) error {
+	if pat == "" {
+		return nil
+	}
+	if !doublestar.ValidatePattern(pat) {
+		return doublestar.ErrBadPattern
+	}
+	if strings.HasSuffix(pat, "/") {
+		dir := strings.TrimSuffix(strings.TrimRight(pat, "/"), "/**")
+		return fmt.Errorf("patterns match files, not directories; use %q", dir+"/**")
+	}
+	return nil
+}
+
+// validateContentPatterns checks regexes applied to file content: each must compile and
+// must not match empty content, which would rewrite every position of every file.
+func validateContentPatterns(key string, pats []string) error {
+	for _, pat := range pats {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			return fmt.Errorf("%s %q is invalid: %v", key, pat, err)
+		}
+		if re.MatchString("") {
+			return fmt.Errorf("%s %q must not match empty content", key, pat)
+		}
+	}
+	return nil
+}
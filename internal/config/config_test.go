@@ -1,8 +1,12 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -23,6 +27,23 @@ func TestFindConfigPathPrecedence(t *testing.T) {
 	}
 }
 
+func TestResolveProfilePrecedence(t *testing.T) {
+	cfg := Config{DefaultProfile: "api"}
+	t.Setenv("SNIP_PROFILE", "ci")
+
+	if got := ResolveProfile(cfg, "debug"); got != "debug" {
+		t.Fatalf("ResolveProfile explicit=%q", got)
+	}
+	if got := ResolveProfile(cfg, ""); got != "ci" {
+		t.Fatalf("ResolveProfile env=%q", got)
+	}
+
+	t.Setenv("SNIP_PROFILE", "")
+	if got := ResolveProfile(cfg, ""); got != "api" {
+		t.Fatalf("ResolveProfile default=%q", got)
+	}
+}
+
 func TestLoadMergesDefaultsAndInfersProfile(t *testing.T) {
 	t.Parallel()
 
@@ -66,6 +87,231 @@ profiles:
 	}
 }
 
+func TestLoadResolvesProfileExtends(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".snip.yaml")
+	yaml := `
+default_profile: base
+slices:
+  api: {include: ["**/*.go"], priority: 10}
+  docs: {include: ["docs/**"], priority: 3}
+  tests: {include: ["**/*_test.go"], priority: 5}
+profiles:
+  base:
+    enable: [api, docs]
+    budgets: {max_chars: 50000}
+    render: {tree_depth: 2}
+    include: {api: ["cmd/**"]}
+  review:
+    extends: base
+    enable: [tests]
+    disable: [docs]
+    render: {tree_depth: 6}
+    include: {api: ["internal/**"]}
+  deep:
+    extends: review
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	deep := cfg.Profiles["deep"]
+	if strings.Join(deep.Enable, ",") != "api,tests" {
+		t.Fatalf("deep.Enable=%v", deep.Enable)
+	}
+	if deep.Budgets.MaxChars != 50000 || deep.Render.TreeDepth != 6 {
+		t.Fatalf("deep overrides=%+v %+v", deep.Budgets, deep.Render)
+	}
+
+	again, err := ResolveProfiles(cfg)
+	if err != nil {
+		t.Fatalf("ResolveProfiles: %v", err)
+	}
+	if strings.Join(again.Profiles["review"].Enable, ",") != "api,tests" {
+		t.Fatalf("re-resolved review.Enable=%v", again.Profiles["review"].Enable)
+	}
+	if got := deep.Include["api"]; strings.Join(got, ",") != "cmd/**,internal/**" {
+		t.Fatalf("deep.Include[api]=%v", got)
+	}
+	// Resolving twice is a no-op.
+	if !reflect.DeepEqual(again.Profiles, cfg.Profiles) {
+		t.Fatalf("re-resolved profiles=%+v\nwant %+v", again.Profiles, cfg.Profiles)
+	}
+}
+
+func TestLoadExpandsPresets(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".snip.yaml")
+	if err := os.WriteFile(path, []byte(`
+version: 1
+presets: [go-service@v1]
+slices:
+  api: {priority: 7}
+  docs: null
+profiles:
+  default: {enable: [api, tests]}
+`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	api := cfg.Slices["api"]
+	if api.Priority != 7 || !strings.Contains(strings.Join(api.Include, ","), "cmd/**/*.go") {
+		t.Fatalf("api slice=%+v", api)
+	}
+	if _, ok := cfg.Slices["docs"]; ok {
+		t.Fatalf("docs should be removed by null: %+v", cfg.Slices)
+	}
+	if cfg.PresetSlices["api"] != "go-service@v1" || cfg.PresetSlices["tests"] != "go-service@v1" {
+		t.Fatalf("preset slices=%v", cfg.PresetSlices)
+	}
+	if _, ok := cfg.PresetSlices["docs"]; ok {
+		t.Fatalf("removed slice still attributed: %v", cfg.PresetSlices)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("version: 1\npresets: [rails]\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), `unknown preset "rails"`) {
+		t.Fatalf("Load(unknown preset) err=%v", err)
+	}
+}
+
+func TestLoadLayersExtendedConfigFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		return p
+	}
+	write("team/.snip.base.yaml", `
+default_profile: api
+budgets: {max_chars: 50000, per_file_max_lines: 100}
+ignore: {use_gitignore: true, cache: true}
+slices:
+  api: {include: ["**/*.go"], exclude: ["**/*_gen.go"], priority: 10}
+  docs: {include: ["docs/**"], priority: 3}
+  infra: {include: ["deploy/**"], priority: 1}
+profiles:
+  api: {enable: [api, docs]}
+`)
+	local := write(".snip.local.yaml", `
+extends: team/.snip.base.yaml
+budgets: {max_chars: 90000}
+ignore: {cache: false}
+slices:
+  api: {include: ["cmd/**/*.go"]}
+  infra: null
+profiles:
+  api: {enable: [api]}
+`)
+
+	cfg, err := Load(local)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Budgets.MaxChars != 90000 || cfg.Budgets.PerFileMaxLines != 100 {
+		t.Fatalf("budgets=%+v", cfg.Budgets)
+	}
+	if !cfg.Ignore.UseGitignore || cfg.Ignore.Cache {
+		t.Fatalf("ignore=%+v", cfg.Ignore)
+	}
+	api := cfg.Slices["api"]
+	if strings.Join(api.Include, ",") != "cmd/**/*.go" || strings.Join(api.Exclude, ",") != "**/*_gen.go" || api.Priority != 10 {
+		t.Fatalf("api slice=%+v", api)
+	}
+	if _, ok := cfg.Slices["infra"]; ok {
+		t.Fatalf("infra should be removed by null: %+v", cfg.Slices)
+	}
+	if _, ok := cfg.Slices["docs"]; !ok || strings.Join(cfg.Profiles["api"].Enable, ",") != "api" {
+		t.Fatalf("slices=%+v profiles=%+v", cfg.Slices, cfg.Profiles)
+	}
+
+	write("a.yaml", "extends: b.yaml\n")
+	write("b.yaml", "extends: ./a.yaml\n")
+	if _, err := Load(filepath.Join(dir, "a.yaml")); err == nil || !strings.Contains(err.Error(), "extends cycle") {
+		t.Fatalf("Load(cycle) err=%v", err)
+	}
+}
+
+func TestApplyProfileOverridesAddsSliceGlobs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".snip.yaml")
+	if err := os.WriteFile(path, []byte(`
+default_profile: api
+slices:
+  api: {include: ["**/*.go"], exclude: ["**/*_test.go"], priority: 10}
+  tests: {include: ["**/*_test.go"], priority: 5}
+profiles:
+  api:
+    enable: [api, tests]
+    exclude: {tests: ["**/integration_test.go"]}
+  ci:
+    extends: api
+    include: {api: ["tools/*.sh"]}
+    exclude: {tests: ["e2e/**"]}
+`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	eff, err := ApplyProfileOverrides(cfg, "ci")
+	if err != nil {
+		t.Fatalf("ApplyProfileOverrides: %v", err)
+	}
+	tests := eff.Slices["tests"]
+	if got := strings.Join(tests.Exclude, ","); got != "**/integration_test.go,e2e/**" {
+		t.Fatalf("tests.Exclude=%s", got)
+	}
+	if got := strings.Join(tests.ProfileExclude, ","); got != "**/integration_test.go,e2e/**" {
+		t.Fatalf("tests.ProfileExclude=%s", got)
+	}
+	if got := strings.Join(eff.Slices["api"].Include, ","); got != "**/*.go,tools/*.sh" {
+		t.Fatalf("api.Include=%s", got)
+	}
+	// The loaded config is left alone.
+	if len(cfg.Slices["tests"].Exclude) != 0 || len(cfg.Slices["api"].Include) != 1 {
+		t.Fatalf("base slices modified: %+v", cfg.Slices)
+	}
+
+	bad := cfg
+	bad.Profiles = map[string]Profile{"api": {Enable: []string{"api"}, Exclude: map[string][]string{"docs": {"x"}}}}
+	if err := Validate(bad); err == nil || !strings.Contains(err.Error(), `profile "api" exclude names unknown slice "docs"`) {
+		t.Fatalf("Validate err=%v", err)
+	}
+	bad.Profiles = map[string]Profile{"api": {Enable: []string{"api"}, Include: map[string][]string{"api": {"cmd/[x"}}}}
+	if err := Validate(bad); err == nil || !strings.Contains(err.Error(), `profile "api" include pattern "cmd/[x" for slice "api" is invalid`) {
+		t.Fatalf("Validate err=%v", err)
+	}
+}
+
 func TestValidateRejectsInvalidConfig(t *testing.T) {
 	t.Parallel()
 
@@ -87,6 +333,128 @@ func TestValidateRejectsInvalidConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("negative slice budget", func(t *testing.T) {
+		cfg := base
+		cfg.Slices = map[string]SliceConfig{
+			"s": {Include: []string{"**/*.go"}, Priority: 1, Budget: SliceBudget{MaxFiles: -1}},
+		}
+		err := Validate(cfg)
+		if err == nil || !strings.Contains(err.Error(), "budget.max_files") {
+			t.Fatalf("Validate err=%v", err)
+		}
+	})
+
+	t.Run("invalid slice regex", func(t *testing.T) {
+		cfg := base
+		cfg.Slices = map[string]SliceConfig{
+			"s": {IncludeRegex: []string{`handler_(.*`}, Priority: 1},
+		}
+		err := Validate(cfg)
+		if err == nil || !strings.Contains(err.Error(), `slice "s" include_regex "handler_(.*" is invalid`) {
+			t.Fatalf("Validate err=%v", err)
+		}
+	})
+
+	t.Run("invalid slice glob", func(t *testing.T) {
+		for pat, want := range map[string]string{
+			"internal/[ab":  `slice "s" include pattern "internal/[ab" is invalid: syntax error in pattern`,
+			"internal/**/":  `use "internal/**"`,
+			"{api,cmd/*.go": `slice "s" include pattern "{api,cmd/*.go" is invalid`,
+		} {
+			cfg := base
+			cfg.Slices = map[string]SliceConfig{"s": {Include: []string{pat}, Priority: 1}}
+			err := Validate(cfg)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("Validate(%q) err=%v", pat, err)
+			}
+		}
+	})
+
+	t.Run("contains context", func(t *testing.T) {
+		for _, tc := range []struct {
+			slice SliceConfig
+			want  string
+		}{
+			{SliceConfig{Include: []string{"**/*.go"}, Priority: 1, ContainsRegex: "TODO", ContainsContext: 3}, ""},
+			{SliceConfig{Include: []string{"**/*.go"}, Priority: 1, ContainsContext: 3}, `slice "s" contains_context requires contains_regex`},
+			{SliceConfig{Include: []string{"**/*.go"}, Priority: 1, ContainsRegex: "TODO", ContainsContext: 3, WholeFilesOnly: true}, "cannot combine contains_context with whole_files_only"},
+		} {
+			cfg := base
+			cfg.Slices = map[string]SliceConfig{"s": tc.slice}
+			err := Validate(cfg)
+			if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+				t.Fatalf("Validate(%+v) err=%v", tc.slice, err)
+			}
+		}
+	})
+
+	t.Run("invalid include priority glob", func(t *testing.T) {
+		cfg := base
+		cfg.Slices = map[string]SliceConfig{
+			"s": {Include: []string{"**/*.go"}, Priority: 1, IncludePriorities: map[string]int{"internal/[ab": 5}},
+		}
+		err := Validate(cfg)
+		if err == nil || !strings.Contains(err.Error(), `slice "s" include_priorities pattern "internal/[ab" is invalid`) {
+			t.Fatalf("Validate err=%v", err)
+		}
+	})
+
+	t.Run("render languages", func(t *testing.T) {
+		cfg := base
+		cfg.Render.Languages = map[string]string{".tsx": "tsx", "vue": "html"}
+		if err := Validate(cfg); err != nil {
+			t.Fatalf("Validate err=%v", err)
+		}
+		for _, bad := range []map[string]string{{".TSX": "tsx"}, {".d.ts": "typescript"}, {"tsx": "type script"}} {
+			cfg.Render.Languages = bad
+			err := Validate(cfg)
+			if err == nil || !strings.Contains(err.Error(), "render.languages") {
+				t.Fatalf("Validate(%v) err=%v", bad, err)
+			}
+		}
+	})
+
+	t.Run("unknown parent profile", func(t *testing.T) {
+		cfg := base
+		cfg.Profiles = map[string]Profile{
+			"p": {Extends: "missing", Enable: []string{"s"}},
+		}
+		err := Validate(cfg)
+		if err == nil || !strings.Contains(err.Error(), `profile "p" extends unknown profile "missing"`) {
+			t.Fatalf("Validate err=%v", err)
+		}
+	})
+
+	t.Run("circular extends", func(t *testing.T) {
+		cfg := base
+		cfg.Profiles = map[string]Profile{
+			"a": {Extends: "b", Enable: []string{"s"}},
+			"b": {Extends: "a"},
+			"p": {Enable: []string{"s"}},
+		}
+		err := Validate(cfg)
+		if err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+			t.Fatalf("Validate err=%v", err)
+		}
+	})
+
+	t.Run("render format", func(t *testing.T) {
+		cfg := base
+		cfg.Render.Format = "plain"
+		if err := Validate(cfg); err != nil {
+			t.Fatalf("Validate(plain) err=%v", err)
+		}
+		cfg.Render.Format = "html"
+		if err := Validate(cfg); err != nil {
+			t.Fatalf("Validate(html) err=%v", err)
+		}
+		cfg.Render.Format = "pdf"
+		err := Validate(cfg)
+		if err == nil || !strings.Contains(err.Error(), "render.format") {
+			t.Fatalf("Validate err=%v", err)
+		}
+	})
+
 	t.Run("unknown slice in profile", func(t *testing.T) {
 		cfg := base
 		cfg.Profiles = map[string]Profile{
@@ -139,3 +507,288 @@ func TestEffectiveRootAndWriteAndSymlinkGuard(t *testing.T) {
 		t.Fatalf("EnsureNoSymlinkRoot real dir: %v", err)
 	}
 }
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"SNIP_OUT": "/tmp/out", "EMPTY": "", "PKG": "api"}
+	lookup := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	for _, tc := range []struct {
+		in, want, err string
+	}{
+		{in: "${SNIP_OUT:-.snip}", want: "/tmp/out"},
+		{in: "${MISSING:-.snip}", want: ".snip"},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "${EMPTY}", want: ""},
+		{in: "cost$$5 and $x", want: "cost$5 and $x"},
+		{in: "${MISSING}", err: `"MISSING" is not set`},
+		{in: "${SNIP_OUT", err: "unterminated"},
+	} {
+		got, err := expandEnv(tc.in, lookup)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expandEnv(%q) err=%v want %q", tc.in, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("expandEnv(%q)=(%q,%v) want %q", tc.in, got, err, tc.want)
+		}
+	}
+
+	cfg := Default()
+	cfg.Output.Dir = "${SNIP_OUT:-.snip}"
+	cfg.Slices = map[string]SliceConfig{"s": {Include: []string{"internal/${PKG}/**"}, Exclude: []string{"${NOPE}"}}}
+	if _, err := interpolateEnv(cfg, lookup); err == nil || !strings.Contains(err.Error(), "slices.s.exclude[0]") {
+		t.Fatalf("interpolateEnv err=%v", err)
+	}
+	cfg.Slices["s"] = SliceConfig{Include: []string{"internal/${PKG}/**"}}
+	out, err := interpolateEnv(cfg, lookup)
+	if err != nil {
+		t.Fatalf("interpolateEnv: %v", err)
+	}
+	if out.Output.Dir != "/tmp/out" || out.Slices["s"].Include[0] != "internal/api/**" {
+		t.Fatalf("out dir=%q include=%v", out.Output.Dir, out.Slices["s"].Include)
+	}
+	if cfg.Slices["s"].Include[0] != "internal/${PKG}/**" {
+		t.Fatalf("input config was modified: %v", cfg.Slices["s"].Include)
+	}
+}
+
+func TestSchemaMatchesValidate(t *testing.T) {
+	t.Parallel()
+
+	raw, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema: %v", err)
+	}
+	var root map[string]any
+	if err := json.Unmarshal(raw, &root); err != nil {
+		t.Fatalf("schema is not JSON: %v", err)
+	}
+	enums := map[string][]any{}
+	minimums := map[string]bool{}
+	var walk func(path string, s map[string]any)
+	walk = func(path string, s map[string]any) {
+		if e, ok := s["enum"].([]any); ok {
+			enums[path] = e
+		}
+		if _, ok := s["minimum"]; ok {
+			minimums[path] = true
+		}
+		if props, ok := s["properties"].(map[string]any); ok {
+			for name, sub := range props {
+				walk(joinSchemaPath(path, name), sub.(map[string]any))
+			}
+		}
+		if sub, ok := s["additionalProperties"].(map[string]any); ok {
+			walk(joinSchemaPath(path, "*"), sub)
+		}
+	}
+	walk("", root)
+	if len(enums) != len(schemaEnums) || len(minimums) != len(schemaNonNegative) {
+		t.Fatalf("schema enums=%v minimums=%v: a constrained path no longer matches a config field", enums, minimums)
+	}
+
+	base := Default()
+	base.DefaultProfile = "p"
+	base.Slices = map[string]SliceConfig{"s": {Include: []string{"**/*.go"}, Priority: 1}}
+	base.Profiles = map[string]Profile{"p": {Enable: []string{"s"}}}
+	if err := Validate(base); err != nil {
+		t.Fatalf("Validate(base) err=%v", err)
+	}
+
+	// Every string field Validate restricts ("<path> must be 'a' or 'b'") has the same enum in
+	// the schema.
+	quoted := regexp.MustCompile(`'([^']*)'`)
+	for _, path := range schemaStringPaths(reflect.ValueOf(base), "") {
+		cfg := cloneForSchemaTest(base)
+		setSchemaPath(t, reflect.ValueOf(&cfg).Elem(), strings.Split(path, "."), "bogus")
+		err := Validate(cfg)
+		if err == nil {
+			continue
+		}
+		allowed, ok := strings.CutPrefix(err.Error(), path+" must be ")
+		if !ok {
+			continue
+		}
+		var want []string
+		for _, m := range quoted.FindAllStringSubmatch(allowed, -1) {
+			want = append(want, m[1])
+		}
+		var got []string
+		for _, v := range enums[path] {
+			got = append(got, v.(string))
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: schema enum %v, Validate allows %v", path, got, want)
+		}
+	}
+
+	for path, values := range enums {
+		for _, v := range values {
+			cfg := cloneForSchemaTest(base)
+			setSchemaPath(t, reflect.ValueOf(&cfg).Elem(), strings.Split(path, "."), v.(string))
+			if err := Validate(cfg); err != nil {
+				t.Fatalf("%s=%v: schema allows it but Validate err=%v", path, v, err)
+			}
+		}
+		cfg := cloneForSchemaTest(base)
+		setSchemaPath(t, reflect.ValueOf(&cfg).Elem(), strings.Split(path, "."), "bogus")
+		if err := Validate(cfg); err == nil {
+			t.Fatalf("%s=bogus: schema rejects it but Validate accepts it", path)
+		}
+	}
+	for path := range minimums {
+		cfg := cloneForSchemaTest(base)
+		setSchemaPath(t, reflect.ValueOf(&cfg).Elem(), strings.Split(path, "."), int64(-1))
+		if err := Validate(cfg); err == nil {
+			t.Fatalf("%s=-1: schema rejects it but Validate accepts it", path)
+		}
+	}
+}
+
+func cloneForSchemaTest(cfg Config) Config {
+	cfg.Slices = map[string]SliceConfig{"s": cfg.Slices["s"]}
+	cfg.Profiles = map[string]Profile{"p": cfg.Profiles["p"]}
+	return cfg
+}
+
+// schemaStringPaths lists the YAML paths of the string fields in v; a non-empty map
+// contributes its first entry's fields under "*".
+func schemaStringPaths(v reflect.Value, path string) []string {
+	switch v.Kind() {
+	case reflect.String:
+		return []string{path}
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		return schemaStringPaths(v.MapIndex(v.MapKeys()[0]), joinSchemaPath(path, "*"))
+	case reflect.Struct:
+		var out []string
+		for i := 0; i < v.NumField(); i++ {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			out = append(out, schemaStringPaths(v.Field(i), joinSchemaPath(path, name))...)
+		}
+		return out
+	}
+	return nil
+}
+
+// setSchemaPath assigns v to the field named by the YAML path; "*" picks the map's only key.
+func setSchemaPath(t *testing.T, v reflect.Value, path []string, val any) {
+	t.Helper()
+	if len(path) == 0 {
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(val.(string))
+		case reflect.Int, reflect.Int64:
+			v.SetInt(val.(int64))
+		default:
+			t.Fatalf("cannot set %s", v.Type())
+		}
+		return
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		key := v.MapKeys()[0]
+		elem := reflect.New(v.Type().Elem()).Elem()
+		elem.Set(v.MapIndex(key))
+		setSchemaPath(t, elem, path[1:], val)
+		v.SetMapIndex(key, elem)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ","); name == path[0] {
+				setSchemaPath(t, v.Field(i), path[1:], val)
+				return
+			}
+		}
+		t.Fatalf("no field %q in %s", path[0], v.Type())
+	default:
+		t.Fatalf("cannot descend into %s", v.Type())
+	}
+}
+
+func TestMigrateYAML(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name, in, want string
+		steps          int
+		err            string
+	}{
+		{
+			name:  "unversioned file gets version 1 under its head comment",
+			in:    "# team config\nroot: .\nslices:\n    api:\n        include: [\"**/*.go\"] # code\n",
+			want:  "# team config\nversion: 1\nroot: .\nslices:\n    api:\n        include: [\"**/*.go\"] # code\n",
+			steps: 1,
+		},
+		{name: "empty file", in: "", want: "version: 1\n", steps: 1},
+		{name: "current file is untouched", in: "version: 1\nroot:   .\n", want: "version: 1\nroot:   .\n"},
+		{name: "newer version", in: "version: 2\n", err: "newer than this snip supports"},
+		{name: "bad version", in: "version: one\n", err: "non-negative integer"},
+		{name: "not a mapping", in: "- a\n", err: "YAML mapping"},
+	} {
+		got, steps, err := MigrateYAML([]byte(tc.in))
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("%s: err=%v want %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: MigrateYAML: %v", tc.name, err)
+		}
+		if string(got) != tc.want || len(steps) != tc.steps {
+			t.Fatalf("%s: got %q steps=%v\nwant %q", tc.name, got, steps, tc.want)
+		}
+	}
+
+	// Every version up to CurrentVersion has exactly one step.
+	for i, m := range migrations {
+		if m.from != i || m.summary == "" {
+			t.Fatalf("migrations[%d]=%+v", i, m)
+		}
+	}
+	if len(migrations) != CurrentVersion {
+		t.Fatalf("%d migrations for CurrentVersion %d", len(migrations), CurrentVersion)
+	}
+}
+
+func TestLoadMigratesEachLayer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	if err := os.WriteFile(base, []byte("version: 9\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	local := filepath.Join(dir, ".snip.yaml")
+	if err := os.WriteFile(local, []byte("extends: base.yaml\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := Load(local); err == nil || !strings.Contains(err.Error(), "base.yaml: config version 9 is newer") {
+		t.Fatalf("Load with a newer base=%v", err)
+	}
+
+	if err := os.WriteFile(base, []byte("slices:\n  api: {include: [\"**/*.go\"]}\nprofiles:\n  api: {enable: [api]}\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(local)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Fatalf("version=%d", cfg.Version)
+	}
+}
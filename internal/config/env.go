@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// interpolateEnv expands ${VAR} and ${VAR:-default} in the config fields that
+// commonly vary per environment: root, output.dir, output.pattern and slice
+// include/exclude globs. "$$" yields a literal "$".
+func interpolateEnv(cfg Config, lookup func(string) (string, bool)) (Config, error) {
+	var err error
+	expand := func(field string, s *string) {
+		if err != nil {
+			return
+		}
+		var v string
+		if v, err = expandEnv(*s, lookup); err != nil {
+			err = fmt.Errorf("%s: %w", field, err)
+			return
+		}
+		*s = v
+	}
+
+	expand("root", &cfg.Root)
+	expand("output.dir", &cfg.Output.Dir)
+	expand("output.pattern", &cfg.Output.Pattern)
+
+	names := make([]string, 0, len(cfg.Slices))
+	for name := range cfg.Slices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	slices := make(map[string]SliceConfig, len(cfg.Slices))
+	for _, name := range names {
+		sl := cfg.Slices[name]
+		sl.Include = append([]string(nil), sl.Include...)
+		sl.Exclude = append([]string(nil), sl.Exclude...)
+		for i := range sl.Include {
+			expand(fmt.Sprintf("slices.%s.include[%d]", name, i), &sl.Include[i])
+		}
+		for i := range sl.Exclude {
+			expand(fmt.Sprintf("slices.%s.exclude[%d]", name, i), &sl.Exclude[i])
+		}
+		slices[name] = sl
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	if cfg.Slices != nil {
+		cfg.Slices = slices
+	}
+	return cfg, nil
+}
+
+// expandEnv expands ${VAR} and ${VAR:-default} in s. A set-but-empty variable
+// takes the default, as in POSIX shells. A "$" not followed by "{" or "$" is
+// kept as is.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			expr := s[i+2 : i+2+end]
+			name, def, hasDef := strings.Cut(expr, ":-")
+			if name == "" {
+				return "", fmt.Errorf("empty variable name in %q", s)
+			}
+			v, ok := lookup(name)
+			switch {
+			case ok && v != "":
+				b.WriteString(v)
+			case hasDef:
+				b.WriteString(def)
+			case ok:
+				// Set but empty, no default.
+			default:
+				return "", fmt.Errorf("environment variable %q is not set", name)
+			}
+			i += 2 + end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
@@ -17,3 +17,19 @@ func FindConfigPath(explicit string) string {
 	}
 	return ".snip.yaml"
 }
+
+// ResolveProfile resolves the profile to use.
+//
+// Precedence:
+//  1. explicit argument
+//  2. SNIP_PROFILE env var
+//  3. cfg.DefaultProfile
+func ResolveProfile(cfg Config, explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if v := os.Getenv("SNIP_PROFILE"); v != "" {
+		return v
+	}
+	return cfg.DefaultProfile
+}
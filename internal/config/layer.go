@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mmrzaf/snip/internal/presets"
+)
+
+// readLayers reads the config file at path and, when it sets `extends: <path>`, the chain of
+// base files below it, and returns them merged into one YAML mapping. A relative extends path
+// is resolved against the directory of the file that names it.
+//
+// Precedence: the extending file wins. Mappings (including slices and profiles) merge
+// recursively by key; any other value, lists included, replaces the base value; a key set to
+// null removes the inherited value.
+func readLayers(path string, chain []string) (map[string]any, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	for i, c := range chain {
+		if c == abs {
+			return nil, fmt.Errorf("config extends cycle: %s", strings.Join(append(chain[i:], abs), " -> "))
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		if len(chain) > 0 {
+			return nil, fmt.Errorf("parse yaml %s: %w", path, err)
+		}
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	// Each file is migrated to CurrentVersion on its own, before the layers merge.
+	var layer map[string]any
+	if _, err := Migrate(&doc); err != nil {
+		if len(chain) > 0 {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, err
+	}
+	if err := doc.Decode(&layer); err != nil {
+		if len(chain) > 0 {
+			return nil, fmt.Errorf("parse yaml %s: %w", path, err)
+		}
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	if layer == nil {
+		layer = map[string]any{}
+	}
+
+	raw, ok := layer["extends"]
+	if !ok || raw == nil {
+		return layer, nil
+	}
+	base, ok := raw.(string)
+	if !ok || base == "" {
+		return nil, fmt.Errorf("%s: extends must be a config file path", path)
+	}
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(filepath.Dir(path), base)
+	}
+	under, err := readLayers(base, append(chain, abs))
+	if err != nil {
+		return nil, err
+	}
+	return mergeLayer(under, layer), nil
+}
+
+// applyPresets layers the built-in presets named by the merged config's `presets` list under
+// it, in list order (a later preset wins over an earlier one, and the config over both, key by
+// key as with extends). It also returns which preset each of their slices came from.
+func applyPresets(layer map[string]any) (map[string]any, map[string]string, error) {
+	raw, ok := layer["presets"]
+	if !ok || raw == nil {
+		return layer, nil, nil
+	}
+	refs, ok := raw.([]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("presets must be a list of preset names")
+	}
+	base := map[string]any{}
+	owners := map[string]string{}
+	for _, r := range refs {
+		ref, ok := r.(string)
+		if !ok || ref == "" {
+			return nil, nil, fmt.Errorf("presets must be a list of preset names")
+		}
+		p, err := presets.Lookup(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		base = mergeLayer(base, p.Layer)
+		for _, s := range p.Slices() {
+			owners[s] = p.ID()
+		}
+	}
+	return mergeLayer(base, layer), owners, nil
+}
+
+// mergeLayer returns base overlaid with over (see readLayers for the rules). Neither input is
+// modified.
+func mergeLayer(base, over map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		if v == nil {
+			delete(out, k)
+			continue
+		}
+		bm, bok := out[k].(map[string]any)
+		om, ook := v.(map[string]any)
+		if bok && ook {
+			out[k] = mergeLayer(bm, om)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version this snip reads and writes.
+const CurrentVersion = 1
+
+// migration upgrades a config document from version from to from+1. Migrate sets the new
+// version itself; apply only rewrites the keys that changed, and may be nil.
+type migration struct {
+	from    int
+	summary string
+	apply   func(doc *yaml.Node) error
+}
+
+// migrations holds one step per schema version, in order (migrations[i].from == i). A
+// published step never changes: a schema change adds the next step, documents it in
+// ARCHITECTURE.md §6.7 and bumps CurrentVersion.
+var migrations = []migration{
+	{from: 0, summary: "set version: 1 (the version key was optional before it was required)"},
+}
+
+// Migrate upgrades the config document doc (a YAML document or mapping node) in place to
+// CurrentVersion, one step at a time, and returns what each applied step did; nothing when doc
+// is already current. Comments and key order are kept. A version newer than CurrentVersion is
+// an error: snip cannot know what a later release changed.
+func Migrate(doc *yaml.Node) ([]string, error) {
+	m, err := documentMapping(doc)
+	if err != nil {
+		return nil, err
+	}
+	version := 0
+	if v := mappingValue(m, "version"); v != nil {
+		n, err := strconv.Atoi(v.Value)
+		if v.Kind != yaml.ScalarNode || err != nil || n < 0 {
+			return nil, fmt.Errorf("version must be a non-negative integer, got %q", v.Value)
+		}
+		version = n
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("config version %d is newer than this snip supports (version %d); upgrade snip", version, CurrentVersion)
+	}
+
+	var applied []string
+	for ; version < CurrentVersion; version++ {
+		step := migrations[version]
+		if step.apply != nil {
+			if err := step.apply(m); err != nil {
+				return nil, fmt.Errorf("migrate version %d to %d: %w", version, version+1, err)
+			}
+		}
+		setVersion(m, version+1)
+		applied = append(applied, fmt.Sprintf("%d -> %d: %s", version, version+1, step.summary))
+	}
+	return applied, nil
+}
+
+// MigrateYAML migrates the config file contents b (see Migrate). When no step applies, b is
+// returned unchanged; otherwise the document is re-encoded with the file's own indentation.
+func MigrateYAML(b []byte) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	applied, err := Migrate(&doc)
+	if err != nil || len(applied) == 0 {
+		return b, nil, err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(yamlIndent(b))
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("marshal yaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("marshal yaml: %w", err)
+	}
+	return buf.Bytes(), applied, nil
+}
+
+// documentMapping returns the top-level mapping of doc, creating it for an empty document.
+func documentMapping(doc *yaml.Node) (*yaml.Node, error) {
+	switch doc.Kind {
+	case 0:
+		*doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+		return doc.Content[0], nil
+	case yaml.DocumentNode:
+		if len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode {
+			return doc.Content[0], nil
+		}
+		if len(doc.Content) == 1 && doc.Content[0].Tag == "!!null" {
+			doc.Content[0] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			return doc.Content[0], nil
+		}
+	case yaml.MappingNode:
+		return doc, nil
+	}
+	return nil, fmt.Errorf("config must be a YAML mapping")
+}
+
+// mappingValue returns the value node for key in mapping m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setVersion sets the version key of m, adding it as the first key when missing.
+func setVersion(m *yaml.Node, version int) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	if v := mappingValue(m, "version"); v != nil {
+		v.Kind, v.Tag, v.Value, v.Style = value.Kind, value.Tag, value.Value, 0
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if len(m.Content) > 0 {
+		// A comment heading the file stays at the top.
+		key.HeadComment, m.Content[0].HeadComment = m.Content[0].HeadComment, ""
+	}
+	m.Content = append([]*yaml.Node{key, value}, m.Content...)
+}
+
+// yamlIndent guesses the indentation b uses from its first indented line (2 if none is).
+func yamlIndent(b []byte) int {
+	for _, line := range strings.Split(string(b), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if n := len(line) - len(trimmed); n > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "- ") {
+			return n
+		}
+	}
+	return 2
+}
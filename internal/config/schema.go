@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the $id of the JSON Schema returned by Schema.
+const SchemaID = "https://github.com/mmrzaf/snip/schema/snip.schema.json"
+
+// schemaEnums lists the allowed values of string fields by dotted YAML path ("*" stands for a
+// slice or profile name). Validate enforces the same sets; TestSchemaMatchesValidate reads
+// them back from its errors to keep the two in sync.
+var schemaEnums = map[string][]string{
+	"output.compress":           {"none", "gzip"},
+	"output.split":              {"none", "per_slice"},
+	"render.format":             {"md", "ndjson", "plain", "html"},
+	"render.warnings_position":  {"top", "bottom"},
+	"render.file_order":         {"path", "slice_priority", "include_order"},
+	"budgets.drop_policy":       {"drop_low_priority", "drop_largest", "drop_newest"},
+	"budgets.truncate_mode":     {"head", "head_tail"},
+	"selector.primary_tiebreak": {"name", "first_enabled"},
+}
+
+// schemaConsts pins integer fields to a single value.
+var schemaConsts = map[string]int{
+	"version": 1,
+}
+
+// schemaNonNegative lists integer fields that Validate rejects when negative. A 0 budget is
+// replaced by the default (budgets.*) or disables the limit (everything else).
+var schemaNonNegative = []string{
+	"budgets.max_chars",
+	"budgets.max_tokens",
+	"budgets.per_file_max_lines",
+	"budgets.per_file_max_bytes",
+	"ignore.max_file_bytes",
+	"ignore.max_files",
+	"output.keep_last",
+	"render.tree_max_nodes",
+	"slices.*.budget.max_chars",
+	"slices.*.budget.max_files",
+	"slices.*.contains_context",
+}
+
+// Schema returns a JSON Schema (draft 2020-12) for .snip.yaml, derived from the Config struct's
+// YAML tags plus the enum and range constraints that Validate enforces. Editors can use it via
+// a `# yaml-language-server: $schema=<file>` comment.
+func Schema() ([]byte, error) {
+	nonNeg := make(map[string]bool, len(schemaNonNegative))
+	for _, p := range schemaNonNegative {
+		nonNeg[p] = true
+	}
+	root := schemaFor(reflect.TypeOf(Config{}), "", nonNeg)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = SchemaID
+	root["title"] = "snip configuration (.snip.yaml)"
+	// Validate requires slices and profiles, but a file that extends a base may inherit them and
+	// presets supply slices.
+	root["if"] = map[string]any{"not": map[string]any{"anyOf": []any{
+		map[string]any{"required": []string{"extends"}},
+		map[string]any{"required": []string{"presets"}},
+	}}}
+	root["then"] = map[string]any{"required": []string{"slices", "profiles"}}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func schemaFor(t reflect.Type, path string, nonNeg map[string]bool) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "" || name == "-" || !f.IsExported() {
+				continue
+			}
+			props[name] = schemaFor(f.Type, joinSchemaPath(path, name), nonNeg)
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), joinSchemaPath(path, "*"), nonNeg)}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), path, nonNeg)}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		s := map[string]any{"type": "integer"}
+		if v, ok := schemaConsts[path]; ok {
+			s["const"] = v
+		}
+		if nonNeg[path] {
+			s["minimum"] = 0
+		}
+		return s
+	default:
+		s := map[string]any{"type": "string"}
+		if enum := schemaEnums[path]; len(enum) > 0 {
+			s["enum"] = enum
+		}
+		return s
+	}
+}
+
+func joinSchemaPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
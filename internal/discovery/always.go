@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// alwaysRule is one ignore.always glob. A leading "!" negates it, re-including paths an
+// earlier rule excluded; "\!" escapes a literal "!".
+type alwaysRule struct {
+	glob   string
+	negate bool
+}
+
+// alwaysRules is the ordered ignore.always list; as in gitignore, the last matching
+// rule decides.
+type alwaysRules []alwaysRule
+
+func parseAlways(patterns []string) alwaysRules {
+	var rs alwaysRules
+	for _, p := range patterns {
+		r := alwaysRule{glob: p}
+		switch {
+		case strings.HasPrefix(p, `\!`):
+			r.glob = p[1:]
+		case strings.HasPrefix(p, "!"):
+			r.glob, r.negate = p[1:], true
+		}
+		if r.glob == "" {
+			continue
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// String renders the rule as written in the config.
+func (r alwaysRule) String() string {
+	switch {
+	case r.negate:
+		return "!" + r.glob
+	case strings.HasPrefix(r.glob, "!"):
+		return `\` + r.glob
+	}
+	return r.glob
+}
+
+// last returns the index of the last rule matching rel, or -1. Invalid globs never match.
+func (rs alwaysRules) last(rel string) int {
+	for i := len(rs) - 1; i >= 0; i-- {
+		if ok, err := doublestar.Match(rs[i].glob, rel); err == nil && ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// match reports whether the file rel is excluded.
+func (rs alwaysRules) match(rel string) bool {
+	i := rs.last(rel)
+	return i >= 0 && !rs[i].negate
+}
+
+// prune reports whether the directory rel can be skipped entirely: it is excluded (matched
+// as "rel/") and no later negation could re-include anything beneath it. Unlike git, an
+// excluded directory is still walked when a negation reaches into it, so
+// "vendor/**" followed by "!vendor/mypkg/**" keeps vendor/mypkg.
+func (rs alwaysRules) prune(rel string) bool {
+	i := rs.last(rel + "/")
+	if i < 0 || rs[i].negate {
+		return false
+	}
+	for _, r := range rs[i+1:] {
+		if r.negate && reachesInto(r.glob, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// reachesInto reports whether glob could match a path inside the directory dir, judged
+// by the glob's literal leading directories.
+func reachesInto(glob, dir string) bool {
+	base, _ := doublestar.SplitPattern(glob)
+	return base == "." || base == dir ||
+		strings.HasPrefix(base, dir+"/") || strings.HasPrefix(dir, base+"/")
+}
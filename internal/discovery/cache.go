@@ -0,0 +1,153 @@
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// CacheDir is where discovery results are cached, relative to the root.
+const CacheDir = ".snip/cache"
+
+const (
+	cacheFile    = "discovery.json"
+	cacheVersion = "snip-discovery-cache/1"
+)
+
+type cacheEntry struct {
+	Key   string     `json:"key"`
+	Files []PathInfo `json:"files"`
+}
+
+// cacheKey hashes everything a discovery result depends on that can change
+// without re-reading every file: the engine options, the ignore files' content,
+// and the mtime of every directory not pruned by ignore.always.
+//
+// Directory mtimes change when entries are added, removed or renamed; editing a
+// file in place does not, so sizes and binary sniff results can be stale until
+// a directory in the tree changes.
+func (e *Engine) cacheKey() (string, error) {
+	h := sha256.New()
+	field := func(parts ...any) {
+		for _, p := range parts {
+			_, _ = fmt.Fprintf(h, "%#v\x00", p)
+		}
+		_, _ = h.Write([]byte{'\n'})
+	}
+
+	field(cacheVersion, e.root)
+	field("gitignore", e.useGitignore, "snipignore", e.useSnipignore)
+	field("always", e.ignoreAlways)
+	field("sensitive", e.sensitiveGlobs)
+	exts := append([]string(nil), e.binaryExtsList...)
+	sort.Strings(exts)
+	field("binary", exts)
+	texts := make([]string, 0, len(e.textExts))
+	for ext := range e.textExts {
+		texts = append(texts, ext)
+	}
+	sort.Strings(texts)
+	field("text", texts, e.sniffRatio)
+	field("limits", e.maxFileBytes, e.maxFiles)
+	// The index changes on `git add` without touching any walked directory.
+	field("tracked", e.tracked != nil)
+	if e.tracked != nil {
+		tracked := make([]string, 0, len(e.tracked))
+		for rel := range e.tracked {
+			tracked = append(tracked, rel)
+		}
+		sort.Strings(tracked)
+		field(tracked)
+	}
+	for _, path := range e.excludesFiles {
+		if err := hashFile(h, path); err != nil {
+			return "", err
+		}
+	}
+	if e.useSnipignore {
+		if err := hashFile(h, filepath.Join(e.root, SnipignoreFile)); err != nil {
+			return "", err
+		}
+	}
+
+	cacheRel := filepath.ToSlash(CacheDir)
+	err := filepath.WalkDir(e.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(e.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." {
+			if rel == cacheRel || e.ignoreAlways.prune(rel) {
+				return filepath.SkipDir
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		field("dir", rel, info.ModTime().UnixNano())
+		if e.useGitignore {
+			return hashFile(h, filepath.Join(path, gitignoreFile))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the content of path to h; a missing file hashes as empty.
+func hashFile(h hash.Hash, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, _ = fmt.Fprintf(h, "%s\x00%d\x00", filepath.Base(path), len(data))
+	_, _ = h.Write(data)
+	return nil
+}
+
+func (e *Engine) cachePath() string {
+	return filepath.Join(e.root, filepath.FromSlash(CacheDir), cacheFile)
+}
+
+// loadCache returns the cached result when its key matches.
+func (e *Engine) loadCache(key string) ([]PathInfo, bool) {
+	data, err := os.ReadFile(e.cachePath())
+	if err != nil {
+		return nil, false
+	}
+	var ce cacheEntry
+	if err := json.Unmarshal(data, &ce); err != nil || ce.Key != key {
+		return nil, false
+	}
+	return ce.Files, true
+}
+
+func (e *Engine) saveCache(key string, files []PathInfo) error {
+	data, err := json.Marshal(cacheEntry{Key: key, Files: files})
+	if err != nil {
+		return err
+	}
+	path := e.cachePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return util.AtomicWriteFile(path, data, 0o644)
+}
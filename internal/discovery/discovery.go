@@ -6,11 +6,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/bmatcuk/doublestar/v4"
-	"github.com/go-git/go-billy/v5/osfs"
-	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 
 	"github.com/mmrzaf/snip/internal/util"
 )
@@ -19,16 +21,24 @@ import (
 type ExclusionReason string
 
 const (
-	// ExcludedOutsideRoot indicates the file is not under root (should not happen with filepath.WalkDir).
+	// ExcludedOutsideRoot indicates the path resolves outside root (a followed symlink escaping it).
 	ExcludedOutsideRoot ExclusionReason = "excluded_outside_root"
 	// ExcludedIgnoreAlways indicates the file matched ignore.always.
 	ExcludedIgnoreAlways ExclusionReason = "excluded_ignore_always"
+	// ExcludedSnipignore indicates the file matched .snipignore rules.
+	ExcludedSnipignore ExclusionReason = "excluded_snipignore"
 	// ExcludedSensitive indicates the file matched sensitive.exclude_globs.
 	ExcludedSensitive ExclusionReason = "excluded_sensitive"
 	// ExcludedGitignore indicates the file matched gitignore rules.
 	ExcludedGitignore ExclusionReason = "excluded_gitignore"
 	// ExcludedBinary indicates a file is binary by extension or sniffing.
 	ExcludedBinary ExclusionReason = "excluded_binary"
+	// ExcludedTooLarge indicates the file exceeds ignore.max_file_bytes.
+	ExcludedTooLarge ExclusionReason = "excluded_too_large"
+	// ExcludedFileLimit indicates ignore.max_files was already reached.
+	ExcludedFileLimit ExclusionReason = "excluded_file_limit"
+	// ExcludedUntracked indicates the file is not tracked by git (ignore.tracked_only).
+	ExcludedUntracked ExclusionReason = "excluded_untracked"
 	// ExcludedUnreadable indicates the file could not be opened/stat/read.
 	ExcludedUnreadable ExclusionReason = "unreadable"
 )
@@ -44,171 +54,507 @@ type PathInfo struct {
 	ExclusionDetail string
 }
 
+// Options configures a discovery Engine.
+type Options struct {
+	UseGitignore   bool
+	UseSnipignore  bool // read <root>/.snipignore (gitignore syntax)
+	FollowSymlinks bool // follow symlinks that resolve inside root
+	IgnoreAlways   []string
+	SensitiveGlobs []string
+	BinaryExts     []string
+	Workers        int   // stat/sniff concurrency; 0 means GOMAXPROCS
+	UseCache       bool  // reuse results from <root>/.snip/cache while directory mtimes are unchanged
+	MaxFileBytes   int64 // files larger than this are excluded; 0 disables
+	MaxFiles       int   // files past this many (in path order) are excluded unread; 0 disables
+	// Tracked, when non-nil, is the set of root-relative paths tracked by git; other
+	// files are excluded as untracked.
+	Tracked map[string]bool
+	// TextExts are never excluded as binary: neither binary_extensions nor the sniff applies.
+	TextExts []string
+	// BinarySniffRatio is the sniff threshold (see util.SniffBinaryRatio); 0 means the default.
+	BinarySniffRatio float64
+	// Progress, when set, is called during the walk with the number of files seen so far.
+	// It is not called when Discover is answered from the cache.
+	Progress func(files int)
+}
+
+// SnipignoreFile is the snip-specific ignore file read from the root.
+const SnipignoreFile = ".snipignore"
+
 // Engine discovers files under a root applying ignore rules.
 type Engine struct {
-	root             string
-	useGitignore     bool
-	ignoreAlways     []string
-	sensitiveGlobs   []string
-	binaryExts       map[string]bool
-	gitignoreMatcher gitignore.Matcher
+	root            string
+	useGitignore    bool
+	followSymlinks  bool
+	ignoreAlways    alwaysRules
+	sensitiveGlobs  []string
+	binaryExts      map[string]bool
+	gitignoreRules  ignoreRules // git excludes files and root .gitignore; nested files are added during Discover
+	snipignoreRules ignoreRules
+	// workers bounds how many files are stat'ed and sniffed concurrently.
+	workers        int
+	useSnipignore  bool
+	useCache       bool
+	binaryExtsList []string // as configured, for the cache key
+	maxFileBytes   int64
+	maxFiles       int
+	tracked        map[string]bool
+	// excludesFiles are the global and .git/info/exclude files read for gitignoreRules.
+	excludesFiles []string
+	textExts      map[string]bool
+	sniffRatio    float64
+	progress      func(files int)
 }
 
 // NewEngine builds a discovery engine for the given root.
 func NewEngine(root string, useGitignore bool, ignoreAlways, sensitiveGlobs, binaryExts []string) (*Engine, error) {
+	return New(root, Options{
+		UseGitignore:   useGitignore,
+		IgnoreAlways:   ignoreAlways,
+		SensitiveGlobs: sensitiveGlobs,
+		BinaryExts:     binaryExts,
+	})
+}
+
+// New builds a discovery engine for the given root and options.
+func New(root string, opts Options) (*Engine, error) {
 	abs, err := filepath.Abs(root)
 	if err != nil {
 		return nil, fmt.Errorf("abs root: %w", err)
 	}
-	extMap := map[string]bool{}
-	for _, e := range binaryExts {
-		if e == "" {
-			continue
+	extMap := extensionSet(opts.BinaryExts)
+
+	var gitRules ignoreRules
+	var excludesFiles []string
+	if opts.UseGitignore {
+		// Git's own excludes come first so the repo's .gitignore can override them.
+		excludesFiles = gitExcludesFiles(abs)
+		for _, path := range excludesFiles {
+			source := filepath.ToSlash(path)
+			if rel, err := filepath.Rel(abs, path); err == nil && filepath.IsLocal(rel) {
+				source = filepath.ToSlash(rel)
+			}
+			rules, err := readIgnorePath(path, source, nil)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", source, err)
+			}
+			gitRules = append(gitRules, rules...)
 		}
-		if !strings.HasPrefix(e, ".") {
-			e = "." + e
+		rootRules, err := readIgnoreFile(abs, gitignoreFile, nil)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", gitignoreFile, err)
 		}
-		extMap[strings.ToLower(e)] = true
+		gitRules = append(gitRules, rootRules...)
 	}
 
-	var matcher gitignore.Matcher
-	if useGitignore {
-		fs := osfs.New(abs)
-		pats, err := gitignore.ReadPatterns(fs, nil)
-		if err != nil && !errors.Is(err, os.ErrNotExist) {
-			return nil, fmt.Errorf("read gitignore: %w", err)
+	var snipRules ignoreRules
+	if opts.UseSnipignore {
+		snipRules, err = readIgnoreFile(abs, SnipignoreFile, nil)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", SnipignoreFile, err)
 		}
-		matcher = gitignore.NewMatcher(pats)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
 	}
 
 	return &Engine{
-		root:             abs,
-		useGitignore:     useGitignore,
-		ignoreAlways:     ignoreAlways,
-		sensitiveGlobs:   sensitiveGlobs,
-		binaryExts:       extMap,
-		gitignoreMatcher: matcher,
+		root:            abs,
+		useGitignore:    opts.UseGitignore,
+		followSymlinks:  opts.FollowSymlinks,
+		ignoreAlways:    parseAlways(opts.IgnoreAlways),
+		sensitiveGlobs:  opts.SensitiveGlobs,
+		binaryExts:      extMap,
+		gitignoreRules:  gitRules,
+		snipignoreRules: snipRules,
+		workers:         workers,
+		useSnipignore:   opts.UseSnipignore,
+		useCache:        opts.UseCache,
+		binaryExtsList:  opts.BinaryExts,
+		maxFileBytes:    opts.MaxFileBytes,
+		maxFiles:        opts.MaxFiles,
+		tracked:         opts.Tracked,
+		excludesFiles:   excludesFiles,
+		textExts:        extensionSet(opts.TextExts),
+		sniffRatio:      opts.BinarySniffRatio,
+		progress:        opts.Progress,
 	}, nil
 }
 
+// extensionSet normalizes extensions ("png" or ".PNG") to lower-case ".png" keys.
+func extensionSet(exts []string) map[string]bool {
+	set := map[string]bool{}
+	for _, e := range exts {
+		if e == "" {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		set[strings.ToLower(e)] = true
+	}
+	return set
+}
+
 // Discover walks the root and returns discovered file candidates.
+// With UseCache, a previous result is reused when the cache key still matches.
 func (e *Engine) Discover() ([]PathInfo, error) {
-	var out []PathInfo
+	// Followed symlinks can reach directories whose mtimes the key does not cover.
+	if !e.useCache || e.followSymlinks {
+		return e.discover()
+	}
+	key, err := e.cacheKey()
+	if err != nil {
+		return e.discover()
+	}
+	if out, ok := e.loadCache(key); ok {
+		return out, nil
+	}
+	out, err := e.discover()
+	if err != nil {
+		return nil, err
+	}
+	// The cache is an optimization; failing to write it is not an error.
+	_ = e.saveCache(key, out)
+	return out, nil
+}
 
-	err := filepath.WalkDir(e.root, func(path string, d os.DirEntry, err error) error {
+func (e *Engine) discover() ([]PathInfo, error) {
+	w := &walker{
+		e: e,
+		// Nested .gitignore files are accumulated as their directories are entered;
+		// each rule is scoped to its own directory, so siblings never interfere.
+		gitRules: append(ignoreRules(nil), e.gitignoreRules...),
+		visited:  map[string]bool{},
+	}
+	if e.followSymlinks {
+		real, err := filepath.EvalSymlinks(e.root)
 		if err != nil {
-			// IO error when traversing: propagate, because root traversal itself failed.
-			return err
+			return nil, fmt.Errorf("resolve root: %w", err)
 		}
-		if path == e.root {
-			return nil
+		w.realRoot = real
+		w.visited[real] = true
+	}
+	if err := w.walk(e.root, ""); err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	// Inspect in path order so ignore.max_files can stop before the rest are stat'ed.
+	slices.SortFunc(w.pending, func(a, b candidate) int { return strings.Compare(a.rel, b.rel) })
+	w.out = append(w.out, e.inspect(w.pending)...)
+
+	// Determinism: sort by relpath.
+	// (The caller may further group/order included files.)
+	sortPathInfos(w.out)
+	e.applyFileLimit(w.out)
+	return w.out, nil
+}
+
+// applyFileLimit excludes files past the maxFiles-th candidate, in path order. inspect
+// already stops early; this settles the files that were in flight when it did.
+func (e *Engine) applyFileLimit(infos []PathInfo) {
+	if e.maxFiles <= 0 {
+		return
+	}
+	kept := 0
+	for i := range infos {
+		if infos[i].Excluded {
+			continue
+		}
+		if kept < e.maxFiles {
+			kept++
+			continue
 		}
+		infos[i].Excluded = true
+		infos[i].ExclusionReason = ExcludedFileLimit
+		infos[i].ExclusionDetail = fmt.Sprintf("ignore.max_files=%d", e.maxFiles)
+	}
+}
 
-		// Avoid following symlinks: they can escape root and/or loop.
-		if d.Type()&os.ModeSymlink != 0 {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
+// walker holds the state of a single Discover call.
+type walker struct {
+	e        *Engine
+	gitRules ignoreRules
+	out      []PathInfo
+	// pending holds files whose path rules were evaluated during the walk; their
+	// stat and binary sniff run afterwards on the worker pool (see inspect).
+	pending  []candidate
+	realRoot string
+	// visited holds resolved directories already walked, so a symlink cycle (or two
+	// links to the same target) never walks a directory twice.
+	visited map[string]bool
+	// dirs, when non-nil, records each directory walked (absolute path to rel); see Watchlist.
+	dirs map[string]string
+}
+
+// walk visits the tree at dir, reporting paths under the logical prefix relPrefix.
+// For the root relPrefix is empty; for a followed symlink it is the link's path.
+func (w *walker) walk(dir, relPrefix string) error {
+	e := w.e
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// IO error when traversing: propagate, because root traversal itself failed.
+			return err
+		}
+		if path == dir {
 			return nil
 		}
 
-		rel, err := filepath.Rel(e.root, path)
+		rel, err := filepath.Rel(dir, path)
 		if err != nil {
 			return nil
 		}
 		rel = filepath.ToSlash(rel)
+		if relPrefix != "" {
+			rel = relPrefix + "/" + rel
+		}
+
+		if d.Type()&os.ModeSymlink != 0 {
+			// Without follow_symlinks, skip them: they can escape root and/or loop.
+			if !e.followSymlinks {
+				return nil
+			}
+			return w.visitSymlink(path, rel)
+		}
 
-		// Skip directories early if they match ignore always.
 		if d.IsDir() {
-			if e.matchesAny(rel+"/", e.ignoreAlways) {
+			skip, err := w.enterDir(rel)
+			if err != nil {
+				return err
+			}
+			if skip {
 				return filepath.SkipDir
 			}
-			if e.useGitignore && e.gitignoreMatcher != nil {
-				parts := strings.Split(rel, "/")
-				if e.gitignoreMatcher.Match(append(parts, ""), true) {
-					return filepath.SkipDir
-				}
+			if w.dirs != nil {
+				w.dirs[path] = rel
 			}
 			return nil
 		}
 
-		pi := PathInfo{
-			RelPath:  rel,
-			AbsPath:  path,
-			IsHidden: isHiddenRel(rel),
-		}
+		w.visitFile(rel)
+		return nil
+	})
+}
 
-		st, statErr := os.Stat(path)
-		if statErr != nil {
-			pi.Excluded = true
-			pi.ExclusionReason = ExcludedUnreadable
-			pi.ExclusionDetail = statErr.Error()
-			out = append(out, pi)
-			return nil
-		}
-		pi.SizeBytes = st.Size()
-
-		// Apply ignore order from ARCHITECTURE.md §8.2.
-		if e.matchesAny(rel, e.ignoreAlways) {
-			pi.Excluded = true
-			pi.ExclusionReason = ExcludedIgnoreAlways
-			pi.ExclusionDetail = "ignore.always"
-			out = append(out, pi)
+// visitSymlink resolves a symlink and visits its target under the link's path.
+func (w *walker) visitSymlink(path, rel string) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		w.out = append(w.out, w.excluded(rel, ExcludedUnreadable, err.Error()))
+		return nil
+	}
+	if _, ok := util.RelWithinRoot(w.realRoot, target); !ok {
+		w.out = append(w.out, w.excluded(rel, ExcludedOutsideRoot, "symlink target "+target))
+		return nil
+	}
+	st, err := os.Stat(target)
+	if err != nil {
+		w.out = append(w.out, w.excluded(rel, ExcludedUnreadable, err.Error()))
+		return nil
+	}
+	if !st.IsDir() {
+		w.visitFile(rel)
+		return nil
+	}
+
+	skip, err := w.enterDir(rel)
+	if err != nil || skip {
+		return err
+	}
+	if w.visited[target] {
+		return nil
+	}
+	// A link to one of its own ancestors would recurse forever.
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		if _, inside := util.RelWithinRoot(target, parent); inside {
 			return nil
 		}
-		if e.matchesAny(rel, e.sensitiveGlobs) {
-			pi.Excluded = true
-			pi.ExclusionReason = ExcludedSensitive
-			pi.ExclusionDetail = "sensitive.exclude_globs"
-			out = append(out, pi)
-			return nil
+	}
+	w.visited[target] = true
+	if w.dirs != nil {
+		w.dirs[target] = rel
+	}
+	return w.walk(target, rel)
+}
+
+// enterDir applies directory-level rules and loads the directory's .gitignore.
+// It reports whether the directory should be skipped.
+func (w *walker) enterDir(rel string) (bool, error) {
+	e := w.e
+	if e.ignoreAlways.prune(rel) {
+		return true, nil
+	}
+	parts := strings.Split(rel, "/")
+	if _, ok := e.snipignoreRules.match(parts, true); ok {
+		return true, nil
+	}
+	if e.useGitignore {
+		if _, ok := w.gitRules.match(parts, true); ok {
+			return true, nil
 		}
-		if e.useGitignore && e.gitignoreMatcher != nil {
-			parts := strings.Split(rel, "/")
-			if e.gitignoreMatcher.Match(parts, false) {
-				pi.Excluded = true
-				pi.ExclusionReason = ExcludedGitignore
-				pi.ExclusionDetail = ".gitignore"
-				out = append(out, pi)
-				return nil
-			}
+		nested, err := readIgnoreFile(e.root, rel+"/"+gitignoreFile, parts)
+		if err != nil {
+			return false, fmt.Errorf("read %s/%s: %w", rel, gitignoreFile, err)
 		}
+		w.gitRules = append(w.gitRules, nested...)
+	}
+	return false, nil
+}
 
-		ext := strings.ToLower(filepath.Ext(rel))
-		if e.binaryExts[ext] {
-			pi.Excluded = true
-			pi.ExclusionReason = ExcludedBinary
-			pi.ExclusionDetail = "binary extension"
-			out = append(out, pi)
-			return nil
-		}
-		isBin, sniffErr := sniffBinary(path)
-		if sniffErr != nil {
-			pi.Excluded = true
-			pi.ExclusionReason = ExcludedUnreadable
-			pi.ExclusionDetail = sniffErr.Error()
-			out = append(out, pi)
-			return nil
+func (w *walker) excluded(rel string, reason ExclusionReason, detail string) PathInfo {
+	return PathInfo{
+		RelPath:         rel,
+		AbsPath:         filepath.Join(w.e.root, filepath.FromSlash(rel)),
+		IsHidden:        isHiddenRel(rel),
+		Excluded:        true,
+		ExclusionReason: reason,
+		ExclusionDetail: detail,
+	}
+}
+
+// candidate is a file seen during the walk, with the verdict of its path rules.
+type candidate struct {
+	rel    string
+	reason ExclusionReason // empty when no path rule excluded it
+	detail string
+}
+
+// visitFile queues rel for inspection along with the verdict of its path rules.
+func (w *walker) visitFile(rel string) {
+	reason, detail := w.pathRules(rel)
+	w.pending = append(w.pending, candidate{rel: rel, reason: reason, detail: detail})
+	if w.e.progress != nil {
+		w.e.progress(len(w.pending))
+	}
+}
+
+// pathRules applies the path-based ignore rules to rel.
+func (w *walker) pathRules(rel string) (ExclusionReason, string) {
+	e := w.e
+	// Apply ignore order from ARCHITECTURE.md §8.2 (.snipignore layered after ignore.always).
+	if e.ignoreAlways.match(rel) {
+		return ExcludedIgnoreAlways, "ignore.always"
+	}
+	parts := strings.Split(rel, "/")
+	if r, ok := e.snipignoreRules.match(parts, false); ok {
+		return ExcludedSnipignore, r.String()
+	}
+	if e.matchesAny(rel, e.sensitiveGlobs) {
+		return ExcludedSensitive, "sensitive.exclude_globs"
+	}
+	if e.useGitignore {
+		if r, ok := w.gitRules.match(parts, false); ok {
+			return ExcludedGitignore, r.String()
 		}
-		if isBin {
-			pi.Excluded = true
-			pi.ExclusionReason = ExcludedBinary
-			pi.ExclusionDetail = "binary sniff"
-			out = append(out, pi)
-			return nil
+	}
+	if e.tracked != nil && !e.tracked[rel] {
+		return ExcludedUntracked, "not in git ls-files"
+	}
+	return "", ""
+}
+
+// inspect stats and sniffs candidates on a bounded worker pool. Results keep the
+// candidates' order, so each PathInfo (and any error) stays with its own file. Candidates
+// must be in path order: once maxFiles of them are kept, the rest are not queued and are
+// settled by their path rules alone (see limitedFile).
+func (e *Engine) inspect(cands []candidate) []PathInfo {
+	out := make([]PathInfo, len(cands))
+	idx := make(chan int)
+	var kept atomic.Int64
+	var wg sync.WaitGroup
+	for range min(e.workers, len(cands)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				out[i] = e.inspectFile(cands[i])
+				if !out[i].Excluded {
+					kept.Add(1)
+				}
+			}
+		}()
+	}
+	queued := 0
+	for ; queued < len(cands); queued++ {
+		if e.maxFiles > 0 && kept.Load() >= int64(e.maxFiles) {
+			break
 		}
+		idx <- queued
+	}
+	close(idx)
+	wg.Wait()
+	for i := queued; i < len(cands); i++ {
+		out[i] = e.limitedFile(cands[i])
+	}
+	return out
+}
 
-		out = append(out, pi)
-		return nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("walk: %w", err)
+// limitedFile settles a candidate past ignore.max_files without any IO: path rules and
+// binary extensions still name their own reason, everything else hit the file limit.
+func (e *Engine) limitedFile(c candidate) PathInfo {
+	pi := PathInfo{
+		RelPath:  c.rel,
+		AbsPath:  filepath.Join(e.root, filepath.FromSlash(c.rel)),
+		IsHidden: isHiddenRel(c.rel),
+		Excluded: true,
 	}
+	ext := strings.ToLower(filepath.Ext(c.rel))
+	switch {
+	case c.reason != "":
+		pi.ExclusionReason, pi.ExclusionDetail = c.reason, c.detail
+	case e.binaryExts[ext] && !e.textExts[ext]:
+		pi.ExclusionReason, pi.ExclusionDetail = ExcludedBinary, "binary extension"
+	default:
+		pi.ExclusionReason, pi.ExclusionDetail = ExcludedFileLimit, fmt.Sprintf("ignore.max_files=%d", e.maxFiles)
+	}
+	return pi
+}
 
-	// Determinism: sort by relpath.
-	// (The caller may further group/order included files.)
-	sortPathInfos(out)
-	return out, nil
+// inspectFile applies the IO-bound checks (stat, binary extension, sniff) to c.
+func (e *Engine) inspectFile(c candidate) PathInfo {
+	path := filepath.Join(e.root, filepath.FromSlash(c.rel))
+	pi := PathInfo{
+		RelPath:  c.rel,
+		AbsPath:  path,
+		IsHidden: isHiddenRel(c.rel),
+	}
+	exclude := func(reason ExclusionReason, detail string) PathInfo {
+		pi.Excluded = true
+		pi.ExclusionReason = reason
+		pi.ExclusionDetail = detail
+		return pi
+	}
+
+	st, statErr := os.Stat(path)
+	if statErr != nil {
+		return exclude(ExcludedUnreadable, statErr.Error())
+	}
+	pi.SizeBytes = st.Size()
+	if c.reason != "" {
+		return exclude(c.reason, c.detail)
+	}
+
+	// Checked before sniffing: a huge text file would otherwise be read in full later.
+	if e.maxFileBytes > 0 && pi.SizeBytes > e.maxFileBytes {
+		return exclude(ExcludedTooLarge, fmt.Sprintf("%d bytes > ignore.max_file_bytes=%d", pi.SizeBytes, e.maxFileBytes))
+	}
+
+	ext := strings.ToLower(filepath.Ext(c.rel))
+	if e.textExts[ext] {
+		return pi
+	}
+	if e.binaryExts[ext] {
+		return exclude(ExcludedBinary, "binary extension")
+	}
+	isBin, sniffErr := sniffBinary(path, e.sniffRatio)
+	if sniffErr != nil {
+		return exclude(ExcludedUnreadable, sniffErr.Error())
+	}
+	if isBin {
+		return exclude(ExcludedBinary, "binary sniff")
+	}
+	return pi
 }
 
 func isHiddenRel(rel string) bool {
@@ -240,7 +586,7 @@ func (e *Engine) matchesAny(rel string, patterns []string) bool {
 	return false
 }
 
-func sniffBinary(path string) (bool, error) {
+func sniffBinary(path string, ratio float64) (bool, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return false, err
@@ -254,7 +600,16 @@ func sniffBinary(path string) (bool, error) {
 		return false, err
 	}
 	buf = buf[:r]
-	return util.SniffBinary(buf), nil
+	// UTF-16 text is full of NUL bytes; sniff what it decodes to instead.
+	switch enc, bomLen := util.DetectBOM(buf); enc {
+	case util.EncodingUTF16LE, util.EncodingUTF16BE:
+		units := buf[bomLen:]
+		text, _ := util.DecodeUTF16(units[:len(units)&^1], enc)
+		buf = []byte(text)
+	case util.EncodingUTF8BOM:
+		buf = buf[bomLen:]
+	}
+	return util.SniffBinaryRatio(buf, ratio), nil
 }
 
 func sortPathInfos(in []PathInfo) {
@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuleCheck is one path-rule stage evaluated by ExplainPath.
+type RuleCheck struct {
+	// Rule names the stage: "ignore.always", ".snipignore", "sensitive.exclude_globs",
+	// ".gitignore" or "tracked_only".
+	Rule string
+	// Path is the path the stage was checked against: the file itself, or the ancestor
+	// directory (with a trailing "/") whose exclusion pruned it.
+	Path string
+	// Patterns are the patterns evaluated, in order (for .snipignore and .gitignore,
+	// "<source>: <pattern>").
+	Patterns []string
+	// Matched is the pattern that decided the verdict, if any. With a negation it is the
+	// "!pattern" that re-included the path.
+	Matched  string
+	Excluded bool
+}
+
+// ExplainPath evaluates the path rules for rel in the order Discover applies them and
+// returns every stage checked. Evaluation stops at the first stage that excludes the
+// path; an excluded ancestor directory is reported in place of the file's own checks.
+// Binary, size and readability checks are not path rules and are not included.
+func (e *Engine) ExplainPath(rel string) ([]RuleCheck, error) {
+	w := &walker{e: e, gitRules: append(ignoreRules(nil), e.gitignoreRules...)}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if c, pruned := w.dirExclusion(dir); pruned {
+			return []RuleCheck{c}, nil
+		}
+		if e.useGitignore {
+			nested, err := readIgnoreFile(e.root, dir+"/"+gitignoreFile, parts[:i])
+			if err != nil {
+				return nil, fmt.Errorf("read %s/%s: %w", dir, gitignoreFile, err)
+			}
+			w.gitRules = append(w.gitRules, nested...)
+		}
+	}
+	return w.fileChecks(rel), nil
+}
+
+// dirExclusion mirrors enterDir: it returns the check that prunes the directory rel, if any.
+func (w *walker) dirExclusion(rel string) (RuleCheck, bool) {
+	e := w.e
+	path := rel + "/"
+	parts := strings.Split(rel, "/")
+	// Pruning also weighs later negations, so a matching ignore.always glob alone is not enough.
+	if e.ignoreAlways.prune(rel) {
+		return alwaysCheck(e.ignoreAlways, path).at(path), true
+	}
+	if c := ruleListCheck(".snipignore", e.snipignoreRules, parts, true); c.Excluded {
+		return c.at(path), true
+	}
+	if e.useGitignore {
+		if c := ruleListCheck(".gitignore", w.gitRules, parts, true); c.Excluded {
+			return c.at(path), true
+		}
+	}
+	return RuleCheck{}, false
+}
+
+// fileChecks mirrors pathRules for the file rel.
+func (w *walker) fileChecks(rel string) []RuleCheck {
+	e := w.e
+	parts := strings.Split(rel, "/")
+	var checks []RuleCheck
+	add := func(c RuleCheck) bool {
+		checks = append(checks, c.at(rel))
+		return c.Excluded
+	}
+	if add(alwaysCheck(e.ignoreAlways, rel)) {
+		return checks
+	}
+	if e.useSnipignore && add(ruleListCheck(".snipignore", e.snipignoreRules, parts, false)) {
+		return checks
+	}
+	sensitive := RuleCheck{Rule: "sensitive.exclude_globs"}
+	for _, g := range e.sensitiveGlobs {
+		if g == "" {
+			continue
+		}
+		sensitive.Patterns = append(sensitive.Patterns, g)
+		if sensitive.Matched == "" && e.matchesAny(rel, []string{g}) {
+			sensitive.Matched, sensitive.Excluded = g, true
+		}
+	}
+	if add(sensitive) {
+		return checks
+	}
+	if e.useGitignore && add(ruleListCheck(".gitignore", w.gitRules, parts, false)) {
+		return checks
+	}
+	if e.tracked != nil {
+		tracked := RuleCheck{Rule: "tracked_only", Patterns: []string{"git ls-files"}}
+		if !e.tracked[rel] {
+			tracked.Matched, tracked.Excluded = "not in git ls-files", true
+		}
+		add(tracked)
+	}
+	return checks
+}
+
+func (c RuleCheck) at(path string) RuleCheck {
+	c.Path = path
+	return c
+}
+
+func alwaysCheck(rs alwaysRules, rel string) RuleCheck {
+	c := RuleCheck{Rule: "ignore.always"}
+	for _, r := range rs {
+		c.Patterns = append(c.Patterns, r.String())
+	}
+	if i := rs.last(rel); i >= 0 {
+		c.Matched, c.Excluded = rs[i].String(), !rs[i].negate
+	}
+	return c
+}
+
+func ruleListCheck(name string, rs ignoreRules, parts []string, isDir bool) RuleCheck {
+	c := RuleCheck{Rule: name}
+	for _, r := range rs {
+		c.Patterns = append(c.Patterns, r.String())
+	}
+	if i, excluded := rs.decide(parts, isDir); i >= 0 {
+		c.Matched, c.Excluded = rs[i].String(), excluded
+	}
+	return c
+}
@@ -0,0 +1,159 @@
+package discovery
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gitconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+const gitignoreFile = ".gitignore"
+
+// ignoreRule is one gitignore-syntax pattern together with where it came from,
+// so exclusions can be explained.
+type ignoreRule struct {
+	pattern gitignore.Pattern
+	source  string // file the rule was read from, relative to root
+	text    string // the pattern as written
+}
+
+// String renders the rule as "<source>: <pattern>".
+func (r ignoreRule) String() string {
+	return r.source + ": " + r.text
+}
+
+// ignoreRules is an ordered rule list; later rules take precedence.
+type ignoreRules []ignoreRule
+
+// match returns the rule that excludes parts. As in git, the last matching rule
+// decides, so a later negation ("!pattern") re-includes the path.
+func (rs ignoreRules) match(parts []string, isDir bool) (ignoreRule, bool) {
+	if i, excluded := rs.decide(parts, isDir); excluded {
+		return rs[i], true
+	}
+	return ignoreRule{}, false
+}
+
+// decide returns the index of the last rule matching parts (-1 if none) and whether it
+// excludes them.
+func (rs ignoreRules) decide(parts []string, isDir bool) (int, bool) {
+	for i := len(rs) - 1; i >= 0; i-- {
+		switch rs[i].pattern.Match(parts, isDir) {
+		case gitignore.Exclude:
+			return i, true
+		case gitignore.Include:
+			return i, false
+		}
+	}
+	return -1, false
+}
+
+// readIgnoreFile parses the gitignore-syntax file rel (slash-separated, relative to
+// root). A missing file yields no rules. domain is the slash-split directory the
+// patterns apply to.
+func readIgnoreFile(root, rel string, domain []string) (ignoreRules, error) {
+	return readIgnorePath(filepath.Join(root, filepath.FromSlash(rel)), rel, domain)
+}
+
+// readIgnorePath is readIgnoreFile for a file anywhere on disk; source names it in
+// explanations.
+func readIgnorePath(path, source string, domain []string) (ignoreRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var rules ignoreRules
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		rules = append(rules, ignoreRule{
+			pattern: gitignore.ParsePattern(line, domain),
+			source:  source,
+			text:    strings.TrimSpace(line),
+		})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// gitExcludesFiles returns the files git reads before any .gitignore, lowest precedence
+// first: the global excludes file (core.excludesFile, defaulting to
+// $XDG_CONFIG_HOME/git/ignore) and <root>/.git/info/exclude. The files need not exist.
+func gitExcludesFiles(root string) []string {
+	var files []string
+	if global := globalExcludesFile(root); global != "" {
+		files = append(files, global)
+	}
+	return append(files, filepath.Join(root, ".git", "info", "exclude"))
+}
+
+// globalExcludesFile resolves core.excludesFile from the user's git config files and the
+// repository's .git/config (later files win), as git does. It returns "" when neither the
+// setting nor a home directory is available.
+func globalExcludesFile(root string) string {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	var configs []string
+	if xdg != "" {
+		configs = append(configs, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+	configs = append(configs, filepath.Join(root, ".git", "config"))
+
+	file := ""
+	for _, path := range configs {
+		if v := readExcludesSetting(path); v != "" {
+			file = v
+		}
+	}
+	switch {
+	case file == "":
+		if xdg == "" {
+			return ""
+		}
+		return filepath.Join(xdg, "git", "ignore")
+	case file == "~" || strings.HasPrefix(file, "~/"):
+		if home == "" {
+			return ""
+		}
+		return filepath.Join(home, file[1:])
+	case !filepath.IsAbs(file):
+		return filepath.Join(root, file)
+	}
+	return file
+}
+
+// readExcludesSetting returns core.excludesFile from a git config file, or "" if the file
+// is missing, unparsable or does not set it.
+func readExcludesSetting(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+	cfg := gitconfig.New()
+	if err := gitconfig.NewDecoder(f).Decode(cfg); err != nil {
+		return ""
+	}
+	return cfg.Section("core").Options.Get("excludesfile")
+}
@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Watchlist is the set of directories a walk descends into, together with the ignore rules
+// loaded along the way, so a file watcher can tell which events may change discovery.
+type Watchlist struct {
+	Root string // absolute root
+	// Dirs maps each walked directory's absolute path to its root-relative path ("" for the root).
+	Dirs map[string]string
+	w    *walker
+}
+
+// Watchlist walks the tree like Discover, skipping ignored directories, without inspecting files.
+// A Watchlist reflects the tree at the time of the call; build a new one after changes.
+func (e *Engine) Watchlist() (*Watchlist, error) {
+	w := &walker{
+		e:        e,
+		gitRules: append(ignoreRules(nil), e.gitignoreRules...),
+		visited:  map[string]bool{},
+		dirs:     map[string]string{e.root: ""},
+	}
+	if e.followSymlinks {
+		real, err := filepath.EvalSymlinks(e.root)
+		if err != nil {
+			return nil, fmt.Errorf("resolve root: %w", err)
+		}
+		w.realRoot = real
+		w.visited[real] = true
+	}
+	if err := w.walk(e.root, ""); err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	return &Watchlist{Root: e.root, Dirs: w.dirs, w: w}, nil
+}
+
+// Ignored reports whether a change to the absolute path name cannot affect discovery: it lies
+// outside the walked directories, or the path rules (ignore.always, .snipignore, sensitive globs,
+// .gitignore) exclude it.
+func (l *Watchlist) Ignored(name string) bool {
+	dir, ok := l.Dirs[filepath.Dir(name)]
+	if !ok {
+		return true
+	}
+	rel := filepath.Base(name)
+	if dir != "" {
+		rel = dir + "/" + rel
+	}
+	reason, _ := l.w.pathRules(rel)
+	return reason != ""
+}
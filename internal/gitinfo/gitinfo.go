@@ -1,24 +1,340 @@
 package gitinfo
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
+// shortLen matches git's default abbreviated object name length.
+const shortLen = 7
+
+// openRepo opens the repository containing root with go-git, so no git binary is needed.
+func openRepo(root string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(root, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+}
+
 // ShortSHA returns the short git SHA for the repo at root.
+// It reads the repository with go-git and only shells out to git if go-git cannot open it.
 // If git is unavailable or root is not a git repo, it returns "" and a non-nil error.
 func ShortSHA(ctx context.Context, root string) (string, error) {
-	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--short", "HEAD")
+	repo, err := openRepo(root)
+	if err != nil {
+		out, err := runGit(ctx, root, "rev-parse", "--short", "HEAD")
+		if err != nil {
+			return "", fmt.Errorf("git rev-parse: %w", err)
+		}
+		return out, nil
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("git head: %w", err)
+	}
+	return head.Hash().String()[:shortLen], nil
+}
+
+// Branch returns the current branch name for the repo at root, or "" on a detached HEAD.
+// If git is unavailable or root is not a git repo, it returns "" and a non-nil error.
+func Branch(ctx context.Context, root string) (string, error) {
+	repo, err := openRepo(root)
+	if err != nil {
+		out, err := runGit(ctx, root, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return "", fmt.Errorf("git rev-parse: %w", err)
+		}
+		if out == "HEAD" {
+			return "", nil
+		}
+		return out, nil
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("git head: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return "", nil
+	}
+	return head.Name().Short(), nil
+}
+
+// IsDirty reports whether the working tree at root has uncommitted changes
+// (including untracked, non-ignored files).
+// It runs `git status --porcelain` when the git binary is available, so the answer matches
+// git's (core.excludesFile, the untracked cache and fsmonitor all apply), and only falls back
+// to go-git's worktree status, which hashes every file, without it.
+// If git is unavailable or root is not a git repo, it returns false and a non-nil error.
+func IsDirty(ctx context.Context, root string) (bool, error) {
+	if _, err := exec.LookPath("git"); err == nil {
+		out, err := runGit(ctx, root, "status", "--porcelain")
+		if err != nil {
+			return false, fmt.Errorf("git status: %w", err)
+		}
+		return out != "", nil
+	}
+	repo, err := openRepo(root)
+	if err != nil {
+		return false, fmt.Errorf("git open: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return false, fmt.Errorf("git worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return false, fmt.Errorf("git status: %w", err)
+	}
+	return !status.IsClean(), nil
+}
+
+// TrackedFiles returns the files in the git index under root, as slash-separated paths
+// relative to root (which may be a subdirectory of the work tree), like `git ls-files`.
+// If git is unavailable or root is not a git repo, it returns nil and a non-nil error.
+func TrackedFiles(ctx context.Context, root string) (map[string]bool, error) {
+	repo, err := openRepo(root)
+	if err != nil {
+		out, err := runGit(ctx, root, "ls-files", "-z")
+		if err != nil {
+			return nil, fmt.Errorf("git ls-files: %w", err)
+		}
+		tracked := map[string]bool{}
+		for _, name := range strings.Split(out, "\x00") {
+			if name != "" {
+				tracked[name] = true
+			}
+		}
+		return tracked, nil
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("git worktree: %w", err)
+	}
+	prefix, err := subdirPrefix(wt.Filesystem.Root(), root)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("git index: %w", err)
+	}
+	tracked := make(map[string]bool, len(idx.Entries))
+	for _, e := range idx.Entries {
+		if rel, ok := strings.CutPrefix(e.Name, prefix); ok {
+			tracked[rel] = true
+		}
+	}
+	return tracked, nil
+}
+
+// ChangedFiles returns the files under root that differ between the merge base of ref and HEAD
+// and HEAD itself, like `git diff --name-only ref...HEAD`, as slash-separated paths relative to
+// root. Deleted files are left out. If git is unavailable, root is not a git repo, or ref does
+// not resolve, it returns nil and a non-nil error.
+func ChangedFiles(ctx context.Context, root, ref string) (map[string]bool, error) {
+	repo, err := openRepo(root)
+	if err != nil {
+		out, err := runGit(ctx, root, "diff", "--name-only", "-z", "--relative", "--diff-filter=d", ref+"...HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("git diff %s...HEAD: %w", ref, err)
+		}
+		changed := map[string]bool{}
+		for _, name := range strings.Split(out, "\x00") {
+			if name != "" {
+				changed[name] = true
+			}
+		}
+		return changed, nil
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("git worktree: %w", err)
+	}
+	prefix, err := subdirPrefix(wt.Filesystem.Root(), root)
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("git head: %w", err)
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("git head commit: %w", err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("resolve %q: %w", ref, err)
+	}
+	refCommit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %q: %w", ref, err)
+	}
+	bases, err := refCommit.MergeBase(headCommit)
+	if err != nil {
+		return nil, fmt.Errorf("merge base of %q and HEAD: %w", ref, err)
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("%q and HEAD have no common ancestor", ref)
+	}
+	baseTree, err := bases[0].Tree()
+	if err != nil {
+		return nil, fmt.Errorf("git tree: %w", err)
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("git tree: %w", err)
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, baseTree, headTree, nil)
+	if err != nil {
+		return nil, fmt.Errorf("git diff: %w", err)
+	}
+	changed := map[string]bool{}
+	for _, ch := range changes {
+		if ch.To.Name == "" {
+			continue // deleted
+		}
+		if rel, ok := strings.CutPrefix(ch.To.Name, prefix); ok {
+			changed[rel] = true
+		}
+	}
+	return changed, nil
+}
+
+// FileCommit is the most recent commit that touched a file.
+type FileCommit struct {
+	SHA        string // short SHA
+	AuthorDate time.Time
+}
+
+// commitMarker starts each commit header in FileCommits' git log output.
+const commitMarker = "\x1e"
+
+// pathspecBatch caps the paths passed to one `git log` so the command line stays short.
+const pathspecBatch = 256
+
+// FileCommits returns the last commit of each of paths (slash-separated, relative to root).
+// Untracked paths are skipped; the rest are passed as literal pathspecs to `git log
+// --name-only` (in batches of pathspecBatch), so git only walks history for those paths and
+// each log stops early once all of its paths have been seen. Paths without history are absent
+// from the result. If git is unavailable or root is not a git repo, it returns nil and a
+// non-nil error.
+func FileCommits(ctx context.Context, root string, paths []string) (map[string]FileCommit, error) {
+	if tracked, err := TrackedFiles(ctx, root); err == nil {
+		var keep []string
+		for _, p := range paths {
+			if tracked[p] {
+				keep = append(keep, p)
+			}
+		}
+		paths = keep
+	}
+	out := make(map[string]FileCommit, len(paths))
+	for start := 0; start < len(paths); start += pathspecBatch {
+		if err := logFileCommits(ctx, root, paths[start:min(start+pathspecBatch, len(paths))], out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// logFileCommits runs one `git log` limited to paths and records each path's first
+// (most recent) commit in out.
+func logFileCommits(ctx context.Context, root string, paths []string, out map[string]FileCommit) error {
+	want := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		want[p] = true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	args := []string{"--literal-pathspecs", "-c", "core.quotePath=false", "log",
+		"--format=" + commitMarker + "%h%x09%aI", "--name-only", "--relative", "--no-renames", "--"}
+	cmd := exec.CommandContext(ctx, "git", append(args, paths...)...)
+	cmd.Dir = root
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("git log: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("git log: %w", err)
+	}
+
+	var cur FileCommit
+	found := 0
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() && found < len(want) {
+		line := sc.Text()
+		if header, ok := strings.CutPrefix(line, commitMarker); ok {
+			sha, date, _ := strings.Cut(header, "\t")
+			cur = FileCommit{SHA: sha}
+			cur.AuthorDate, _ = time.Parse(time.RFC3339, date)
+			continue
+		}
+		if line == "" || !want[line] {
+			continue
+		}
+		if _, seen := out[line]; !seen {
+			out[line] = cur
+			found++
+		}
+	}
+	if found == len(want) {
+		// Everything was found; stop git instead of reading the rest of history.
+		cancel()
+		_ = cmd.Wait()
+		return nil
+	}
+	if err := sc.Err(); err != nil {
+		_ = cmd.Wait()
+		return fmt.Errorf("git log: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git log: %w", err)
+	}
+	return nil
+}
+
+// subdirPrefix returns root's path inside the work tree as "dir/" ("" for the top level),
+// resolving symlinks on both sides.
+func subdirPrefix(worktree, root string) (string, error) {
+	top, err := filepath.EvalSymlinks(worktree)
+	if err != nil {
+		return "", fmt.Errorf("resolve work tree: %w", err)
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("abs root: %w", err)
+	}
+	if abs, err = filepath.EvalSymlinks(abs); err != nil {
+		return "", fmt.Errorf("resolve root: %w", err)
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("root %s is outside work tree %s", abs, top)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel) + "/", nil
+}
+
+// runGit runs the git binary in root and returns its trimmed stdout.
+func runGit(ctx context.Context, root string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = root
 	var out bytes.Buffer
 	cmd.Stdout = &out
-	cmd.Stderr = &out
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("git rev-parse: %w", err)
+		return "", err
 	}
-	sha := strings.TrimSpace(out.String())
-	return sha, nil
+	return strings.TrimSpace(out.String()), nil
 }
@@ -0,0 +1,271 @@
+package gitinfo
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestGitInfoReadsRepoWithoutGitBinary(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := t.TempDir()
+
+	if sha, err := ShortSHA(ctx, root); err == nil || sha != "" {
+		t.Fatalf("ShortSHA outside repo=(%q,%v)", sha, err)
+	}
+
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	if _, err := wt.Add("a.txt"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	hash, err := wt.Commit("init", &git.CommitOptions{
+		Author: &object.Signature{Name: "t", Email: "t@example.com", When: time.Unix(0, 0)},
+	})
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	sha, err := ShortSHA(ctx, sub)
+	if err != nil || sha != hash.String()[:7] {
+		t.Fatalf("ShortSHA=(%q,%v) want %q", sha, err, hash.String()[:7])
+	}
+	if branch, err := Branch(ctx, root); err != nil || branch != "master" {
+		t.Fatalf("Branch=(%q,%v)", branch, err)
+	}
+	if dirty, err := IsDirty(ctx, root); err != nil || dirty {
+		t.Fatalf("IsDirty clean=(%t,%v)", dirty, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte("b\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if dirty, err := IsDirty(ctx, root); err != nil || !dirty {
+		t.Fatalf("IsDirty untracked=(%t,%v)", dirty, err)
+	}
+}
+
+func TestIsDirtyHonorsExcludesFile(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	ctx := context.Background()
+	root := t.TempDir()
+	if _, err := git.PlainInit(root, false); err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	excludes := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(excludes, []byte("*.log\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := runGit(ctx, root, "config", "core.excludesFile", excludes); err != nil {
+		t.Fatalf("git config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "debug.log"), []byte("x\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if dirty, err := IsDirty(ctx, root); err != nil || dirty {
+		t.Fatalf("IsDirty with excluded file=(%t,%v)", dirty, err)
+	}
+}
+
+func TestTrackedFilesIsRelativeToRoot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := t.TempDir()
+	if tracked, err := TrackedFiles(ctx, root); err == nil || tracked != nil {
+		t.Fatalf("TrackedFiles outside repo=(%v,%v)", tracked, err)
+	}
+
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	for _, rel := range []string{"a.txt", "sub/b.txt", "sub/.hidden", "sub/untracked.txt"} {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(rel+"\n"), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	for _, rel := range []string{"a.txt", "sub/b.txt", "sub/.hidden"} {
+		if _, err := wt.Add(rel); err != nil {
+			t.Fatalf("Add(%s): %v", rel, err)
+		}
+	}
+
+	tracked, err := TrackedFiles(ctx, root)
+	if err != nil {
+		t.Fatalf("TrackedFiles: %v", err)
+	}
+	if len(tracked) != 3 || !tracked["a.txt"] || !tracked["sub/b.txt"] || !tracked["sub/.hidden"] {
+		t.Fatalf("tracked=%v", tracked)
+	}
+
+	tracked, err = TrackedFiles(ctx, filepath.Join(root, "sub"))
+	if err != nil {
+		t.Fatalf("TrackedFiles(sub): %v", err)
+	}
+	if len(tracked) != 2 || !tracked["b.txt"] || !tracked[".hidden"] {
+		t.Fatalf("tracked(sub)=%v", tracked)
+	}
+}
+
+func TestChangedFilesSinceMergeBase(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	commit := func(msg string, files map[string]string, removed ...string) {
+		t.Helper()
+		for rel, data := range files {
+			p := filepath.Join(root, filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatalf("MkdirAll: %v", err)
+			}
+			if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if _, err := wt.Add(rel); err != nil {
+				t.Fatalf("Add(%s): %v", rel, err)
+			}
+		}
+		for _, rel := range removed {
+			if _, err := wt.Remove(rel); err != nil {
+				t.Fatalf("Remove(%s): %v", rel, err)
+			}
+		}
+		if _, err := wt.Commit(msg, &git.CommitOptions{
+			Author: &object.Signature{Name: "t", Email: "t@example.com", When: time.Unix(0, 0)},
+		}); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+	}
+
+	commit("base", map[string]string{"keep.go": "k\n", "edit.go": "e\n", "gone.go": "g\n", "sub/old.go": "o\n"})
+	base, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+	commit("change", map[string]string{"edit.go": "e2\n", "sub/new.go": "n\n"}, "gone.go")
+
+	changed, err := ChangedFiles(ctx, root, base.Hash().String())
+	if err != nil {
+		t.Fatalf("ChangedFiles: %v", err)
+	}
+	if len(changed) != 2 || !changed["edit.go"] || !changed["sub/new.go"] {
+		t.Fatalf("changed=%v", changed)
+	}
+
+	changed, err = ChangedFiles(ctx, filepath.Join(root, "sub"), "HEAD~1")
+	if err != nil {
+		t.Fatalf("ChangedFiles(sub): %v", err)
+	}
+	if len(changed) != 1 || !changed["new.go"] {
+		t.Fatalf("changed(sub)=%v", changed)
+	}
+
+	if _, err := ChangedFiles(ctx, root, "no-such-ref"); err == nil {
+		t.Fatalf("expected error for unknown ref")
+	}
+}
+
+func TestFileCommitsUsesLastTouchingCommit(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	ctx := context.Background()
+	root := t.TempDir()
+	if got, err := FileCommits(ctx, root, []string{"a.txt"}); err == nil {
+		t.Fatalf("FileCommits outside repo=%v", got)
+	}
+
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	commit := func(when int64, files ...string) string {
+		t.Helper()
+		for _, rel := range files {
+			p := filepath.Join(root, filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatalf("MkdirAll: %v", err)
+			}
+			if err := os.WriteFile(p, []byte(time.Unix(when, 0).String()), 0o644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if _, err := wt.Add(rel); err != nil {
+				t.Fatalf("Add(%s): %v", rel, err)
+			}
+		}
+		hash, err := wt.Commit("c", &git.CommitOptions{
+			Author: &object.Signature{Name: "t", Email: "t@example.com", When: time.Unix(when, 0).UTC()},
+		})
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		return hash.String()[:shortLen]
+	}
+	first := commit(1e9, "a.txt", "sub/b.txt")
+	second := commit(2e9, "a.txt")
+
+	got, err := FileCommits(ctx, root, []string{"a.txt", "sub/b.txt", "untracked.txt"})
+	if err != nil {
+		t.Fatalf("FileCommits: %v", err)
+	}
+	if len(got) != 2 || got["a.txt"].SHA != second || got["sub/b.txt"].SHA != first {
+		t.Fatalf("got=%+v want a.txt=%s sub/b.txt=%s", got, second, first)
+	}
+	if !got["a.txt"].AuthorDate.Equal(time.Unix(2e9, 0)) {
+		t.Fatalf("a.txt date=%v", got["a.txt"].AuthorDate)
+	}
+
+	got, err = FileCommits(ctx, filepath.Join(root, "sub"), []string{"b.txt"})
+	if err != nil || got["b.txt"].SHA != first {
+		t.Fatalf("FileCommits(sub)=(%+v,%v)", got, err)
+	}
+}
@@ -3,6 +3,7 @@ package initwizard
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -20,6 +21,10 @@ type Options struct {
 	NonInteractive bool
 	ProfileDefault string
 	ProjectType    string // optional hint: "go", "python", "node", etc.
+	// In and Out carry the prompts (default os.Stdin and os.Stderr). Unless NonInteractive
+	// is set, init prompts when In is given or stdin is a terminal.
+	In  io.Reader
+	Out io.Writer
 }
 
 // Run creates a .snip.yaml configuration in the root directory.
@@ -52,6 +57,11 @@ func Run(opts Options) (string, error) {
 	// Build slices tailored to the project
 	slices := buildSlices(project, paths)
 
+	// Let the user map top-level directories onto slices
+	if in, out, ok := prompter(opts); ok {
+		assignDirectories(in, out, paths, slices)
+	}
+
 	// Build profiles from available slices
 	profiles := buildProfiles(project, slices)
 
@@ -106,6 +116,9 @@ type ProjectInfo struct {
 	HasDocs    bool // evidence of docs
 	HasConfigs bool // config files present
 	HasInfra   bool // CI/CD or container files
+	// Ecosystems are the languages besides Kind whose signal file is at the root
+	// (package.json, pyproject.toml, Cargo.toml, ...), in ecosystems order.
+	Ecosystems []ProjectKind
 }
 
 func detectProject(root string, hint string) ProjectInfo {
@@ -143,29 +156,90 @@ func detectProject(root string, hint string) ProjectInfo {
 	info.HasDocs = hasDocsIndicator(root)
 	info.HasConfigs = hasConfigsIndicator(root)
 	info.HasInfra = hasInfraIndicator(root)
+	for _, eco := range ecosystems {
+		if eco.kind == info.Kind {
+			continue
+		}
+		for _, f := range eco.signals {
+			if fileExists(root, f) {
+				info.Ecosystems = append(info.Ecosystems, eco.kind)
+				break
+			}
+		}
+	}
 
 	return info
 }
 
+// ecosystem is a language that gets its own slice when its signal file exists and it is not
+// the primary Kind (which the "code" slice already covers), e.g. the frontend of a Go service.
+type ecosystem struct {
+	kind     ProjectKind
+	slice    string
+	signals  []string // any of these at the root
+	include  []string
+	tests    []string // added to the tests slice
+	priority int
+}
+
+var ecosystems = []ecosystem{
+	{
+		kind:     KindNode,
+		slice:    "node",
+		signals:  []string{"package.json"},
+		include:  []string{"src/**", "**/*.ts", "**/*.tsx", "**/*.js", "**/*.jsx", "!**/*.test.*", "!**/*.spec.*"},
+		tests:    []string{"**/*.test.*", "**/*.spec.*", "**/__tests__/**"},
+		priority: 70,
+	},
+	{
+		kind:     KindPython,
+		slice:    "python",
+		signals:  []string{"pyproject.toml", "requirements.txt"},
+		include:  []string{"**/*.py", "!**/test_*.py", "!**/*_test.py"},
+		tests:    []string{"**/*_test.py", "**/test_*.py"},
+		priority: 70,
+	},
+	{
+		kind:     KindRust,
+		slice:    "rust",
+		signals:  []string{"Cargo.toml"},
+		include:  []string{"**/*.rs", "Cargo.toml", "!tests/**"},
+		tests:    []string{"tests/**/*.rs"},
+		priority: 70,
+	},
+	{
+		kind:     KindJava,
+		slice:    "java",
+		signals:  []string{"pom.xml", "build.gradle"},
+		include:  []string{"src/main/**", "pom.xml", "build.gradle"},
+		tests:    []string{"src/test/**"},
+		priority: 70,
+	},
+}
+
 func detectLanguage(root string) ProjectKind {
-	markers := map[string]ProjectKind{
-		"go.mod":           KindGo,
-		"requirements.txt": KindPython,
-		"setup.py":         KindPython,
-		"pyproject.toml":   KindPython,
-		"Pipfile":          KindPython,
-		"package.json":     KindNode,
-		"yarn.lock":        KindNode,
-		"pnpm-lock.yaml":   KindNode,
-		"Cargo.toml":       KindRust,
-		"pom.xml":          KindJava,
-		"build.gradle":     KindJava,
-		"Gemfile":          KindRuby,
-		"composer.json":    KindPHP,
-	}
-	for file, kind := range markers {
-		if _, err := os.Stat(filepath.Join(root, file)); err == nil {
-			return kind
+	// Checked in order, so a repo with several markers always gets the same kind.
+	markers := []struct {
+		file string
+		kind ProjectKind
+	}{
+		{"go.mod", KindGo},
+		{"Cargo.toml", KindRust},
+		{"pom.xml", KindJava},
+		{"build.gradle", KindJava},
+		{"pyproject.toml", KindPython},
+		{"setup.py", KindPython},
+		{"requirements.txt", KindPython},
+		{"Pipfile", KindPython},
+		{"package.json", KindNode},
+		{"yarn.lock", KindNode},
+		{"pnpm-lock.yaml", KindNode},
+		{"Gemfile", KindRuby},
+		{"composer.json", KindPHP},
+	}
+	for _, m := range markers {
+		if _, err := os.Stat(filepath.Join(root, m.file)); err == nil {
+			return m.kind
 		}
 	}
 	// Check for .csproj or .sln
@@ -303,6 +377,27 @@ func buildSlices(proj ProjectInfo, paths []string) map[string]config.SliceConfig
 		}
 	}
 
+	// A slice per secondary ecosystem, and its tests
+	for _, eco := range ecosystems {
+		if !containsKind(proj.Ecosystems, eco.kind) {
+			continue
+		}
+		addUniversalSlice(slices, eco.slice, eco.include, eco.priority, paths)
+		if _, ok := slices[eco.slice]; !ok || countMatches(paths, eco.tests) == 0 {
+			continue
+		}
+		tests, ok := slices["tests"]
+		if !ok {
+			tests.Priority = 40
+		}
+		for _, pat := range eco.tests {
+			if !contains(tests.Include, pat) {
+				tests.Include = append(tests.Include, pat)
+			}
+		}
+		slices["tests"] = tests
+	}
+
 	// Universal slices (if they match files)
 	addUniversalSlice(slices, "docs", []string{"README*", "docs/**", "*.md"}, 20, paths)
 	addUniversalSlice(slices, "configs", []string{
@@ -401,6 +496,11 @@ func buildProfiles(proj ProjectInfo, slices map[string]config.SliceConfig) map[s
 	if _, ok := slices["code"]; ok {
 		defaultEnable = append(defaultEnable, "code")
 	}
+	for _, eco := range ecosystems {
+		if _, ok := slices[eco.slice]; ok {
+			defaultEnable = append(defaultEnable, eco.slice)
+		}
+	}
 	for _, s := range []string{"docs", "configs"} {
 		if _, ok := slices[s]; ok {
 			defaultEnable = append(defaultEnable, s)
@@ -450,6 +550,115 @@ func buildProfiles(proj ProjectInfo, slices map[string]config.SliceConfig) map[s
 	}
 }
 
+// ----------------------------------------------------------------------
+// Directory assignment
+// ----------------------------------------------------------------------
+
+// assignedSlicePriority is the priority of a slice created by assigning a directory to it:
+// below code, above tests.
+const assignedSlicePriority = 50
+
+// prompter returns where to read answers and write prompts, or false when init must not
+// prompt.
+func prompter(opts Options) (*bufio.Reader, io.Writer, bool) {
+	if opts.NonInteractive {
+		return nil, nil, false
+	}
+	in, out := opts.In, opts.Out
+	if in == nil {
+		if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+			return nil, nil, false
+		}
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stderr
+	}
+	return bufio.NewReader(in), out, true
+}
+
+type topDir struct {
+	name  string
+	files int
+}
+
+// topLevelDirs counts paths per top-level directory, in name order.
+func topLevelDirs(paths []string) []topDir {
+	var dirs []topDir
+	for _, p := range paths {
+		name, _, ok := strings.Cut(p, "/")
+		if !ok {
+			continue
+		}
+		if n := len(dirs); n > 0 && dirs[n-1].name == name {
+			dirs[n-1].files++
+			continue
+		}
+		dirs = append(dirs, topDir{name: name, files: 1})
+	}
+	return dirs
+}
+
+// assignDirectories lists the top-level directories and, if the user opts in, asks which
+// slice each belongs to. Naming a slice adds "<dir>/**" to its includes, creating it if
+// needed; Enter (or end of input) skips.
+func assignDirectories(in *bufio.Reader, out io.Writer, paths []string, slices map[string]config.SliceConfig) {
+	dirs := topLevelDirs(paths)
+	if len(dirs) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "\nTop-level directories:")
+	for _, d := range dirs {
+		fmt.Fprintf(out, "  %-20s %d files\n", d.name+"/", d.files)
+	}
+	fmt.Fprint(out, "Assign directories to slices? [y/N] ")
+	if ans, _ := readAnswer(in); !strings.EqualFold(ans, "y") && !strings.EqualFold(ans, "yes") {
+		return
+	}
+
+	names := make([]string, 0, len(slices))
+	for n := range slices {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(out, "Slices: %s. Type a slice name (a new name creates it) or press Enter to skip.\n", strings.Join(names, ", "))
+	for _, d := range dirs {
+		for {
+			fmt.Fprintf(out, "  %s/ (%d files) -> ", d.name, d.files)
+			ans, ok := readAnswer(in)
+			if !ok {
+				return
+			}
+			if ans == "" {
+				break
+			}
+			if _, err := selector.ParseModifiers([]string{"+" + ans}); err != nil {
+				fmt.Fprintf(out, "  invalid slice name %q\n", ans)
+				continue
+			}
+			pattern := d.name + "/**"
+			sl, exists := slices[ans]
+			if !exists {
+				sl.Priority = assignedSlicePriority
+			}
+			if !contains(sl.Include, pattern) {
+				sl.Include = append(sl.Include, pattern)
+			}
+			slices[ans] = sl
+			break
+		}
+	}
+}
+
+// readAnswer reads one trimmed line; ok is false at end of input with nothing read.
+func readAnswer(in *bufio.Reader) (string, bool) {
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
+
 // ----------------------------------------------------------------------
 // Interactive review
 // ----------------------------------------------------------------------
@@ -580,6 +789,15 @@ func dirExists(root, name string) bool {
 	return err == nil && info.IsDir()
 }
 
+func containsKind(kinds []ProjectKind, k ProjectKind) bool {
+	for _, v := range kinds {
+		if v == k {
+			return true
+		}
+	}
+	return false
+}
+
 func contains(slice []string, s string) bool {
 	for _, v := range slice {
 		if v == s {
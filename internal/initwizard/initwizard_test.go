@@ -0,0 +1,116 @@
+package initwizard
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmrzaf/snip/internal/config"
+)
+
+func writeTree(t *testing.T, root string, files ...string) {
+	t.Helper()
+	for _, rel := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll(%s): %v", rel, err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0o644); err != nil {
+			t.Fatalf("WriteFile(%s): %v", rel, err)
+		}
+	}
+}
+
+func TestRunAssignsDirectoriesToSlices(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, "go.mod", "main.go", "api/a.go", "docs/guide.md", "web/app.js", "web/app.css")
+
+	// api -> existing code slice, docs skipped, web -> invalid name then a new slice.
+	in := strings.NewReader("y\ncode\n\nWeb UI\nweb\n")
+	path, err := Run(Options{Root: root, In: in, Out: io.Discard})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !contains(cfg.Slices["code"].Include, "api/**") {
+		t.Fatalf("code include=%v", cfg.Slices["code"].Include)
+	}
+	if got := strings.Join(cfg.Slices["docs"].Include, ","); got != "README*,docs/**,*.md" {
+		t.Fatalf("skipped dir changed docs include=%s", got)
+	}
+	web, ok := cfg.Slices["web"]
+	if !ok || strings.Join(web.Include, ",") != "web/**" || web.Priority != assignedSlicePriority {
+		t.Fatalf("web slice=%+v ok=%t", web, ok)
+	}
+	if !contains(cfg.Profiles["full"].Enable, "web") {
+		t.Fatalf("full profile=%v", cfg.Profiles["full"].Enable)
+	}
+}
+
+func TestRunNonInteractiveSkipsPrompts(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, "go.mod", "main.go", "web/app.js")
+
+	path, err := Run(Options{Root: root, NonInteractive: true, In: strings.NewReader("y\nweb\n"), Out: io.Discard})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := cfg.Slices["web"]; ok {
+		t.Fatalf("--non-interactive created slice web: %v", cfg.Slices)
+	}
+}
+
+func TestRunAddsSlicesForSecondaryEcosystems(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root,
+		"go.mod", "main.go", "main_test.go",
+		"package.json", "src/app.ts", "src/app.test.ts",
+		"requirements.txt", "tools/gen.py", "tools/test_gen.py",
+	)
+
+	path, err := Run(Options{Root: root, NonInteractive: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !contains(cfg.Slices["code"].Include, "**/*.go") {
+		t.Fatalf("go should stay the primary code slice: %v", cfg.Slices["code"].Include)
+	}
+	for _, name := range []string{"node", "python"} {
+		if _, ok := cfg.Slices[name]; !ok {
+			t.Fatalf("missing %s slice: %v", name, cfg.Slices)
+		}
+		if !contains(cfg.Profiles["default"].Enable, name) {
+			t.Fatalf("default profile missing %s: %v", name, cfg.Profiles["default"].Enable)
+		}
+	}
+	for _, name := range []string{"rust", "java"} {
+		if _, ok := cfg.Slices[name]; ok {
+			t.Fatalf("%s slice without its signal file", name)
+		}
+	}
+	tests := cfg.Slices["tests"].Include
+	for _, pat := range []string{"**/*_test.go", "**/*.test.*", "**/test_*.py"} {
+		if !contains(tests, pat) {
+			t.Fatalf("tests include=%v missing %s", tests, pat)
+		}
+	}
+}
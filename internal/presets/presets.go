@@ -0,0 +1,116 @@
+// Package presets holds the built-in slice templates that a config can pull in with
+// `presets: [name]`.
+//
+// Each preset is a versioned YAML layer in this directory named <name>.v<N>.yaml. A published
+// version never changes; changing a preset means adding the next version, so configs that pin
+// one (name@vN) keep getting the same slices.
+package presets
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed *.yaml
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^([a-z0-9-]+)\.v([0-9]+)\.yaml$`)
+
+// Preset is one version of a built-in template.
+type Preset struct {
+	Name    string
+	Version int
+	// Layer is the preset's config mapping (currently only "slices"), merged under the
+	// user's config like an extends base.
+	Layer map[string]any
+}
+
+// ID is the preset's pinned reference, e.g. "go-service@v1".
+func (p Preset) ID() string {
+	return fmt.Sprintf("%s@v%d", p.Name, p.Version)
+}
+
+// Slices returns the names of the slices the preset defines, sorted.
+func (p Preset) Slices() []string {
+	m, _ := p.Layer["slices"].(map[string]any)
+	out := make([]string, 0, len(m))
+	for name := range m {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// List returns every version of every preset, by name then version.
+func List() []Preset {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		panic(err) // the embedded directory always exists
+	}
+	var out []Preset
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		v, _ := strconv.Atoi(m[2])
+		b, err := files.ReadFile(e.Name())
+		if err != nil {
+			panic(err)
+		}
+		var layer map[string]any
+		if err := yaml.Unmarshal(b, &layer); err != nil {
+			panic(fmt.Sprintf("preset %s: %v", e.Name(), err))
+		}
+		out = append(out, Preset{Name: m[1], Version: v, Layer: layer})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out
+}
+
+// Lookup resolves ref, either "name" (the latest version) or "name@vN".
+func Lookup(ref string) (Preset, error) {
+	name, want := ref, 0
+	if i := strings.LastIndex(ref, "@v"); i >= 0 {
+		v, err := strconv.Atoi(ref[i+2:])
+		if err != nil || v <= 0 {
+			return Preset{}, fmt.Errorf("preset %q: version must look like @v1", ref)
+		}
+		name, want = ref[:i], v
+	}
+	var found *Preset
+	var names []string
+	for _, p := range List() {
+		if len(names) == 0 || names[len(names)-1] != p.Name {
+			names = append(names, p.Name)
+		}
+		if p.Name != name || (want != 0 && p.Version != want) {
+			continue
+		}
+		p := p
+		found = &p // List is ordered by version, so the last match is the latest
+	}
+	if found == nil {
+		if want != 0 {
+			for _, n := range names {
+				if n == name {
+					return Preset{}, fmt.Errorf("preset %q has no version %d", name, want)
+				}
+			}
+		}
+		return Preset{}, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return *found, nil
+}
@@ -0,0 +1,49 @@
+package presets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupResolvesLatestAndPinnedVersions(t *testing.T) {
+	t.Parallel()
+
+	p, err := Lookup("go-service")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if p.ID() != "go-service@v1" || len(p.Slices()) == 0 {
+		t.Fatalf("preset=%s slices=%v", p.ID(), p.Slices())
+	}
+	if pinned, err := Lookup("go-service@v1"); err != nil || pinned.ID() != p.ID() {
+		t.Fatalf("Lookup(pinned)=%v, %v", pinned.ID(), err)
+	}
+
+	for ref, want := range map[string]string{
+		"rails":          `unknown preset "rails" (available: go-service, node-app)`,
+		"go-service@v99": `preset "go-service" has no version 99`,
+		"go-service@vx":  `version must look like @v1`,
+	} {
+		if _, err := Lookup(ref); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Lookup(%q) err=%v want %q", ref, err, want)
+		}
+	}
+}
+
+func TestPresetsOnlyDefineSlices(t *testing.T) {
+	t.Parallel()
+
+	for _, p := range List() {
+		for key := range p.Layer {
+			if key != "slices" {
+				t.Fatalf("%s sets %q; presets may only define slices", p.ID(), key)
+			}
+		}
+		for _, s := range p.Slices() {
+			sl, _ := p.Layer["slices"].(map[string]any)[s].(map[string]any)
+			if inc, _ := sl["include"].([]any); len(inc) == 0 {
+				t.Fatalf("%s slice %q has no include patterns", p.ID(), s)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/snip/internal/budget"
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// Prism.js assets linked by RenderHTML when Renderer.HTMLPrism is set.
+const (
+	prismCSS = "https://cdn.jsdelivr.net/npm/prismjs@1/themes/prism.min.css"
+	prismJS  = "https://cdn.jsdelivr.net/npm/prismjs@1/prism.min.js"
+	prismAll = "https://cdn.jsdelivr.net/npm/prismjs@1/plugins/autoloader/prism-autoloader.min.js"
+)
+
+const htmlStyle = `body{margin:0;font:14px/1.5 system-ui,sans-serif;color:#1f2328;display:flex}
+nav{flex:0 0 18rem;height:100vh;overflow:auto;position:sticky;top:0;border-right:1px solid #d0d7de;padding:1rem;box-sizing:border-box;background:#f6f8fa}
+nav ul{list-style:none;margin:0;padding-left:1rem}nav>ul{padding-left:0}nav a{text-decoration:none;color:#0969da}
+main{flex:1;min-width:0;padding:1rem 2rem}
+dl{display:grid;grid-template-columns:max-content 1fr;gap:0 1rem}dt{font-weight:600}dd{margin:0}
+details{border:1px solid #d0d7de;border-radius:6px;margin:.75rem 0}summary{cursor:pointer;padding:.5rem .75rem;background:#f6f8fa;font-family:ui-monospace,monospace}
+.meta{color:#57606a;margin-left:.5rem;font-size:12px}.warnings{color:#9a6700}
+pre{margin:0;padding:.75rem;overflow:auto;font:12px/1.45 ui-monospace,monospace}`
+
+// RenderHTML renders plan as a self-contained HTML page: the bundle header, an optional
+// tree sidebar linking to each file, the manifest, and one collapsible <details> section
+// per file with its content in <pre><code>. Everything taken from the repository is
+// HTML-escaped. Custom file_block delimiters do not apply.
+func (r Renderer) RenderHTML(info BundleInfo, plan budget.Plan) (string, error) {
+	nl := r.Newline
+	if nl == "" {
+		nl = "\n"
+	}
+	esc := html.EscapeString
+	files := OrderIncluded(plan.Included, r.Manifest.GroupBySlice, r.FileOrder)
+	ids := make(map[string]string, len(files))
+	for i, f := range files {
+		ids[f.RelPath] = fmt.Sprintf("f%d", i+1)
+	}
+
+	var buf bytes.Buffer
+	write := func(s string) { buf.WriteString(s); buf.WriteString(nl) }
+
+	write("<!DOCTYPE html>")
+	write(`<html lang="en">`)
+	write("<head>")
+	write(`<meta charset="utf-8">`)
+	write(fmt.Sprintf("<title>snip bundle: %s (%s)</title>", esc(info.Repo), esc(info.Profile)))
+	write("<style>" + strings.ReplaceAll(htmlStyle, "\n", nl) + "</style>")
+	if r.HTMLPrism {
+		write(fmt.Sprintf(`<link rel="stylesheet" href="%s">`, prismCSS))
+		write(fmt.Sprintf(`<script defer src="%s"></script>`, prismJS))
+		write(fmt.Sprintf(`<script defer src="%s"></script>`, prismAll))
+	}
+	write("</head>")
+	write("<body>")
+
+	if r.IncludeTree && len(files) > 0 {
+		write("<nav>")
+		tree := newTreeNode(".")
+		for _, f := range files {
+			tree.add(strings.Split(f.RelPath, "/"), 0, false)
+		}
+		tree.renderHTML(&buf, "", ids, nl)
+		write("</nav>")
+	}
+
+	write("<main>")
+	write("<h1>snip bundle</h1>")
+	truncated := 0
+	for _, f := range plan.Included {
+		if f.Truncated {
+			truncated++
+		}
+	}
+	write("<dl>")
+	for _, kv := range [][2]string{
+		{"repo", info.Repo},
+		{"root", info.Root},
+		{"profile", info.Profile},
+		{"enabled_slices", "[" + strings.Join(info.Enabled, ", ") + "]"},
+		{"git_sha", info.GitSHA},
+		{"git_dirty", DirtyLabel(info.GitDirty)},
+		{"timestamp", info.Timestamp.Format(time.RFC3339)},
+		{"snip_version", info.SnipVersion},
+		{"dropped_slices", fmt.Sprint(len(plan.DroppedSlices))},
+		{"dropped_files", fmt.Sprint(len(plan.Dropped))},
+		{"truncated_files", fmt.Sprint(truncated)},
+		{"partial", fmt.Sprint(plan.Partial)},
+	} {
+		write(fmt.Sprintf("<dt>%s</dt><dd>%s</dd>", kv[0], esc(kv[1])))
+	}
+	write("</dl>")
+
+	warnings := r.embeddedWarnings(plan)
+	if r.WarningsPosition != "bottom" {
+		writeHTMLWarnings(write, warnings)
+	}
+
+	if r.IncludeManifest {
+		write("<h2>Manifest (included)</h2>")
+		write("<pre>" + esc(renderManifestIncluded(files, r.Manifest, FileBlockOptions{}, nl)) + "</pre>")
+		write("<h2>Manifest (dropped)</h2>")
+		write("<pre>" + esc(renderManifestDropped(plan.Dropped, plan.DroppedSlices, files, info.Enabled, r.SlicePatterns, nl)) + "</pre>")
+	}
+
+	write("<h2>Files</h2>")
+	for i, f := range files {
+		if r.SkipEmptyFiles && f.Empty {
+			continue
+		}
+		meta := []string{
+			fmt.Sprintf("lines=%d", f.OriginalLines),
+			fmt.Sprintf("bytes=%d", f.OriginalBytes),
+			"slices=[" + strings.Join(f.Slices, ",") + "]",
+		}
+		if f.Truncated {
+			meta = append(meta, "truncated=true")
+			if len(f.Segments) > 0 {
+				meta = append(meta, "kept_lines="+segmentList(f.Segments))
+			}
+			if f.Excerpt {
+				meta = append(meta, "excerpt=true")
+			}
+		}
+		if f.Stripped {
+			meta = append(meta, "stripped=true")
+		}
+		if f.Redacted > 0 {
+			meta = append(meta, fmt.Sprintf("redacted=%d", f.Redacted))
+		}
+		if f.Empty {
+			meta = append(meta, "empty=true")
+		}
+		if f.Encoding != "" {
+			meta = append(meta, "encoding="+f.Encoding)
+		}
+		write(fmt.Sprintf(`<details id="%s" open>`, ids[f.RelPath]))
+		write(fmt.Sprintf(`<summary>%d) %s<span class="meta">%s</span></summary>`, i+1, esc(f.RelPath), esc(strings.Join(meta, " "))))
+
+		content := f.Content
+		if r.LineNumbers {
+			content = numberLines(f)
+		}
+		class := ""
+		if lang := util.LanguageFromPath(f.RelPath, r.Languages); lang != "" {
+			class = fmt.Sprintf(` class="language-%s"`, esc(lang))
+		}
+		content = strings.ReplaceAll(content, "\n", nl)
+		write(fmt.Sprintf("<pre><code%s>%s</code></pre>", class, esc(content)))
+		write("</details>")
+	}
+
+	if r.WarningsPosition == "bottom" {
+		writeHTMLWarnings(write, warnings)
+	}
+	write("</main>")
+	write("</body>")
+	write("</html>")
+	return buf.String(), nil
+}
+
+func writeHTMLWarnings(write func(string), warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	write("<h2>Warnings</h2>")
+	write(`<ul class="warnings">`)
+	for _, w := range warnings {
+		write("<li>" + html.EscapeString(w) + "</li>")
+	}
+	write("</ul>")
+}
+
+// renderHTML writes the children of n as a nested list, directories first. Files link to
+// their section through ids, keyed by path.
+func (n *treeNode) renderHTML(buf *bytes.Buffer, dir string, ids map[string]string, nl string) {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := n.children[names[i]], n.children[names[j]]
+		if a.isFile != b.isFile {
+			return !a.isFile && b.isFile
+		}
+		return names[i] < names[j]
+	})
+
+	buf.WriteString("<ul>" + nl)
+	for _, name := range names {
+		child := n.children[name]
+		path := name
+		if dir != "" {
+			path = dir + "/" + name
+		}
+		if child.isFile {
+			fmt.Fprintf(buf, `<li><a href="#%s">%s</a></li>%s`, ids[path], html.EscapeString(name), nl)
+			continue
+		}
+		buf.WriteString("<li>" + html.EscapeString(name) + "/" + nl)
+		child.renderHTML(buf, path, ids, nl)
+		buf.WriteString("</li>" + nl)
+	}
+	buf.WriteString("</ul>" + nl)
+}
@@ -2,12 +2,14 @@ package render
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"path/filepath"
 	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
+	"unicode"
 
 	"github.com/mmrzaf/snip/internal/budget"
 	"github.com/mmrzaf/snip/internal/util"
@@ -20,6 +22,7 @@ type BundleInfo struct {
 	Profile     string
 	Enabled     []string
 	GitSHA      string
+	GitDirty    *bool // nil when git is unavailable
 	Timestamp   time.Time
 	SnipVersion string
 }
@@ -32,15 +35,40 @@ type FileBlockOptions struct {
 
 // Renderer renders bundles.
 type Renderer struct {
-	Newline         string
-	CodeFences      bool
-	IncludeTree     bool
-	TreeDepth       int
-	TreePaths       []string
+	Newline     string
+	CodeFences  bool
+	IncludeTree bool
+	TreeDepth   int
+	TreePaths   []string
+	// TreeMaxNodes caps the tree's lines below the root by folding directories (0: no cap).
+	TreeMaxNodes int
+	// TreeSizes appends a human-readable size to each tree file and the total to each directory.
+	TreeSizes bool
+	// TreePathBytes sizes TreePaths entries; included files use their FileEntry.OriginalBytes.
+	TreePathBytes   map[string]int64
 	SlicePatterns   map[string]SlicePatterns
 	IncludeManifest bool
 	Manifest        ManifestOptions
 	FileBlock       FileBlockOptions
+	// LineNumbers prefixes fenced content lines with right-aligned line numbers.
+	LineNumbers bool
+	// EmbedWarnings renders a "## Warnings" section listing why the bundle is incomplete.
+	EmbedWarnings bool
+	// WarningsPosition is "top" (default) or "bottom".
+	WarningsPosition string
+	// IncludeTOC renders a "## Contents" section linking to each "## N) path" heading.
+	// It has no effect with custom FileBlock delimiters, which replace those headings.
+	IncludeTOC bool
+	// Languages maps file extensions to code fence languages over the built-in set
+	// (see util.LanguageFromPath).
+	Languages map[string]string
+	// SkipEmptyFiles leaves empty files out of the content section (and the TOC); they are
+	// still listed in the manifest, marked empty=true.
+	SkipEmptyFiles bool
+	// HTMLPrism makes RenderHTML link Prism.js from a CDN to highlight code blocks.
+	HTMLPrism bool
+	// FileOrder orders files within slice groups; see OrderIncluded.
+	FileOrder string
 }
 
 // SlicePatterns describes slice include/exclude patterns for diagnostics.
@@ -56,6 +84,18 @@ type ManifestOptions struct {
 	IncludeByteCounts      bool
 	IncludeTruncationNotes bool
 	IncludeUnreadableNotes bool
+	// Commits annotates files with their last commit (render.manifest.include_git_info);
+	// files missing from the map have no annotation.
+	Commits map[string]FileCommit
+	// IncludeHashes adds each file's FileEntry.SHA256 to its manifest line (and NDJSON record)
+	// and makes RenderMarkdown seal the bundle with a bundle_sha256 line (see SealMarkdown).
+	IncludeHashes bool
+}
+
+// FileCommit is a file's most recent commit.
+type FileCommit struct {
+	SHA        string
+	AuthorDate time.Time
 }
 
 // RenderMarkdown renders plan as a markdown bundle.
@@ -65,7 +105,7 @@ func (r Renderer) RenderMarkdown(info BundleInfo, plan budget.Plan) (string, err
 		nl = "\n"
 	}
 
-	files := orderIncluded(plan.Included, r.Manifest.GroupBySlice)
+	files := OrderIncluded(plan.Included, r.Manifest.GroupBySlice, r.FileOrder)
 
 	var buf bytes.Buffer
 	write := func(s string) { buf.WriteString(s); buf.WriteString(nl) }
@@ -77,8 +117,24 @@ func (r Renderer) RenderMarkdown(info BundleInfo, plan budget.Plan) (string, err
 	write(fmt.Sprintf("profile: %s", info.Profile))
 	write(fmt.Sprintf("enabled_slices: [%s]", strings.Join(info.Enabled, ", ")))
 	write(fmt.Sprintf("git_sha: %s", info.GitSHA))
+	write("git_dirty: " + DirtyLabel(info.GitDirty))
 	write(fmt.Sprintf("timestamp: %s", info.Timestamp.Format(time.RFC3339)))
 	write(fmt.Sprintf("snip_version: %s", info.SnipVersion))
+	truncated := 0
+	for _, f := range plan.Included {
+		if f.Truncated {
+			truncated++
+		}
+	}
+	write(fmt.Sprintf("dropped_slices: %d", len(plan.DroppedSlices)))
+	write(fmt.Sprintf("dropped_files: %d", len(plan.Dropped)))
+	write(fmt.Sprintf("truncated_files: %d", truncated))
+	write(fmt.Sprintf("partial: %t", plan.Partial))
+
+	warnings := r.embeddedWarnings(plan)
+	if r.WarningsPosition != "bottom" {
+		writeWarnings(&buf, warnings, nl)
+	}
 
 	if r.IncludeTree {
 		treePaths := append([]string(nil), r.TreePaths...)
@@ -92,7 +148,17 @@ func (r Renderer) RenderMarkdown(info BundleInfo, plan budget.Plan) (string, err
 		write("")
 		buf.WriteString("```")
 		buf.WriteString(nl)
-		for _, line := range buildTree(treePaths, r.TreeDepth) {
+		var sizes map[string]int64
+		if r.TreeSizes {
+			sizes = make(map[string]int64, len(treePaths))
+			for p, n := range r.TreePathBytes {
+				sizes[p] = n
+			}
+			for _, f := range files {
+				sizes[f.RelPath] = f.OriginalBytes
+			}
+		}
+		for _, line := range buildTree(treePaths, sizes, r.TreeDepth, r.TreeMaxNodes) {
 			buf.WriteString(line)
 			buf.WriteString(nl)
 		}
@@ -111,14 +177,45 @@ func (r Renderer) RenderMarkdown(info BundleInfo, plan budget.Plan) (string, err
 		buf.WriteString(renderManifestDropped(plan.Dropped, plan.DroppedSlices, files, info.Enabled, r.SlicePatterns, nl))
 	}
 
+	customDelims := r.FileBlock.Header != "" || r.FileBlock.Footer != ""
+	if r.IncludeTOC && !customDelims && len(files) > 0 {
+		write("")
+		write("## Contents")
+		write("")
+		for i, a := range tocAnchors(files) {
+			if r.SkipEmptyFiles && files[i].Empty {
+				continue
+			}
+			write(fmt.Sprintf("- [%s](#%s)", files[i].RelPath, a))
+		}
+	}
+
 	// Content.
+	r.writeFileBlocks(&buf, files, 1, nl)
+
+	if r.WarningsPosition == "bottom" {
+		writeWarnings(&buf, warnings, nl)
+	}
+
+	if r.Manifest.IncludeHashes {
+		return SealMarkdown(buf.String(), nl), nil
+	}
+	return buf.String(), nil
+}
+
+// writeFileBlocks writes the content section: one block per file, numbered from first.
+func (r Renderer) writeFileBlocks(buf *bytes.Buffer, files []budget.FileEntry, first int, nl string) {
+	write := func(s string) { buf.WriteString(s); buf.WriteString(nl) }
 	customDelims := r.FileBlock.Header != "" || r.FileBlock.Footer != ""
 	for i, f := range files {
-		idx := i + 1
+		if r.SkipEmptyFiles && f.Empty {
+			continue
+		}
+		idx := first + i
 		write("")
 
 		if customDelims {
-			h := applyFileBlockToken(r.FileBlock.Header, f.RelPath)
+			h := applyFileBlockToken(r.FileBlock.Header, idx, f)
 			if h != "" {
 				write(h)
 			}
@@ -126,6 +223,24 @@ func (r Renderer) RenderMarkdown(info BundleInfo, plan budget.Plan) (string, err
 			write(fmt.Sprintf("bytes: %d", f.OriginalBytes))
 			write(fmt.Sprintf("slices: [%s]", strings.Join(f.Slices, ", ")))
 			write(fmt.Sprintf("truncated: %t", f.Truncated))
+			if f.Truncated && len(f.Segments) > 0 {
+				write("kept_lines: " + segmentList(f.Segments))
+			}
+			if f.Excerpt {
+				write("excerpt: true")
+			}
+			if f.Stripped {
+				write("stripped: true")
+			}
+			if f.Redacted > 0 {
+				write(fmt.Sprintf("redacted: %d", f.Redacted))
+			}
+			if f.Empty {
+				write("empty: true")
+			}
+			if f.Encoding != "" {
+				write("encoding: " + f.Encoding)
+			}
 			write("")
 		} else {
 			write("---")
@@ -135,23 +250,41 @@ func (r Renderer) RenderMarkdown(info BundleInfo, plan budget.Plan) (string, err
 			write(fmt.Sprintf("bytes: %d", f.OriginalBytes))
 			write(fmt.Sprintf("slices: [%s]", strings.Join(f.Slices, ", ")))
 			write(fmt.Sprintf("truncated: %t", f.Truncated))
+			if f.Truncated && len(f.Segments) > 0 {
+				write("kept_lines: " + segmentList(f.Segments))
+			}
+			if f.Excerpt {
+				write("excerpt: true")
+			}
+			if f.Stripped {
+				write("stripped: true")
+			}
+			if f.Redacted > 0 {
+				write(fmt.Sprintf("redacted: %d", f.Redacted))
+			}
+			if f.Empty {
+				write("empty: true")
+			}
+			if f.Encoding != "" {
+				write("encoding: " + f.Encoding)
+			}
 			write("")
 		}
 
 		if r.CodeFences {
-			lang := util.LanguageFromPath(f.RelPath)
-			if lang != "" {
-				buf.WriteString("```" + lang)
-			} else {
-				buf.WriteString("```")
+			content := f.Content
+			if r.LineNumbers {
+				content = numberLines(f)
 			}
+			fence := fenceFor(content)
+			buf.WriteString(fence + util.LanguageFromPath(f.RelPath, r.Languages))
 			buf.WriteString(nl)
-			content := strings.ReplaceAll(f.Content, "\n", nl)
+			content = strings.ReplaceAll(content, "\n", nl)
 			buf.WriteString(content)
 			if !strings.HasSuffix(content, nl) {
 				buf.WriteString(nl)
 			}
-			buf.WriteString("```")
+			buf.WriteString(fence)
 			buf.WriteString(nl)
 		} else {
 			content := strings.ReplaceAll(f.Content, "\n", nl)
@@ -162,27 +295,278 @@ func (r Renderer) RenderMarkdown(info BundleInfo, plan budget.Plan) (string, err
 		}
 
 		if customDelims {
-			foot := applyFileBlockToken(r.FileBlock.Footer, f.RelPath)
+			foot := applyFileBlockToken(r.FileBlock.Footer, idx, f)
 			if foot != "" {
 				write(foot)
 			}
 		}
 	}
+}
 
+// RenderContinuation renders only the file blocks of plan, numbered from first, to append to
+// an existing markdown bundle (run --append): no header, tree, manifest, TOC or warnings.
+func (r Renderer) RenderContinuation(plan budget.Plan, first int) (string, error) {
+	nl := r.Newline
+	if nl == "" {
+		nl = "\n"
+	}
+	var buf bytes.Buffer
+	r.writeFileBlocks(&buf, OrderIncluded(plan.Included, r.Manifest.GroupBySlice, r.FileOrder), first, nl)
 	return buf.String(), nil
 }
 
-func applyFileBlockToken(s string, path string) string {
+// BundleChecksumPrefix starts the last line of a sealed markdown bundle.
+const BundleChecksumPrefix = "bundle_sha256: "
+
+// SealMarkdown appends a blank line and a "bundle_sha256: <hex>" line holding the SHA-256 of
+// everything before that line, so readers can verify a bundle by hashing all but its last line.
+func SealMarkdown(body, nl string) string {
+	if nl == "" {
+		nl = "\n"
+	}
+	if !strings.HasSuffix(body, nl) {
+		body += nl
+	}
+	body += nl
+	sum := sha256.Sum256([]byte(body))
+	return body + BundleChecksumPrefix + hex.EncodeToString(sum[:]) + nl
+}
+
+// SealReserve returns text as long as the most SealMarkdown can add to a body, with the
+// same token estimate, so a budget can leave room for the seal before it is computed.
+func SealReserve(nl string) string {
+	if nl == "" {
+		nl = "\n"
+	}
+	return nl + nl + BundleChecksumPrefix + strings.Repeat("0", hex.EncodedLen(sha256.Size)) + nl
+}
+
+// UnsealMarkdown returns bundle without the blank line and bundle_sha256 line SealMarkdown
+// added, and whether it was sealed.
+func UnsealMarkdown(bundle string) (string, bool) {
+	trimmed := strings.TrimRight(bundle, "\r\n")
+	i := strings.LastIndex(trimmed, "\n")
+	if !strings.HasPrefix(trimmed[i+1:], BundleChecksumPrefix) {
+		return bundle, false
+	}
+	body := strings.TrimSuffix(trimmed[:i+1], "\n")
+	return strings.TrimSuffix(body, "\r"), true
+}
+
+// tocAnchors returns the GitHub-style anchor of each file's "## N) path" heading. A slug that
+// repeats an earlier one gets the file's index appended, so every link is unique.
+func tocAnchors(files []budget.FileEntry) []string {
+	out := make([]string, len(files))
+	seen := make(map[string]bool, len(files))
+	for i, f := range files {
+		a := headingSlug(fmt.Sprintf("%d) %s", i+1, f.RelPath))
+		if seen[a] {
+			a = fmt.Sprintf("%s-%d", a, i+1)
+		}
+		seen[a] = true
+		out[i] = a
+	}
+	return out
+}
+
+// headingSlug lowercases heading, drops punctuation other than '-' and '_', and turns spaces
+// into hyphens, as GitHub does for heading anchors.
+func headingSlug(heading string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ':
+			sb.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// DirtyLabel formats a git dirty state (BundleInfo.GitDirty) as true, false, or unknown.
+func DirtyLabel(dirty *bool) string {
+	if dirty == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("%t", *dirty)
+}
+
+func (r Renderer) embeddedWarnings(plan budget.Plan) []string {
+	if !r.EmbedWarnings {
+		return nil
+	}
+	return Warnings(plan)
+}
+
+// Warnings lists human-readable reasons why plan is incomplete, in deterministic order.
+func Warnings(plan budget.Plan) []string {
+	var out []string
+	for _, s := range plan.DroppedSlices {
+		out = append(out, fmt.Sprintf("slice dropped due to budget: %s", s))
+	}
+	for _, d := range plan.Dropped {
+		switch d.Reason {
+		case "unreadable":
+			out = append(out, fmt.Sprintf("unreadable file excluded: %s", d.RelPath))
+		case "invalid_utf8":
+			out = append(out, fmt.Sprintf("invalid UTF-8 file excluded: %s", d.RelPath))
+		case "slice_budget_exceeded":
+			out = append(out, fmt.Sprintf("file dropped due to slice budget: %s", d.RelPath))
+		case "whole_file_too_large":
+			out = append(out, fmt.Sprintf("file dropped instead of truncated (whole_files_only): %s", d.RelPath))
+		case "budget_exceeded":
+			if d.Detail != budget.DetailSliceDropped {
+				out = append(out, fmt.Sprintf("file dropped due to budget (%s): %s", d.Detail, d.RelPath))
+			}
+		}
+	}
+	if plan.HardCut {
+		out = append(out, "bundle hard-cut to fit max_chars; trailing content is missing")
+	}
+	return out
+}
+
+func writeWarnings(buf *bytes.Buffer, warnings []string, nl string) {
+	if len(warnings) == 0 {
+		return
+	}
+	buf.WriteString(nl)
+	buf.WriteString("## Warnings")
+	buf.WriteString(nl)
+	buf.WriteString(nl)
+	for _, w := range warnings {
+		buf.WriteString("- " + w)
+		buf.WriteString(nl)
+	}
+}
+
+// numberLines prefixes each kept content line with its original line number. Kept lines are a
+// head of the file, so positions 1..KeptLines match the original; the truncation marker is left as is.
+func numberLines(f budget.FileEntry) string {
+	lines := strings.SplitAfter(f.Content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(f.Segments) > 0 {
+		return numberSegments(lines, f.Segments)
+	}
+	n := len(lines)
+	if f.Truncated && n > 0 {
+		n-- // marker line
+	}
+	if f.KeptLines > 0 && f.KeptLines < n {
+		n = f.KeptLines
+	}
+	width := len(fmt.Sprint(n))
+	var sb strings.Builder
+	for i, line := range lines {
+		if i < n {
+			prefix := fmt.Sprintf("%*d |", width, i+1)
+			if line != "\n" && line != "" {
+				prefix += " "
+			}
+			sb.WriteString(prefix)
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// numberSegments numbers kept lines with their original line numbers. Lines beyond a
+// segment's length are truncation markers and stay unnumbered.
+func numberSegments(lines []string, segs []budget.LineRange) string {
+	width := len(fmt.Sprint(segs[len(segs)-1].End))
+	var sb strings.Builder
+	seg, n := 0, segs[0].Start
+	for _, line := range lines {
+		if seg < len(segs) && n > segs[seg].End {
+			// Marker between segments; the next line starts the next segment.
+			sb.WriteString(line)
+			seg++
+			if seg < len(segs) {
+				n = segs[seg].Start
+			}
+			continue
+		}
+		if seg >= len(segs) {
+			sb.WriteString(line)
+			continue
+		}
+		prefix := fmt.Sprintf("%*d |", width, n)
+		if line != "\n" && line != "" {
+			prefix += " "
+		}
+		sb.WriteString(prefix)
+		sb.WriteString(line)
+		n++
+	}
+	return sb.String()
+}
+
+// fenceFor returns a backtick fence one longer than the longest backtick run in
+// content (minimum three), so embedded fences cannot close the block early.
+func fenceFor(content string) string {
+	longest, run := 0, 0
+	for i := 0; i < len(content); i++ {
+		if content[i] != '`' {
+			run = 0
+			continue
+		}
+		run++
+		if run > longest {
+			longest = run
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// segmentList formats kept ranges as "1-40,91-110".
+func segmentList(segs []budget.LineRange) string {
+	var parts []string
+	for _, s := range segs {
+		if s.End >= s.Start {
+			parts = append(parts, fmt.Sprintf("%d-%d", s.Start, s.End))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// applyFileBlockToken fills a file_block header or footer for the idx-th file (1-based, bundle
+// order): {path}, {lines} and {bytes} (original counts), {slice} (primary slice) and {index}.
+func applyFileBlockToken(s string, idx int, f budget.FileEntry) string {
 	if s == "" {
 		return ""
 	}
-	return strings.ReplaceAll(s, "{path}", path)
+	return strings.NewReplacer(
+		"{path}", f.RelPath,
+		"{lines}", fmt.Sprint(f.OriginalLines),
+		"{bytes}", fmt.Sprint(f.OriginalBytes),
+		"{slice}", f.PrimarySlice,
+		"{index}", fmt.Sprint(idx),
+	).Replace(s)
 }
 
-func orderIncluded(files []budget.FileEntry, groupBySlice bool) []budget.FileEntry {
+// File orders within a slice group (render.file_order).
+const (
+	// FileOrderPath sorts by path.
+	FileOrderPath = "path"
+	// FileOrderSlicePriority sorts by primary slice priority (desc), then slice name and path.
+	FileOrderSlicePriority = "slice_priority"
+	// FileOrderIncludeOrder is FileOrderSlicePriority, then the index of the primary slice's
+	// include pattern that matched the file, then path.
+	FileOrderIncludeOrder = "include_order"
+)
+
+// OrderIncluded returns files in bundle order: grouped by primary slice (priority desc, then
+// name) when groupBySlice is set, and sorted by order (a FileOrder* value; "" is
+// FileOrderPath) within the groups or, without grouping, overall. All renderers share this
+// ordering.
+func OrderIncluded(files []budget.FileEntry, groupBySlice bool, order string) []budget.FileEntry {
 	out := append([]budget.FileEntry(nil), files...)
+	less := fileLess(order)
 	if !groupBySlice {
-		sort.Slice(out, func(i, j int) bool { return out[i].RelPath < out[j].RelPath })
+		sort.Slice(out, func(i, j int) bool { return less(out[i], out[j]) })
 		return out
 	}
 	// Group by primary slice; sort slice groups by priority desc then name.
@@ -210,12 +594,31 @@ func orderIncluded(files []budget.FileEntry, groupBySlice bool) []budget.FileEnt
 	ordered := make([]budget.FileEntry, 0, len(out))
 	for _, s := range slices {
 		g := groups[s]
-		sort.Slice(g, func(i, j int) bool { return g[i].RelPath < g[j].RelPath })
+		sort.Slice(g, func(i, j int) bool { return less(g[i], g[j]) })
 		ordered = append(ordered, g...)
 	}
 	return ordered
 }
 
+func fileLess(order string) func(a, b budget.FileEntry) bool {
+	byPath := func(a, b budget.FileEntry) bool { return a.RelPath < b.RelPath }
+	if order != FileOrderSlicePriority && order != FileOrderIncludeOrder {
+		return byPath
+	}
+	return func(a, b budget.FileEntry) bool {
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.PrimarySlice != b.PrimarySlice {
+			return a.PrimarySlice < b.PrimarySlice
+		}
+		if order == FileOrderIncludeOrder && a.IncludeIndex != b.IncludeIndex {
+			return a.IncludeIndex < b.IncludeIndex
+		}
+		return byPath(a, b)
+	}
+}
+
 func renderManifestIncluded(files []budget.FileEntry, opt ManifestOptions, fb FileBlockOptions, nl string) string {
 	var buf bytes.Buffer
 
@@ -270,6 +673,27 @@ func writeManifestLine(w *tabwriter.Writer, idx int, f budget.FileEntry, opt Man
 	if opt.IncludeTruncationNotes {
 		parts = append(parts, fmt.Sprintf("truncated=%t", f.Truncated))
 	}
+	if f.Excerpt {
+		parts = append(parts, "excerpt=true")
+	}
+	if f.Stripped {
+		parts = append(parts, "stripped=true")
+	}
+	if f.Redacted > 0 {
+		parts = append(parts, fmt.Sprintf("redacted=%d", f.Redacted))
+	}
+	if f.Empty {
+		parts = append(parts, "empty=true")
+	}
+	if f.Encoding != "" {
+		parts = append(parts, "encoding="+f.Encoding)
+	}
+	if c, ok := opt.Commits[f.RelPath]; ok {
+		parts = append(parts, fmt.Sprintf("commit=%s date=%s", c.SHA, c.AuthorDate.Format(time.DateOnly)))
+	}
+	if opt.IncludeHashes && f.SHA256 != "" {
+		parts = append(parts, "sha256="+f.SHA256)
+	}
 	_, _ = fmt.Fprintf(w, "%3d\t%s\t%s\n", idx, f.RelPath, strings.Join(parts, " "))
 }
 
@@ -370,17 +794,14 @@ func sanitizeDetail(s string) string {
 	return s
 }
 
-func buildTree(paths []string, depth int) []string {
+// buildTree renders paths as a tree up to depth levels, fitted to maxNodes entries (0 for no
+// cap; see treeNode.fit). With non-nil sizes, files found in sizes show their size and
+// directories the total of the sized files below them.
+func buildTree(paths []string, sizes map[string]int64, depth, maxNodes int) []string {
 	if depth <= 0 {
 		depth = 1
 	}
-	sort.Strings(paths)
-
-	tree := newTreeNode(".")
-	for _, p := range paths {
-		parts := strings.Split(filepath.ToSlash(p), "/")
-		tree.add(parts)
-	}
+	tree, _ := newFittedTree(paths, sizes, depth, maxNodes)
 	var out []string
 	tree.render(&out, "", true, depth, 0)
 	return out
@@ -390,13 +811,20 @@ type treeNode struct {
 	name     string
 	children map[string]*treeNode
 	isFile   bool
+	size     int64 // file size, or the sum of sized files below a directory
+	sized    bool  // size is known
+	// collapsed renders a directory as one "dir (N files)" line; cut keeps only the first keep
+	// children and summarizes the rest (set by fit).
+	collapsed bool
+	cut       bool
+	keep      int
 }
 
 func newTreeNode(name string) *treeNode {
 	return &treeNode{name: name, children: map[string]*treeNode{}}
 }
 
-func (n *treeNode) add(parts []string) {
+func (n *treeNode) add(parts []string, size int64, sized bool) {
 	cur := n
 	for i, p := range parts {
 		child, ok := cur.children[p]
@@ -407,10 +835,45 @@ func (n *treeNode) add(parts []string) {
 		if i == len(parts)-1 {
 			child.isFile = true
 		}
+		if sized {
+			child.size += size
+			child.sized = true
+		}
 		cur = child
 	}
 }
 
+// label is the node's name, followed by its size when known.
+func (n *treeNode) label() string {
+	if !n.sized {
+		return n.name
+	}
+	return fmt.Sprintf("%s (%s)", n.name, humanSize(n.size))
+}
+
+// humanSize formats n bytes with binary multiples, e.g. "512 B" or "12.3 KB".
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	v, i := float64(n)/unit, 0
+	for v >= unit && i < 3 {
+		v /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", v, []string{"KB", "MB", "GB", "TB"}[i])
+}
+
+// descendants counts every file and directory below n.
+func (n *treeNode) descendants() int {
+	total := 0
+	for _, c := range n.children {
+		total += 1 + c.descendants()
+	}
+	return total
+}
+
 func (n *treeNode) render(out *[]string, prefix string, isLast bool, maxDepth int, depth int) {
 	if depth == 0 {
 		*out = append(*out, n.name)
@@ -421,10 +884,25 @@ func (n *treeNode) render(out *[]string, prefix string, isLast bool, maxDepth in
 			branch = "└── "
 			nextPrefix = prefix + "    "
 		}
-		*out = append(*out, prefix+branch+n.name)
+		if n.collapsed {
+			count := fmt.Sprintf("%d files", n.files())
+			if count == "1 files" {
+				count = "1 file"
+			}
+			if n.sized {
+				count = humanSize(n.size) + ", " + count
+			}
+			label := fmt.Sprintf("%s (%s)", n.name, count)
+			*out = append(*out, prefix+branch+label)
+			return
+		}
+		*out = append(*out, prefix+branch+n.label())
 		prefix = nextPrefix
 	}
 	if depth >= maxDepth {
+		if len(n.children) > 0 {
+			*out = append(*out, fmt.Sprintf("%s└── (%d more files/dirs)", prefix, n.descendants()))
+		}
 		return
 	}
 
@@ -441,8 +919,18 @@ func (n *treeNode) render(out *[]string, prefix string, isLast bool, maxDepth in
 		return names[i] < names[j]
 	})
 
+	var rest int
+	if n.cut && len(names) > n.keep {
+		for _, name := range names[n.keep:] {
+			rest += 1 + n.children[name].descendants()
+		}
+		names = names[:n.keep]
+	}
 	for i, name := range names {
 		child := n.children[name]
-		child.render(out, prefix, i == len(names)-1, maxDepth, depth+1)
+		child.render(out, prefix, i == len(names)-1 && rest == 0, maxDepth, depth+1)
+	}
+	if rest > 0 {
+		*out = append(*out, fmt.Sprintf("%s└── (%d more files/dirs)", prefix, rest))
 	}
 }
@@ -0,0 +1,561 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmrzaf/snip/internal/budget"
+	"github.com/mmrzaf/snip/internal/tools/apply"
+)
+
+func TestRenderMarkdownLineNumbers(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Profile:       "p",
+		EnabledSlices: []string{"api"},
+		Included: []budget.FileEntry{{
+			RelPath:       "a.go",
+			Slices:        []string{"api"},
+			PrimarySlice:  "api",
+			OriginalLines: 12,
+			KeptLines:     10,
+			Truncated:     true,
+			Content:       "l1\nl2\n\nl4\nl5\nl6\nl7\nl8\nl9\nl10\n… [TRUNCATED: original_lines=12 kept_lines=10]\n",
+		}},
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Enabled: []string{"api"}, Timestamp: time.Unix(0, 0)}
+
+	r := Renderer{Newline: "\n", CodeFences: true, LineNumbers: true}
+	out, err := r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	for _, want := range []string{
+		"```go\n 1 | l1\n 2 | l2\n 3 |\n 4 | l4\n",
+		"10 | l10\n… [TRUNCATED: original_lines=12 kept_lines=10]\n```",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	// Off by default: content is unchanged.
+	r.LineNumbers = false
+	out, err = r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	if !strings.Contains(out, "```go\nl1\nl2\n") {
+		t.Fatalf("unexpected unnumbered output:\n%s", out)
+	}
+}
+
+func TestRenderMarkdownLineNumbersFollowSegments(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{{
+			RelPath:       "a.go",
+			Slices:        []string{"api"},
+			PrimarySlice:  "api",
+			OriginalLines: 120,
+			KeptLines:     3,
+			Truncated:     true,
+			Segments:      []budget.LineRange{{Start: 1, End: 2}, {Start: 120, End: 120}},
+			Content:       "l1\nl2\n… [MIDDLE TRUNCATED: original_lines=120 kept_lines=3 omitted=3-119]\nl120\n",
+		}},
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Timestamp: time.Unix(0, 0)}
+
+	r := Renderer{Newline: "\n", CodeFences: true, LineNumbers: true, FileBlock: FileBlockOptions{Header: "<<<FILE:{path}>>>"}}
+	out, err := r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	for _, want := range []string{
+		"kept_lines: 1-2,120-120\n",
+		"  1 | l1\n  2 | l2\n… [MIDDLE TRUNCATED: original_lines=120 kept_lines=3 omitted=3-119]\n120 | l120\n```",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderMarkdownFenceOutgrowsNestedFences(t *testing.T) {
+	t.Parallel()
+
+	readme := "# Title\n\n```go\nfmt.Println(\"hi\")\n```\n\n````\nnested\n````\n"
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "README.md", Slices: []string{"docs"}, PrimarySlice: "docs", Content: readme},
+			{RelPath: "a.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package a\n"},
+		},
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Timestamp: time.Unix(0, 0)}
+
+	r := Renderer{Newline: "\n", CodeFences: true, FileBlock: FileBlockOptions{Header: "<<<FILE:{path}>>>"}}
+	out, err := r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	if !strings.Contains(out, "`````md\n"+readme+"`````\n") {
+		t.Fatalf("expected five-backtick fence in:\n%s", out)
+	}
+	if !strings.Contains(out, "```go\npackage a\n```\n") {
+		t.Fatalf("expected default fence for plain content in:\n%s", out)
+	}
+
+	blocks, err := apply.Parse(out, "<<<FILE:{path}>>>")
+	if err != nil {
+		t.Fatalf("apply.Parse: %v", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("blocks=%d", len(blocks))
+	}
+	if blocks[0].Path != "README.md" || string(blocks[0].Content) != readme {
+		t.Fatalf("block[0]=%+v", blocks[0])
+	}
+	if blocks[1].Path != "a.go" || string(blocks[1].Content) != "package a\n" {
+		t.Fatalf("block[1]=%+v", blocks[1])
+	}
+}
+
+func TestRenderMarkdownGitDirtyHeader(t *testing.T) {
+	t.Parallel()
+
+	dirty := true
+	r := Renderer{Newline: "\n"}
+	for _, tc := range []struct {
+		dirty *bool
+		want  string
+	}{
+		{&dirty, "git_sha: abc123\ngit_dirty: true\n"},
+		{nil, "git_sha: abc123\ngit_dirty: unknown\n"},
+	} {
+		info := BundleInfo{Repo: "r", Root: ".", Profile: "p", GitSHA: "abc123", GitDirty: tc.dirty, Timestamp: time.Unix(0, 0)}
+		out, err := r.RenderMarkdown(info, budget.Plan{})
+		if err != nil {
+			t.Fatalf("RenderMarkdown: %v", err)
+		}
+		if !strings.Contains(out, tc.want) {
+			t.Fatalf("missing %q in:\n%s", tc.want, out)
+		}
+	}
+}
+
+func TestRenderMarkdownSummaryHeader(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "a.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package a\n", Truncated: true},
+			{RelPath: "b.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package b\n"},
+		},
+		Dropped: []budget.DroppedEntry{
+			{RelPath: "c.md", Slices: []string{"docs"}, PrimarySlice: "docs", Reason: "budget_exceeded"},
+			{RelPath: "d.go", Slices: []string{"api"}, PrimarySlice: "api", Reason: "unreadable"},
+		},
+		DroppedSlices: []string{"docs"},
+		Partial:       true,
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Timestamp: time.Unix(0, 0)}
+	out, err := Renderer{Newline: "\n"}.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	want := "dropped_slices: 1\ndropped_files: 2\ntruncated_files: 1\npartial: true\n"
+	if !strings.Contains(out, want) {
+		t.Fatalf("missing %q in:\n%s", want, out)
+	}
+}
+
+func TestRenderMarkdownEmptyFiles(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "a.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package a\n"},
+			{RelPath: "empty.go", Slices: []string{"api"}, PrimarySlice: "api", Empty: true},
+		},
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Timestamp: time.Unix(0, 0)}
+	r := Renderer{Newline: "\n", CodeFences: true, IncludeManifest: true}
+
+	out, err := r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	if !strings.Contains(out, "empty.go  slices=[api] empty=true") || !strings.Contains(out, "## 2) empty.go") || !strings.Contains(out, "empty: true") {
+		t.Fatalf("empty file not annotated:\n%s", out)
+	}
+
+	r.SkipEmptyFiles = true
+	out, err = r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	if !strings.Contains(out, "empty=true") || strings.Contains(out, "## 2) empty.go") || !strings.Contains(out, "## 1) a.go") {
+		t.Fatalf("skip_empty_files output:\n%s", out)
+	}
+}
+
+func TestRenderPlainOmitsScaffolding(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "b.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package b\n"},
+			{RelPath: "a.go", Slices: []string{"api"}, PrimarySlice: "api", Truncated: true,
+				Content: "l1\n… [TRUNCATED: original_lines=9 kept_lines=1]\n"},
+		},
+	}
+
+	out, err := Renderer{Newline: "\n", CodeFences: true, IncludeTree: true, IncludeManifest: true}.RenderPlain(plan)
+	if err != nil {
+		t.Fatalf("RenderPlain: %v", err)
+	}
+	want := "==> a.go <==\nl1\n… [TRUNCATED: original_lines=9 kept_lines=1]\n\n==> b.go <==\npackage b\n"
+	if out != want {
+		t.Fatalf("out=%q want %q", out, want)
+	}
+
+	r := Renderer{Newline: "\n", FileBlock: FileBlockOptions{Header: "// FILE: {path}", Footer: "// END {path}"}}
+	out, err = r.RenderPlain(plan)
+	if err != nil {
+		t.Fatalf("RenderPlain: %v", err)
+	}
+	if !strings.HasPrefix(out, "// FILE: a.go\nl1\n") || !strings.HasSuffix(out, "// FILE: b.go\npackage b\n// END b.go\n") {
+		t.Fatalf("unexpected custom delimiters:\n%s", out)
+	}
+}
+
+func TestFileBlockHeaderTokens(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "a.go", Slices: []string{"api", "core"}, PrimarySlice: "api", OriginalLines: 2, OriginalBytes: 20, Content: "package a\n\n"},
+			{RelPath: "b.go", Slices: []string{"core"}, PrimarySlice: "core", OriginalLines: 1, OriginalBytes: 10, Content: "package b\n"},
+		},
+	}
+	r := Renderer{Newline: "\n", FileBlock: FileBlockOptions{
+		Header: "<<<FILE {index}:{path} lines={lines} bytes={bytes} slice={slice}>>>",
+		Footer: "<<<END {path}>>>",
+	}}
+	out, err := r.RenderPlain(plan)
+	if err != nil {
+		t.Fatalf("RenderPlain: %v", err)
+	}
+	for _, want := range []string{
+		"<<<FILE 1:a.go lines=2 bytes=20 slice=api>>>\npackage a\n",
+		"<<<FILE 2:b.go lines=1 bytes=10 slice=core>>>\npackage b\n<<<END b.go>>>\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderHTMLEscapesContent(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "web/<x>.html", Slices: []string{"web"}, PrimarySlice: "web",
+				Content: "<script>alert(1)</script>\n&amp;\n"},
+			{RelPath: "main.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package main\n"},
+		},
+	}
+	info := BundleInfo{Repo: "demo", Profile: "<p>", Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	out, err := Renderer{Newline: "\n", IncludeTree: true, IncludeManifest: true}.RenderHTML(info, plan)
+	if err != nil {
+		t.Fatalf("RenderHTML: %v", err)
+	}
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<title>snip bundle: demo (&lt;p&gt;)</title>",
+		`<li><a href="#f2">&lt;x&gt;.html</a></li>`,
+		`<details id="f1" open>`,
+		`<summary>1) main.go<span class="meta">`,
+		`<pre><code class="language-go">package main`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;\n&amp;amp;\n",
+		"</html>\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<script>alert") || strings.Contains(out, "prism") {
+		t.Fatalf("unescaped content or CDN reference:\n%s", out)
+	}
+
+	out, err = Renderer{Newline: "\n", HTMLPrism: true}.RenderHTML(info, plan)
+	if err != nil {
+		t.Fatalf("RenderHTML: %v", err)
+	}
+	if !strings.Contains(out, prismJS) || strings.Contains(out, "<nav>") {
+		t.Fatalf("prism or tree mismatch:\n%s", out)
+	}
+}
+
+func TestRenderManifestGitInfo(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "a.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package a\n"},
+			{RelPath: "new.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package a\n"},
+		},
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Timestamp: time.Unix(0, 0)}
+	r := Renderer{Newline: "\n", IncludeManifest: true, Manifest: ManifestOptions{
+		Commits: map[string]FileCommit{"a.go": {SHA: "abc1234", AuthorDate: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}},
+	}}
+	out, err := r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	if !strings.Contains(out, "a.go    slices=[api] commit=abc1234 date=2024-05-01\n") {
+		t.Fatalf("missing git info in:\n%s", out)
+	}
+	if !strings.Contains(out, "new.go  slices=[api]\n") {
+		t.Fatalf("file without history should have no annotation:\n%s", out)
+	}
+}
+
+func TestRenderMarkdownTOC(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "internal/app/snip.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package app\n"},
+			{RelPath: "README.md", Slices: []string{"api"}, PrimarySlice: "api", Content: "# r\n"},
+		},
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Timestamp: time.Unix(0, 0)}
+
+	r := Renderer{Newline: "\n", CodeFences: true, IncludeTOC: true}
+	out, err := r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	want := "## Contents\n\n- [README.md](#1-readmemd)\n- [internal/app/snip.go](#2-internalappsnipgo)\n"
+	if !strings.Contains(out, want) || !strings.Contains(out, "## 1) README.md\n") {
+		t.Fatalf("missing %q in:\n%s", want, out)
+	}
+
+	r.FileBlock = FileBlockOptions{Header: "<<<FILE:{path}>>>"}
+	out, err = r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	if strings.Contains(out, "## Contents") {
+		t.Fatalf("TOC rendered with custom delimiters:\n%s", out)
+	}
+}
+
+func TestOrderIncludedFileOrder(t *testing.T) {
+	t.Parallel()
+
+	files := []budget.FileEntry{
+		{RelPath: "a/util.go", PrimarySlice: "api", Priority: 10, IncludeIndex: 1},
+		{RelPath: "docs/guide.md", PrimarySlice: "docs", Priority: 1, IncludeIndex: 0},
+		{RelPath: "z/main.go", PrimarySlice: "api", Priority: 10, IncludeIndex: 0},
+	}
+	paths := func(fs []budget.FileEntry) string {
+		var out []string
+		for _, f := range fs {
+			out = append(out, f.RelPath)
+		}
+		return strings.Join(out, " ")
+	}
+	for _, tc := range []struct {
+		group bool
+		order string
+		want  string
+	}{
+		{false, "", "a/util.go docs/guide.md z/main.go"},
+		{false, FileOrderSlicePriority, "a/util.go z/main.go docs/guide.md"},
+		{false, FileOrderIncludeOrder, "z/main.go a/util.go docs/guide.md"},
+		{true, FileOrderPath, "a/util.go z/main.go docs/guide.md"},
+		{true, FileOrderIncludeOrder, "z/main.go a/util.go docs/guide.md"},
+	} {
+		if got := paths(OrderIncluded(files, tc.group, tc.order)); got != tc.want {
+			t.Fatalf("group=%t order=%q: got %s want %s", tc.group, tc.order, got, tc.want)
+		}
+	}
+}
+
+func TestBuildTreeSummarizesEntriesPastDepth(t *testing.T) {
+	t.Parallel()
+
+	paths := []string{
+		"a/b/c/d.go",
+		"a/b/c/e/f.go",
+		"a/b/g.go",
+		"a/h.go",
+		"z.go",
+	}
+	want := []string{
+		".",
+		"├── a",
+		"│   ├── b",
+		"│   │   └── (5 more files/dirs)",
+		"│   └── h.go",
+		"└── z.go",
+	}
+	for i := 0; i < 2; i++ {
+		got := buildTree(append([]string(nil), paths...), nil, 2, 0)
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("tree:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	got := buildTree(paths, nil, 4, 0)
+	if !strings.Contains(strings.Join(got, "\n"), "(1 more files/dirs)") {
+		t.Fatalf("depth 4 tree:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestBuildTreeFitsMaxNodes(t *testing.T) {
+	t.Parallel()
+
+	paths := []string{
+		"cmd/snip/main.go",
+		"internal/app/a.go",
+		"internal/app/b.go",
+		"internal/app/c.go",
+		"internal/util/u.go",
+		"go.mod",
+	}
+	tree := func(max int) string {
+		return strings.Join(buildTree(append([]string(nil), paths...), nil, 4, max), "\n")
+	}
+
+	if got := tree(0); strings.Count(got, "\n") != 11 {
+		t.Fatalf("uncapped tree has %d entries:\n%s", strings.Count(got, "\n"), got)
+	}
+	// The largest bottom-level directory folds first.
+	want := strings.Join([]string{
+		".",
+		"├── cmd",
+		"│   └── snip",
+		"│       └── main.go",
+		"├── internal",
+		"│   ├── app (3 files)",
+		"│   └── util",
+		"│       └── u.go",
+		"└── go.mod",
+	}, "\n")
+	if got := tree(8); got != want {
+		t.Fatalf("tree:\n%s\nwant:\n%s", got, want)
+	}
+	want = strings.Join([]string{
+		".",
+		"├── cmd (1 file)",
+		"├── internal (4 files)",
+		"└── go.mod",
+	}, "\n")
+	if got := tree(3); got != want {
+		t.Fatalf("tree:\n%s\nwant:\n%s", got, want)
+	}
+	want = strings.Join([]string{
+		".",
+		"├── cmd (1 file)",
+		"└── (8 more files/dirs)",
+	}, "\n")
+	if got := tree(2); got != want {
+		t.Fatalf("tree:\n%s\nwant:\n%s", got, want)
+	}
+
+	fit := FitTree(append([]string(nil), paths...), 4, 3)
+	if fit.Entries != 11 || fit.Collapsed != 5 || fit.Cut || !fit.Fitted() {
+		t.Fatalf("fit=%+v", fit)
+	}
+}
+
+func TestBuildTreeSizes(t *testing.T) {
+	t.Parallel()
+
+	sizes := map[string]int64{"a/b.go": 12595, "a/c.go": 500, "d.go": 3 << 20}
+	got := buildTree([]string{"a/b.go", "a/c.go", "d.go", "e.go"}, sizes, 4, 0)
+	want := []string{
+		".",
+		"├── a (12.8 KB)",
+		"│   ├── b.go (12.3 KB)",
+		"│   └── c.go (500 B)",
+		"├── d.go (3.0 MB)",
+		"└── e.go",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("tree:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRenderMarkdownHashes(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "a.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package a\n", SHA256: "abc123"},
+		},
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Timestamp: time.Unix(0, 0)}
+	r := Renderer{Newline: "\n", CodeFences: true, IncludeManifest: true, Manifest: ManifestOptions{IncludeHashes: true}}
+	out, err := r.RenderMarkdown(info, plan)
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	if !strings.Contains(out, "a.go  slices=[api] sha256=abc123\n") {
+		t.Fatalf("missing file hash in:\n%s", out)
+	}
+
+	body, last, ok := strings.Cut(strings.TrimSuffix(out, "\n"), "\n"+BundleChecksumPrefix)
+	if !ok || strings.Contains(last, "\n") {
+		t.Fatalf("bundle does not end with a checksum line:\n%s", out)
+	}
+	sum := sha256.Sum256([]byte(body + "\n"))
+	if last != hex.EncodeToString(sum[:]) {
+		t.Fatalf("bundle_sha256=%s, want hash of everything before it", last)
+	}
+}
+
+func TestRenderContinuationAppendsBlocks(t *testing.T) {
+	t.Parallel()
+
+	plan := budget.Plan{
+		Included: []budget.FileEntry{
+			{RelPath: "c.go", Slices: []string{"api"}, PrimarySlice: "api", OriginalLines: 1, Content: "package c\n"},
+		},
+	}
+	info := BundleInfo{Repo: "r", Root: ".", Profile: "p", Timestamp: time.Unix(0, 0)}
+	r := Renderer{Newline: "\n", CodeFences: true, IncludeManifest: true, Manifest: ManifestOptions{IncludeHashes: true}}
+	base, err := r.RenderMarkdown(info, budget.Plan{Included: []budget.FileEntry{
+		{RelPath: "a.go", Slices: []string{"api"}, PrimarySlice: "api", Content: "package a\n"},
+	}})
+	if err != nil {
+		t.Fatalf("RenderMarkdown: %v", err)
+	}
+	body, sealed := UnsealMarkdown(base)
+	if !sealed || strings.Contains(body, BundleChecksumPrefix) || !strings.HasSuffix(body, "```\n") {
+		t.Fatalf("UnsealMarkdown(sealed)=%q, %v", body, sealed)
+	}
+	if _, sealed := UnsealMarkdown(body); sealed {
+		t.Fatalf("UnsealMarkdown(unsealed) reported a seal")
+	}
+
+	out, err := r.RenderContinuation(plan, 2)
+	if err != nil {
+		t.Fatalf("RenderContinuation: %v", err)
+	}
+	want := "\n---\n\n## 2) c.go\nlines: 1\nbytes: 0\nslices: [api]\ntruncated: false\n\n```go\npackage c\n```\n"
+	if out != want {
+		t.Fatalf("continuation=%q\nwant         %q", out, want)
+	}
+}
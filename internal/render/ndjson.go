@@ -0,0 +1,180 @@
+package render
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/snip/internal/budget"
+)
+
+// ndjsonHeader is the first NDJSON line: bundle metadata plus plan status.
+type ndjsonHeader struct {
+	Type          string          `json:"type"`
+	Repo          string          `json:"repo"`
+	Root          string          `json:"root"`
+	Profile       string          `json:"profile"`
+	EnabledSlices []string        `json:"enabled_slices"`
+	GitSHA        string          `json:"git_sha"`
+	GitDirty      *bool           `json:"git_dirty"`
+	Timestamp     string          `json:"timestamp"`
+	SnipVersion   string          `json:"snip_version"`
+	Files         int             `json:"files"`
+	Partial       bool            `json:"partial"`
+	HardCut       bool            `json:"hard_cut"`
+	DroppedSlices []string        `json:"dropped_slices"`
+	Dropped       []ndjsonDropped `json:"dropped"`
+}
+
+type ndjsonDropped struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+	Slice  string `json:"slice,omitempty"`
+}
+
+// ndjsonFile is one NDJSON line per included file.
+type ndjsonFile struct {
+	Type         string   `json:"type"`
+	Index        int      `json:"index"`
+	Path         string   `json:"path"`
+	Slices       []string `json:"slices"`
+	PrimarySlice string   `json:"primary_slice"`
+	Lines        int      `json:"lines"`
+	Bytes        int64    `json:"bytes"`
+	KeptLines    int      `json:"kept_lines"`
+	Truncated    bool     `json:"truncated"`
+	Excerpt      bool     `json:"excerpt,omitempty"`
+	Stripped     bool     `json:"stripped,omitempty"`
+	Redacted     int      `json:"redacted,omitempty"`
+	Empty        bool     `json:"empty,omitempty"`
+	Encoding     string   `json:"encoding,omitempty"`
+	Commit       string   `json:"commit,omitempty"`
+	CommitDate   string   `json:"commit_date,omitempty"`
+	SHA256       string   `json:"sha256,omitempty"`
+	Content      string   `json:"content"`
+}
+
+// RenderNDJSON streams plan to w as newline-delimited JSON: a "bundle" header line followed by
+// one "file" line per included file, in the same order as the markdown renderer.
+// The plan must already be finalized (budgets enforced); nothing is buffered beyond one line.
+func (r Renderer) RenderNDJSON(w io.Writer, info BundleInfo, plan budget.Plan) error {
+	files := OrderIncluded(plan.Included, r.Manifest.GroupBySlice, r.FileOrder)
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(ndjsonHeaderFor(info, plan, len(files))); err != nil {
+		return fmt.Errorf("encode header: %w", err)
+	}
+	for i, f := range files {
+		if err := enc.Encode(r.ndjsonFileFor(i, f)); err != nil {
+			return fmt.Errorf("encode %s: %w", f.RelPath, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	return nil
+}
+
+// NDJSONHeader returns the "bundle" header line RenderNDJSON writes for plan.
+func (r Renderer) NDJSONHeader(info BundleInfo, plan budget.Plan) (string, error) {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(ndjsonHeaderFor(info, plan, len(plan.Included))); err != nil {
+		return "", fmt.Errorf("encode header: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// EachNDJSONFile calls fn with each "file" line RenderNDJSON writes for plan, in order. Only
+// one line is held at a time, so callers can measure a bundle without buffering it.
+func (r Renderer) EachNDJSONFile(plan budget.Plan, fn func(line string) error) error {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	for i, f := range OrderIncluded(plan.Included, r.Manifest.GroupBySlice, r.FileOrder) {
+		sb.Reset()
+		if err := enc.Encode(r.ndjsonFileFor(i, f)); err != nil {
+			return fmt.Errorf("encode %s: %w", f.RelPath, err)
+		}
+		if err := fn(sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ndjsonHeaderFor builds the header line for plan with files included files.
+func ndjsonHeaderFor(info BundleInfo, plan budget.Plan, files int) ndjsonHeader {
+	h := ndjsonHeader{
+		Type:          "bundle",
+		Repo:          info.Repo,
+		Root:          info.Root,
+		Profile:       info.Profile,
+		EnabledSlices: nonNil(info.Enabled),
+		GitSHA:        info.GitSHA,
+		GitDirty:      info.GitDirty,
+		Timestamp:     info.Timestamp.Format(time.RFC3339),
+		SnipVersion:   info.SnipVersion,
+		Files:         files,
+		Partial:       plan.Partial,
+		HardCut:       plan.HardCut,
+		DroppedSlices: nonNil(plan.DroppedSlices),
+		Dropped:       []ndjsonDropped{},
+	}
+	for _, d := range plan.Dropped {
+		h.Dropped = append(h.Dropped, ndjsonDropped{Path: d.RelPath, Reason: d.Reason, Detail: d.Detail, Slice: d.PrimarySlice})
+	}
+	return h
+}
+
+// ndjsonFileFor builds the line for f, the i-th (0-based) file in bundle order.
+func (r Renderer) ndjsonFileFor(i int, f budget.FileEntry) ndjsonFile {
+	line := ndjsonFile{
+		Type:         "file",
+		Index:        i + 1,
+		Path:         f.RelPath,
+		Slices:       nonNil(f.Slices),
+		PrimarySlice: f.PrimarySlice,
+		Lines:        f.OriginalLines,
+		Bytes:        f.OriginalBytes,
+		KeptLines:    f.KeptLines,
+		Truncated:    f.Truncated,
+		Excerpt:      f.Excerpt,
+		Stripped:     f.Stripped,
+		Redacted:     f.Redacted,
+		Empty:        f.Empty,
+		Encoding:     f.Encoding,
+		Content:      f.Content,
+	}
+	if r.Manifest.IncludeHashes {
+		line.SHA256 = f.SHA256
+	}
+	if c, ok := r.Manifest.Commits[f.RelPath]; ok {
+		line.Commit, line.CommitDate = c.SHA, c.AuthorDate.Format(time.RFC3339)
+	}
+	return line
+}
+
+// RenderNDJSONString renders plan as NDJSON into a string (used for budget measurement).
+func (r Renderer) RenderNDJSONString(info BundleInfo, plan budget.Plan) (string, error) {
+	var sb strings.Builder
+	if err := r.RenderNDJSON(&sb, info, plan); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func nonNil(in []string) []string {
+	if in == nil {
+		return []string{}
+	}
+	return in
+}
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/budget"
+)
+
+// DefaultPlainHeader delimits files in plain output when render.file_block.header is unset.
+const DefaultPlainHeader = "==> {path} <=="
+
+// RenderPlain renders plan as concatenated file contents: per file, the delimiter header, the
+// content (truncation markers included) and the optional footer, separated by blank lines. There
+// is no bundle header, tree, or manifest, and no code fences.
+func (r Renderer) RenderPlain(plan budget.Plan) (string, error) {
+	nl := r.Newline
+	if nl == "" {
+		nl = "\n"
+	}
+	header := r.FileBlock.Header
+	if header == "" {
+		header = DefaultPlainHeader
+	}
+
+	var buf bytes.Buffer
+	wrote := false
+	for i, f := range OrderIncluded(plan.Included, r.Manifest.GroupBySlice, r.FileOrder) {
+		if r.SkipEmptyFiles && f.Empty {
+			continue
+		}
+		if wrote {
+			buf.WriteString(nl)
+		}
+		wrote = true
+		buf.WriteString(applyFileBlockToken(header, i+1, f))
+		buf.WriteString(nl)
+
+		content := f.Content
+		if r.LineNumbers {
+			content = numberLines(f)
+		}
+		content = strings.ReplaceAll(content, "\n", nl)
+		buf.WriteString(content)
+		if content != "" && !strings.HasSuffix(content, nl) {
+			buf.WriteString(nl)
+		}
+
+		if foot := applyFileBlockToken(r.FileBlock.Footer, i+1, f); foot != "" {
+			buf.WriteString(foot)
+			buf.WriteString(nl)
+		}
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,149 @@
+package render
+
+import (
+	"container/heap"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TreeFit describes how a tree was fitted to render.tree_max_nodes.
+type TreeFit struct {
+	// Entries is the number of lines below the root before fitting, "(N more files/dirs)"
+	// summaries past tree_depth included.
+	Entries int
+	// Collapsed counts directories folded into a single "dir (N files)" line.
+	Collapsed int
+	// Cut reports that folding every directory was not enough and the root's own entries
+	// were cut short as well.
+	Cut bool
+}
+
+// Fitted reports whether the tree differs from its uncapped rendering.
+func (f TreeFit) Fitted() bool { return f.Collapsed > 0 || f.Cut }
+
+// FitTree reports how the tree of paths at depth would be fitted to maxNodes entries
+// (0 disables the cap), without rendering it.
+func FitTree(paths []string, depth, maxNodes int) TreeFit {
+	_, fit := newFittedTree(paths, nil, depth, maxNodes)
+	return fit
+}
+
+func newFittedTree(paths []string, sizes map[string]int64, depth, maxNodes int) (*treeNode, TreeFit) {
+	sort.Strings(paths)
+	tree := newTreeNode(".")
+	for _, p := range paths {
+		parts := strings.Split(filepath.ToSlash(p), "/")
+		size, ok := sizes[p]
+		tree.add(parts, size, ok)
+	}
+	return tree, tree.fit(depth, maxNodes)
+}
+
+// foldState is the bookkeeping fit keeps for each directory.
+type foldState struct {
+	node    *treeNode
+	parent  *treeNode
+	depth   int
+	path    string
+	lines   int // lines the directory renders, itself included
+	pending int // child directories still expanded
+}
+
+// fit folds directories until the tree renders at most maxNodes lines below the root.
+// Folding works bottom-up: only a directory whose subdirectories are all folded (or lie past
+// maxDepth) can be folded, the one rendering the most lines first, deeper and then
+// alphabetically first on ties. The top levels therefore stay visible longest. If every
+// directory is folded and the tree is still too big, the root keeps its first entries and
+// summarizes the rest.
+func (n *treeNode) fit(maxDepth, maxNodes int) TreeFit {
+	state := map[*treeNode]*foldState{}
+	var walk func(t, parent *treeNode, depth int, path string) int
+	walk = func(t, parent *treeNode, depth int, path string) int {
+		s := &foldState{node: t, parent: parent, depth: depth, path: path, lines: 1}
+		state[t] = s
+		switch {
+		case len(t.children) == 0:
+		case depth >= maxDepth:
+			s.lines++ // the "(N more files/dirs)" line
+		default:
+			for name, c := range t.children {
+				s.lines += walk(c, t, depth+1, path+"/"+name)
+				if len(c.children) > 0 {
+					s.pending++
+				}
+			}
+		}
+		return s.lines
+	}
+	total := walk(n, nil, 0, "") - 1
+	fit := TreeFit{Entries: total}
+	if maxNodes <= 0 || total <= maxNodes {
+		return fit
+	}
+
+	q := &foldQueue{}
+	for t, s := range state {
+		if t != n && len(t.children) > 0 && (s.depth >= maxDepth || s.pending == 0) {
+			heap.Push(q, s)
+		}
+	}
+	for total > maxNodes && q.Len() > 0 {
+		s := heap.Pop(q).(*foldState)
+		s.node.collapsed = true
+		fit.Collapsed++
+		saved := s.lines - 1
+		total -= saved
+		for p := s.parent; p != nil; p = state[p].parent {
+			state[p].lines -= saved
+		}
+		if ps := state[s.parent]; s.parent != n {
+			if ps.pending--; ps.pending == 0 {
+				heap.Push(q, ps)
+			}
+		}
+	}
+	if total > maxNodes {
+		// Every directory is a single line now, as is every file.
+		n.keep = max(maxNodes-1, 0)
+		n.cut = true
+		fit.Cut = true
+	}
+	return fit
+}
+
+// foldQueue orders fold candidates: most lines first, then deepest, then by path.
+type foldQueue []*foldState
+
+func (q foldQueue) Len() int { return len(q) }
+func (q foldQueue) Less(i, j int) bool {
+	a, b := q[i], q[j]
+	if a.lines != b.lines {
+		return a.lines > b.lines
+	}
+	if a.depth != b.depth {
+		return a.depth > b.depth
+	}
+	return a.path < b.path
+}
+func (q foldQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *foldQueue) Push(x any)   { *q = append(*q, x.(*foldState)) }
+func (q *foldQueue) Pop() any {
+	old := *q
+	s := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return s
+}
+
+// files counts the files below n.
+func (n *treeNode) files() int {
+	total := 0
+	for _, c := range n.children {
+		if len(c.children) == 0 {
+			total++
+			continue
+		}
+		total += c.files()
+	}
+	return total
+}
@@ -3,6 +3,7 @@ package selector
 import (
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -69,6 +70,45 @@ func EnabledSlices(cfg config.Config, profile string, mods []Modifier) ([]string
 	return out, nil
 }
 
+// Primary-slice tiebreak policies (selector.primary_tiebreak).
+const (
+	// TiebreakName attributes a file to the alphabetically first of its equal-priority slices.
+	TiebreakName = "name"
+	// TiebreakFirstEnabled attributes it to the one its profile enables first.
+	TiebreakFirstEnabled = "first_enabled"
+)
+
+// EnableOrder lists the slices a profile enables in its enable order (parents first for
+// extends), followed by slices added by modifiers in argument order, without those the
+// modifiers disable. It sets config.SelectorConfig.EnableOrder for TiebreakFirstEnabled.
+func EnableOrder(cfg config.Config, profile string, mods []Modifier) []string {
+	enabled := map[string]bool{}
+	var order []string
+	add := func(s string) {
+		if !enabled[s] {
+			enabled[s] = true
+			order = append(order, s)
+		}
+	}
+	for _, s := range cfg.Profiles[profile].Enable {
+		add(s)
+	}
+	for _, m := range mods {
+		if m.Enable {
+			add(m.Name)
+		} else {
+			enabled[m.Name] = false
+		}
+	}
+	out := order[:0]
+	for _, s := range order {
+		if enabled[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 // File describes a file considered by selection.
 type File struct {
 	RelPath         string
@@ -78,6 +118,13 @@ type File struct {
 	Slices          []string
 	PrimarySlice    string
 	PrimaryPriority int
+	// IncludeIndex is the index of the first of the primary slice's include patterns that
+	// matches the file (globs first, then include_regex); files added by an ad-hoc --include
+	// come after every pattern. render.file_order include_order sorts by it.
+	IncludeIndex int
+	// PriorityDelta ranks the file within its primary slice: the delta of the longest of the
+	// slice's include_priorities globs that matches it, else 0.
+	PriorityDelta   int
 	Excluded        bool
 	ExclusionReason discovery.ExclusionReason
 	ExclusionDetail string
@@ -100,15 +147,59 @@ func Select(cfg config.Config, enabledSlices []string, discovered []discovery.Pa
 	for _, s := range enabledSlices {
 		slicePriorities[s] = cfg.Slices[s].Priority
 	}
+	// With first_enabled, equal priorities fall back to enable order, then name.
+	var enableRank map[string]int
+	if cfg.Selector.PrimaryTiebreak == TiebreakFirstEnabled {
+		enableRank = make(map[string]int, len(cfg.Selector.EnableOrder))
+		for i, s := range cfg.Selector.EnableOrder {
+			enableRank[s] = i + 1
+		}
+	}
+
+	// Compile each enabled slice's patterns once for the whole selection.
+	matchers := map[string]sliceMatcher{}
+	for _, s := range enabledSlices {
+		matchers[s] = newSliceMatcher(cfg.Slices[s])
+	}
+
+	// With global_exclude_wins, gather every enabled slice's excludes up front so a match in
+	// any slice strips the file from all slices.
+	var globalExcludes patternSet
+	if cfg.Selector.GlobalExcludeWins {
+		for _, s := range enabledSlices {
+			globalExcludes.globs = append(globalExcludes.globs, matchers[s].exclude.globs...)
+			globalExcludes.regexes = append(globalExcludes.regexes, matchers[s].exclude.regexes...)
+		}
+	}
+
+	// Ad-hoc --include files outside every enabled slice join the highest-priority one, so
+	// budgets drop them last; ad-hoc --exclude globs apply on top of every slice's excludes.
+	adHocInclude := newPatternSet(cfg.Selector.Include, nil)
+	adHocExclude := newPatternSet(cfg.Selector.Exclude, nil)
+	top := EnabledSliceList(enabledSlices, cfg)[0]
 
 	var included []File
 	var dropped []File
 
 	for _, pi := range discovered {
-		mem := membership(cfg, enabledSlices, pi.RelPath, pi.IsHidden, includeHidden)
-		if len(mem) == 0 {
+		if ok, _ := globalExcludes.matches(pi.RelPath); ok {
 			continue
 		}
+		if ok, _ := adHocExclude.matches(pi.RelPath); ok {
+			continue
+		}
+		mem := membership(matchers, enabledSlices, pi.RelPath, pi.IsHidden, includeHidden)
+		// Content filters run after every path rule, so only files those rules kept are read.
+		if len(mem) > 0 && !pi.Excluded {
+			mem = filterContains(matchers, mem, pi.AbsPath)
+		}
+		if len(mem) == 0 {
+			ok, explicitHidden := adHocInclude.matches(pi.RelPath)
+			if !ok || (pi.IsHidden && !includeHidden && !explicitHidden) {
+				continue
+			}
+			mem = []string{top}
+		}
 
 		f := File{
 			RelPath:         pi.RelPath,
@@ -120,7 +211,9 @@ func Select(cfg config.Config, enabledSlices []string, discovered []discovery.Pa
 			ExclusionReason: pi.ExclusionReason,
 			ExclusionDetail: pi.ExclusionDetail,
 		}
-		f.PrimarySlice, f.PrimaryPriority = primary(mem, slicePriorities)
+		f.PrimarySlice, f.PrimaryPriority = primary(mem, slicePriorities, enableRank)
+		f.IncludeIndex = matchers[f.PrimarySlice].include.index(pi.RelPath)
+		f.PriorityDelta = matchers[f.PrimarySlice].priorityDelta(pi.RelPath)
 		if f.Excluded {
 			dropped = append(dropped, f)
 			continue
@@ -135,18 +228,164 @@ func Select(cfg config.Config, enabledSlices []string, discovered []discovery.Pa
 	return Selected{Included: included, Dropped: dropped}, nil
 }
 
-func membership(cfg config.Config, enabled []string, rel string, isHidden bool, includeHidden bool) []string {
+// sliceMatcher holds a slice's include/exclude globs and compiled regexes.
+type sliceMatcher struct {
+	include  patternSet
+	exclude  patternSet
+	contains *regexp.Regexp // nil when the slice has no contains_regex
+	// priorities are the include_priorities globs, longest first.
+	priorities []string
+	deltas     map[string]int
+}
+
+func newSliceMatcher(sl config.SliceConfig) sliceMatcher {
+	m := sliceMatcher{
+		include: newPatternSet(sl.Include, sl.IncludeRegex),
+		exclude: newPatternSet(sl.Exclude, sl.ExcludeRegex),
+		deltas:  sl.IncludePriorities,
+	}
+	if sl.ContainsRegex != "" {
+		m.contains, _ = regexp.Compile(sl.ContainsRegex)
+	}
+	for pat := range sl.IncludePriorities {
+		m.priorities = append(m.priorities, pat)
+	}
+	sort.Slice(m.priorities, func(i, j int) bool {
+		a, b := m.priorities[i], m.priorities[j]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return m
+}
+
+// priorityDelta returns the delta of the longest include_priorities glob matching rel, or 0.
+func (m sliceMatcher) priorityDelta(rel string) int {
+	for _, pat := range m.priorities {
+		if ok, _ := matchesAny(rel, []string{pat}); ok {
+			return m.deltas[pat]
+		}
+	}
+	return 0
+}
+
+// filterContains drops slices from mem whose contains_regex does not match the file at abs.
+// The file is read at most once, and only when a member slice has a content filter. A file
+// that cannot be read keeps its slices so the budget step reports it as unreadable.
+func filterContains(matchers map[string]sliceMatcher, mem []string, abs string) []string {
+	var (
+		content []byte
+		read    bool
+		out     []string
+	)
+	for _, s := range mem {
+		re := matchers[s].contains
+		if re == nil {
+			out = append(out, s)
+			continue
+		}
+		if !read {
+			data, err := os.ReadFile(abs)
+			if err != nil {
+				return mem
+			}
+			content, read = data, true
+		}
+		if re.Match(content) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ContainsMatch reports whether the file at abs matches sl's contains_regex. ok is false
+// when the slice has no content filter or the file cannot be read.
+func ContainsMatch(sl config.SliceConfig, abs string) (matched bool, ok bool) {
+	re := newSliceMatcher(sl).contains
+	if re == nil {
+		return false, false
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return false, false
+	}
+	return re.Match(data), true
+}
+
+// patternSet is a list of doublestar globs plus regular expressions.
+type patternSet struct {
+	globs   []string
+	regexes []*regexp.Regexp
+}
+
+// newPatternSet compiles regexes, skipping invalid ones (config.Validate rejects them).
+func newPatternSet(globs, regexes []string) patternSet {
+	ps := patternSet{globs: globs}
+	for _, pat := range regexes {
+		if re, err := regexp.Compile(pat); err == nil {
+			ps.regexes = append(ps.regexes, re)
+		}
+	}
+	return ps
+}
+
+// matches reports whether any pattern matches rel. Only globs that name a dot
+// segment count as explicitly including hidden files; regexes never do.
+func (ps patternSet) matches(rel string) (matched bool, explicitHidden bool) {
+	matched, explicitHidden = matchesAny(rel, ps.globs)
+	if matched {
+		return matched, explicitHidden
+	}
+	for _, re := range ps.regexes {
+		if re.MatchString(rel) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// index returns the position of the first pattern matching rel, counting globs then
+// regexes, or the number of patterns when none does.
+func (ps patternSet) index(rel string) int {
+	for i, pat := range ps.globs {
+		if ok, _ := matchesAny(rel, []string{pat}); ok {
+			return i
+		}
+	}
+	for i, re := range ps.regexes {
+		if re.MatchString(rel) {
+			return len(ps.globs) + i
+		}
+	}
+	return len(ps.globs) + len(ps.regexes)
+}
+
+// first returns the first matching glob, else the first matching regex.
+func (ps patternSet) first(rel string) (PatternMatch, bool) {
+	if ok, pat, explicitHidden := firstMatch(rel, ps.globs); ok {
+		return PatternMatch{Matched: true, Pattern: pat}, explicitHidden
+	}
+	for _, re := range ps.regexes {
+		if re.MatchString(rel) {
+			return PatternMatch{Matched: true, Pattern: re.String(), Regex: true}, false
+		}
+	}
+	return PatternMatch{}, false
+}
+
+func membership(matchers map[string]sliceMatcher, enabled []string, rel string, isHidden bool, includeHidden bool) []string {
 	var mem []string
 	for _, s := range enabled {
-		sl := cfg.Slices[s]
-		inc, incExplicitHidden := matchesAny(rel, sl.Include)
+		m := matchers[s]
+		inc, incExplicitHidden := m.include.matches(rel)
 		if !inc {
 			continue
 		}
 		if isHidden && !includeHidden && !incExplicitHidden {
 			continue
 		}
-		if ok, _ := matchesAny(rel, sl.Exclude); ok {
+		if ok, _ := m.exclude.matches(rel); ok {
 			continue
 		}
 		mem = append(mem, s)
@@ -188,12 +427,14 @@ func patternExplicitlyIncludesHidden(pat string) bool {
 	return false
 }
 
-func primary(mem []string, pri map[string]int) (string, int) {
+// primary picks the highest-priority slice in mem. Ties go to the lowest rank when rank is
+// non-nil (unranked slices last), then to the alphabetically first name.
+func primary(mem []string, pri map[string]int, rank map[string]int) (string, int) {
 	best := ""
 	bestP := -1 << 30
 	for _, s := range mem {
 		p := pri[s]
-		if p > bestP || (p == bestP && s < best) {
+		if p > bestP || (p == bestP && earlier(s, best, rank)) {
 			best = s
 			bestP = p
 		}
@@ -201,6 +442,22 @@ func primary(mem []string, pri map[string]int) (string, int) {
 	return best, bestP
 }
 
+func earlier(a, b string, rank map[string]int) bool {
+	if rank != nil {
+		ra, rb := rank[a], rank[b]
+		if ra == 0 {
+			ra = 1 << 30
+		}
+		if rb == 0 {
+			rb = 1 << 30
+		}
+		if ra != rb {
+			return ra < rb
+		}
+	}
+	return a < b
+}
+
 // EnabledSliceList formats enabled slices deterministically, ordered by priority desc then name.
 func EnabledSliceList(enabled []string, cfg config.Config) []string {
 	out := append([]string(nil), enabled...)
@@ -215,14 +472,51 @@ func EnabledSliceList(enabled []string, cfg config.Config) []string {
 	return out
 }
 
+// PatternMatch describes which include/exclude pattern matched a path.
+type PatternMatch struct {
+	Matched bool
+	Pattern string
+	Regex   bool // Pattern is an include_regex/exclude_regex entry rather than a glob
+}
+
+// String formats the match as `pattern="..."` or `regex="..."`.
+func (m PatternMatch) String() string {
+	if m.Regex {
+		return fmt.Sprintf("regex=%q", m.Pattern)
+	}
+	return fmt.Sprintf("pattern=%q", m.Pattern)
+}
+
 // ExplainSliceMatch reports include/exclude matching details for a single slice.
-// It returns:
-//   - includeMatched, includePattern, includeExplicitHidden
-//   - excludeMatched, excludePattern
-func ExplainSliceMatch(rel string, sl config.SliceConfig) (bool, string, bool, bool, string) {
-	incOK, incPat, incExplicitHidden := firstMatch(rel, sl.Include)
-	excOK, excPat, _ := firstMatch(rel, sl.Exclude)
-	return incOK, incPat, incExplicitHidden, excOK, excPat
+// Globs are checked before regexes. It returns:
+//   - the include match and whether it explicitly includes hidden files
+//   - the exclude match
+func ExplainSliceMatch(rel string, sl config.SliceConfig) (include PatternMatch, includeExplicitHidden bool, exclude PatternMatch) {
+	m := newSliceMatcher(sl)
+	include, includeExplicitHidden = m.include.first(rel)
+	exclude, _ = m.exclude.first(rel)
+	return include, includeExplicitHidden, exclude
+}
+
+// GlobalExcludeMatch reports the first enabled slice (in name order) whose exclude patterns
+// match rel. It is only meaningful when selector.global_exclude_wins is enabled.
+func GlobalExcludeMatch(cfg config.Config, enabledSlices []string, rel string) (slice string, match PatternMatch, ok bool) {
+	names := append([]string(nil), enabledSlices...)
+	sort.Strings(names)
+	for _, s := range names {
+		if m, _ := newSliceMatcher(cfg.Slices[s]).exclude.first(rel); m.Matched {
+			return s, m, true
+		}
+	}
+	return "", PatternMatch{}, false
+}
+
+// AdHocMatch reports the first --include and --exclude globs (config.SelectorConfig.Include and
+// Exclude) that match rel, and whether the include glob explicitly names hidden files.
+func AdHocMatch(cfg config.Config, rel string) (include PatternMatch, includeExplicitHidden bool, exclude PatternMatch) {
+	include, includeExplicitHidden = newPatternSet(cfg.Selector.Include, nil).first(rel)
+	exclude, _ = newPatternSet(cfg.Selector.Exclude, nil).first(rel)
+	return include, includeExplicitHidden, exclude
 }
 
 func firstMatch(rel string, patterns []string) (matched bool, pattern string, explicitHidden bool) {
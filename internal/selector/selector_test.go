@@ -1,6 +1,10 @@
 package selector
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/mmrzaf/snip/internal/config"
@@ -139,3 +143,270 @@ func TestSelectPrimaryAndHiddenRules(t *testing.T) {
 		t.Fatalf("slices=%v want both", hidden2.Slices)
 	}
 }
+
+func TestSelectGlobalExcludeWins(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.DefaultProfile = "p"
+	cfg.Slices = map[string]config.SliceConfig{
+		"api":  {Include: []string{"**/*.go"}, Exclude: []string{"**/*_gen.go"}, Priority: 10},
+		"code": {Include: []string{"**/*"}, Priority: 1},
+	}
+	cfg.Profiles = map[string]config.Profile{"p": {Enable: []string{"api", "code"}}}
+
+	discovered := []discovery.PathInfo{
+		{RelPath: "main.go", AbsPath: "/tmp/main.go"},
+		{RelPath: "model_gen.go", AbsPath: "/tmp/model_gen.go"},
+	}
+
+	// Default: the per-slice exclude only affects "api"; "code" still includes the file.
+	selected, err := Select(cfg, []string{"api", "code"}, discovered, false)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if len(selected.Included) != 2 {
+		t.Fatalf("included=%d want=2", len(selected.Included))
+	}
+	for _, f := range selected.Included {
+		if f.RelPath == "model_gen.go" && (len(f.Slices) != 1 || f.Slices[0] != "code") {
+			t.Fatalf("model_gen.go slices=%v want [code]", f.Slices)
+		}
+	}
+
+	// global_exclude_wins: the "api" exclude removes the file from every slice.
+	cfg.Selector.GlobalExcludeWins = true
+	selected2, err := Select(cfg, []string{"api", "code"}, discovered, false)
+	if err != nil {
+		t.Fatalf("Select(global): %v", err)
+	}
+	if len(selected2.Included) != 1 || selected2.Included[0].RelPath != "main.go" {
+		t.Fatalf("included=%+v want only main.go", selected2.Included)
+	}
+
+	slice, m, ok := GlobalExcludeMatch(cfg, []string{"api", "code"}, "model_gen.go")
+	if !ok || slice != "api" || m.Pattern != "**/*_gen.go" || m.Regex {
+		t.Fatalf("GlobalExcludeMatch=(%q,%+v,%t)", slice, m, ok)
+	}
+}
+
+func TestSelectRegexPatterns(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Slices = map[string]config.SliceConfig{
+		"handlers": {
+			IncludeRegex: []string{`(^|/)handler_.*_v\d+\.go$`},
+			ExcludeRegex: []string{`_v0\.go$`},
+			Priority:     10,
+		},
+	}
+	discovered := []discovery.PathInfo{
+		{RelPath: "api/handler_user_v2.go"},
+		{RelPath: "api/handler_user.go"},
+		{RelPath: "api/handler_old_v0.go"},
+	}
+
+	selected, err := Select(cfg, []string{"handlers"}, discovered, false)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if len(selected.Included) != 1 || selected.Included[0].RelPath != "api/handler_user_v2.go" {
+		t.Fatalf("included=%+v", selected.Included)
+	}
+
+	inc, _, exc := ExplainSliceMatch("api/handler_old_v0.go", cfg.Slices["handlers"])
+	if !inc.Matched || !inc.Regex || inc.Pattern != `(^|/)handler_.*_v\d+\.go$` {
+		t.Fatalf("include=%+v", inc)
+	}
+	if exc.String() != `regex="_v0\\.go$"` {
+		t.Fatalf("exclude=%s", exc)
+	}
+}
+
+func TestSelectRecordsIncludeIndex(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Slices = map[string]config.SliceConfig{
+		"api": {Include: []string{"cmd/**/main.go", "internal/**/*.go"}, IncludeRegex: []string{`\.proto$`}, Priority: 10},
+	}
+	cfg.Selector.Include = []string{"Makefile"}
+	discovered := []discovery.PathInfo{
+		{RelPath: "Makefile"},
+		{RelPath: "api/user.proto"},
+		{RelPath: "cmd/snip/main.go"},
+		{RelPath: "internal/app/app.go"},
+	}
+
+	selected, err := Select(cfg, []string{"api"}, discovered, false)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	var got []string
+	for _, f := range selected.Included {
+		got = append(got, fmt.Sprintf("%s=%d", f.RelPath, f.IncludeIndex))
+	}
+	if want := "Makefile=3 api/user.proto=2 cmd/snip/main.go=0 internal/app/app.go=1"; strings.Join(got, " ") != want {
+		t.Fatalf("include indexes=%v want %s", got, want)
+	}
+}
+
+func TestSelectRecordsPriorityDelta(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Slices = map[string]config.SliceConfig{
+		"api": {Include: []string{"internal/**"}, Priority: 10, IncludePriorities: map[string]int{
+			"internal/**":             5,
+			"internal/util/**":        -5,
+			"internal/util/keep/*.go": 2,
+		}},
+	}
+	discovered := []discovery.PathInfo{
+		{RelPath: "internal/core/a.go"},
+		{RelPath: "internal/util/b.go"},
+		{RelPath: "internal/util/keep/c.go"},
+	}
+
+	selected, err := Select(cfg, []string{"api"}, discovered, false)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	var got []string
+	for _, f := range selected.Included {
+		got = append(got, fmt.Sprintf("%s=%d", f.RelPath, f.PriorityDelta))
+	}
+	if want := "internal/core/a.go=5 internal/util/b.go=-5 internal/util/keep/c.go=2"; strings.Join(got, " ") != want {
+		t.Fatalf("priority deltas=%v want %s", got, want)
+	}
+}
+
+func TestSelectAdHocIncludeExclude(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.DefaultProfile = "p"
+	cfg.Slices = map[string]config.SliceConfig{
+		"api":  {Include: []string{"**/*.go"}, Priority: 10},
+		"docs": {Include: []string{"docs/**"}, Priority: 1},
+	}
+	cfg.Profiles = map[string]config.Profile{"p": {Enable: []string{"api", "docs"}}}
+	cfg.Selector.Exclude = []string{"gen/**"}
+	cfg.Selector.Include = []string{"Makefile", "gen/keep.go", "**/.tool-versions", "*.env"}
+
+	discovered := []discovery.PathInfo{
+		{RelPath: ".tool-versions", IsHidden: true},
+		{RelPath: "Makefile"},
+		{RelPath: "README.txt"},
+		{RelPath: "docs/a.md"},
+		{RelPath: "gen/keep.go"},
+		{RelPath: "gen/z.go"},
+		{RelPath: "main.go"},
+		{RelPath: "secret.env", IsHidden: true},
+	}
+	selected, err := Select(cfg, []string{"api", "docs"}, discovered, false)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	var got []string
+	for _, f := range selected.Included {
+		got = append(got, f.RelPath+"="+f.PrimarySlice)
+	}
+	// --exclude wins over --include; forced files join the top-priority slice; hidden files
+	// need a glob that names the dot segment.
+	want := ".tool-versions=api Makefile=api docs/a.md=docs main.go=api"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("included=%v want %s", got, want)
+	}
+
+	inc, explicitHidden, exc := AdHocMatch(cfg, "gen/keep.go")
+	if !inc.Matched || inc.Pattern != "gen/keep.go" || explicitHidden || exc.Pattern != "gen/**" {
+		t.Fatalf("AdHocMatch=(%+v,%t,%+v)", inc, explicitHidden, exc)
+	}
+}
+
+func TestSelectContainsRegex(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"pay.go":   "type PaymentGateway interface{}\n",
+		"other.go": "package other\n",
+		"cli.go":   "package cli\n",
+	}
+	var discovered []discovery.PathInfo
+	for name, body := range files {
+		abs := filepath.Join(dir, name)
+		if err := os.WriteFile(abs, []byte(body), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		discovered = append(discovered, discovery.PathInfo{RelPath: name, AbsPath: abs})
+	}
+	discovered = append(discovered, discovery.PathInfo{RelPath: "gone.go", AbsPath: filepath.Join(dir, "gone.go")})
+
+	cfg := config.Default()
+	cfg.Slices = map[string]config.SliceConfig{
+		"payments": {Include: []string{"*.go"}, ContainsRegex: `\bPaymentGateway\b`, Priority: 10},
+	}
+	cfg.Selector.Include = []string{"cli.go"}
+
+	selected, err := Select(cfg, []string{"payments"}, discovered, false)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	var got []string
+	for _, f := range selected.Included {
+		got = append(got, f.RelPath)
+	}
+	// Unreadable files stay selected so the budget step can report them.
+	if strings.Join(got, ",") != "cli.go,gone.go,pay.go" {
+		t.Fatalf("included=%v", got)
+	}
+
+	if matched, ok := ContainsMatch(cfg.Slices["payments"], filepath.Join(dir, "other.go")); !ok || matched {
+		t.Fatalf("ContainsMatch(other.go)=%t,%t", matched, ok)
+	}
+}
+
+func TestSelectPrimaryTiebreak(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Slices = map[string]config.SliceConfig{
+		"api":  {Include: []string{"**/*.go"}, Priority: 10},
+		"core": {Include: []string{"core/**"}, Priority: 10},
+		"misc": {Include: []string{"**"}, Priority: 10},
+	}
+	cfg.Profiles = map[string]config.Profile{"p": {Enable: []string{"core", "api"}}}
+	discovered := []discovery.PathInfo{{RelPath: "core/a.go"}}
+	mods := []Modifier{{Name: "misc", Enable: true}, {Name: "api", Enable: false}}
+	enabled, err := EnabledSlices(cfg, "p", mods)
+	if err != nil {
+		t.Fatalf("EnabledSlices: %v", err)
+	}
+	if got := strings.Join(EnableOrder(cfg, "p", mods), ","); got != "core,misc" {
+		t.Fatalf("EnableOrder=%s", got)
+	}
+
+	// misc was enabled after core, so first_enabled keeps core; reversing the order flips it.
+	for _, tc := range []struct {
+		policy string
+		order  []string
+		want   string
+	}{
+		{"", []string{"misc", "core"}, "core"},
+		{TiebreakName, []string{"misc", "core"}, "core"},
+		{TiebreakFirstEnabled, EnableOrder(cfg, "p", mods), "core"},
+		{TiebreakFirstEnabled, []string{"misc", "core"}, "misc"},
+	} {
+		cfg.Selector.PrimaryTiebreak, cfg.Selector.EnableOrder = tc.policy, tc.order
+		selected, err := Select(cfg, enabled, discovered, false)
+		if err != nil {
+			t.Fatalf("Select: %v", err)
+		}
+		if got := selected.Included[0].PrimarySlice; got != tc.want {
+			t.Fatalf("policy %q order %v primary=%s want %s", tc.policy, tc.order, got, tc.want)
+		}
+	}
+}
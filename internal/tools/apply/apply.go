@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/mmrzaf/snip/internal/util"
@@ -31,6 +33,9 @@ type Error struct {
 func (e *Error) Error() string { return e.Err.Error() }
 func (e *Error) Unwrap() error { return e.Err }
 
+// ErrNoBlocks is wrapped by the KindInvalidInput error returned when input has no file blocks.
+var ErrNoBlocks = errors.New("no file blocks detected")
+
 func invalidf(format string, args ...any) error {
 	return &Error{Kind: KindInvalidInput, Err: fmt.Errorf(format, args...)}
 }
@@ -50,18 +55,32 @@ func IsKind(err error, kind Kind) bool {
 
 // Options configures parsing + apply behavior.
 type Options struct {
-	Root       string
-	FileHeader string // Required. Must contain exactly one {path} token.
-	Write      bool   // Default false (dry-run).
-	Force      bool   // Default false (no overwrite).
+	Root            string
+	FileHeader      string // Must contain exactly one {path} token. Mutually exclusive with FileHeaderRegex.
+	FileHeaderRegex string // Regex with a named capture group "path". Mutually exclusive with FileHeader.
+	Auto            bool   // Detect the header from a snip bundle (see DetectHeader). Excludes both of the above.
+	Patch           bool   // Input is a unified diff (see ParsePatch) instead of file blocks.
+	Write           bool   // Default false (dry-run).
+	Force           bool   // Default false (no overwrite).
+	Backup          bool   // Copy each overwritten or deleted file to <path>.snip-bak before writing.
 }
 
 // Block is one parsed file payload.
 type Block struct {
 	Path    string // as declared in input (trimmed)
 	Content []byte // exact bytes between opening and closing fence after newline normalization
+	// Mode is the permission set by a "mode: 0755" line between the header and the
+	// fence; zero means none was given.
+	Mode os.FileMode
 }
 
+// DefaultFileMode is the permission of files created without an explicit mode.
+const DefaultFileMode os.FileMode = 0o644
+
+// DeleteMarker is the block content (surrounding whitespace ignored) that asks apply
+// to delete the target file instead of writing it.
+const DeleteMarker = "__DELETE__"
+
 // PlannedFile is a validated filesystem operation.
 type PlannedFile struct {
 	RelPath   string
@@ -69,27 +88,78 @@ type PlannedFile struct {
 	Content   []byte
 	Exists    bool
 	Overwrite bool
+	// Mode is the permission the file is written with: the block's explicit mode, else
+	// the existing file's mode, else DefaultFileMode.
+	Mode os.FileMode
+	// Delete marks a block whose content is DeleteMarker. Deleting a file that
+	// does not exist (Exists false) is a no-op.
+	Delete bool
+	// BackupPath is the root-relative path the previous content was copied to
+	// (set only when Options.Backup is on and the file was overwritten or deleted).
+	BackupPath string
 }
 
 // Result is the parsed + validated plan, with optional writes applied.
 type Result struct {
-	Files  []PlannedFile
-	Wrote  int
-	DryRun bool
+	Files   []PlannedFile
+	Wrote   int
+	Deleted int
+	DryRun  bool
+	// SkippedHeaders counts header lines that were ignored because they sat inside
+	// an unrelated code fence (see ParseStats).
+	SkippedHeaders int
 }
 
-// Run reads an input file (or stdin when inputPath == "-"), parses file/code blocks, validates paths
-// against root, and optionally writes them.
+// ParseStats describes what the parser saw besides the returned blocks.
+type ParseStats struct {
+	// SkippedHeaders counts header matches inside fences that do not belong to a
+	// file block; they are treated as content and never applied.
+	SkippedHeaders int
+}
+
+// Run reads an input file (or stdin when inputPath == "-"), parses file/code blocks (or a unified
+// diff when opts.Patch is set), validates paths against root, and optionally writes them.
 func Run(inputPath string, opts Options) (Result, error) {
 	text, err := readInput(inputPath)
 	if err != nil {
 		return Result{}, err
 	}
-	blocks, err := Parse(text, opts.FileHeader)
+	if opts.Patch {
+		if opts.FileHeader != "" || opts.FileHeaderRegex != "" || opts.Auto {
+			return Result{}, invalidf("patch mode cannot be combined with a file header template, regex or auto-detection")
+		}
+		patches, err := ParsePatch(text)
+		if err != nil {
+			return Result{}, err
+		}
+		return ApplyPatches(patches, opts)
+	}
+	var (
+		blocks []Block
+		stats  ParseStats
+	)
+	switch {
+	case opts.FileHeader != "" && opts.FileHeaderRegex != "":
+		return Result{}, invalidf("file header template and file header regex are mutually exclusive")
+	case opts.Auto && (opts.FileHeader != "" || opts.FileHeaderRegex != ""):
+		return Result{}, invalidf("auto-detection cannot be combined with a file header template or regex")
+	case opts.Auto:
+		if header, ok := DetectHeader(text); ok {
+			blocks, stats, err = ParseWithStats(text, header)
+		} else {
+			blocks, stats, err = ParseRegexWithStats(text, DefaultHeaderRegex)
+		}
+	case opts.FileHeaderRegex != "":
+		blocks, stats, err = ParseRegexWithStats(text, opts.FileHeaderRegex)
+	default:
+		blocks, stats, err = ParseWithStats(text, opts.FileHeader)
+	}
 	if err != nil {
 		return Result{}, err
 	}
-	return Apply(blocks, opts)
+	res, err := Apply(blocks, opts)
+	res.SkippedHeaders = stats.SkippedHeaders
+	return res, err
 }
 
 // fenceInfo holds the parsed properties of an opening fence.
@@ -167,13 +237,43 @@ func countFenceChars(line string, char byte) int {
 // Parse extracts file blocks from markdown-like text using a header template such as
 // "===== FILE: {path} =====". It handles nested code fences correctly.
 func Parse(input string, fileHeader string) ([]Block, error) {
+	blocks, _, err := ParseWithStats(input, fileHeader)
+	return blocks, err
+}
+
+// ParseWithStats is Parse that also reports how many headers were skipped because
+// they appeared inside unrelated code fences.
+func ParseWithStats(input string, fileHeader string) ([]Block, ParseStats, error) {
 	hm, err := compileHeaderMatcher(fileHeader)
 	if err != nil {
-		return nil, err
+		return nil, ParseStats{}, err
+	}
+	return parseBlocks(input, hm)
+}
+
+// ParseRegex extracts file blocks like Parse, but matches header lines with a regular
+// expression such as `^// FILE \(\d+ of \d+\): (?P<path>.+)$`. The named capture
+// group "path" provides the file path.
+func ParseRegex(input string, pattern string) ([]Block, error) {
+	blocks, _, err := ParseRegexWithStats(input, pattern)
+	return blocks, err
+}
+
+// ParseRegexWithStats is ParseRegex that also reports skipped headers (see ParseWithStats).
+func ParseRegexWithStats(input string, pattern string) ([]Block, ParseStats, error) {
+	hm, err := compileHeaderRegex(pattern)
+	if err != nil {
+		return nil, ParseStats{}, err
 	}
+	return parseBlocks(input, hm)
+}
 
+func parseBlocks(input string, hm headerMatcher) ([]Block, ParseStats, error) {
 	src := util.NormalizeNewlines(input)
-	var blocks []Block
+	var (
+		blocks []Block
+		stats  ParseStats
+	)
 	seen := make(map[string]int)
 
 	// Track whether we are inside a *non-apply* fenced block.
@@ -197,6 +297,8 @@ func Parse(input string, fileHeader string) ([]Block, error) {
 			if isFenceClose(line, fenceInfoCurrent) {
 				inFence = false
 				fenceInfoCurrent = fenceInfo{}
+			} else if _, matched := hm.match(line); matched {
+				stats.SkippedHeaders++
 			}
 			continue
 		}
@@ -217,10 +319,10 @@ func Parse(input string, fileHeader string) ([]Block, error) {
 
 		path = strings.TrimSpace(path)
 		if path == "" {
-			return nil, invalidf("empty path in header at line %d", lineNo)
+			return nil, stats, invalidf("empty path in header at line %d", lineNo)
 		}
 		if prev, dup := seen[path]; dup {
-			return nil, invalidf("ambiguous duplicate file path %q (headers at lines %d and %d)", path, prev, lineNo)
+			return nil, stats, invalidf("ambiguous duplicate file path %q (headers at lines %d and %d)", path, prev, lineNo)
 		}
 		seen[path] = lineNo
 
@@ -232,6 +334,7 @@ func Parse(input string, fileHeader string) ([]Block, error) {
 			contentStart int
 		)
 
+		var mode os.FileMode
 		j := i
 		ln := lineNo
 		for {
@@ -243,7 +346,13 @@ func Parse(input string, fileHeader string) ([]Block, error) {
 
 			// A second header before the first fence is ambiguity => fail.
 			if _, m2 := hm.match(l2); m2 {
-				return nil, invalidf("header at line %d for %q has no code fence before next header at line %d", lineNo, path, ln)
+				return nil, stats, invalidf("header at line %d for %q has no code fence before next header at line %d", lineNo, path, ln)
+			}
+			if m, ok, err := parseModeLine(l2); ok {
+				if err != nil {
+					return nil, stats, invalidf("line %d for %q: %v", ln, path, err)
+				}
+				mode = m
 			}
 
 			if info, ok := parseFenceOpen(l2); ok {
@@ -257,7 +366,7 @@ func Parse(input string, fileHeader string) ([]Block, error) {
 			j = next2
 		}
 		if !foundOpen {
-			return nil, invalidf("header at line %d for %q has no code fence", lineNo, path)
+			return nil, stats, invalidf("header at line %d for %q has no code fence", lineNo, path)
 		}
 
 		// Use a stack to track nested fences.
@@ -303,13 +412,14 @@ func Parse(input string, fileHeader string) ([]Block, error) {
 		}
 
 		if closeLineNo == 0 {
-			return nil, invalidf("unclosed code fence for %q (header line %d, fence line %d)", path, lineNo, openLineNo)
+			return nil, stats, invalidf("unclosed code fence for %q (header line %d, fence line %d)", path, lineNo, openLineNo)
 		}
 
 		content := src[contentStart:contentEnd]
 		blocks = append(blocks, Block{
 			Path:    path,
 			Content: []byte(content),
+			Mode:    mode,
 		})
 
 		// Continue scanning after the closing fence.
@@ -318,9 +428,12 @@ func Parse(input string, fileHeader string) ([]Block, error) {
 	}
 
 	if len(blocks) == 0 {
-		return nil, invalidf("no file blocks detected")
+		if stats.SkippedHeaders > 0 {
+			return nil, stats, &Error{Kind: KindInvalidInput, Err: fmt.Errorf("%w (%d header(s) inside other code fences were ignored)", ErrNoBlocks, stats.SkippedHeaders)}
+		}
+		return nil, stats, &Error{Kind: KindInvalidInput, Err: ErrNoBlocks}
 	}
-	return blocks, nil
+	return blocks, stats, nil
 }
 
 // Apply validates paths, plans operations, and optionally writes files.
@@ -354,19 +467,65 @@ func Apply(blocks []Block, opts Options) (Result, error) {
 		if exists && st.IsDir() {
 			return Result{}, invalidf("target %q is a directory", rel)
 		}
+		if isDeleteMarker(b.Content) {
+			if exists && !opts.Force {
+				return Result{}, invalidf("deleting requires --force: %q", rel)
+			}
+			plan = append(plan, PlannedFile{RelPath: rel, AbsPath: abs, Exists: exists, Delete: true})
+			continue
+		}
 		if exists && !opts.Force {
 			return Result{}, invalidf("target exists (use --force): %q", rel)
 		}
 
+		mode := b.Mode
+		if mode == 0 {
+			mode = existingMode(st, exists)
+		}
 		plan = append(plan, PlannedFile{
 			RelPath:   rel,
 			AbsPath:   abs,
 			Content:   append([]byte(nil), b.Content...),
 			Exists:    exists,
 			Overwrite: exists && opts.Force,
+			Mode:      mode,
 		})
 	}
 
+	return execute(plan, opts)
+}
+
+// existingMode returns the permission bits of an existing target, or DefaultFileMode.
+func existingMode(st os.FileInfo, exists bool) os.FileMode {
+	if !exists {
+		return DefaultFileMode
+	}
+	return st.Mode().Perm()
+}
+
+// parseModeLine recognizes a "mode: 0755" annotation line (a 0o prefix is accepted). Lines
+// whose value is not numeric are ordinary prose; err is set for numbers that are not valid
+// octal permissions.
+func parseModeLine(line string) (mode os.FileMode, ok bool, err error) {
+	key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+	if !found || !strings.EqualFold(strings.TrimSpace(key), "mode") {
+		return 0, false, nil
+	}
+	value = strings.TrimSpace(value)
+	digits := strings.TrimPrefix(value, "0o")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, false, nil
+	}
+	n, perr := strconv.ParseUint(digits, 8, 32)
+	if perr != nil || n == 0 || n > 0o777 {
+		return 0, true, fmt.Errorf("invalid mode %q (want octal permissions such as 0755)", value)
+	}
+	return os.FileMode(n), true, nil
+}
+
+// execute returns the plan as a dry-run result, or backs up, writes and deletes its files when
+// opts.Write is set.
+func execute(plan []PlannedFile, opts Options) (Result, error) {
 	res := Result{
 		Files:  plan,
 		DryRun: !opts.Write,
@@ -375,8 +534,35 @@ func Apply(blocks []Block, opts Options) (Result, error) {
 		return res, nil
 	}
 
+	// Back everything up first, so a failed backup leaves every target untouched.
+	if opts.Backup {
+		for i, pf := range plan {
+			if !pf.Overwrite && !(pf.Delete && pf.Exists) {
+				continue
+			}
+			bak, err := backupFile(pf)
+			if err != nil {
+				return Result{}, err
+			}
+			res.Files[i].BackupPath = bak
+		}
+	}
 	for _, pf := range plan {
-		if err := util.AtomicWriteFile(pf.AbsPath, pf.Content, 0o644); err != nil {
+		if pf.Delete {
+			if !pf.Exists {
+				continue
+			}
+			if err := os.Remove(pf.AbsPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return Result{}, iof(err, "delete %s", pf.RelPath)
+			}
+			res.Deleted++
+			continue
+		}
+		mode := pf.Mode
+		if mode == 0 {
+			mode = DefaultFileMode
+		}
+		if err := util.AtomicWriteFile(pf.AbsPath, pf.Content, mode); err != nil {
 			return Result{}, iof(err, "write %s", pf.RelPath)
 		}
 		res.Wrote++
@@ -384,7 +570,45 @@ func Apply(blocks []Block, opts Options) (Result, error) {
 	return res, nil
 }
 
-type headerMatcher struct {
+func isDeleteMarker(content []byte) bool {
+	return strings.TrimSpace(string(content)) == DeleteMarker
+}
+
+// backupSuffix is appended to an overwritten file's path to name its backup.
+const backupSuffix = ".snip-bak"
+
+// backupFile copies pf's current content to <path>.snip-bak, or <path>.snip-bak.N when
+// earlier backups exist, keeping the file mode. It returns the backup's root-relative path.
+func backupFile(pf PlannedFile) (string, error) {
+	st, err := os.Stat(pf.AbsPath)
+	if err != nil {
+		return "", iof(err, "back up %s", pf.RelPath)
+	}
+	data, err := os.ReadFile(pf.AbsPath)
+	if err != nil {
+		return "", iof(err, "back up %s", pf.RelPath)
+	}
+	suffix := backupSuffix
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(pf.AbsPath + suffix); errors.Is(err, os.ErrNotExist) {
+			break
+		} else if err != nil {
+			return "", iof(err, "back up %s", pf.RelPath)
+		}
+		suffix = fmt.Sprintf("%s.%d", backupSuffix, n)
+	}
+	if err := util.AtomicWriteFile(pf.AbsPath+suffix, data, st.Mode().Perm()); err != nil {
+		return "", iof(err, "back up %s", pf.RelPath)
+	}
+	return pf.RelPath + suffix, nil
+}
+
+// headerMatcher recognizes file header lines and extracts the declared path.
+type headerMatcher interface {
+	match(line string) (string, bool)
+}
+
+type templateMatcher struct {
 	prefix string
 	suffix string
 }
@@ -393,19 +617,53 @@ func compileHeaderMatcher(tpl string) (headerMatcher, error) {
 	tpl = util.NormalizeNewlines(tpl)
 	tpl = strings.TrimSuffix(tpl, "\n")
 	if strings.TrimSpace(tpl) == "" {
-		return headerMatcher{}, invalidf("file header template is required (must contain {path})")
+		return nil, invalidf("file header template is required (must contain {path})")
 	}
 	if strings.Count(tpl, "{path}") != 1 {
-		return headerMatcher{}, invalidf("file header template must contain exactly one {path} token")
+		return nil, invalidf("file header template must contain exactly one {path} token")
+	}
+	if reHeaderField.MatchString(tpl) {
+		return fieldMatcher(tpl), nil
 	}
 	idx := strings.Index(tpl, "{path}")
-	return headerMatcher{
+	return templateMatcher{
 		prefix: tpl[:idx],
 		suffix: tpl[idx+len("{path}"):],
 	}, nil
 }
 
-func (m headerMatcher) match(line string) (string, bool) {
+// reHeaderField matches the descriptive tokens snip can render into a file header besides
+// {path} (render.file_block.header). A template using them matches any value in their place.
+var reHeaderField = regexp.MustCompile(`\{(index|lines|bytes|slice)\}`)
+
+// fieldMatcher compiles a header template with descriptive tokens into an anchored regex:
+// numeric tokens match digits, {slice} anything, and {path} the rest.
+func fieldMatcher(tpl string) headerMatcher {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, part := range strings.SplitAfter(tpl, "}") {
+		lit, tok := part, ""
+		if i := strings.LastIndex(part, "{"); i >= 0 {
+			lit, tok = part[:i], part[i:]
+		}
+		sb.WriteString(regexp.QuoteMeta(lit))
+		switch tok {
+		case "{path}":
+			sb.WriteString("(?P<path>.*?)")
+		case "{index}", "{lines}", "{bytes}":
+			sb.WriteString(`\d+`)
+		case "{slice}":
+			sb.WriteString(".*?")
+		default:
+			sb.WriteString(regexp.QuoteMeta(tok))
+		}
+	}
+	sb.WriteString("$")
+	re := regexp.MustCompile(sb.String())
+	return regexMatcher{re: re, group: re.SubexpIndex("path")}
+}
+
+func (m templateMatcher) match(line string) (string, bool) {
 	if !strings.HasPrefix(line, m.prefix) {
 		return "", false
 	}
@@ -415,6 +673,47 @@ func (m headerMatcher) match(line string) (string, bool) {
 	return line[len(m.prefix) : len(line)-len(m.suffix)], true
 }
 
+type regexMatcher struct {
+	re    *regexp.Regexp
+	group int
+}
+
+func compileHeaderRegex(pattern string) (headerMatcher, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, invalidf("file header regex is required (must contain a named group \"path\")")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, invalidf("invalid file header regex: %v", err)
+	}
+	paths := 0
+	for _, name := range re.SubexpNames() {
+		if name == "path" {
+			paths++
+		}
+	}
+	if paths == 0 {
+		return nil, invalidf("file header regex must contain a named group \"path\" (e.g. (?P<path>.+))")
+	}
+	if paths > 1 {
+		return nil, invalidf("file header regex must contain exactly one named group \"path\", found %d", paths)
+	}
+	group := re.SubexpIndex("path")
+	return regexMatcher{re: re, group: group}, nil
+}
+
+func (m regexMatcher) match(line string) (string, bool) {
+	sm := m.re.FindStringSubmatchIndex(line)
+	if sm == nil {
+		return "", false
+	}
+	start, end := sm[2*m.group], sm[2*m.group+1]
+	if start < 0 {
+		return "", true
+	}
+	return line[start:end], true
+}
+
 func readInput(path string) (string, error) {
 	if path == "" {
 		return "", invalidf("input file is required")
@@ -471,17 +770,14 @@ func resolveTarget(rootAbs string, declared string) (rel string, abs string, err
 	}
 
 	abs = filepath.Clean(filepath.Join(rootAbs, clean))
-	relCheck, relErr := filepath.Rel(rootAbs, abs)
-	if relErr != nil {
-		return "", "", iof(relErr, "rel path")
-	}
-	if relCheck == ".." || strings.HasPrefix(relCheck, ".."+string(filepath.Separator)) {
+	relCheck, ok := util.RelWithinRoot(rootAbs, abs)
+	if !ok {
 		return "", "", invalidf("path escapes root: %q", p)
 	}
 	if relCheck == "." {
 		return "", "", invalidf("invalid target path %q", p)
 	}
-	return filepath.ToSlash(relCheck), abs, nil
+	return relCheck, abs, nil
 }
 
 func readLine(s string, start int) (line string, next int, ok bool) {
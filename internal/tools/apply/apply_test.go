@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -195,6 +196,32 @@ func TestParse_HeaderInsideFencedBlockIgnored(t *testing.T) {
 	}
 }
 
+func TestParseWithStats_CountsHeadersInsideOtherFences(t *testing.T) {
+	input := "```text\n" +
+		"FILE: a.txt\n" +
+		"FILE: b.txt\n" +
+		"```\n\n" +
+		"FILE: real.txt\n" +
+		"```\n" +
+		"FILE: nested.txt\n" +
+		"```\n"
+	blocks, stats, err := ParseWithStats(input, "FILE: {path}")
+	if err != nil {
+		t.Fatalf("ParseWithStats failed: %v", err)
+	}
+	if len(blocks) != 1 || stats.SkippedHeaders != 2 {
+		t.Fatalf("blocks=%d skipped=%d, want 1 and 2", len(blocks), stats.SkippedHeaders)
+	}
+
+	_, stats, err = ParseWithStats("```\nFILE: a.txt\n```\n", "FILE: {path}")
+	if !errors.Is(err, ErrNoBlocks) || stats.SkippedHeaders != 1 {
+		t.Fatalf("err=%v skipped=%d", err, stats.SkippedHeaders)
+	}
+	if !strings.Contains(err.Error(), "1 header(s) inside other code fences") {
+		t.Fatalf("error should mention skipped headers: %v", err)
+	}
+}
+
 func TestParse_EmptyPathError(t *testing.T) {
 	input := "FILE: \n" +
 		"```\n" +
@@ -285,6 +312,94 @@ func TestParse_InvalidHeaderTemplate(t *testing.T) {
 	}
 }
 
+func TestParseRegex_NumberedHeaders(t *testing.T) {
+	input := "// FILE (1 of 2): a/b.go\n" +
+		"```go\npackage a\n```\n" +
+		"Notes between files.\n" +
+		"// FILE (2 of 2): c.txt\n" +
+		"```\nhello\n```\n"
+	blocks, err := ParseRegex(input, `^// FILE \(\d+ of \d+\): (?P<path>.+)$`)
+	if err != nil {
+		t.Fatalf("ParseRegex failed: %v", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(blocks))
+	}
+	if blocks[0].Path != "a/b.go" || blocks[1].Path != "c.txt" {
+		t.Errorf("paths = %q, %q", blocks[0].Path, blocks[1].Path)
+	}
+	if string(blocks[0].Content) != "package a\n" {
+		t.Errorf("content = %q", string(blocks[0].Content))
+	}
+}
+
+func TestParseRegex_TrailingHeaderMetadata(t *testing.T) {
+	input := "### File: a.go (lines 1-40)\n```go\npackage a\n```\n" +
+		"```md\n### File: skipped.md (lines 1-2)\n```\n" +
+		"### File: dir/b.go (lines 3-9, truncated)\n```go\npackage b\n```\n"
+	blocks, err := ParseRegex(input, `^### File: (?P<path>\S+) \(lines [^)]*\)$`)
+	if err != nil {
+		t.Fatalf("ParseRegex: %v", err)
+	}
+	if len(blocks) != 2 || blocks[0].Path != "a.go" || blocks[1].Path != "dir/b.go" {
+		t.Fatalf("blocks = %+v", blocks)
+	}
+	if string(blocks[1].Content) != "package b\n" {
+		t.Errorf("content = %q", blocks[1].Content)
+	}
+}
+
+func TestParse_HeaderFieldTokens(t *testing.T) {
+	input := "<<<FILE 1:a b.go lines=2 slice=api>>>\n```go\npackage a\n```\n" +
+		"<<<FILE 2:dir/c.go lines=12 slice=>>>\n```go\npackage c\n```\n"
+	blocks, err := Parse(input, "<<<FILE {index}:{path} lines={lines} slice={slice}>>>")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(blocks) != 2 || blocks[0].Path != "a b.go" || blocks[1].Path != "dir/c.go" {
+		t.Fatalf("blocks = %+v", blocks)
+	}
+	if string(blocks[1].Content) != "package c\n" {
+		t.Errorf("content = %q", blocks[1].Content)
+	}
+}
+
+func TestParseRegex_DuplicatePathError(t *testing.T) {
+	input := "// FILE (1 of 2): dup.txt\n```\nfirst\n```\n" +
+		"// FILE (2 of 2): dup.txt\n```\nsecond\n```\n"
+	_, err := ParseRegex(input, `^// FILE \(\d+ of \d+\): (?P<path>.+)$`)
+	if err == nil || !strings.Contains(err.Error(), "ambiguous duplicate") {
+		t.Fatalf("expected duplicate path error, got %v", err)
+	}
+}
+
+func TestParseRegex_InvalidPattern(t *testing.T) {
+	if _, err := ParseRegex("", ""); err == nil {
+		t.Fatal("expected error for empty regex")
+	}
+	if _, err := ParseRegex("", `^FILE: (.+)$`); err == nil || !IsKind(err, KindInvalidInput) {
+		t.Fatalf("expected invalid input error for missing path group, got %v", err)
+	}
+	if _, err := ParseRegex("", `^FILE: (?P<path>.+$`); err == nil {
+		t.Fatal("expected error for malformed regex")
+	}
+	if _, err := ParseRegex("", `^(?:A: (?P<path>\S+)|B: (?P<path>\S+))$`); err == nil || !strings.Contains(err.Error(), "exactly one") {
+		t.Fatalf("expected error for duplicate path groups, got %v", err)
+	}
+}
+
+func TestRun_HeaderModesMutuallyExclusive(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "ai.txt")
+	if err := os.WriteFile(in, []byte("FILE: a.txt\n```\na\n```\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Run(in, Options{Root: dir, FileHeader: "FILE: {path}", FileHeaderRegex: `^FILE: (?P<path>.+)$`})
+	if err == nil || !IsKind(err, KindInvalidInput) {
+		t.Fatalf("expected invalid input error, got %v", err)
+	}
+}
+
 func TestApply_PlanAndDryRun(t *testing.T) {
 	dir := t.TempDir()
 	blocks := []Block{
@@ -490,3 +605,291 @@ func TestIsKind(t *testing.T) {
 		t.Error("plain error should not match")
 	}
 }
+
+func TestDiff_OverwriteShowsUnifiedHunks(t *testing.T) {
+	dir := t.TempDir()
+	var old strings.Builder
+	for i := 1; i <= 12; i++ {
+		old.WriteString("line" + string(rune('a'+i-1)) + "\n")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(old.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	updated := strings.Replace(old.String(), "lineb\n", "lineB\n", 1)
+	updated = strings.Replace(updated, "linek\n", "", 1) + "tail"
+
+	res, err := Apply([]Block{{Path: "a.txt", Content: []byte(updated)}}, Options{Root: dir, FileHeader: "ignore", Force: true})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	got, err := Diff(res.Files[0])
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := "--- a/a.txt\n+++ b/a.txt\n" +
+		"@@ -1,5 +1,5 @@\n linea\n-lineb\n+lineB\n linec\n lined\n linee\n" +
+		"@@ -8,5 +8,5 @@\n lineh\n linei\n linej\n-linek\n linel\n+tail\n\\ No newline at end of file\n"
+	if got != want {
+		t.Errorf("Diff =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDiff_NewIdenticalAndBinaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "same.txt"), []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "img.bin"), []byte{0x00, 0x01, 0x02}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Apply([]Block{
+		{Path: "new.txt", Content: []byte("a\nb\n")},
+		{Path: "same.txt", Content: []byte("x\n")},
+		{Path: "img.bin", Content: []byte("text now\n")},
+	}, Options{Root: dir, FileHeader: "ignore", Force: true})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := []string{
+		"--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		"",
+		"Binary files a/img.bin and b/img.bin differ\n",
+	}
+	for i, pf := range res.Files {
+		got, err := Diff(pf)
+		if err != nil {
+			t.Fatalf("Diff(%s): %v", pf.RelPath, err)
+		}
+		if got != want[i] {
+			t.Errorf("Diff(%s) = %q, want %q", pf.RelPath, got, want[i])
+		}
+	}
+}
+
+func TestApply_BackupBeforeOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(target, []byte("v1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Root: dir, FileHeader: "ignore", Write: true, Force: true, Backup: true}
+
+	for i, want := range []string{"a.txt.snip-bak", "a.txt.snip-bak.1"} {
+		content := []byte("v" + string(rune('2'+i)) + "\n")
+		res, err := Apply([]Block{{Path: "a.txt", Content: content}}, opts)
+		if err != nil {
+			t.Fatalf("Apply #%d: %v", i+1, err)
+		}
+		if got := res.Files[0].BackupPath; got != want {
+			t.Errorf("BackupPath #%d = %q, want %q", i+1, got, want)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"a.txt":            "v3\n",
+		"a.txt.snip-bak":   "v1\n",
+		"a.txt.snip-bak.1": "v2\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = (%q, %v), want %q", path, got, err, want)
+		}
+	}
+	if st, err := os.Stat(filepath.Join(dir, "a.txt.snip-bak")); err != nil || st.Mode().Perm() != 0o600 {
+		t.Errorf("backup mode = %v, %v", st, err)
+	}
+}
+
+func TestRun_AutoDetectsSnipBundleHeaders(t *testing.T) {
+	dir := t.TempDir()
+	custom := "# snip bundle\n\nprofile: api\n\n## Manifest (included)\n\n" +
+		"delimiter_header: \"<<<FILE:{path}>>>\"\n" +
+		"  1  a.go  lines=1 slices=[api]\n\n## Manifest (dropped)\n\n(none)\n\n" +
+		"<<<FILE:a.go>>>\nlines: 1\nbytes: 10\nslices: [api]\ntruncated: false\n\n```go\npackage a\n```\n"
+	plain := "# snip bundle\n\nprofile: api\n\n---\n\n## 1) b/b.go\nlines: 1\n\n```go\npackage b\n```\n"
+
+	for name, tc := range map[string]struct {
+		input string
+		path  string
+		body  string
+	}{
+		"custom delimiter": {custom, "a.go", "package a\n"},
+		"default headers":  {plain, "b/b.go", "package b\n"},
+	} {
+		in := filepath.Join(dir, strings.ReplaceAll(name, " ", "_")+".md")
+		if err := os.WriteFile(in, []byte(tc.input), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		res, err := Run(in, Options{Root: dir, Auto: true})
+		if err != nil {
+			t.Fatalf("%s: Run: %v", name, err)
+		}
+		if len(res.Files) != 1 || res.Files[0].RelPath != tc.path || string(res.Files[0].Content) != tc.body {
+			t.Errorf("%s: files = %+v", name, res.Files)
+		}
+	}
+
+	if _, err := Run(filepath.Join(dir, "custom_delimiter.md"), Options{Root: dir, Auto: true, FileHeader: "x {path}"}); !IsKind(err, KindInvalidInput) {
+		t.Errorf("expected invalid input for --auto with a header, got %v", err)
+	}
+}
+
+func TestApply_DeleteMarker(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "old.txt")
+	if err := os.WriteFile(target, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	blocks := []Block{
+		{Path: "old.txt", Content: []byte("__DELETE__\n")},
+		{Path: "missing.txt", Content: []byte("  __DELETE__  \n")},
+	}
+
+	if _, err := Apply(blocks, Options{Root: dir, Write: true}); !IsKind(err, KindInvalidInput) {
+		t.Fatalf("expected --force to be required for deletion, got %v", err)
+	}
+
+	res, err := Apply(blocks, Options{Root: dir, Force: true})
+	if err != nil {
+		t.Fatalf("dry-run: %v", err)
+	}
+	if !res.Files[0].Delete || !res.Files[0].Exists || !res.Files[1].Delete || res.Files[1].Exists {
+		t.Errorf("unexpected plan: %+v", res.Files)
+	}
+	diff, err := Diff(res.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-old\n"; diff != want {
+		t.Errorf("diff = %q, want %q", diff, want)
+	}
+
+	res, err = Apply(blocks, Options{Root: dir, Write: true, Force: true})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if res.Deleted != 1 || res.Wrote != 0 {
+		t.Errorf("Deleted = %d, Wrote = %d, want 1, 0", res.Deleted, res.Wrote)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("old.txt still exists: %v", err)
+	}
+}
+
+func TestRun_PatchAppliesUnifiedDiff(t *testing.T) {
+	dir := t.TempDir()
+	mustWrite := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two extra lines at the top shift the hunk from its declared position.
+	mustWrite("a.go", "// x\n// y\npackage a\n\nfunc A() int {\n\treturn 1\n}\n")
+	mustWrite("gone.txt", "bye\n")
+
+	patch := "Here is the change:\n\n```diff\n" +
+		"diff --git a/a.go b/a.go\nindex 1111111..2222222 100644\n--- a/a.go\n+++ b/a.go\n" +
+		"@@ -1,5 +1,5 @@\n package a\n \n func A() int {\n-\treturn 1\n+\treturn 2\n }\n" +
+		"diff --git a/new.txt b/new.txt\nnew file mode 100644\n--- /dev/null\n+++ b/new.txt\n" +
+		"@@ -0,0 +1 @@\n+hello\n\\ No newline at end of file\n" +
+		"diff --git a/gone.txt b/gone.txt\ndeleted file mode 100644\n--- a/gone.txt\n+++ /dev/null\n" +
+		"@@ -1 +0,0 @@\n-bye\n```\n"
+	in := filepath.Join(dir, "change.diff")
+	if err := os.WriteFile(in, []byte(patch), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Run(in, Options{Root: dir, Patch: true, Write: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Wrote != 2 || res.Deleted != 1 {
+		t.Errorf("Wrote = %d, Deleted = %d, want 2, 1", res.Wrote, res.Deleted)
+	}
+	for name, want := range map[string]string{
+		"a.go":    "// x\n// y\npackage a\n\nfunc A() int {\n\treturn 2\n}\n",
+		"new.txt": "hello",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.txt")); !os.IsNotExist(err) {
+		t.Errorf("gone.txt still exists: %v", err)
+	}
+
+	// The same patch no longer applies: a.go changed and new.txt exists.
+	if _, err := Run(in, Options{Root: dir, Patch: true}); !IsKind(err, KindInvalidInput) {
+		t.Errorf("expected invalid input when re-applying, got %v", err)
+	}
+}
+
+func TestApplyPatches_RejectsMismatchAndEscapes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, patch := range map[string]string{
+		"mismatch": "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-three\n+four\n",
+		"escape":   "--- a/../x.txt\n+++ b/../x.txt\n@@ -1 +1 @@\n-x\n+y\n",
+		"rename":   "diff --git a/a.txt b/b.txt\nsimilarity index 100%\nrename from a.txt\nrename to b.txt\n",
+		"missing":  "--- a/nope.txt\n+++ b/nope.txt\n@@ -1 +1 @@\n-x\n+y\n",
+	} {
+		patches, err := ParsePatch(patch)
+		if err == nil {
+			_, err = ApplyPatches(patches, Options{Root: dir, Write: true})
+		}
+		if !IsKind(err, KindInvalidInput) {
+			t.Errorf("%s: expected invalid input, got %v", name, err)
+		}
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "one\ntwo\n" {
+		t.Errorf("a.txt modified: %q", got)
+	}
+}
+
+func TestApply_FileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not preserved on windows")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret.env"), []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	input := "FILE: run.sh\nmode: 0755\n```sh\necho hi\n```\n" +
+		"FILE: secret.env\n```\nnew\n```\n" +
+		"FILE: notes.txt\nMode: draft\n```\nx\n```\n"
+	blocks, err := Parse(input, "FILE: {path}")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if blocks[0].Mode != 0o755 || blocks[1].Mode != 0 || blocks[2].Mode != 0 {
+		t.Fatalf("modes=%o,%o,%o", blocks[0].Mode, blocks[1].Mode, blocks[2].Mode)
+	}
+	if _, err := Apply(blocks, Options{Root: dir, Write: true, Force: true}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for rel, want := range map[string]os.FileMode{"run.sh": 0o755, "secret.env": 0o600, "notes.txt": DefaultFileMode} {
+		st, err := os.Stat(filepath.Join(dir, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Mode().Perm() != want {
+			t.Fatalf("%s mode=%o want %o", rel, st.Mode().Perm(), want)
+		}
+	}
+
+	if _, err := Parse("FILE: a\nmode: 0999\n```\nx\n```\n", "FILE: {path}"); err == nil || !IsKind(err, KindInvalidInput) {
+		t.Fatalf("expected invalid mode error, got %v", err)
+	}
+}
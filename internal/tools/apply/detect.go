@@ -0,0 +1,40 @@
+package apply
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// DefaultHeaderRegex matches snip's built-in "## 3) path" file headers, used by
+// bundles rendered without a custom render.file_block.header.
+const DefaultHeaderRegex = `^## \d+\) (?P<path>.+)$`
+
+// DetectHeader reads the `delimiter_header: "..."` line that snip writes into a
+// bundle's "## Manifest (included)" section. It reports false when the input
+// declares no delimiter (or the line cannot be unquoted), in which case the
+// bundle uses DefaultHeaderRegex.
+func DetectHeader(input string) (string, bool) {
+	inManifest := false
+	for _, line := range strings.Split(util.NormalizeNewlines(input), "\n") {
+		if strings.HasPrefix(line, "## ") {
+			if inManifest {
+				return "", false
+			}
+			inManifest = strings.TrimSpace(line) == "## Manifest (included)"
+			continue
+		}
+		if !inManifest {
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "delimiter_header: "); ok {
+			h, err := strconv.Unquote(v)
+			if err != nil || h == "" {
+				return "", false
+			}
+			return h, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,206 @@
+package apply
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// Diff returns a unified diff from the file currently at pf.AbsPath to pf.Content.
+// New files diff against /dev/null, as do deletions on the new side; unchanged files
+// and deletions of missing files yield "". If either side looks
+// binary, a one-line "Binary files ... differ" note replaces the diff.
+func Diff(pf PlannedFile) (string, error) {
+	var old []byte
+	if pf.Exists {
+		b, err := os.ReadFile(pf.AbsPath)
+		if err != nil {
+			return "", iof(err, "read %s", pf.RelPath)
+		}
+		old = b
+	}
+	if pf.Delete {
+		if !pf.Exists {
+			return "", nil
+		}
+		return unifiedDiff(pf.RelPath, old, nil, true, true), nil
+	}
+	return unifiedDiff(pf.RelPath, old, pf.Content, pf.Exists, false), nil
+}
+
+func unifiedDiff(rel string, old, new []byte, exists, deleted bool) string {
+	if exists && !deleted && bytes.Equal(old, new) {
+		return ""
+	}
+	oldName, newName := "a/"+rel, "b/"+rel
+	if !exists {
+		oldName = "/dev/null"
+	}
+	if deleted {
+		newName = "/dev/null"
+	}
+	if util.SniffBinary(old) || util.SniffBinary(new) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	}
+
+	edits := diffLines(splitLines(string(old)), splitLines(string(new)))
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks(edits, diffContext) {
+		writeHunk(&b, edits[h[0]:h[1]])
+	}
+	return b.String()
+}
+
+// splitLines splits s into lines that keep their "\n"; a final unterminated line is kept as is.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// edit is one line of a line diff: op is ' ' (kept), '-' (removed) or '+' (added).
+// oldLine and newLine are the 0-based positions in each side before this edit.
+type edit struct {
+	op      byte
+	text    string
+	oldLine int
+	newLine int
+}
+
+// diffLines computes a shortest edit script from a to b (Myers' O(ND) algorithm).
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	maxD := n + m
+	off := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+search:
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			x := v[off+k-1] + 1
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards from (n, m), emitting edits in reverse.
+	var rev []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, edit{op: ' ', text: a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, edit{op: '+', text: b[y]})
+		} else {
+			x--
+			rev = append(rev, edit{op: '-', text: a[x]})
+		}
+	}
+
+	out := make([]edit, len(rev))
+	oldLine, newLine := 0, 0
+	for i := range rev {
+		e := rev[len(rev)-1-i]
+		e.oldLine, e.newLine = oldLine, newLine
+		if e.op != '+' {
+			oldLine++
+		}
+		if e.op != '-' {
+			newLine++
+		}
+		out[i] = e
+	}
+	return out
+}
+
+// hunks groups changed edits with ctx lines of context, merging groups whose
+// context would overlap. It returns [start, end) index pairs into edits.
+func hunks(edits []edit, ctx int) [][2]int {
+	var out [][2]int
+	for i := 0; i < len(edits); i++ {
+		if edits[i].op == ' ' {
+			continue
+		}
+		start := max(0, i-ctx)
+		end := i + 1
+		for j := i + 1; j < len(edits) && j <= end+2*ctx; j++ {
+			if edits[j].op != ' ' {
+				end = j + 1
+			}
+		}
+		end = min(len(edits), end+ctx)
+		if n := len(out); n > 0 && start <= out[n-1][1] {
+			out[n-1][1] = end
+		} else {
+			out = append(out, [2]int{start, end})
+		}
+		i = end - 1
+	}
+	return out
+}
+
+func writeHunk(b *strings.Builder, edits []edit) {
+	oldCount, newCount := 0, 0
+	for _, e := range edits {
+		if e.op != '+' {
+			oldCount++
+		}
+		if e.op != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(edits[0].oldLine, oldCount), hunkRange(edits[0].newLine, newCount))
+	for _, e := range edits {
+		b.WriteByte(e.op)
+		b.WriteString(e.text)
+		if !strings.HasSuffix(e.text, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a unified-diff range from a 0-based start and a line count.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
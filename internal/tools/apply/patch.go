@@ -0,0 +1,330 @@
+package apply
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mmrzaf/snip/internal/util"
+)
+
+// devNull is the path unified diffs use for the missing side of a creation or deletion.
+const devNull = "/dev/null"
+
+// FilePatch is one file's section of a unified diff.
+type FilePatch struct {
+	OldPath string // "a/" prefix stripped; "/dev/null" for new files
+	NewPath string // "b/" prefix stripped; "/dev/null" for deleted files
+	Hunks   []PatchHunk
+}
+
+// PatchHunk is one "@@ -a,b +c,d @@" hunk. Lines keep their ' ', '-' or '+' prefix and their
+// trailing "\n", except a line followed by "\ No newline at end of file".
+type PatchHunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []string
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParsePatch extracts file patches from a unified diff such as `git diff` output. Text outside
+// file sections (prose, code fences around the diff) is ignored; binary patches are rejected.
+func ParsePatch(input string) ([]FilePatch, error) {
+	src := util.NormalizeNewlines(input)
+	var (
+		patches []FilePatch
+		cur     *FilePatch
+	)
+	i, lineNo := 0, 0
+	for {
+		line, next, ok := readLine(src, i)
+		if !ok {
+			break
+		}
+		i = next
+		lineNo++
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			patches = append(patches, FilePatch{})
+			cur = &patches[len(patches)-1]
+			if a, b, ok := gitDiffPaths(line); ok {
+				cur.OldPath, cur.NewPath = a, b
+			}
+		case cur != nil && strings.HasPrefix(line, "new file mode "):
+			cur.OldPath = devNull
+		case cur != nil && strings.HasPrefix(line, "deleted file mode "):
+			cur.NewPath = devNull
+		case cur != nil && strings.HasPrefix(line, "rename from "):
+			cur.OldPath = strings.TrimPrefix(line, "rename from ")
+		case cur != nil && strings.HasPrefix(line, "rename to "):
+			cur.NewPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "--- "):
+			nl, next2, ok := readLine(src, i)
+			if !ok || !strings.HasPrefix(nl, "+++ ") {
+				continue
+			}
+			if cur == nil || len(cur.Hunks) > 0 {
+				patches = append(patches, FilePatch{})
+				cur = &patches[len(patches)-1]
+			}
+			cur.OldPath = patchPath(line[len("--- "):], "a/")
+			cur.NewPath = patchPath(nl[len("+++ "):], "b/")
+			i = next2
+			lineNo++
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			return nil, invalidf("binary patches are not supported (line %d)", lineNo)
+		case strings.HasPrefix(line, "@@ "):
+			if cur == nil || cur.NewPath == "" {
+				return nil, invalidf("hunk at line %d has no file header", lineNo)
+			}
+			h, next2, lines, err := parseHunk(src, line, i, lineNo)
+			if err != nil {
+				return nil, err
+			}
+			cur.Hunks = append(cur.Hunks, h)
+			i = next2
+			lineNo += lines
+		}
+	}
+
+	out := patches[:0]
+	for _, p := range patches {
+		// Mode-only sections carry no content change; renames are kept so they can be rejected.
+		if len(p.Hunks) > 0 || p.OldPath != p.NewPath {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return nil, &Error{Kind: KindInvalidInput, Err: ErrNoBlocks}
+	}
+	return out, nil
+}
+
+// gitDiffPaths splits "diff --git a/x b/y"; it only handles paths without spaces, which is
+// enough as a fallback for sections that lack ---/+++ lines (e.g. empty new files).
+func gitDiffPaths(line string) (string, string, bool) {
+	fields := strings.Fields(strings.TrimPrefix(line, "diff --git "))
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return patchPath(fields[0], "a/"), patchPath(fields[1], "b/"), true
+}
+
+// patchPath strips a "\t<timestamp>" suffix and the a/ or b/ prefix from a ---/+++ path.
+func patchPath(p, prefix string) string {
+	if tab := strings.IndexByte(p, '\t'); tab >= 0 {
+		p = p[:tab]
+	}
+	p = strings.TrimSpace(p)
+	if p == devNull {
+		return p
+	}
+	return strings.TrimPrefix(p, prefix)
+}
+
+// parseHunk reads the body of the hunk whose header is header, starting at src[start:]. It returns
+// the position after the hunk and the number of lines consumed.
+func parseHunk(src, header string, start, headerLine int) (PatchHunk, int, int, error) {
+	m := hunkHeaderRe.FindStringSubmatch(header)
+	if m == nil {
+		return PatchHunk{}, 0, 0, invalidf("malformed hunk header at line %d: %q", headerLine, header)
+	}
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	h := PatchHunk{}
+	h.OldStart, _ = strconv.Atoi(m[1])
+	h.OldLines = count(m[2])
+	h.NewStart, _ = strconv.Atoi(m[3])
+	h.NewLines = count(m[4])
+
+	oldLeft, newLeft := h.OldLines, h.NewLines
+	i, lines := start, 0
+	for oldLeft > 0 || newLeft > 0 {
+		line, next, ok := readLine(src, i)
+		if !ok {
+			return PatchHunk{}, 0, 0, invalidf("truncated hunk at line %d", headerLine)
+		}
+		i = next
+		lines++
+		if line == "" {
+			// Some tools strip the single space from empty context lines.
+			line = " "
+		}
+		switch line[0] {
+		case ' ':
+			oldLeft--
+			newLeft--
+		case '-':
+			oldLeft--
+		case '+':
+			newLeft--
+		case '\\':
+			markNoNewline(&h)
+			continue
+		default:
+			return PatchHunk{}, 0, 0, invalidf("unexpected line %d in hunk at line %d: %q", headerLine+lines, headerLine, line)
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return PatchHunk{}, 0, 0, invalidf("hunk at line %d has more lines than its header declares", headerLine)
+		}
+		h.Lines = append(h.Lines, line+"\n")
+	}
+	// A "\ No newline at end of file" marker may follow the last line.
+	if line, next, ok := readLine(src, i); ok && strings.HasPrefix(line, "\\") {
+		markNoNewline(&h)
+		i = next
+		lines++
+	}
+	return h, i, lines, nil
+}
+
+func markNoNewline(h *PatchHunk) {
+	if n := len(h.Lines); n > 0 {
+		h.Lines[n-1] = strings.TrimSuffix(h.Lines[n-1], "\n")
+	}
+}
+
+// ApplyPatches validates each patch's target against opts.Root, applies its hunks to the current
+// file content, and optionally writes the results. Patching an existing file does not need
+// opts.Force, since a hunk only applies when its context matches; creating a file that already
+// exists, deleting a missing file, renames, and hunks that do not match all fail.
+func ApplyPatches(patches []FilePatch, opts Options) (Result, error) {
+	if len(patches) == 0 {
+		return Result{}, invalidf("no file blocks detected")
+	}
+	root, err := effectiveRoot(opts.Root)
+	if err != nil {
+		return Result{}, err
+	}
+
+	plan := make([]PlannedFile, 0, len(patches))
+	seenRel := make(map[string]int)
+	for i, p := range patches {
+		create, remove := p.OldPath == devNull, p.NewPath == devNull
+		target := p.NewPath
+		if remove {
+			target = p.OldPath
+		}
+		if !create && !remove && p.OldPath != p.NewPath {
+			return Result{}, invalidf("renaming %q to %q is not supported", p.OldPath, p.NewPath)
+		}
+		rel, abs, err := resolveTarget(root, target)
+		if err != nil {
+			return Result{}, err
+		}
+		if first, ok := seenRel[rel]; ok {
+			return Result{}, invalidf("ambiguous duplicate target path %q (entries %d and %d)", rel, first+1, i+1)
+		}
+		seenRel[rel] = i
+
+		st, statErr := os.Stat(abs)
+		exists := statErr == nil
+		if statErr != nil && !errors.Is(statErr, os.ErrNotExist) {
+			return Result{}, iof(statErr, "stat %s", rel)
+		}
+		if exists && st.IsDir() {
+			return Result{}, invalidf("target %q is a directory", rel)
+		}
+		switch {
+		case create && exists:
+			return Result{}, invalidf("patch creates %q but it already exists", rel)
+		case !create && !exists:
+			return Result{}, invalidf("patch target does not exist: %q", rel)
+		}
+
+		var old []byte
+		if exists {
+			if old, err = os.ReadFile(abs); err != nil {
+				return Result{}, iof(err, "read %s", rel)
+			}
+		}
+		content, err := applyHunks(rel, string(old), p.Hunks)
+		if err != nil {
+			return Result{}, err
+		}
+		if remove {
+			if content != "" {
+				return Result{}, invalidf("patch deletes %q but does not remove all of its content", rel)
+			}
+			plan = append(plan, PlannedFile{RelPath: rel, AbsPath: abs, Exists: true, Delete: true})
+			continue
+		}
+		plan = append(plan, PlannedFile{
+			RelPath:   rel,
+			AbsPath:   abs,
+			Content:   []byte(content),
+			Exists:    exists,
+			Overwrite: exists,
+			Mode:      existingMode(st, exists),
+		})
+	}
+	return execute(plan, opts)
+}
+
+// applyHunks applies hunks in order to old. Each hunk is tried at its declared position first and
+// then at increasing distances from it (like patch(1) without fuzz), never overlapping the
+// previous hunk.
+func applyHunks(rel, old string, hunks []PatchHunk) (string, error) {
+	lines := splitLines(old)
+	var out []string
+	pos := 0
+	for i, h := range hunks {
+		var want, repl []string
+		for _, l := range h.Lines {
+			if l[0] != '+' {
+				want = append(want, l[1:])
+			}
+			if l[0] != '-' {
+				repl = append(repl, l[1:])
+			}
+		}
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			// Pure insertions name the line they follow.
+			start = h.OldStart
+		}
+		at, ok := findHunk(lines, want, start, pos)
+		if !ok {
+			return "", invalidf("hunk %d of %q does not apply (expected at line %d)", i+1, rel, h.OldStart)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, repl...)
+		pos = at + len(want)
+	}
+	out = append(out, lines[pos:]...)
+	return strings.Join(out, ""), nil
+}
+
+// findHunk returns the index at or after floor where want matches lines, closest to start.
+func findHunk(lines, want []string, start, floor int) (int, bool) {
+	last := len(lines) - len(want)
+	matches := func(at int) bool {
+		if at < floor || at > last {
+			return false
+		}
+		for j, w := range want {
+			if lines[at+j] != w {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; start-d >= floor || start+d <= last; d++ {
+		if matches(start - d) {
+			return start - d, true
+		}
+		if d > 0 && matches(start+d) {
+			return start + d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// LockFileName is the advisory lock file LockDir creates in a directory while it is held.
+const LockFileName = "lock"
+
+// LockDir takes an exclusive advisory lock on dir/lock (creating dir and the file as
+// needed), blocking until no other process holds it. Call the returned function to
+// release it; releasing removes the file, so nothing is left behind in dir. Cooperating
+// snip processes use it to serialize counter and output.latest updates; it does not stop
+// other programs from writing.
+func LockDir(dir string) (unlock func(), err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir: %w", err)
+	}
+	path := filepath.Join(dir, LockFileName)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open lock: %w", err)
+		}
+		if err := lockFile(f); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		// The previous holder may have removed the file while we waited; then we hold a
+		// lock nobody else can see, so take the one on the current file instead.
+		if held, err := f.Stat(); err == nil {
+			if cur, err := os.Stat(path); err == nil && os.SameFile(held, cur) {
+				return func() { releaseLock(f, path) }, nil
+			}
+		}
+		_ = unlockFile(f)
+		_ = f.Close()
+	}
+}
+
+// releaseLock removes the lock file and releases the lock. Elsewhere the file is removed
+// while still locked, so a waiter always sees it gone; Windows cannot remove an open file,
+// so it is removed after closing, which fails harmlessly if another process opened it.
+func releaseLock(f *os.File, path string) {
+	if runtime.GOOS != "windows" {
+		_ = os.Remove(path)
+	}
+	_ = unlockFile(f)
+	_ = f.Close()
+	if runtime.GOOS == "windows" {
+		_ = os.Remove(path)
+	}
+}
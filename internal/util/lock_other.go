@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package util
+
+import "os"
+
+// Platforms without flock or LockFileEx get no locking; LockDir still creates (and removes) the file.
+func lockFile(*os.File) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
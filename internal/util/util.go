@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"unicode/utf16"
 	"unicode/utf8"
 )
 
@@ -53,53 +54,149 @@ func NormalizeNewlines(s string) string {
 	return s
 }
 
-// LanguageFromPath returns a best-effort code fence language.
-func LanguageFromPath(p string) string {
-	ext := strings.ToLower(filepath.Ext(p))
-	switch ext {
-	case ".go":
-		return "go"
-	case ".js":
-		return "javascript"
-	case ".ts":
-		return "typescript"
-	case ".py":
-		return "python"
-	case ".rb":
-		return "ruby"
-	case ".java":
-		return "java"
-	case ".kt":
-		return "kotlin"
-	case ".rs":
-		return "rust"
-	case ".c":
-		return "c"
-	case ".h":
-		return "c"
-	case ".cpp", ".cc", ".cxx", ".hpp":
-		return "cpp"
-	case ".cs":
-		return "csharp"
-	case ".php":
-		return "php"
-	case ".sh":
-		return "bash"
-	case ".sql":
-		return "sql"
-	case ".yaml", ".yml":
-		return "yaml"
-	case ".json":
-		return "json"
-	case ".md":
-		return "md"
-	default:
-		return ""
+// builtinLanguages maps lowercase file extensions to code fence languages.
+var builtinLanguages = map[string]string{
+	".go":         "go",
+	".js":         "javascript",
+	".mjs":        "javascript",
+	".cjs":        "javascript",
+	".jsx":        "jsx",
+	".ts":         "typescript",
+	".mts":        "typescript",
+	".cts":        "typescript",
+	".tsx":        "tsx",
+	".vue":        "vue",
+	".svelte":     "svelte",
+	".py":         "python",
+	".rb":         "ruby",
+	".java":       "java",
+	".kt":         "kotlin",
+	".kts":        "kotlin",
+	".scala":      "scala",
+	".groovy":     "groovy",
+	".gradle":     "groovy",
+	".rs":         "rust",
+	".zig":        "zig",
+	".c":          "c",
+	".h":          "c",
+	".cpp":        "cpp",
+	".cc":         "cpp",
+	".cxx":        "cpp",
+	".hpp":        "cpp",
+	".hh":         "cpp",
+	".m":          "objectivec",
+	".mm":         "objectivec",
+	".swift":      "swift",
+	".cs":         "csharp",
+	".fs":         "fsharp",
+	".dart":       "dart",
+	".php":        "php",
+	".lua":        "lua",
+	".pl":         "perl",
+	".r":          "r",
+	".jl":         "julia",
+	".ex":         "elixir",
+	".exs":        "elixir",
+	".erl":        "erlang",
+	".hs":         "haskell",
+	".ml":         "ocaml",
+	".clj":        "clojure",
+	".nix":        "nix",
+	".sol":        "solidity",
+	".sh":         "bash",
+	".bash":       "bash",
+	".zsh":        "bash",
+	".fish":       "fish",
+	".ps1":        "powershell",
+	".sql":        "sql",
+	".graphql":    "graphql",
+	".gql":        "graphql",
+	".proto":      "protobuf",
+	".tf":         "hcl",
+	".tfvars":     "hcl",
+	".hcl":        "hcl",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".json":       "json",
+	".toml":       "toml",
+	".ini":        "ini",
+	".xml":        "xml",
+	".html":       "html",
+	".htm":        "html",
+	".css":        "css",
+	".scss":       "scss",
+	".less":       "less",
+	".md":         "md",
+	".markdown":   "md",
+	".mdx":        "mdx",
+	".rst":        "rst",
+	".tex":        "latex",
+	".diff":       "diff",
+	".patch":      "diff",
+	".mk":         "makefile",
+	".cmake":      "cmake",
+	".dockerfile": "dockerfile",
+}
+
+// builtinFileLanguages maps file names without a telling extension to fence languages.
+var builtinFileLanguages = map[string]string{
+	"Dockerfile":     "dockerfile",
+	"Containerfile":  "dockerfile",
+	"Makefile":       "makefile",
+	"GNUmakefile":    "makefile",
+	"CMakeLists.txt": "cmake",
+	"Jenkinsfile":    "groovy",
+}
+
+// LanguageFromPath returns a best-effort code fence language, or "" if unknown. custom
+// (render.languages) maps lowercase extensions, with or without the dot, to languages and takes
+// precedence over the built-in set.
+func LanguageFromPath(p string, custom map[string]string) string {
+	base := filepath.Base(p)
+	ext := strings.ToLower(filepath.Ext(base))
+	if ext != "" {
+		if lang, ok := custom[ext]; ok {
+			return lang
+		}
+		if lang, ok := custom[ext[1:]]; ok {
+			return lang
+		}
+	}
+	if lang, ok := builtinFileLanguages[base]; ok {
+		return lang
+	}
+	return builtinLanguages[ext]
+}
+
+// RelWithinRoot returns abs relative to rootAbs (slash-separated) and whether it
+// stays inside root. Both paths must be absolute and clean.
+func RelWithinRoot(rootAbs, abs string) (string, bool) {
+	rel, err := filepath.Rel(rootAbs, abs)
+	if err != nil {
+		return "", false
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
 	}
+	return filepath.ToSlash(rel), true
 }
 
+// DefaultBinarySniffRatio is the share of non-text bytes above which SniffBinary reports
+// a sample as binary.
+const DefaultBinarySniffRatio = 0.30
+
 // SniffBinary returns true if the byte sample appears binary.
 func SniffBinary(sample []byte) bool {
+	return SniffBinaryRatio(sample, DefaultBinarySniffRatio)
+}
+
+// SniffBinaryRatio is SniffBinary with a custom threshold: a sample containing NUL, or with
+// more than ratio of its bytes outside printable ASCII and common whitespace, is binary.
+// A ratio <= 0 means DefaultBinarySniffRatio.
+func SniffBinaryRatio(sample []byte, ratio float64) bool {
+	if ratio <= 0 {
+		ratio = DefaultBinarySniffRatio
+	}
 	if len(sample) == 0 {
 		return false
 	}
@@ -119,8 +216,7 @@ func SniffBinary(sample []byte) bool {
 		}
 		non++
 	}
-	ratio := float64(non) / float64(len(sample))
-	return ratio > 0.30
+	return float64(non)/float64(len(sample)) > ratio
 }
 
 func bytesContains(b []byte, v byte) bool {
@@ -149,8 +245,54 @@ func FeedUTF8(buf []byte) (bool, []byte) {
 	return true, nil
 }
 
+// Text encodings recognized by their byte order mark (see DetectBOM).
+const (
+	EncodingUTF8BOM = "utf-8-bom"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+)
+
+// DetectBOM returns the encoding announced by a byte order mark at the start of b and the
+// mark's length in bytes, or ("", 0) when b does not start with one.
+func DetectBOM(b []byte) (string, int) {
+	switch {
+	case len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF:
+		return EncodingUTF8BOM, 3
+	case len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE:
+		return EncodingUTF16LE, 2
+	case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+		return EncodingUTF16BE, 2
+	}
+	return "", 0
+}
+
+// DecodeUTF16 converts UTF-16 data (without its BOM) in the given encoding to UTF-8.
+// It reports false for an odd byte count; unpaired surrogates become U+FFFD.
+func DecodeUTF16(b []byte, enc string) (string, bool) {
+	if len(b)%2 != 0 {
+		return "", false
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if enc == EncodingUTF16BE {
+			units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			units[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	return string(utf16.Decode(units)), true
+}
+
 // AtomicWriteFile writes file content atomically by writing to a temp file and renaming.
 func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	return AtomicWriteFunc(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// AtomicWriteFunc is like AtomicWriteFile but streams content from write into the temp file.
+func AtomicWriteFunc(path string, perm os.FileMode, write func(io.Writer) error) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("mkdir %s: %w", dir, err)
@@ -161,7 +303,7 @@ func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
 	if err != nil {
 		return fmt.Errorf("open temp: %w", err)
 	}
-	_, werr := f.Write(data)
+	werr := write(f)
 	cerr := f.Close()
 	if werr != nil {
 		_ = os.Remove(tmp)
@@ -213,18 +355,9 @@ func NextCounter(dir string) (int, error) {
 		return 0, fmt.Errorf("mkdir: %w", err)
 	}
 	path := filepath.Join(dir, "counter")
-	cur := 0
-	if b, err := os.ReadFile(path); err == nil {
-		text := strings.TrimSpace(string(b))
-		if text != "" {
-			v, perr := strconv.Atoi(text)
-			if perr != nil {
-				return 0, fmt.Errorf("parse counter: %w", perr)
-			}
-			cur = v
-		}
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return 0, fmt.Errorf("read counter: %w", err)
+	cur, err := readCounter(path)
+	if err != nil {
+		return 0, err
 	}
 	next := cur + 1
 	if err := AtomicWriteFile(path, []byte(fmt.Sprintf("%d\n", next)), 0o644); err != nil {
@@ -232,3 +365,33 @@ func NextCounter(dir string) (int, error) {
 	}
 	return next, nil
 }
+
+// PeekCounter returns the value the next NextCounter call on dir would return, without
+// creating or changing anything.
+func PeekCounter(dir string) (int, error) {
+	cur, err := readCounter(filepath.Join(dir, "counter"))
+	if err != nil {
+		return 0, err
+	}
+	return cur + 1, nil
+}
+
+// readCounter reads the counter file at path; a missing or empty file is 0.
+func readCounter(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read counter: %w", err)
+	}
+	text := strings.TrimSpace(string(b))
+	if text == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("parse counter: %w", err)
+	}
+	return v, nil
+}
@@ -3,7 +3,10 @@ package util
 import (
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestApplyPatternTokens(t *testing.T) {
@@ -57,3 +60,65 @@ func TestNextCounter(t *testing.T) {
 		t.Fatalf("file=%q", string(b))
 	}
 }
+
+func TestLanguageFromPath(t *testing.T) {
+	t.Parallel()
+
+	custom := map[string]string{".tsx": "typescript", "vue": "html", ".md": ""}
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"main.go", "go"},
+		{"web/App.TSX", "typescript"},
+		{"web/App.vue", "html"},
+		{"README.md", ""},
+		{"infra/main.tf", "hcl"},
+		{"api/v1.proto", "protobuf"},
+		{"build/Dockerfile", "dockerfile"},
+		{"data.unknown", ""},
+		{"LICENSE", ""},
+	} {
+		if got := LanguageFromPath(tc.path, custom); got != tc.want {
+			t.Fatalf("LanguageFromPath(%q)=%q want %q", tc.path, got, tc.want)
+		}
+	}
+	if got := LanguageFromPath("web/App.tsx", nil); got != "tsx" {
+		t.Fatalf("built-in .tsx=%q", got)
+	}
+}
+
+func TestLockDirSerializesAndCleansUp(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var (
+		wg      sync.WaitGroup
+		holders atomic.Int32
+		overlap atomic.Bool
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := LockDir(dir)
+			if err != nil {
+				t.Errorf("LockDir: %v", err)
+				return
+			}
+			if holders.Add(1) > 1 {
+				overlap.Store(true)
+			}
+			time.Sleep(time.Millisecond)
+			holders.Add(-1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+	if overlap.Load() {
+		t.Fatal("two holders at once")
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFileName)); !os.IsNotExist(err) {
+		t.Fatalf("lock file left behind: %v", err)
+	}
+}
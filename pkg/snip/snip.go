@@ -0,0 +1,130 @@
+// Package snip is the embeddable API for building snip bundles from Go programs.
+//
+// It runs the same pipeline as the snip CLI (config, discovery, selection,
+// budgeting, rendering) but returns results instead of writing files.
+// Errors carry the CLI exit code via an ExitCode() int method.
+package snip
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/mmrzaf/snip/internal/app"
+	"github.com/mmrzaf/snip/internal/budget"
+)
+
+type (
+	// Plan is the final bundle plan after budget enforcement.
+	Plan = budget.Plan
+	// FileEntry is a file included in a Plan.
+	FileEntry = budget.FileEntry
+	// DroppedEntry is a file dropped from a Plan, with its reason.
+	DroppedEntry = budget.DroppedEntry
+	// Warning is a notable drop, such as a slice cut by the budget or an unreadable file.
+	Warning = app.Warning
+)
+
+// Options configures a bundle build. Zero values fall back to the config file.
+type Options struct {
+	ConfigPath     string // default .snip.yaml
+	RootOverride   string
+	Profile        string
+	Modifiers      []string // e.g. "+tests", "-docs"
+	MaxChars       int
+	MaxTokens      int
+	Format         string // md, ndjson, plain or html; default render.format
+	NoTree         bool
+	NoManifest     bool
+	TreeDepth      int
+	LineNumbers    bool
+	IncludeHidden  bool
+	FollowSymlinks bool
+	Logger         *slog.Logger
+	Now            func() time.Time
+}
+
+// Result is a built bundle.
+type Result struct {
+	Content string // the rendered bundle
+	Plan    Plan
+	// Partial is true when the bundle lacks content the profile selected: a file was
+	// unreadable or not valid UTF-8, a slice budget or whole_files_only dropped a file, or the
+	// bundle went over max_chars/max_tokens at all (files or slices dropped, truncation
+	// tightened, or a hard cut). Per-file truncation within per_file_max_lines/bytes alone
+	// does not set it. Plan.Dropped lists what is missing.
+	Partial bool
+	// HardCut is the last resort of the global budget: dropping and tightening were not
+	// enough, so the output was cut off at the limit (for NDJSON and HTML, trailing whole
+	// files were dropped instead). It implies Partial.
+	HardCut bool
+	// Warnings are what the CLI prints to stderr after building this bundle.
+	Warnings []Warning
+}
+
+// Bundle builds a bundle in memory. A partial bundle is not an error;
+// check Result.Partial and Result.Plan.Dropped.
+func Bundle(ctx context.Context, opts Options) (Result, error) {
+	res, err := app.Run(ctx, app.RunOptions{
+		ConfigPath:     opts.ConfigPath,
+		RootOverride:   opts.RootOverride,
+		Profile:        opts.Profile,
+		Modifiers:      opts.Modifiers,
+		MaxChars:       opts.MaxChars,
+		MaxTokens:      opts.MaxTokens,
+		Format:         opts.Format,
+		NoTree:         opts.NoTree,
+		NoManifest:     opts.NoManifest,
+		TreeDepth:      opts.TreeDepth,
+		LineNumbers:    opts.LineNumbers,
+		IncludeHidden:  opts.IncludeHidden,
+		FollowSymlinks: opts.FollowSymlinks,
+		NoWrite:        true,
+		Logger:         opts.Logger,
+		Now:            opts.Now,
+	})
+	if err != nil && !isPartial(err) {
+		return Result{}, err
+	}
+	return Result{Content: res.Content, Plan: res.Plan, Partial: res.Partial, HardCut: res.HardCut, Warnings: res.Warnings}, nil
+}
+
+// List returns the dry-run listing printed by snip ls and whether it is partial.
+func List(ctx context.Context, opts Options) (string, bool, error) {
+	out, partial, err := app.List(ctx, app.ListOptions{
+		ConfigPath:     opts.ConfigPath,
+		RootOverride:   opts.RootOverride,
+		Profile:        opts.Profile,
+		Modifiers:      opts.Modifiers,
+		MaxChars:       opts.MaxChars,
+		MaxTokens:      opts.MaxTokens,
+		IncludeHidden:  opts.IncludeHidden,
+		FollowSymlinks: opts.FollowSymlinks,
+		Logger:         opts.Logger,
+		Now:            opts.Now,
+	})
+	if err != nil && !isPartial(err) {
+		return "", false, err
+	}
+	return out, partial, nil
+}
+
+// Explain returns the report printed by snip explain for path.
+func Explain(ctx context.Context, opts Options, path string) (string, error) {
+	return app.Explain(ctx, app.ExplainOptions{
+		ConfigPath:    opts.ConfigPath,
+		RootOverride:  opts.RootOverride,
+		Profile:       opts.Profile,
+		Modifiers:     opts.Modifiers,
+		IncludeHidden: opts.IncludeHidden,
+		Path:          path,
+		Logger:        opts.Logger,
+		Now:           opts.Now,
+	})
+}
+
+func isPartial(err error) bool {
+	var ae *app.Error
+	return errors.As(err, &ae) && ae.ExitCode() == app.ExitPartial
+}
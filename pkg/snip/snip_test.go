@@ -0,0 +1,58 @@
+package snip
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmrzaf/snip/internal/config"
+)
+
+func TestBundleReturnsContentWithoutWriting(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	cfg := config.Default()
+	cfg.Root = root
+	cfg.DefaultProfile = "p"
+	cfg.Ignore.UseGitignore = false
+	cfg.Slices = map[string]config.SliceConfig{
+		"code": {Include: []string{"**/*.go"}, Priority: 10},
+	}
+	cfg.Profiles = map[string]config.Profile{
+		"p": {Enable: []string{"code"}},
+	}
+	cfgPath := filepath.Join(root, ".snip.yaml")
+	if err := config.Write(cfgPath, cfg); err != nil {
+		t.Fatalf("config.Write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bad.go"), []byte("caf\xe9\n"), 0o644); err != nil {
+		t.Fatalf("write bad.go: %v", err)
+	}
+
+	res, err := Bundle(context.Background(), Options{ConfigPath: cfgPath, Profile: "p"})
+	if err != nil {
+		t.Fatalf("Bundle: %v", err)
+	}
+	if !strings.Contains(res.Content, "package main") {
+		t.Fatalf("content missing main.go:\n%s", res.Content)
+	}
+	if len(res.Plan.Included) != 1 || res.Plan.Included[0].RelPath != "main.go" {
+		t.Fatalf("included=%+v", res.Plan.Included)
+	}
+	if !res.Partial || len(res.Plan.Dropped) != 1 || res.Plan.Dropped[0].Reason != "invalid_utf8" {
+		t.Fatalf("partial=%t dropped=%+v", res.Partial, res.Plan.Dropped)
+	}
+	want := Warning{Kind: "invalid_utf8", Path: "bad.go", Message: "invalid UTF-8 file excluded: bad.go"}
+	if len(res.Warnings) != 1 || res.Warnings[0] != want {
+		t.Fatalf("warnings=%+v", res.Warnings)
+	}
+	if _, err := os.Stat(filepath.Join(root, cfg.Output.Dir)); !os.IsNotExist(err) {
+		t.Fatalf("Bundle should not write output: %v", err)
+	}
+}